@@ -0,0 +1,103 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReplikatorConfigName is the name of the singleton ReplikatorConfig that is
+// applied by the operator.
+const ReplikatorConfigName = "default"
+
+// ReplikatorConfigSpec defines policy settings that override the operator's
+// command line flags while it is running. Unset fields fall back to the flags.
+//
+// The domain prefix of replikator's annotations and labels is deliberately not
+// included, it can only be set at startup (with --domain-prefix). Replicas are
+// recognized by their labels and annotations, so changing the domain of a
+// running operator would orphan every existing replica.
+type ReplikatorConfigSpec struct {
+	// ProtectedNamespaces are namespaces (or glob patterns) that replicas are never written to.
+	// +optional
+	ProtectedNamespaces []string `json:"protectedNamespaces,omitempty"`
+	// ExcludeNamespaces are namespaces (or glob patterns) that are ignored entirely.
+	// +optional
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+	// DefaultReplicateTo is the replicate-to filter of sources that don't specify their own.
+	// +optional
+	DefaultReplicateTo *string `json:"defaultReplicateTo,omitempty"`
+	// DefaultReplicateKeys are the replicate-keys filters (by secret type) of
+	// secrets that don't specify their own.
+	// +optional
+	DefaultReplicateKeys map[string]string `json:"defaultReplicateKeys,omitempty"`
+	// MaxReplicaSize is the maximum size of a replica in bytes (0 for no limit).
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxReplicaSize *int32 `json:"maxReplicaSize,omitempty"`
+	// MaxReplicasPerSource is the maximum number of namespaces any one source
+	// is replicated to (0 for no limit).
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxReplicasPerSource *int32 `json:"maxReplicasPerSource,omitempty"`
+	// NamespaceWriteRate is the sustained number of replica writes per second
+	// permitted for sources in any one namespace (0 for no limit). Fractional
+	// rates may be given as a quantity, eg. "0.5" or "500m".
+	// +optional
+	NamespaceWriteRate *resource.Quantity `json:"namespaceWriteRate,omitempty"`
+	// NamespaceWriteBurst is the number of replica writes permitted in a burst
+	// for sources in any one namespace.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	NamespaceWriteBurst *int32 `json:"namespaceWriteBurst,omitempty"`
+}
+
+// ReplikatorConfigStatus defines the observed state of ReplikatorConfig.
+type ReplikatorConfigStatus struct {
+	// ObservedGeneration is the most recent generation applied by the operator.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+
+// ReplikatorConfig holds runtime reconfigurable settings for replikator. Only
+// the ReplikatorConfig named "default" is applied.
+type ReplikatorConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ReplikatorConfigSpec   `json:"spec,omitempty"`
+	Status ReplikatorConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ReplikatorConfigList contains a list of ReplikatorConfig.
+type ReplikatorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ReplikatorConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ReplikatorConfig{}, &ReplikatorConfigList{})
+}
@@ -0,0 +1,58 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// kubectl-replikator exposes the replikator subcommands as a kubectl plugin,
+// eg. `kubectl replikator validate`.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/urfave/cli/v2"
+)
+
+func main() {
+	app := &cli.App{
+		Name:     "kubectl replikator",
+		HelpName: "kubectl replikator",
+		Usage:    "Inspect and manage replikator replication from kubectl",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    "domain-prefix",
+				EnvVars: []string{"REPLIKATOR_DOMAIN_PREFIX"},
+				Usage:   "The domain used for replikator annotations, labels and finalizers",
+				Value:   api.DefaultDomain,
+			},
+		}, commands.KubeconfigFlags()...),
+		Before: func(c *cli.Context) error {
+			api.SetDomain(c.String("domain-prefix"))
+			return nil
+		},
+		Commands: commands.All(),
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
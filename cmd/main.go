@@ -21,18 +21,33 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	"github.com/dpeckett/replikator/api/v1alpha1"
+	"github.com/dpeckett/replikator/internal/commands"
 	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/internal/dryrun"
+	"github.com/dpeckett/replikator/internal/features"
+	"github.com/dpeckett/replikator/internal/logging"
+	replikatorwebhook "github.com/dpeckett/replikator/internal/webhook"
+	"github.com/dpeckett/replikator/pkg/api"
 	"github.com/go-logr/logr"
 	"github.com/urfave/cli/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -45,6 +60,7 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
 
 	//+kubebuilder:scaffold:scheme
 }
@@ -53,50 +69,305 @@ func main() {
 	var logger *slog.Logger
 
 	init := func(c *cli.Context) error {
-		handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		var handler slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 			Level: (*slog.Level)(c.Generic("log-level").(*logLevelFlag)),
 		})
+		handler = logging.NewSamplingHandler(handler, c.Int("log-sampling-initial"), c.Int("log-sampling-thereafter"), time.Second)
 		ctrl.SetLogger(logr.FromSlogHandler(handler))
 
 		logger = slog.New(handler)
 
+		if featureGates := features.DefaultFeatureGate.String(); featureGates != "" {
+			logger.Info("Feature gates set", "featureGates", featureGates)
+		}
+
+		api.SetDomain(c.String("domain-prefix"))
+
+		if !c.Bool("legacy-annotations") {
+			api.DisableLegacyAnnotations()
+		}
+
 		return nil
 	}
 
 	app := &cli.App{
-		Name:  "replikator",
-		Usage: "A simple operator to replicate Kubernetes configmaps and secrets across namespaces",
-		Flags: []cli.Flag{
-			&cli.GenericFlag{
-				Name:  "log-level",
-				Usage: "Log level",
-				Value: fromLogLevel(slog.LevelInfo),
-			},
-			&cli.StringFlag{
-				Name:  "metrics-bind-address",
-				Usage: "The address the metric endpoint binds to",
-				Value: ":8080",
-			},
-			&cli.StringFlag{
-				Name:  "health-probe-bind-address",
-				Usage: "The address the probe endpoint binds to",
-				Value: ":8081",
-			},
-			&cli.BoolFlag{
-				Name:  "leader-elect",
-				Usage: "Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager",
-				Value: false,
-			},
-		},
-		Before: init,
+		Name:     "replikator",
+		Usage:    "A simple operator to replicate Kubernetes configmaps and secrets across namespaces",
+		Flags:    flags(),
+		Before:   init,
+		Commands: commands.All(),
 		Action: func(c *cli.Context) error {
 			metricsAddr := c.String("metrics-bind-address")
 			probeAddr := c.String("health-probe-bind-address")
 			enableLeaderElection := c.Bool("leader-elect")
+			dryRun := c.Bool("dry-run")
+
+			if dryRun {
+				logger.Info("Running in dry run mode, no changes will be made to the cluster")
+
+				// Leader election requires writing to the cluster.
+				enableLeaderElection = false
+			}
+
+			policy := controller.Policy{
+				ProtectedNamespaces:            c.StringSlice("protected-namespaces"),
+				WatchNamespaces:                c.StringSlice("watch-namespaces"),
+				ExcludeNamespaces:              c.StringSlice("exclude-namespaces"),
+				TenantLabel:                    c.String("tenant-label"),
+				MaxReplicaSize:                 c.Int("max-replica-size"),
+				MaxReplicasPerSource:           c.Int("max-replicas-per-source"),
+				RequireKeyFilterForPrivateKeys: c.Bool("require-key-filter-for-private-keys"),
+				ValidateCertificates:           c.Bool("validate-certificates"),
+				NamespacedRBAC:                 c.Bool("namespaced-rbac"),
+				DefaultReplicateTo:             c.String("default-replicate-to"),
+				ReconcileTimeout:               c.Duration("reconcile-timeout"),
+				OverwriteExternalSecrets:       c.Bool("overwrite-external-secrets"),
+				TrustManager:                   c.Bool("trust-manager"),
+				ManifestWorks:                  c.Bool("ocm-manifest-works"),
+				Metadata: api.MetadataFilter{
+					StripLabels:      c.StringSlice("strip-labels"),
+					StripAnnotations: c.StringSlice("strip-annotations"),
+					KeepAnnotations:  c.StringSlice("keep-annotations"),
+				},
+			}
+
+			if c.Bool("capsule") {
+				if policy.TenantLabel != "" && policy.TenantLabel != api.CapsuleTenantLabel {
+					return fmt.Errorf("--capsule can't be combined with a different --tenant-label")
+				}
+
+				policy.TenantLabel = api.CapsuleTenantLabel
+			}
+
+			if c.Bool("argocd-compatibility") {
+				policy.Metadata = policy.Metadata.WithArgoCDCompatibility()
+			}
+
+			if c.Bool("flux-compatibility") {
+				policy.Metadata = policy.Metadata.WithFluxCompatibility()
+			}
+
+			if c.Bool("velero") {
+				policy.Metadata = policy.Metadata.WithVeleroExclusion()
+			}
+
+			if c.Bool("openshift") {
+				policy.ExcludeNamespaces = append(policy.ExcludeNamespaces, api.OpenShiftSystemNamespaces...)
+			}
+
+			if writeRate := c.Float64("namespace-write-rate"); writeRate > 0 {
+				if c.Int("namespace-write-burst") < 1 {
+					return fmt.Errorf("namespace write burst must be at least 1")
+				}
+
+				policy.WriteLimiter = controller.NewWriteLimiter(writeRate, c.Int("namespace-write-burst"))
+			}
+
+			if contentionThreshold := c.Int("contention-threshold"); contentionThreshold > 0 {
+				policy.Contention = controller.NewContentionTracker(contentionThreshold, c.Duration("contention-window"))
+			}
+
+			for _, defaultReplicateKeys := range c.StringSlice("default-replicate-keys") {
+				secretType, pattern, ok := strings.Cut(defaultReplicateKeys, "=")
+				if !ok || secretType == "" {
+					return fmt.Errorf("invalid default replicate-keys %q (expected type=pattern)", defaultReplicateKeys)
+				}
+
+				if err := api.ValidateFilters(pattern); err != nil {
+					return fmt.Errorf("invalid default replicate-keys: %w", err)
+				}
+
+				if policy.DefaultReplicateKeys == nil {
+					policy.DefaultReplicateKeys = make(map[corev1.SecretType]string)
+				}
+
+				if existing, ok := policy.DefaultReplicateKeys[corev1.SecretType(secretType)]; ok {
+					pattern = existing + "," + pattern
+				}
+
+				policy.DefaultReplicateKeys[corev1.SecretType(secretType)] = pattern
+			}
+
+			if policy.DefaultReplicateTo != "" {
+				if err := api.ValidateFilters(policy.DefaultReplicateTo); err != nil {
+					return fmt.Errorf("invalid default replicate-to: %w", err)
+				}
+			}
+
+			for _, pattern := range policy.ProtectedNamespaces {
+				if err := api.ValidateFilters(pattern); err != nil {
+					return fmt.Errorf("invalid protected namespace: %w", err)
+				}
+			}
+
+			for _, pattern := range policy.ExcludeNamespaces {
+				if err := api.ValidateFilters(pattern); err != nil {
+					return fmt.Errorf("invalid excluded namespace: %w", err)
+				}
+			}
+
+			for _, command := range c.StringSlice("hook-command") {
+				args := strings.Fields(command)
+				if len(args) == 0 {
+					return fmt.Errorf("invalid hook command %q", command)
+				}
+
+				policy.Hooks = append(policy.Hooks, &controller.ExecHook{Command: args, Timeout: c.Duration("hook-timeout")})
+			}
+
+			for _, url := range c.StringSlice("hook-url") {
+				policy.Hooks = append(policy.Hooks, &controller.WebhookHook{URL: url, Timeout: c.Duration("hook-timeout")})
+			}
+
+			failurePolicy, err := controller.ParseFailurePolicy(c.String("transform-failure-policy"))
+			if err != nil {
+				return err
+			}
+
+			// SPIFFE trust bundles are only rendered for sources annotated with spiffe-bundle.
+			policy.Transformations = append(policy.Transformations, controller.Transformation{
+				Transformer: &controller.SPIFFEBundleTransformer{
+					Key:         c.String("spiffe-bundle-key"),
+					RefreshHint: c.Duration("spiffe-refresh-hint"),
+				},
+				FailurePolicy: failurePolicy,
+			})
+
+			for _, command := range c.StringSlice("transform-command") {
+				args := strings.Fields(command)
+				if len(args) == 0 {
+					return fmt.Errorf("invalid transform command %q", command)
+				}
+
+				policy.Transformations = append(policy.Transformations, controller.Transformation{
+					Transformer:   &controller.ExecTransformer{Command: args, Timeout: c.Duration("transform-timeout")},
+					FailurePolicy: failurePolicy,
+				})
+			}
+
+			for _, url := range c.StringSlice("transform-url") {
+				policy.Transformations = append(policy.Transformations, controller.Transformation{
+					Transformer:   &controller.WebhookTransformer{URL: url, Timeout: c.Duration("transform-timeout")},
+					FailurePolicy: failurePolicy,
+				})
+			}
+
+			for _, path := range c.StringSlice("transform-wasm") {
+				transformer, err := controller.NewWASMTransformer(c.Context, path, c.Duration("transform-timeout"))
+				if err != nil {
+					return fmt.Errorf("failed to load transformation: %w", err)
+				}
+				defer transformer.Close(c.Context)
+
+				policy.Transformations = append(policy.Transformations, controller.Transformation{
+					Transformer:   transformer,
+					FailurePolicy: failurePolicy,
+				})
+			}
+
+			if secretSelector := c.String("secret-selector"); secretSelector != "" {
+				selector, err := labels.Parse(secretSelector)
+				if err != nil {
+					return fmt.Errorf("invalid secret selector: %w", err)
+				}
+
+				policy.SecretSelector = selector
+			}
+
+			var clientOpts client.Options
+			if policy.NamespacedRBAC {
+				// Replicas are read directly from the API server, as replikator
+				// may not have permission to cache them in every namespace.
+				clientOpts.Cache = &client.CacheOptions{
+					DisableFor: []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}},
+				}
+			}
+
+			var cacheOpts cache.Options
+			if len(policy.WatchNamespaces) > 0 {
+				// Only cache namespaced objects from the watched namespaces, so
+				// that namespace scoped RBAC is sufficient.
+				cacheOpts.DefaultNamespaces = make(map[string]cache.Config)
+				for _, namespace := range policy.WatchNamespaces {
+					cacheOpts.DefaultNamespaces[namespace] = cache.Config{}
+				}
+			}
+
+			if policy.SecretSelector != nil {
+				cacheOpts.ByObject = map[client.Object]cache.ByObject{
+					&corev1.Secret{}: {Label: policy.SecretSelector},
+				}
+			}
+
+			cfg, err := commands.GetConfig(c)
+			if err != nil {
+				return err
+			}
+
+			var writers controller.WriterFactory
+			var impersonatedServiceAccountNames []string
+			if serviceAccountName := c.String("impersonate-service-account"); serviceAccountName != "" {
+				logger.Info("Impersonating service account for replica writes", "serviceAccount", serviceAccountName)
 
-			mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-				Scheme:                 scheme,
-				Metrics:                metricsserver.Options{BindAddress: metricsAddr},
+				impersonatedServiceAccountNames = append(impersonatedServiceAccountNames, serviceAccountName)
+
+				impersonatingWriters := controller.NewImpersonatingWriterFactory(cfg, scheme, serviceAccountName)
+				writers = func(namespace string) (client.Client, error) {
+					writer, err := impersonatingWriters(namespace)
+					if err != nil || !dryRun {
+						return writer, err
+					}
+
+					return dryrun.NewClient(writer, logger), nil
+				}
+			}
+
+			if c.Bool("once") {
+				k8sClient, err := client.New(cfg, client.Options{Scheme: scheme})
+				if err != nil {
+					return fmt.Errorf("unable to create client: %w", err)
+				}
+
+				if dryRun {
+					k8sClient = dryrun.NewClient(k8sClient, logger)
+				}
+
+				if c.Bool("rollout-on-change") {
+					policy.Hooks = append(policy.Hooks, &controller.RolloutHook{Client: k8sClient, DeploymentConfigs: c.Bool("openshift")})
+				}
+
+				logger.Info("Performing a single reconciliation pass")
+
+				if err := controller.ReconcileOnce(c.Context,
+					&controller.SecretReconciler{Client: k8sClient, Scheme: scheme, Policy: policy, Writers: writers},
+					&controller.ConfigMapReconciler{Client: k8sClient, Scheme: scheme, Policy: policy, Writers: writers}); err != nil {
+					return fmt.Errorf("reconciliation failed: %w", err)
+				}
+
+				logger.Info("Reconciliation pass complete")
+
+				return nil
+			}
+
+			replicaProtection := c.String("replica-protection")
+			if !isValidWebhookMode(replicaProtection) {
+				return fmt.Errorf("invalid replica protection mode: %s", replicaProtection)
+			}
+
+			annotationValidation := c.String("annotation-validation")
+			if !isValidWebhookMode(annotationValidation) {
+				return fmt.Errorf("invalid annotation validation mode: %s", annotationValidation)
+			}
+
+			mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+				Scheme:  scheme,
+				Cache:   cacheOpts,
+				Client:  clientOpts,
+				Metrics: metricsserver.Options{BindAddress: metricsAddr},
+				WebhookServer: webhook.NewServer(webhook.Options{
+					Port:    c.Int("webhook-port"),
+					CertDir: c.String("webhook-cert-dir"),
+				}),
 				HealthProbeBindAddress: probeAddr,
 				LeaderElection:         enableLeaderElection,
 				LeaderElectionID:       "767661ca.pecke.tt",
@@ -116,27 +387,258 @@ func main() {
 				return fmt.Errorf("unable to start manager: %w", err)
 			}
 
-			if err = (&controller.ConfigMapReconciler{
-				Client: mgr.GetClient(),
-				Scheme: mgr.GetScheme(),
-			}).SetupWithManager(mgr); err != nil {
+			k8sClient := mgr.GetClient()
+			if dryRun {
+				k8sClient = dryrun.NewClient(k8sClient, logger)
+			}
+
+			recorder := mgr.GetEventRecorderFor("replikator")
+			if dryRun {
+				recorder = dryrun.NewEventRecorder(logger)
+			}
+
+			events := controller.NewEventBus()
+			events.Subscribe(controller.RecordEvents(recorder))
+			events.Subscribe(controller.RecordMetrics)
+			policy.Events = events
+
+			initialSync := &controller.InitialSync{Elected: mgr.Elected()}
+			policy.InitialSync = initialSync
+
+			if c.Bool("rollout-on-change") {
+				policy.Hooks = append(policy.Hooks, &controller.RolloutHook{Client: k8sClient, DeploymentConfigs: c.Bool("openshift")})
+			}
+
+			if c.Bool("runtime-config") {
+				policy.Runtime = controller.NewRuntimeConfig()
+
+				if err = (&controller.ConfigReconciler{
+					Client:   k8sClient,
+					Recorder: recorder,
+					Runtime:  policy.Runtime,
+					Events:   events,
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create controller: %w", err)
+				}
+			}
+
+			configMapReconciler := &controller.ConfigMapReconciler{
+				Client:    k8sClient,
+				Scheme:    mgr.GetScheme(),
+				Recorder:  recorder,
+				Policy:    policy,
+				Writers:   writers,
+				APIReader: mgr.GetAPIReader(),
+			}
+
+			if err = configMapReconciler.SetupWithManager(mgr); err != nil {
 				return fmt.Errorf("unable to create controller: %w", err)
 			}
 
-			if err = (&controller.SecretReconciler{
-				Client: mgr.GetClient(),
-				Scheme: mgr.GetScheme(),
-			}).SetupWithManager(mgr); err != nil {
+			secretReconciler := &controller.SecretReconciler{
+				Client:    k8sClient,
+				Scheme:    mgr.GetScheme(),
+				Recorder:  recorder,
+				Policy:    policy,
+				Writers:   writers,
+				APIReader: mgr.GetAPIReader(),
+			}
+
+			if err = secretReconciler.SetupWithManager(mgr); err != nil {
 				return fmt.Errorf("unable to create controller: %w", err)
 			}
 
+			if features.Enabled(features.PullModel) {
+				if err = (&controller.PullReconciler[*corev1.ConfigMap]{
+					Client:     k8sClient,
+					Recorder:   recorder,
+					Policy:     policy,
+					Writers:    writers,
+					APIReader:  mgr.GetAPIReader(),
+					Replicator: controller.ConfigMapReplicator{},
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create controller: %w", err)
+				}
+
+				if err = (&controller.PullReconciler[*corev1.Secret]{
+					Client:     k8sClient,
+					Recorder:   recorder,
+					Policy:     policy,
+					Writers:    writers,
+					APIReader:  mgr.GetAPIReader(),
+					Replicator: controller.SecretReplicator{},
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create controller: %w", err)
+				}
+			}
+
+			initialSync.Secrets = secretReconciler
+			initialSync.ConfigMaps = configMapReconciler
+
+			if err := mgr.Add(initialSync); err != nil {
+				return fmt.Errorf("unable to add initial sync: %w", err)
+			}
+
+			if c.Bool("cert-manager-certificates") {
+				if err = (&controller.CertificateReconciler{
+					Client: k8sClient,
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create controller: %w", err)
+				}
+			}
+
+			if policy.TrustManager {
+				if err = (&controller.TrustBundleReconciler{
+					Client:         k8sClient,
+					Recorder:       recorder,
+					Policy:         policy,
+					TrustNamespace: c.String("trust-namespace"),
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create controller: %w", err)
+				}
+			}
+
+			if c.Bool("velero") {
+				if err = (&controller.RestoreReconciler{
+					Client: k8sClient,
+					Policy: policy,
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create controller: %w", err)
+				}
+			}
+
+			if policy.ManifestWorks {
+				if err = (&controller.ManifestWorkReconciler[*corev1.Secret]{
+					Client:     k8sClient,
+					Recorder:   recorder,
+					Policy:     policy,
+					Replicator: controller.SecretReplicator{},
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create controller: %w", err)
+				}
+
+				if err = (&controller.ManifestWorkReconciler[*corev1.ConfigMap]{
+					Client:     k8sClient,
+					Recorder:   recorder,
+					Policy:     policy,
+					Replicator: controller.ConfigMapReplicator{},
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create controller: %w", err)
+				}
+			}
+
+			if err := mgr.Add(&controller.GarbageCollector{
+				Client:   k8sClient,
+				Policy:   policy,
+				Interval: c.Duration("orphan-gc-interval"),
+			}); err != nil {
+				return fmt.Errorf("unable to add garbage collector: %w", err)
+			}
+
+			if c.Bool("vcluster") {
+				syncer := &controller.VClusterSyncer{
+					Client:   k8sClient,
+					Scheme:   scheme,
+					Policy:   policy,
+					Interval: c.Duration("vcluster-sync-interval"),
+					DryRun:   dryRun,
+				}
+				if err := syncer.SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to add vcluster syncer: %w", err)
+				}
+			}
+
+			if c.Bool("delete-replicas-on-shutdown") {
+				// The cache is stopped along with the manager, so cleanup reads directly from the API server.
+				cleanupClient, err := client.New(cfg, client.Options{Scheme: scheme})
+				if err != nil {
+					return fmt.Errorf("unable to create client: %w", err)
+				}
+
+				if dryRun {
+					cleanupClient = dryrun.NewClient(cleanupClient, logger)
+				}
+
+				cleanup := &controller.ShutdownCleanup{
+					Client:  cleanupClient,
+					Policy:  policy,
+					Writers: writers,
+					// Must complete within the manager's graceful shutdown timeout.
+					Timeout: 25 * time.Second,
+				}
+
+				if deployment := c.String("operator-deployment"); deployment != "" {
+					namespace, name, ok := strings.Cut(deployment, "/")
+					if !ok || namespace == "" || name == "" {
+						return fmt.Errorf("invalid operator deployment %q (expected namespace/name)", deployment)
+					}
+
+					cleanup.Deployment = &types.NamespacedName{Namespace: namespace, Name: name}
+				} else {
+					logger.Warn("Replicas will be deleted whenever the operator stops (including restarts), set --operator-deployment to only delete them on uninstall")
+				}
+
+				if err := mgr.Add(cleanup); err != nil {
+					return fmt.Errorf("unable to add shutdown cleanup: %w", err)
+				}
+			}
+
+			if c.Duration("verify-interval") > 0 || c.Bool("verify-on-start") {
+				if err := mgr.Add(&controller.Verifier{
+					Client:      k8sClient,
+					Recorder:    recorder,
+					Policy:      policy,
+					Interval:    c.Duration("verify-interval"),
+					SkipInitial: !c.Bool("verify-on-start"),
+				}); err != nil {
+					return fmt.Errorf("unable to add verifier: %w", err)
+				}
+			}
+
+			if replicaProtection != "off" {
+				if err = (&replikatorwebhook.ReplicaProtectionHandler{
+					AllowedUsernames:           c.StringSlice("replica-protection-allowed-users"),
+					AllowedServiceAccountNames: impersonatedServiceAccountNames,
+					WarnOnly:                   replicaProtection == "warn",
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create webhook: %w", err)
+				}
+			}
+
+			if annotationValidation != "off" {
+				if err = (&replikatorwebhook.AnnotationValidationHandler{
+					WarnOnly: annotationValidation == "warn",
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create webhook: %w", err)
+				}
+			}
+
+			if rulesPath := c.String("auto-annotation-rules"); rulesPath != "" {
+				rules, err := replikatorwebhook.LoadAutoAnnotationRules(rulesPath)
+				if err != nil {
+					return err
+				}
+
+				if err = (&replikatorwebhook.AutoAnnotationHandler{
+					Rules: rules,
+				}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create webhook: %w", err)
+				}
+			}
+
+			if c.Bool("provenance") {
+				if err = (&replikatorwebhook.ProvenanceHandler{}).SetupWithManager(mgr); err != nil {
+					return fmt.Errorf("unable to create webhook: %w", err)
+				}
+			}
+
 			//+kubebuilder:scaffold:builder
 
 			if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 				return fmt.Errorf("unable to set up health check: %w", err)
 			}
 
-			if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+			if err := mgr.AddReadyzCheck("readyz", initialSync.Ready); err != nil {
 				return fmt.Errorf("unable to set up ready check: %w", err)
 			}
 
@@ -152,6 +654,391 @@ func main() {
 	}
 }
 
+// flags returns the flags of the operator.
+func flags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.GenericFlag{
+			Name:    "log-level",
+			EnvVars: []string{"REPLIKATOR_LOG_LEVEL"},
+			Usage:   "Log level",
+			Value:   fromLogLevel(slog.LevelInfo),
+		},
+		&cli.IntFlag{
+			Name:    "log-sampling-initial",
+			EnvVars: []string{"REPLIKATOR_LOG_SAMPLING_INITIAL"},
+			Usage:   "The number of identical info and debug messages logged each second before sampling begins (0 to disable sampling)",
+		},
+		&cli.IntFlag{
+			Name:    "log-sampling-thereafter",
+			EnvVars: []string{"REPLIKATOR_LOG_SAMPLING_THEREAFTER"},
+			Usage:   "Once sampling begins, only every nth identical message is logged (0 to drop them all)",
+			Value:   100,
+		},
+		&cli.GenericFlag{
+			Name:    "feature-gates",
+			EnvVars: []string{"REPLIKATOR_FEATURE_GATES"},
+			Usage:   featureGatesUsage(),
+			Value:   features.DefaultFeatureGate,
+		},
+		&cli.StringFlag{
+			Name:    "domain-prefix",
+			EnvVars: []string{"REPLIKATOR_DOMAIN_PREFIX"},
+			Usage:   "The domain used for replikator annotations, labels and finalizers (annotations using the default domain are still honored)",
+			Value:   api.DefaultDomain,
+		},
+		&cli.BoolFlag{
+			Name:    "legacy-annotations",
+			EnvVars: []string{"REPLIKATOR_LEGACY_ANNOTATIONS"},
+			Usage:   "Honor the annotations of earlier releases of replikator and of tls-replicator (v1alpha1.tls-replicator.gpuninja.com/*)",
+			Value:   true,
+		},
+		&cli.StringFlag{
+			Name:    "metrics-bind-address",
+			EnvVars: []string{"REPLIKATOR_METRICS_BIND_ADDRESS"},
+			Usage:   "The address the metric endpoint binds to",
+			Value:   ":8080",
+		},
+		&cli.StringFlag{
+			Name:    "health-probe-bind-address",
+			EnvVars: []string{"REPLIKATOR_HEALTH_PROBE_BIND_ADDRESS"},
+			Usage:   "The address the probe endpoint binds to",
+			Value:   ":8081",
+		},
+		&cli.BoolFlag{
+			Name:    "leader-elect",
+			EnvVars: []string{"REPLIKATOR_LEADER_ELECT"},
+			Usage:   "Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager",
+			Value:   false,
+		},
+		&cli.StringSliceFlag{
+			Name:    "protected-namespaces",
+			EnvVars: []string{"REPLIKATOR_PROTECTED_NAMESPACES"},
+			Usage:   "Namespaces (or glob patterns) that replikator will never write to or delete from",
+		},
+		&cli.StringSliceFlag{
+			Name:    "watch-namespaces",
+			EnvVars: []string{"REPLIKATOR_WATCH_NAMESPACES"},
+			Usage:   "Restrict replikator to sources and targets in the given namespaces (default: all namespaces)",
+		},
+		&cli.StringSliceFlag{
+			Name:    "exclude-namespaces",
+			EnvVars: []string{"REPLIKATOR_EXCLUDE_NAMESPACES"},
+			Usage:   "Namespaces (or glob patterns) whose sources are ignored and which are never replicated to",
+		},
+		&cli.StringFlag{
+			Name:    "tenant-label",
+			EnvVars: []string{"REPLIKATOR_TENANT_LABEL"},
+			Usage:   "Only replicate between namespaces that share the same value for this namespace label",
+		},
+		&cli.BoolFlag{
+			Name:    "capsule",
+			EnvVars: []string{"REPLIKATOR_CAPSULE"},
+			Usage:   "Only replicate between namespaces belonging to the same Capsule tenant (equivalent to --tenant-label=" + api.CapsuleTenantLabel + ")",
+		},
+		&cli.StringFlag{
+			Name:    "default-replicate-to",
+			EnvVars: []string{"REPLIKATOR_DEFAULT_REPLICATE_TO"},
+			Usage:   "Namespaces (or glob patterns, comma separated) to replicate to when a source has no replicate-to annotation (defaults to all namespaces)",
+		},
+		&cli.StringSliceFlag{
+			Name:    "default-replicate-keys",
+			EnvVars: []string{"REPLIKATOR_DEFAULT_REPLICATE_KEYS"},
+			Usage:   "Keys (or glob patterns) to replicate by secret type when a secret has no replicate-keys annotation (eg. kubernetes.io/tls=ca.crt)",
+		},
+		&cli.IntFlag{
+			Name:    "max-replica-size",
+			EnvVars: []string{"REPLIKATOR_MAX_REPLICA_SIZE"},
+			Usage:   "The maximum size (in bytes) of the data of any replica (0 for no limit)",
+		},
+		&cli.IntFlag{
+			Name:    "max-replicas-per-source",
+			EnvVars: []string{"REPLIKATOR_MAX_REPLICAS_PER_SOURCE"},
+			Usage:   "The maximum number of namespaces a single source may be replicated to (0 for no limit)",
+		},
+		&cli.BoolFlag{
+			Name:    "require-key-filter-for-private-keys",
+			EnvVars: []string{"REPLIKATOR_REQUIRE_KEY_FILTER_FOR_PRIVATE_KEYS"},
+			Usage:   "Only replicate secrets containing a TLS private key if they have a replicate-keys annotation",
+		},
+		&cli.BoolFlag{
+			Name:    "validate-certificates",
+			EnvVars: []string{"REPLIKATOR_VALIDATE_CERTIFICATES"},
+			Usage:   "Refuse to replicate TLS secrets with malformed, expired or inconsistent certificates",
+		},
+		&cli.StringSliceFlag{
+			Name:    "strip-labels",
+			EnvVars: []string{"REPLIKATOR_STRIP_LABELS"},
+			Usage:   "Labels (or glob patterns) that are not copied from sources to replicas",
+			Value:   cli.NewStringSlice(api.DefaultStrippedLabels...),
+		},
+		&cli.StringSliceFlag{
+			Name:    "strip-annotations",
+			EnvVars: []string{"REPLIKATOR_STRIP_ANNOTATIONS"},
+			Usage:   "Annotations (or glob patterns) that are not copied from sources to replicas",
+			Value:   cli.NewStringSlice(api.DefaultStrippedAnnotations...),
+		},
+		&cli.StringSliceFlag{
+			Name:    "keep-annotations",
+			EnvVars: []string{"REPLIKATOR_KEEP_ANNOTATIONS"},
+			Usage:   "Annotations (or glob patterns) that are always copied from sources to replicas, overriding --strip-annotations",
+		},
+		&cli.BoolFlag{
+			Name:    "argocd-compatibility",
+			EnvVars: []string{"REPLIKATOR_ARGOCD_COMPATIBILITY"},
+			Usage:   "Annotate replicas so that Argo CD applications owning their namespace neither report them as out of sync nor prune them",
+		},
+		&cli.BoolFlag{
+			Name:    "flux-compatibility",
+			EnvVars: []string{"REPLIKATOR_FLUX_COMPATIBILITY"},
+			Usage:   "Annotate replicas so that Flux neither prunes them nor takes ownership of them",
+		},
+		&cli.BoolFlag{
+			Name:    "openshift",
+			EnvVars: []string{"REPLIKATOR_OPENSHIFT"},
+			Usage:   "Exclude the openshift-* system namespaces from replication, and restart DeploymentConfigs with --rollout-on-change",
+		},
+		&cli.BoolFlag{
+			Name:    "velero",
+			EnvVars: []string{"REPLIKATOR_VELERO"},
+			Usage:   "Exclude replicas from Velero backups, and relink (or delete) the replicas created by Velero restores",
+		},
+		&cli.BoolFlag{
+			Name:    "overwrite-external-secrets",
+			EnvVars: []string{"REPLIKATOR_OVERWRITE_EXTERNAL_SECRETS"},
+			Usage:   "Permit secrets managed by the external-secrets operator to be overwritten by replicas (according to the conflict policy of the source)",
+		},
+		&cli.BoolFlag{
+			Name:    "trust-manager",
+			EnvVars: []string{"REPLIKATOR_TRUST_MANAGER"},
+			Usage:   "Replicate secrets annotated with trust-bundle as trust-manager Bundles, rather than by copying them",
+		},
+		&cli.BoolFlag{
+			Name:    "ocm-manifest-works",
+			EnvVars: []string{"REPLIKATOR_OCM_MANIFEST_WORKS"},
+			Usage:   "Distribute sources annotated with replicate-to-clusters to Open Cluster Management managed clusters as ManifestWorks, rather than by copying them",
+		},
+		&cli.BoolFlag{
+			Name:    "cert-manager-certificates",
+			EnvVars: []string{"REPLIKATOR_CERT_MANAGER_CERTIFICATES"},
+			Usage:   "Copy replikator annotations from cert-manager Certificates to the secrets they issue",
+		},
+		&cli.StringFlag{
+			Name:    "trust-namespace",
+			EnvVars: []string{"REPLIKATOR_TRUST_NAMESPACE"},
+			Usage:   "The namespace trust-manager reads Bundle sources from",
+			Value:   controller.DefaultTrustNamespace,
+		},
+		&cli.Float64Flag{
+			Name:    "namespace-write-rate",
+			EnvVars: []string{"REPLIKATOR_NAMESPACE_WRITE_RATE"},
+			Usage:   "The sustained number of replica writes per second permitted for sources in any one namespace (0 for no limit)",
+		},
+		&cli.IntFlag{
+			Name:    "namespace-write-burst",
+			EnvVars: []string{"REPLIKATOR_NAMESPACE_WRITE_BURST"},
+			Usage:   "The number of replica writes permitted in a burst for sources in any one namespace",
+			Value:   100,
+		},
+		&cli.IntFlag{
+			Name:    "contention-threshold",
+			EnvVars: []string{"REPLIKATOR_CONTENTION_THRESHOLD"},
+			Usage:   "Stop repairing a replica after it has been modified by another writer this many times within the contention window (0 to always repair)",
+			Value:   10,
+		},
+		&cli.DurationFlag{
+			Name:    "contention-window",
+			EnvVars: []string{"REPLIKATOR_CONTENTION_WINDOW"},
+			Usage:   "The window over which replica repairs are counted when detecting contention",
+			Value:   10 * time.Minute,
+		},
+		&cli.BoolFlag{
+			Name:    "namespaced-rbac",
+			EnvVars: []string{"REPLIKATOR_NAMESPACED_RBAC"},
+			Usage:   "Only replicate into namespaces where replikator has been granted access (eg. with a RoleBinding), treating a lack of access as opting out",
+		},
+		&cli.StringFlag{
+			Name:    "secret-selector",
+			EnvVars: []string{"REPLIKATOR_SECRET_SELECTOR"},
+			Usage:   "Only read secrets matching this label selector (eg. replikator.pecke.tt/source=true). This limits what is read, not the RBAC permissions replikator requires",
+		},
+		&cli.DurationFlag{
+			Name:    "orphan-gc-interval",
+			EnvVars: []string{"REPLIKATOR_ORPHAN_GC_INTERVAL"},
+			Usage:   "How often to delete orphaned replicas (garbage is always collected on startup, 0 to only collect on startup)",
+			Value:   time.Hour,
+		},
+		&cli.BoolFlag{
+			Name:    "vcluster",
+			EnvVars: []string{"REPLIKATOR_VCLUSTER"},
+			Usage:   "Copy replicas in the host namespaces of virtual clusters (vcluster) into the virtual clusters",
+		},
+		&cli.DurationFlag{
+			Name:    "vcluster-sync-interval",
+			EnvVars: []string{"REPLIKATOR_VCLUSTER_SYNC_INTERVAL"},
+			Usage:   "How often to sync every virtual cluster (they are also synced when replicas change, 0 to only sync on changes)",
+			Value:   5 * time.Minute,
+		},
+		&cli.DurationFlag{
+			Name:    "reconcile-timeout",
+			EnvVars: []string{"REPLIKATOR_RECONCILE_TIMEOUT"},
+			Usage:   "The maximum time to spend reconciling a single source, after which it is requeued to write the remaining replicas (0 for no limit)",
+			Value:   5 * time.Minute,
+		},
+		&cli.BoolFlag{
+			Name:    "delete-replicas-on-shutdown",
+			EnvVars: []string{"REPLIKATOR_DELETE_REPLICAS_ON_SHUTDOWN"},
+			Usage:   "Delete every replica (and remove replikator's finalizers from sources) when the operator is stopped. Unless --operator-deployment is set, this includes every restart (eg. rolling upgrades and node drains)",
+		},
+		&cli.StringFlag{
+			Name:    "operator-deployment",
+			EnvVars: []string{"REPLIKATOR_OPERATOR_DEPLOYMENT"},
+			Usage:   "The namespace/name of the Deployment running replikator, with --delete-replicas-on-shutdown replicas are then only deleted once it has been deleted (ie. on uninstall)",
+		},
+		&cli.DurationFlag{
+			Name:    "verify-interval",
+			EnvVars: []string{"REPLIKATOR_VERIFY_INTERVAL"},
+			Usage:   "How often to audit every replica against its source, reporting differences as events and metrics (0 to disable)",
+		},
+		&cli.BoolFlag{
+			Name:    "verify-on-start",
+			EnvVars: []string{"REPLIKATOR_VERIFY_ON_START"},
+			Usage:   "Audit every replica against its source on startup",
+		},
+		&cli.BoolFlag{
+			Name:    "runtime-config",
+			EnvVars: []string{"REPLIKATOR_RUNTIME_CONFIG"},
+			Usage:   "Watch the ReplikatorConfig named \"default\" for settings that override the command line while running",
+		},
+		&cli.StringFlag{
+			Name:    "impersonate-service-account",
+			EnvVars: []string{"REPLIKATOR_IMPERSONATE_SERVICE_ACCOUNT"},
+			Usage:   "Write replicas by impersonating the service account with this name in each target namespace",
+		},
+		&cli.StringSliceFlag{
+			Name:    "hook-command",
+			EnvVars: []string{"REPLIKATOR_HOOK_COMMAND"},
+			Usage:   "A program (and its arguments) to run before building each replica template and after each replica write",
+		},
+		&cli.StringSliceFlag{
+			Name:    "hook-url",
+			EnvVars: []string{"REPLIKATOR_HOOK_URL"},
+			Usage:   "A URL to POST to before building each replica template and after each replica write",
+		},
+		&cli.DurationFlag{
+			Name:    "hook-timeout",
+			EnvVars: []string{"REPLIKATOR_HOOK_TIMEOUT"},
+			Usage:   "The maximum time to wait for each invocation of a hook",
+			Value:   10 * time.Second,
+		},
+		&cli.BoolFlag{
+			Name:    "rollout-on-change",
+			EnvVars: []string{"REPLIKATOR_ROLLOUT_ON_CHANGE"},
+			Usage:   "Restart annotated Deployments and StatefulSets when a replica they consume changes",
+		},
+		&cli.StringSliceFlag{
+			Name:    "transform-command",
+			EnvVars: []string{"REPLIKATOR_TRANSFORM_COMMAND"},
+			Usage:   "A program (and its arguments) used to transform each replica before it is written",
+		},
+		&cli.StringSliceFlag{
+			Name:    "transform-url",
+			EnvVars: []string{"REPLIKATOR_TRANSFORM_URL"},
+			Usage:   "A URL to POST each replica to for transformation before it is written",
+		},
+		&cli.StringSliceFlag{
+			Name:    "transform-wasm",
+			EnvVars: []string{"REPLIKATOR_TRANSFORM_WASM"},
+			Usage:   "A WebAssembly (WASI) module used to transform each replica before it is written, without access to the filesystem or network",
+		},
+		&cli.DurationFlag{
+			Name:    "transform-timeout",
+			EnvVars: []string{"REPLIKATOR_TRANSFORM_TIMEOUT"},
+			Usage:   "The maximum time to wait for each transformation",
+			Value:   10 * time.Second,
+		},
+		&cli.StringFlag{
+			Name:    "transform-failure-policy",
+			EnvVars: []string{"REPLIKATOR_TRANSFORM_FAILURE_POLICY"},
+			Usage:   "What to do when a transformation fails (Fail to requeue the source, or Ignore to write the untransformed replica)",
+			Value:   string(controller.FailurePolicyFail),
+		},
+		&cli.StringFlag{
+			Name:    "spiffe-bundle-key",
+			EnvVars: []string{"REPLIKATOR_SPIFFE_BUNDLE_KEY"},
+			Usage:   "The key SPIFFE trust bundles are added to in the replicas of sources annotated with spiffe-bundle",
+			Value:   controller.DefaultSPIFFEBundleKey,
+		},
+		&cli.DurationFlag{
+			Name:    "spiffe-refresh-hint",
+			EnvVars: []string{"REPLIKATOR_SPIFFE_REFRESH_HINT"},
+			Usage:   "How often consumers of SPIFFE trust bundles are advised to poll for updates (0 to omit)",
+		},
+		&cli.IntFlag{
+			Name:    "webhook-port",
+			EnvVars: []string{"REPLIKATOR_WEBHOOK_PORT"},
+			Usage:   "The port the webhook server binds to",
+			Value:   9443,
+		},
+		&cli.StringFlag{
+			Name:    "webhook-cert-dir",
+			EnvVars: []string{"REPLIKATOR_WEBHOOK_CERT_DIR"},
+			Usage:   "The directory containing the webhook server key and certificate",
+			Value:   "/tmp/k8s-webhook-server/serving-certs",
+		},
+		&cli.StringFlag{
+			Name:    "replica-protection",
+			EnvVars: []string{"REPLIKATOR_REPLICA_PROTECTION"},
+			Usage:   "Protect replicas from direct modification using an admission webhook (off, warn, or deny)",
+			Value:   "off",
+		},
+		&cli.StringFlag{
+			Name:    "annotation-validation",
+			EnvVars: []string{"REPLIKATOR_ANNOTATION_VALIDATION"},
+			Usage:   "Validate replikator annotations at admission time using a webhook (off, warn, or deny)",
+			Value:   "off",
+		},
+		&cli.StringFlag{
+			Name:    "auto-annotation-rules",
+			EnvVars: []string{"REPLIKATOR_AUTO_ANNOTATION_RULES"},
+			Usage:   "Path to a file of rules used by the mutating webhook to automatically annotate secrets and configmaps",
+		},
+		&cli.BoolFlag{
+			Name:    "provenance",
+			EnvVars: []string{"REPLIKATOR_PROVENANCE"},
+			Usage:   "Record the user who enabled replication of a source using a mutating webhook",
+		},
+		&cli.StringSliceFlag{
+			Name:    "replica-protection-allowed-users",
+			EnvVars: []string{"REPLIKATOR_REPLICA_PROTECTION_ALLOWED_USERS"},
+			Usage:   "Users that are permitted to modify replicas when replica protection is enabled",
+			Value:   cli.NewStringSlice("system:serviceaccount:replikator:controller-manager"),
+		},
+		&cli.BoolFlag{
+			Name:    "dry-run",
+			EnvVars: []string{"REPLIKATOR_DRY_RUN"},
+			Usage:   "Log every write that would be performed instead of modifying the cluster",
+		},
+		&cli.BoolFlag{
+			Name:    "once",
+			EnvVars: []string{"REPLIKATOR_ONCE"},
+			Usage:   "Perform a single reconciliation pass over all sources and then exit (eg. when run as a CronJob)",
+		},
+	}, commands.KubeconfigFlags()...)
+}
+
+func isValidWebhookMode(mode string) bool {
+	return mode == "off" || mode == "warn" || mode == "deny"
+}
+
+func featureGatesUsage() string {
+	usage := "A comma separated list of feature=bool pairs enabling or disabling experimental features"
+	if knownFeatures := features.DefaultFeatureGate.KnownFeatures(); len(knownFeatures) > 0 {
+		usage += " (" + strings.Join(knownFeatures, ", ") + ")"
+	}
+
+	return usage
+}
+
 type logLevelFlag slog.Level
 
 func fromLogLevel(l slog.Level) *logLevelFlag {
@@ -0,0 +1,61 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestFlags(t *testing.T) {
+	t.Run("Should Have An Environment Variable For Every Flag", func(t *testing.T) {
+		for _, flag := range flags() {
+			name := flag.Names()[0]
+
+			envFlag, ok := flag.(interface{ GetEnvVars() []string })
+			require.True(t, ok, name)
+
+			assert.Equal(t, []string{"REPLIKATOR_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))}, envFlag.GetEnvVars(), name)
+		}
+	})
+
+	t.Run("Should Prefer Flags To Environment Variables", func(t *testing.T) {
+		t.Setenv("REPLIKATOR_DOMAIN_PREFIX", "env.example.com")
+		t.Setenv("REPLIKATOR_MAX_REPLICAS_PER_SOURCE", "10")
+
+		var domain string
+		var maxReplicas int
+		app := &cli.App{
+			Flags: flags(),
+			Action: func(c *cli.Context) error {
+				domain = c.String("domain-prefix")
+				maxReplicas = c.Int("max-replicas-per-source")
+				return nil
+			},
+		}
+
+		require.NoError(t, app.Run([]string{"replikator", "--domain-prefix=flag.example.com"}))
+
+		assert.Equal(t, "flag.example.com", domain)
+		assert.Equal(t, 10, maxReplicas)
+	})
+}
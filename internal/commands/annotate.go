@@ -0,0 +1,158 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/urfave/cli/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AnnotateOptions are the replication settings applied by Annotate.
+type AnnotateOptions struct {
+	// ReplicateTo is a comma-separated list of namespace glob patterns.
+	ReplicateTo string
+	// ReplicateExcept is a comma-separated list of namespace glob patterns to exclude.
+	ReplicateExcept string
+	// ReplicateKeys is a comma-separated list of key glob patterns.
+	ReplicateKeys string
+	// Disable turns off replication instead of enabling it.
+	Disable bool
+}
+
+// AnnotateCommand returns the command that enables replication on an object.
+func AnnotateCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "annotate",
+		Usage:     "Enable replication of a secret or configmap",
+		ArgsUsage: "<secret|configmap> <namespace/name>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "to",
+				Usage: "Comma-separated list of namespace glob patterns to replicate to (default: all namespaces)",
+			},
+			&cli.StringFlag{
+				Name:  "except",
+				Usage: "Comma-separated list of namespace glob patterns never to replicate to",
+			},
+			&cli.StringFlag{
+				Name:  "keys",
+				Usage: "Comma-separated list of key glob patterns to replicate (default: all keys)",
+			},
+			&cli.BoolFlag{
+				Name:  "disable",
+				Usage: "Disable replication of the object",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 2 {
+				return fmt.Errorf("expected exactly two arguments: %s", c.Command.ArgsUsage)
+			}
+
+			obj, err := newObject(c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+
+			key, err := parseRef(c.Args().Get(1))
+			if err != nil {
+				return err
+			}
+
+			k8sClient, err := newClient(c)
+			if err != nil {
+				return err
+			}
+
+			if err := k8sClient.Get(c.Context, key, obj); err != nil {
+				return fmt.Errorf("failed to get %s %s: %w", kindOf(obj), key, err)
+			}
+
+			if err := Annotate(c.Context, k8sClient, obj, AnnotateOptions{
+				ReplicateTo:     c.String("to"),
+				ReplicateExcept: c.String("except"),
+				ReplicateKeys:   c.String("keys"),
+				Disable:         c.Bool("disable"),
+			}); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(c.App.Writer, "Annotated %s %s\n", kindOf(obj), key)
+
+			return nil
+		},
+	}
+}
+
+// Annotate validates the options and applies the corresponding replication
+// annotations to the object.
+func Annotate(ctx context.Context, c client.Client, obj client.Object, opts AnnotateOptions) error {
+	if opts.ReplicateTo != "" {
+		if err := api.ValidateFilters(opts.ReplicateTo); err != nil {
+			return fmt.Errorf("invalid namespace filter: %w", err)
+		}
+	}
+
+	if opts.ReplicateExcept != "" {
+		if err := api.ValidateFilters(opts.ReplicateExcept); err != nil {
+			return fmt.Errorf("invalid namespace exclusion: %w", err)
+		}
+	}
+
+	if opts.ReplicateKeys != "" {
+		if err := api.ValidateFilters(opts.ReplicateKeys); err != nil {
+			return fmt.Errorf("invalid key filter: %w", err)
+		}
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	if opts.Disable {
+		annotations[api.Key(api.AnnotationEnabled)] = "false"
+	} else {
+		annotations[api.Key(api.AnnotationEnabled)] = "true"
+	}
+
+	if opts.ReplicateTo != "" {
+		annotations[api.Key(api.AnnotationReplicateTo)] = opts.ReplicateTo
+	}
+
+	if opts.ReplicateExcept != "" {
+		annotations[api.Key(api.AnnotationReplicateExcept)] = opts.ReplicateExcept
+	}
+
+	if opts.ReplicateKeys != "" {
+		annotations[api.Key(api.AnnotationReplicateKeys)] = opts.ReplicateKeys
+	}
+
+	obj.SetAnnotations(annotations)
+
+	if err := c.Patch(ctx, obj, patch); err != nil {
+		return fmt.Errorf("failed to annotate %s: %w", kindOf(obj), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAnnotate(t *testing.T) {
+	ctx := context.Background()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "root-ca-tls",
+			Namespace: "cert-manager",
+		},
+	}
+
+	t.Run("Should Apply Annotations", func(t *testing.T) {
+		k8sClient := fake.NewClientBuilder().
+			WithObjects(secret).
+			Build()
+
+		obj := secret.DeepCopy()
+		err := commands.Annotate(ctx, k8sClient, obj, commands.AnnotateOptions{
+			ReplicateTo:   "team-*",
+			ReplicateKeys: "ca*",
+		})
+		require.NoError(t, err)
+
+		var updated corev1.Secret
+		require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(secret), &updated))
+
+		assert.Equal(t, "true", updated.Annotations[api.Key(api.AnnotationEnabled)])
+		assert.Equal(t, "team-*", updated.Annotations[api.Key(api.AnnotationReplicateTo)])
+		assert.Equal(t, "ca*", updated.Annotations[api.Key(api.AnnotationReplicateKeys)])
+	})
+
+	t.Run("Should Reject Malformed Patterns", func(t *testing.T) {
+		k8sClient := fake.NewClientBuilder().
+			WithObjects(secret).
+			Build()
+
+		obj := secret.DeepCopy()
+		err := commands.Annotate(ctx, k8sClient, obj, commands.AnnotateOptions{
+			ReplicateTo: "team-[",
+		})
+		require.Error(t, err)
+
+		var updated corev1.Secret
+		require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(secret), &updated))
+
+		assert.Empty(t, updated.Annotations)
+	})
+}
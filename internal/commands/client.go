@@ -0,0 +1,84 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KubeconfigFlags returns the flags used to select the cluster to connect to.
+func KubeconfigFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "kubeconfig",
+			EnvVars: []string{"REPLIKATOR_KUBECONFIG"},
+			Usage:   "Path to the kubeconfig file to use (defaults to the in-cluster config or $KUBECONFIG)",
+		},
+		&cli.StringFlag{
+			Name:    "context",
+			EnvVars: []string{"REPLIKATOR_CONTEXT"},
+			Usage:   "The kubeconfig context to use",
+		},
+	}
+}
+
+// GetConfig returns the config for connecting to the cluster selected by the
+// kubeconfig flags (falling back to the usual controller-runtime defaults).
+func GetConfig(c *cli.Context) (*rest.Config, error) {
+	kubeconfig, kubeContext := c.String("kubeconfig"), c.String("context")
+	if kubeconfig == "" && kubeContext == "" {
+		config, err := ctrl.GetConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
+		}
+
+		return config, nil
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
+	}
+
+	return config, nil
+}
+
+func newClient(c *cli.Context) (client.Client, error) {
+	config, err := GetConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	k8sClient, err := client.New(config, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	return k8sClient, nil
+}
@@ -0,0 +1,109 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"k8s.io/client-go/rest"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: staging
+  cluster:
+    server: https://staging.example.com
+- name: production
+  cluster:
+    server: https://production.example.com
+users:
+- name: admin
+  user:
+    token: s3cr3t
+contexts:
+- name: staging
+  context:
+    cluster: staging
+    user: admin
+- name: production
+  context:
+    cluster: production
+    user: admin
+current-context: staging
+`
+
+func TestGetConfig(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(testKubeconfig), 0o600))
+
+	getConfig := func(t *testing.T, args ...string) *rest.Config {
+		var config *rest.Config
+		app := &cli.App{
+			Flags: commands.KubeconfigFlags(),
+			Action: func(c *cli.Context) (err error) {
+				config, err = commands.GetConfig(c)
+				return err
+			},
+		}
+
+		require.NoError(t, app.Run(append([]string{"replikator"}, args...)))
+
+		return config
+	}
+
+	t.Run("Should Use Current Context Of Kubeconfig", func(t *testing.T) {
+		config := getConfig(t, "--kubeconfig="+kubeconfig)
+
+		assert.Equal(t, "https://staging.example.com", config.Host)
+		assert.Equal(t, "s3cr3t", config.BearerToken)
+	})
+
+	t.Run("Should Use Specified Context", func(t *testing.T) {
+		config := getConfig(t, "--kubeconfig="+kubeconfig, "--context=production")
+
+		assert.Equal(t, "https://production.example.com", config.Host)
+	})
+
+	t.Run("Should Read Flags From Environment", func(t *testing.T) {
+		t.Setenv("REPLIKATOR_KUBECONFIG", kubeconfig)
+		t.Setenv("REPLIKATOR_CONTEXT", "production")
+
+		config := getConfig(t)
+
+		assert.Equal(t, "https://production.example.com", config.Host)
+	})
+
+	t.Run("Should Fail For Unknown Context", func(t *testing.T) {
+		app := &cli.App{
+			Flags: commands.KubeconfigFlags(),
+			Action: func(c *cli.Context) error {
+				_, err := commands.GetConfig(c)
+				return err
+			},
+		}
+
+		assert.Error(t, app.Run([]string{"replikator", "--kubeconfig=" + kubeconfig, "--context=missing"}))
+	})
+}
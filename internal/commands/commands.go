@@ -0,0 +1,36 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import "github.com/urfave/cli/v2"
+
+// All returns every replikator subcommand. These are shared between the
+// operator binary and the kubectl plugin.
+func All() []*cli.Command {
+	return []*cli.Command{
+		AnnotateCommand(),
+		DiffCommand(),
+		DoctorCommand(),
+		ExportCommand(),
+		MigrateAnnotationsCommand(),
+		PruneCommand(),
+		SimulateCommand(),
+		SnapshotCommand(),
+		ValidateCommand(),
+	}
+}
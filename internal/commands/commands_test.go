@@ -0,0 +1,56 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestAll(t *testing.T) {
+	t.Run("Should Have Unique Runnable Commands", func(t *testing.T) {
+		names := make(map[string]bool)
+		for _, command := range commands.All() {
+			assert.False(t, names[command.Name], command.Name)
+			names[command.Name] = true
+
+			assert.NotEmpty(t, command.Usage, command.Name)
+			assert.True(t, command.Action != nil || len(command.Subcommands) > 0, command.Name)
+		}
+	})
+
+	t.Run("Should Show Help For Every Command", func(t *testing.T) {
+		for _, command := range commands.All() {
+			var out bytes.Buffer
+			app := &cli.App{
+				Name:     "kubectl replikator",
+				Writer:   &out,
+				Flags:    commands.KubeconfigFlags(),
+				Commands: commands.All(),
+			}
+
+			require.NoError(t, app.Run([]string{"kubectl-replikator", command.Name, "--help"}), command.Name)
+			assert.Contains(t, out.String(), command.Usage, command.Name)
+		}
+	})
+}
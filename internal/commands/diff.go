@@ -0,0 +1,117 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/urfave/cli/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Drift is a difference between a source and one of its replicas.
+type Drift = controller.Drift
+
+// DiffCommand returns the command that compares sources against their replicas.
+func DiffCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "diff",
+		Usage:     "Compare sources against their replicas, exiting non-zero if any have drifted",
+		ArgsUsage: "[<secret|configmap> <namespace/name>]",
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 0 && c.NArg() != 2 {
+				return fmt.Errorf("expected either zero or two arguments: %s", c.Command.ArgsUsage)
+			}
+
+			k8sClient, err := newClient(c)
+			if err != nil {
+				return err
+			}
+
+			var sources []client.Object
+			if c.NArg() == 2 {
+				obj, err := newObject(c.Args().Get(0))
+				if err != nil {
+					return err
+				}
+
+				key, err := parseRef(c.Args().Get(1))
+				if err != nil {
+					return err
+				}
+
+				if err := k8sClient.Get(c.Context, key, obj); err != nil {
+					return fmt.Errorf("failed to get %s %s: %w", kindOf(obj), key, err)
+				}
+
+				sources = append(sources, obj)
+			} else {
+				objects, err := listObjects(c.Context, k8sClient)
+				if err != nil {
+					return err
+				}
+
+				for _, obj := range objects {
+					if api.IsReplicationEnabled(obj) {
+						sources = append(sources, obj)
+					}
+				}
+			}
+
+			var drifted int
+			for _, source := range sources {
+				drift, err := Diff(c.Context, k8sClient, source)
+				if err != nil {
+					return err
+				}
+
+				for _, d := range drift {
+					fmt.Fprintf(c.App.Writer, "%s %s/%s -> %s: %s\n", kindOf(source), source.GetNamespace(), source.GetName(), d.Namespace, d.Message)
+				}
+
+				drifted += len(drift)
+			}
+
+			if drifted > 0 {
+				return cli.Exit(fmt.Sprintf("found %d difference(s)", drifted), 1)
+			}
+
+			fmt.Fprintln(c.App.Writer, "No differences found")
+
+			return nil
+		},
+	}
+}
+
+// Diff compares a source object against each of its replicas.
+func Diff(ctx context.Context, c client.Client, source client.Object) ([]Drift, error) {
+	if !api.IsReplicationEnabled(source) {
+		return nil, fmt.Errorf("replication is not enabled for %s %s/%s", kindOf(source), source.GetNamespace(), source.GetName())
+	}
+
+	return controller.DiffSource(ctx, c, controller.Policy{Metadata: defaultMetadataFilter}, source)
+}
+
+// defaultMetadataFilter mirrors the operator's default metadata sanitization.
+var defaultMetadataFilter = api.MetadataFilter{
+	StripLabels:      api.DefaultStrippedLabels,
+	StripAnnotations: api.DefaultStrippedAnnotations,
+}
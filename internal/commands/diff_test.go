@@ -0,0 +1,97 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+
+	source := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		Data: map[string]string{
+			"foo": "bar",
+		},
+	}
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	replica, err := api.ConfigMapTemplate(source, api.MetadataFilter{})
+	require.NoError(t, err)
+	replica.Namespace = anotherNamespace.Name
+
+	t.Run("Should Report No Drift When In Sync", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(source, anotherNamespace, replica).
+			Build()
+
+		drift, err := commands.Diff(ctx, client, source)
+		require.NoError(t, err)
+
+		assert.Empty(t, drift)
+	})
+
+	t.Run("Should Report Missing Replicas", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(source, anotherNamespace).
+			Build()
+
+		drift, err := commands.Diff(ctx, client, source)
+		require.NoError(t, err)
+
+		require.Len(t, drift, 1)
+		assert.Equal(t, anotherNamespace.Name, drift[0].Namespace)
+		assert.Equal(t, "replica is missing", drift[0].Message)
+	})
+
+	t.Run("Should Report Modified Keys", func(t *testing.T) {
+		modifiedReplica := replica.DeepCopy()
+		modifiedReplica.Data["foo"] = "baz"
+
+		client := fake.NewClientBuilder().
+			WithObjects(source, anotherNamespace, modifiedReplica).
+			Build()
+
+		drift, err := commands.Diff(ctx, client, source)
+		require.NoError(t, err)
+
+		require.Len(t, drift, 1)
+		assert.Contains(t, drift[0].Message, `key "foo" has hash`)
+	})
+}
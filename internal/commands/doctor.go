@@ -0,0 +1,201 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/urfave/cli/v2"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CheckResult is the outcome of a single doctor check.
+type CheckResult struct {
+	// Name describes what was checked.
+	Name string
+	// Err is nil if the check passed.
+	Err error
+	// Remediation suggests how to fix a failed check.
+	Remediation string
+}
+
+// DoctorCommand returns the command that checks the operator's environment
+// (API server connectivity, RBAC permissions and ports).
+func DoctorCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "doctor",
+		Usage: "Check that replikator has the permissions and environment it needs",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "sample-size",
+				Usage: "Number of namespaces to check secret and configmap permissions in",
+				Value: 5,
+			},
+			&cli.StringFlag{
+				Name:  "metrics-bind-address",
+				Usage: "The address the metric endpoint binds to",
+				Value: ":8080",
+			},
+			&cli.StringFlag{
+				Name:  "health-probe-bind-address",
+				Usage: "The address the probe endpoint binds to",
+				Value: ":8081",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			config, err := GetConfig(c)
+			if err != nil {
+				return err
+			}
+
+			var results []CheckResult
+
+			discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+			if err != nil {
+				return fmt.Errorf("failed to create discovery client: %w", err)
+			}
+
+			_, err = discoveryClient.ServerVersion()
+			results = append(results, CheckResult{
+				Name:        fmt.Sprintf("API server %s is reachable", config.Host),
+				Err:         err,
+				Remediation: "check your kubeconfig (or the in-cluster service account token) and network connectivity to the API server",
+			})
+
+			if err == nil {
+				k8sClient, err := newClient(c)
+				if err != nil {
+					return err
+				}
+
+				results = append(results, CheckPermissions(c.Context, k8sClient, c.Int("sample-size"))...)
+			}
+
+			results = append(results, CheckAddresses(c.String("metrics-bind-address"), c.String("health-probe-bind-address"))...)
+
+			var failed int
+			for _, result := range results {
+				if result.Err == nil {
+					fmt.Fprintf(c.App.Writer, "[OK]   %s\n", result.Name)
+					continue
+				}
+
+				failed++
+				fmt.Fprintf(c.App.Writer, "[FAIL] %s: %v\n", result.Name, result.Err)
+				fmt.Fprintf(c.App.Writer, "       Remediation: %s\n", result.Remediation)
+			}
+
+			if failed > 0 {
+				return cli.Exit(fmt.Sprintf("%d check(s) failed", failed), 1)
+			}
+
+			return nil
+		},
+	}
+}
+
+// CheckPermissions uses SelfSubjectAccessReviews to verify that the current
+// user can list namespaces, and read/write secrets and configmaps in a sample
+// of up to sampleSize namespaces.
+func CheckPermissions(ctx context.Context, c client.Client, sampleSize int) []CheckResult {
+	var results []CheckResult
+
+	for _, verb := range []string{"get", "list", "watch"} {
+		results = append(results, checkAccess(ctx, c, "", "namespaces", verb))
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := c.List(ctx, &namespaces, client.Limit(int64(sampleSize))); err != nil {
+		return append(results, CheckResult{
+			Name:        "list namespaces",
+			Err:         err,
+			Remediation: "grant the replikator service account list access to namespaces",
+		})
+	}
+
+	for _, namespace := range namespaces.Items {
+		for _, resource := range []string{"secrets", "configmaps"} {
+			for _, verb := range []string{"get", "list", "watch", "create", "update", "patch", "delete"} {
+				results = append(results, checkAccess(ctx, c, namespace.Name, resource, verb))
+			}
+		}
+	}
+
+	return results
+}
+
+func checkAccess(ctx context.Context, c client.Client, namespace, resource, verb string) CheckResult {
+	result := CheckResult{
+		Name:        fmt.Sprintf("can %s %s", verb, resource),
+		Remediation: fmt.Sprintf("grant the replikator service account %q access to %s (see config/rbac/role.yaml)", verb, resource),
+	}
+
+	if namespace != "" {
+		result.Name += fmt.Sprintf(" in namespace %s", namespace)
+	}
+
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Resource:  resource,
+			},
+		},
+	}
+
+	if err := c.Create(ctx, review); err != nil {
+		result.Err = fmt.Errorf("failed to review access: %w", err)
+	} else if !review.Status.Allowed {
+		result.Err = errors.New("access denied")
+		if review.Status.Reason != "" {
+			result.Err = fmt.Errorf("access denied: %s", review.Status.Reason)
+		}
+	}
+
+	return result
+}
+
+// CheckAddresses verifies that the addresses the operator binds to are free.
+func CheckAddresses(addrs ...string) []CheckResult {
+	var results []CheckResult
+	for _, addr := range addrs {
+		results = append(results, CheckResult{
+			Name:        fmt.Sprintf("address %s is free", addr),
+			Err:         checkAddressFree(addr),
+			Remediation: "stop the process using the port or choose a different bind address",
+		})
+	}
+
+	return results
+}
+
+func checkAddressFree(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return lis.Close()
+}
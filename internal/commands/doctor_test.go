@@ -0,0 +1,167 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	ctx := context.Background()
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a",
+		},
+	}
+
+	tests := []struct {
+		name string
+		// review decides the outcome of each access review (nil allows everything).
+		review func(attributes *authorizationv1.ResourceAttributes) (authorizationv1.SubjectAccessReviewStatus, error)
+		// failed are the names of the checks expected to fail.
+		failed []string
+	}{
+		{
+			name: "Should Pass When Everything Is Allowed",
+		},
+		{
+			name: "Should Fail When Namespaces Can't Be Watched",
+			review: func(attributes *authorizationv1.ResourceAttributes) (authorizationv1.SubjectAccessReviewStatus, error) {
+				return authorizationv1.SubjectAccessReviewStatus{Allowed: attributes.Resource != "namespaces" || attributes.Verb != "watch"}, nil
+			},
+			failed: []string{"can watch namespaces"},
+		},
+		{
+			name: "Should Fail When Secrets Can't Be Written",
+			review: func(attributes *authorizationv1.ResourceAttributes) (authorizationv1.SubjectAccessReviewStatus, error) {
+				if attributes.Resource == "secrets" && (attributes.Verb == "create" || attributes.Verb == "update") {
+					return authorizationv1.SubjectAccessReviewStatus{Reason: "no RBAC policy matched"}, nil
+				}
+
+				return authorizationv1.SubjectAccessReviewStatus{Allowed: true}, nil
+			},
+			failed: []string{"can create secrets in namespace team-a", "can update secrets in namespace team-a"},
+		},
+		{
+			name: "Should Fail When Access Can't Be Reviewed",
+			review: func(attributes *authorizationv1.ResourceAttributes) (authorizationv1.SubjectAccessReviewStatus, error) {
+				if attributes.Resource == "configmaps" && attributes.Verb == "delete" {
+					return authorizationv1.SubjectAccessReviewStatus{}, errors.New("forbidden")
+				}
+
+				return authorizationv1.SubjectAccessReviewStatus{Allowed: true}, nil
+			},
+			failed: []string{"can delete configmaps in namespace team-a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientBuilder().
+				WithObjects(namespace).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Create: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, opts ...ctrlclient.CreateOption) error {
+						review, ok := obj.(*authorizationv1.SelfSubjectAccessReview)
+						if !ok {
+							return c.Create(ctx, obj, opts...)
+						}
+
+						if tt.review == nil {
+							review.Status.Allowed = true
+							return nil
+						}
+
+						status, err := tt.review(review.Spec.ResourceAttributes)
+						review.Status = status
+						return err
+					},
+				}).
+				Build()
+
+			results := commands.CheckPermissions(ctx, client, 5)
+
+			// Namespaces, and secrets and configmaps in the sampled namespace.
+			assert.Len(t, results, 3+2*7)
+
+			var failed []string
+			for _, result := range results {
+				if result.Err != nil {
+					failed = append(failed, result.Name)
+					assert.NotEmpty(t, result.Remediation)
+				}
+			}
+
+			assert.ElementsMatch(t, tt.failed, failed)
+		})
+	}
+
+	t.Run("Should Fail When Namespaces Can't Be Listed", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, opts ...ctrlclient.CreateOption) error {
+					obj.(*authorizationv1.SelfSubjectAccessReview).Status.Allowed = true
+					return nil
+				},
+				List: func(ctx context.Context, c ctrlclient.WithWatch, list ctrlclient.ObjectList, opts ...ctrlclient.ListOption) error {
+					return fmt.Errorf("namespaces is forbidden")
+				},
+			}).
+			Build()
+
+		results := commands.CheckPermissions(ctx, client, 5)
+
+		last := results[len(results)-1]
+		assert.Equal(t, "list namespaces", last.Name)
+		assert.Error(t, last.Err)
+	})
+}
+
+func TestCheckAddresses(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = lis.Close()
+	})
+
+	results := commands.CheckAddresses(lis.Addr().String(), "127.0.0.1:0")
+	require.Len(t, results, 2)
+
+	t.Run("Should Fail When Address Is In Use", func(t *testing.T) {
+		assert.Error(t, results[0].Err)
+		assert.NotEmpty(t, results[0].Remediation)
+	})
+
+	t.Run("Should Pass When Address Is Free", func(t *testing.T) {
+		assert.NoError(t, results[1].Err)
+	})
+}
@@ -0,0 +1,132 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/urfave/cli/v2"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// ExportCommand returns the command that exports the replication configuration
+// of the cluster as a manifest bundle.
+func ExportCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "export",
+		Usage: "Export the replication configuration of all sources as a manifest bundle",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "File to write the bundle to (default: stdout)",
+			},
+			&cli.BoolFlag{
+				Name:  "include-data",
+				Usage: "Include the data of sources (including secret values) in the bundle",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			k8sClient, err := newClient(c)
+			if err != nil {
+				return err
+			}
+
+			bundle, err := Export(c.Context, k8sClient, c.Bool("include-data"))
+			if err != nil {
+				return err
+			}
+
+			if output := c.String("output"); output != "" {
+				if err := os.WriteFile(output, bundle, 0o600); err != nil {
+					return fmt.Errorf("failed to write bundle: %w", err)
+				}
+
+				return nil
+			}
+
+			_, err = c.App.Writer.Write(bundle)
+			return err
+		},
+	}
+}
+
+// Export returns a multi-document YAML bundle describing every replication
+// enabled source in the cluster. By default only the replikator annotations
+// are included, so the bundle can be re-applied (with server-side apply) to
+// existing objects on a rebuilt cluster without touching their data.
+func Export(ctx context.Context, c client.Client, includeData bool) ([]byte, error) {
+	objects, err := listObjects(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, obj := range objects {
+		if !api.IsReplicationEnabled(obj) {
+			continue
+		}
+
+		annotations := make(map[string]string)
+		for key, value := range obj.GetAnnotations() {
+			if strings.HasPrefix(key, api.KeyPrefix()) {
+				annotations[key] = value
+			}
+		}
+
+		manifest := map[string]any{
+			"apiVersion": "v1",
+			"metadata": map[string]any{
+				"name":        obj.GetName(),
+				"namespace":   obj.GetNamespace(),
+				"annotations": annotations,
+			},
+		}
+
+		switch obj := obj.(type) {
+		case *corev1.Secret:
+			manifest["kind"] = "Secret"
+			if includeData {
+				manifest["type"] = obj.Type
+				manifest["data"] = obj.Data
+			}
+		case *corev1.ConfigMap:
+			manifest["kind"] = "ConfigMap"
+			if includeData {
+				manifest["data"] = obj.Data
+			}
+		}
+
+		doc, err := yaml.Marshal(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s %s/%s: %w", kindOf(obj), obj.GetNamespace(), obj.GetName(), err)
+		}
+
+		buf.WriteString("---\n")
+		buf.Write(doc)
+	}
+
+	return buf.Bytes(), nil
+}
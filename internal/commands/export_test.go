@@ -0,0 +1,71 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled):     "true",
+				api.Key(api.AnnotationReplicateTo): "team-*",
+				"unrelated":                        "annotation",
+			},
+		},
+		Data: map[string][]byte{
+			"password": []byte("hunter2"),
+		},
+	}
+
+	client := fake.NewClientBuilder().
+		WithObjects(secret).
+		Build()
+
+	t.Run("Should Export Annotations Only", func(t *testing.T) {
+		bundle, err := commands.Export(ctx, client, false)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(bundle), "kind: Secret")
+		assert.Contains(t, string(bundle), api.Key(api.AnnotationReplicateTo)+": team-*")
+		assert.NotContains(t, string(bundle), "unrelated")
+		assert.NotContains(t, string(bundle), "password")
+	})
+
+	t.Run("Should Export Data When Requested", func(t *testing.T) {
+		bundle, err := commands.Export(ctx, client, true)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(bundle), "password")
+	})
+}
@@ -0,0 +1,81 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"fmt"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/urfave/cli/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MigrateAnnotationsCommand returns the command that rewrites legacy
+// (gpuninja.com / tls-replicator) annotations to the current annotation set.
+func MigrateAnnotationsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "migrate-annotations",
+		Usage: "Rewrite legacy replikator and tls-replicator annotations to the current annotation set",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only print the objects that would be migrated",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			k8sClient, err := newClient(c)
+			if err != nil {
+				return err
+			}
+
+			objects, err := listObjects(c.Context, k8sClient)
+			if err != nil {
+				return err
+			}
+
+			var migrated int
+			for _, obj := range objects {
+				ref := fmt.Sprintf("%s %s/%s", kindOf(obj), obj.GetNamespace(), obj.GetName())
+
+				patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+				if !controller.MigrateAnnotations(obj) {
+					continue
+				}
+
+				migrated++
+
+				if c.Bool("dry-run") {
+					fmt.Fprintf(c.App.Writer, "Would migrate %s\n", ref)
+					continue
+				}
+
+				if err := k8sClient.Patch(c.Context, obj, patch); err != nil {
+					return fmt.Errorf("failed to migrate %s: %w", ref, err)
+				}
+
+				fmt.Fprintf(c.App.Writer, "Migrated %s\n", ref)
+			}
+
+			if migrated == 0 {
+				fmt.Fprintln(c.App.Writer, "No objects with legacy annotations found")
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,74 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newObject returns an empty object for the given (user supplied) kind.
+func newObject(kind string) (client.Object, error) {
+	switch strings.ToLower(kind) {
+	case "secret", "secrets":
+		return &corev1.Secret{}, nil
+	case "configmap", "configmaps", "cm":
+		return &corev1.ConfigMap{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported kind %q (expected secret or configmap)", kind)
+	}
+}
+
+// parseRef parses a "namespace/name" object reference.
+func parseRef(ref string) (types.NamespacedName, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return types.NamespacedName{}, fmt.Errorf("invalid reference %q (expected namespace/name)", ref)
+	}
+
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// listObjects returns all the secrets and configmaps in the cluster.
+func listObjects(ctx context.Context, c client.Client) ([]client.Object, error) {
+	var secrets corev1.SecretList
+	if err := c.List(ctx, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	var configMaps corev1.ConfigMapList
+	if err := c.List(ctx, &configMaps); err != nil {
+		return nil, fmt.Errorf("failed to list configmaps: %w", err)
+	}
+
+	var objects []client.Object
+	for i := range secrets.Items {
+		objects = append(objects, &secrets.Items[i])
+	}
+
+	for i := range configMaps.Items {
+		objects = append(objects, &configMaps.Items[i])
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,129 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/urfave/cli/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PruneCommand returns the command that deletes orphaned replicas.
+func PruneCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "prune",
+		Usage: "Delete replicas whose source no longer exists (or no longer targets their namespace)",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "Delete orphaned replicas without prompting for confirmation",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			k8sClient, err := newClient(c)
+			if err != nil {
+				return err
+			}
+
+			orphans, err := FindOrphans(c.Context, k8sClient)
+			if err != nil {
+				return err
+			}
+
+			if len(orphans) == 0 {
+				fmt.Fprintln(c.App.Writer, "No orphaned replicas found")
+				return nil
+			}
+
+			scanner := bufio.NewScanner(c.App.Reader)
+			for _, obj := range orphans {
+				ref := fmt.Sprintf("%s %s/%s", kindOf(obj), obj.GetNamespace(), obj.GetName())
+
+				if !c.Bool("yes") {
+					fmt.Fprintf(c.App.Writer, "Delete orphaned %s? [y/N] ", ref)
+
+					if !scanner.Scan() {
+						return scanner.Err()
+					}
+
+					answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+					if answer != "y" && answer != "yes" {
+						continue
+					}
+				}
+
+				deleted, err := DeleteOrphan(c.Context, k8sClient, obj)
+				if err != nil {
+					return fmt.Errorf("failed to delete %s: %w", ref, err)
+				}
+
+				if !deleted {
+					fmt.Fprintf(c.App.Writer, "Skipped %s, as it changed after it was found\n", ref)
+					continue
+				}
+
+				fmt.Fprintf(c.App.Writer, "Deleted %s\n", ref)
+			}
+
+			return nil
+		},
+	}
+}
+
+// FindOrphans returns all managed replicas that are no longer backed by a
+// replication enabled source (of the same kind and name) targeting their namespace.
+func FindOrphans(ctx context.Context, c client.Client) ([]client.Object, error) {
+	return controller.FindOrphans(ctx, c)
+}
+
+// DeleteOrphan deletes an orphaned replica returned by FindOrphans, unless it
+// has changed since (eg. its source was recreated, or it was adopted by another
+// owner). It returns false if the replica was skipped as it had changed.
+func DeleteOrphan(ctx context.Context, c client.Client, obj client.Object) (bool, error) {
+	uid, resourceVersion := obj.GetUID(), obj.GetResourceVersion()
+	if err := c.Delete(ctx, obj, client.Preconditions{UID: &uid, ResourceVersion: &resourceVersion}); err != nil {
+		if apierrors.IsConflict(err) {
+			return false, nil
+		}
+
+		if !apierrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func kindOf(obj client.Object) string {
+	switch obj.(type) {
+	case *corev1.Secret:
+		return "secret"
+	case *corev1.ConfigMap:
+		return "configmap"
+	default:
+		return "object"
+	}
+}
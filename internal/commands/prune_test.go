@@ -0,0 +1,152 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestFindOrphans(t *testing.T) {
+	ctx := context.Background()
+
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled):     "true",
+				api.Key(api.AnnotationReplicateTo): "team-*",
+			},
+		},
+	}
+
+	replica := func(namespace string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      source.Name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					api.LabelManagedByKey: api.LabelManagedByValue,
+				},
+			},
+		}
+	}
+
+	t.Run("Should Ignore Replicas With A Matching Source", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(source, replica("team-a")).
+			Build()
+
+		orphans, err := commands.FindOrphans(ctx, client)
+		require.NoError(t, err)
+
+		assert.Empty(t, orphans)
+	})
+
+	t.Run("Should Find Replicas Without A Source", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(replica("team-a")).
+			Build()
+
+		orphans, err := commands.FindOrphans(ctx, client)
+		require.NoError(t, err)
+
+		require.Len(t, orphans, 1)
+		assert.Equal(t, "team-a", orphans[0].GetNamespace())
+	})
+
+	t.Run("Should Find Replicas No Longer Targeted By Their Source", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(source, replica("team-a"), replica("other-namespace")).
+			Build()
+
+		orphans, err := commands.FindOrphans(ctx, client)
+		require.NoError(t, err)
+
+		require.Len(t, orphans, 1)
+		assert.Equal(t, "other-namespace", orphans[0].GetNamespace())
+	})
+}
+
+func TestDeleteOrphan(t *testing.T) {
+	ctx := context.Background()
+
+	orphan := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "team-a",
+			Labels: map[string]string{
+				api.LabelManagedByKey: api.LabelManagedByValue,
+			},
+		},
+	}
+
+	t.Run("Should Delete Unchanged Orphans", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(orphan).
+			Build()
+
+		orphans, err := commands.FindOrphans(ctx, client)
+		require.NoError(t, err)
+		require.Len(t, orphans, 1)
+
+		deleted, err := commands.DeleteOrphan(ctx, client, orphans[0])
+		require.NoError(t, err)
+		assert.True(t, deleted)
+
+		err = client.Get(ctx, ctrlclient.ObjectKeyFromObject(orphan), &corev1.Secret{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Skip Orphans That Changed", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(orphan).
+			Build()
+
+		orphans, err := commands.FindOrphans(ctx, client)
+		require.NoError(t, err)
+		require.Len(t, orphans, 1)
+
+		// The source is recreated and the replica rewritten before it's deleted.
+		var replica corev1.Secret
+		require.NoError(t, client.Get(ctx, ctrlclient.ObjectKeyFromObject(orphan), &replica))
+
+		replica.Annotations = map[string]string{
+			api.Key(api.AnnotationSourceNamespace): "test-namespace",
+			api.Key(api.AnnotationSourceName):      "test-secret",
+		}
+		require.NoError(t, client.Update(ctx, &replica))
+
+		deleted, err := commands.DeleteOrphan(ctx, client, orphans[0])
+		require.NoError(t, err)
+		assert.False(t, deleted)
+
+		require.NoError(t, client.Get(ctx, ctrlclient.ObjectKeyFromObject(orphan), &corev1.Secret{}))
+	})
+}
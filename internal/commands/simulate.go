@@ -0,0 +1,128 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/urfave/cli/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Changes are the writes the controller would make to the replicas of a source.
+type Changes = controller.Changes
+
+// SimulateCommand returns the command that previews the changes replikator
+// would make to the replicas of a source.
+func SimulateCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "simulate",
+		Usage:     "Preview the changes replikator would make to the replicas of a secret or configmap",
+		ArgsUsage: "<secret|configmap> <namespace/name>",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "annotation",
+				Usage: "Simulate setting (key=value) or removing (key-) an annotation on the source",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 2 {
+				return fmt.Errorf("expected exactly two arguments: %s", c.Command.ArgsUsage)
+			}
+
+			obj, err := newObject(c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+
+			key, err := parseRef(c.Args().Get(1))
+			if err != nil {
+				return err
+			}
+
+			k8sClient, err := newClient(c)
+			if err != nil {
+				return err
+			}
+
+			if err := k8sClient.Get(c.Context, key, obj); err != nil {
+				return fmt.Errorf("failed to get %s %s: %w", kindOf(obj), key, err)
+			}
+
+			if err := applyAnnotations(obj, c.StringSlice("annotation")); err != nil {
+				return err
+			}
+
+			changes, err := Simulate(c.Context, k8sClient, obj)
+			if err != nil {
+				return err
+			}
+
+			if changes.Empty() {
+				fmt.Fprintln(c.App.Writer, "No changes")
+				return nil
+			}
+
+			for _, change := range []struct {
+				action  string
+				objects []client.Object
+			}{{"create", changes.Create}, {"update", changes.Update}, {"delete", changes.Delete}} {
+				for _, replica := range change.objects {
+					fmt.Fprintf(c.App.Writer, "%s %s %s/%s\n", change.action, kindOf(obj), replica.GetNamespace(), replica.GetName())
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// Simulate returns the changes the controller would make to the replicas of
+// the source (which need not match the source in the cluster).
+func Simulate(ctx context.Context, c client.Client, source client.Object) (*Changes, error) {
+	return controller.Plan(ctx, c, controller.Policy{Metadata: defaultMetadataFilter}, source)
+}
+
+// applyAnnotations sets (key=value) or removes (key-) annotations on the object.
+func applyAnnotations(obj client.Object, changes []string) error {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	for _, change := range changes {
+		if key, ok := strings.CutSuffix(change, "-"); ok && !strings.Contains(change, "=") {
+			delete(annotations, key)
+			continue
+		}
+
+		key, value, ok := strings.Cut(change, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid annotation %q (expected key=value or key-)", change)
+		}
+
+		annotations[key] = value
+	}
+
+	obj.SetAnnotations(annotations)
+
+	return nil
+}
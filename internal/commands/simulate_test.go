@@ -0,0 +1,77 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSimulate(t *testing.T) {
+	ctx := context.Background()
+
+	source := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "test-namespace",
+		},
+		Data: map[string]string{
+			"foo": "bar",
+		},
+	}
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	t.Run("Should Preview Enabling Replication", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(source, anotherNamespace).
+			Build()
+
+		enabled := source.DeepCopy()
+		enabled.Annotations = map[string]string{api.Key(api.AnnotationEnabled): "true"}
+
+		changes, err := commands.Simulate(ctx, client, enabled)
+		require.NoError(t, err)
+
+		require.Len(t, changes.Create, 1)
+		assert.Equal(t, anotherNamespace.Name, changes.Create[0].GetNamespace())
+	})
+
+	t.Run("Should Preview No Changes When Replication Is Not Enabled", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(source, anotherNamespace).
+			Build()
+
+		changes, err := commands.Simulate(ctx, client, source)
+		require.NoError(t, err)
+
+		assert.True(t, changes.Empty())
+	})
+}
@@ -0,0 +1,364 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/urfave/cli/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Snapshot records which sources are replicated and where their replicas are,
+// without any of their data. It can later be compared against the cluster, or
+// used to restore the replication annotations of the sources (eg. after the
+// cluster has been rebuilt from a backup).
+type Snapshot struct {
+	// TakenAt is when the snapshot was taken.
+	TakenAt time.Time `json:"takenAt"`
+	// Sources are the replication enabled sources, sorted by kind, namespace and name.
+	Sources []SourceSnapshot `json:"sources"`
+}
+
+// SourceSnapshot records the replication state of a single source.
+type SourceSnapshot struct {
+	// Kind is the lowercase kind of the source (eg. "secret").
+	Kind string `json:"kind"`
+	// Namespace is the namespace of the source.
+	Namespace string `json:"namespace"`
+	// Name is the name of the source.
+	Name string `json:"name"`
+	// Annotations are the replikator annotations of the source.
+	Annotations map[string]string `json:"annotations"`
+	// Replicas are the (sorted) namespaces containing a replica of the source.
+	Replicas []string `json:"replicas,omitempty"`
+}
+
+// SnapshotDifference is a difference between a snapshot and the cluster.
+type SnapshotDifference struct {
+	// Kind is the lowercase kind of the source.
+	Kind string
+	// Source is the namespace and name of the source.
+	Source types.NamespacedName
+	// Message describes the difference.
+	Message string
+}
+
+// SnapshotCommand returns the command that saves, verifies and restores
+// snapshots of the replication state of the cluster.
+func SnapshotCommand() *cli.Command {
+	fileFlag := &cli.StringFlag{
+		Name:     "file",
+		Aliases:  []string{"f"},
+		Usage:    "Snapshot file to read",
+		Required: true,
+	}
+
+	return &cli.Command{
+		Name:  "snapshot",
+		Usage: "Save, verify and restore the mapping of sources to replicas",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "save",
+				Usage: "Save a snapshot of the replication state of the cluster",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "File to write the snapshot to (default: stdout)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					k8sClient, err := newClient(c)
+					if err != nil {
+						return err
+					}
+
+					snapshot, err := TakeSnapshot(c.Context, k8sClient)
+					if err != nil {
+						return err
+					}
+
+					data, err := yaml.Marshal(snapshot)
+					if err != nil {
+						return fmt.Errorf("failed to marshal snapshot: %w", err)
+					}
+
+					if output := c.String("output"); output != "" {
+						if err := os.WriteFile(output, data, 0o644); err != nil {
+							return fmt.Errorf("failed to write snapshot: %w", err)
+						}
+
+						return nil
+					}
+
+					_, err = c.App.Writer.Write(data)
+					return err
+				},
+			},
+			{
+				Name:  "verify",
+				Usage: "Compare the cluster against a snapshot, exiting non-zero if they differ",
+				Flags: []cli.Flag{fileFlag},
+				Action: func(c *cli.Context) error {
+					snapshot, err := readSnapshot(c.String("file"))
+					if err != nil {
+						return err
+					}
+
+					k8sClient, err := newClient(c)
+					if err != nil {
+						return err
+					}
+
+					differences, err := VerifySnapshot(c.Context, k8sClient, snapshot)
+					if err != nil {
+						return err
+					}
+
+					for _, d := range differences {
+						fmt.Fprintf(c.App.Writer, "%s %s: %s\n", d.Kind, d.Source, d.Message)
+					}
+
+					if len(differences) > 0 {
+						return cli.Exit(fmt.Sprintf("found %d difference(s)", len(differences)), 1)
+					}
+
+					fmt.Fprintln(c.App.Writer, "Cluster matches snapshot")
+
+					return nil
+				},
+			},
+			{
+				Name:  "restore",
+				Usage: "Re-apply the replication annotations recorded in a snapshot to existing sources",
+				Flags: []cli.Flag{fileFlag},
+				Action: func(c *cli.Context) error {
+					snapshot, err := readSnapshot(c.String("file"))
+					if err != nil {
+						return err
+					}
+
+					k8sClient, err := newClient(c)
+					if err != nil {
+						return err
+					}
+
+					missing, err := RestoreSnapshot(c.Context, k8sClient, snapshot)
+					if err != nil {
+						return err
+					}
+
+					for _, source := range missing {
+						fmt.Fprintf(c.App.Writer, "Skipped %s %s/%s as it does not exist\n", source.Kind, source.Namespace, source.Name)
+					}
+
+					fmt.Fprintf(c.App.Writer, "Restored %d source(s)\n", len(snapshot.Sources)-len(missing))
+
+					if len(missing) > 0 {
+						return cli.Exit(fmt.Sprintf("%d source(s) could not be restored", len(missing)), 1)
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// TakeSnapshot returns a snapshot of every replication enabled source in the
+// cluster, along with the namespaces its replicas are in.
+func TakeSnapshot(ctx context.Context, c client.Client) (*Snapshot, error) {
+	objects, err := listObjects(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make(map[string][]string)
+	for _, obj := range objects {
+		if !api.IsReplica(obj) {
+			continue
+		}
+
+		ref, _, ok := api.GetSourceReference(obj)
+		if !ok {
+			continue
+		}
+
+		key := kindOf(obj) + "/" + ref.String()
+		replicas[key] = append(replicas[key], obj.GetNamespace())
+	}
+
+	snapshot := &Snapshot{TakenAt: time.Now().UTC()}
+	for _, obj := range objects {
+		if !api.IsReplicationEnabled(obj) {
+			continue
+		}
+
+		annotations := make(map[string]string)
+		for key, value := range obj.GetAnnotations() {
+			if strings.HasPrefix(key, api.KeyPrefix()) {
+				annotations[key] = value
+			}
+		}
+
+		namespaces := replicas[kindOf(obj)+"/"+client.ObjectKeyFromObject(obj).String()]
+		sort.Strings(namespaces)
+
+		snapshot.Sources = append(snapshot.Sources, SourceSnapshot{
+			Kind:        kindOf(obj),
+			Namespace:   obj.GetNamespace(),
+			Name:        obj.GetName(),
+			Annotations: annotations,
+			Replicas:    namespaces,
+		})
+	}
+
+	sort.Slice(snapshot.Sources, func(i, j int) bool {
+		a, b := snapshot.Sources[i], snapshot.Sources[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	return snapshot, nil
+}
+
+// VerifySnapshot compares the replication state of the cluster against the
+// snapshot, returning every source that is missing, annotated differently, or
+// has replicas in a different set of namespaces.
+func VerifySnapshot(ctx context.Context, c client.Client, snapshot *Snapshot) ([]SnapshotDifference, error) {
+	current, err := TakeSnapshot(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]SourceSnapshot, len(current.Sources))
+	for _, source := range current.Sources {
+		sources[source.Kind+"/"+source.Namespace+"/"+source.Name] = source
+	}
+
+	var differences []SnapshotDifference
+	for _, expected := range snapshot.Sources {
+		difference := func(messageFmt string, args ...any) {
+			differences = append(differences, SnapshotDifference{
+				Kind:    expected.Kind,
+				Source:  types.NamespacedName{Namespace: expected.Namespace, Name: expected.Name},
+				Message: fmt.Sprintf(messageFmt, args...),
+			})
+		}
+
+		actual, ok := sources[expected.Kind+"/"+expected.Namespace+"/"+expected.Name]
+		if !ok {
+			difference("source is missing or not replicated")
+			continue
+		}
+
+		if !reflect.DeepEqual(expected.Annotations, actual.Annotations) {
+			difference("replication annotations differ")
+		}
+
+		for _, namespace := range expected.Replicas {
+			if !slices.Contains(actual.Replicas, namespace) {
+				difference("replica in namespace %s is missing", namespace)
+			}
+		}
+
+		for _, namespace := range actual.Replicas {
+			if !slices.Contains(expected.Replicas, namespace) {
+				difference("replica in namespace %s is not in the snapshot", namespace)
+			}
+		}
+	}
+
+	return differences, nil
+}
+
+// RestoreSnapshot re-applies the replikator annotations recorded in the
+// snapshot to each source, after which the operator reconstructs the replicas.
+// Sources themselves aren't recorded in snapshots, so sources that don't exist
+// are skipped and returned.
+func RestoreSnapshot(ctx context.Context, c client.Client, snapshot *Snapshot) ([]SourceSnapshot, error) {
+	var missing []SourceSnapshot
+	for _, source := range snapshot.Sources {
+		obj, err := newObject(source.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		key := types.NamespacedName{Namespace: source.Namespace, Name: source.Name}
+		if err := c.Get(ctx, key, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				missing = append(missing, source)
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to get %s %s: %w", source.Kind, key, err)
+		}
+
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+
+		annotations := make(map[string]string)
+		for k, v := range obj.GetAnnotations() {
+			if !strings.HasPrefix(k, api.KeyPrefix()) {
+				annotations[k] = v
+			}
+		}
+
+		for k, v := range source.Annotations {
+			annotations[k] = v
+		}
+
+		obj.SetAnnotations(annotations)
+
+		if err := c.Patch(ctx, obj, patch); err != nil {
+			return nil, fmt.Errorf("failed to restore %s %s: %w", source.Kind, key, err)
+		}
+	}
+
+	return missing, nil
+}
+
+func readSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := yaml.UnmarshalStrict(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
@@ -0,0 +1,142 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled):     "true",
+				api.Key(api.AnnotationReplicateTo): "team-*",
+				"unrelated":                        "annotation",
+			},
+		},
+		Data: map[string][]byte{
+			"password": []byte("hunter2"),
+		},
+	}
+
+	replica := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "team-a",
+			Labels: map[string]string{
+				api.LabelManagedByKey: api.LabelManagedByValue,
+			},
+			Annotations: map[string]string{
+				api.Key(api.AnnotationSourceNamespace): secret.Namespace,
+				api.Key(api.AnnotationSourceName):      secret.Name,
+			},
+		},
+		Data: secret.Data,
+	}
+
+	t.Run("Should Record Sources And Replicas", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret, replica).
+			Build()
+
+		snapshot, err := commands.TakeSnapshot(ctx, client)
+		require.NoError(t, err)
+
+		require.Len(t, snapshot.Sources, 1)
+		assert.Equal(t, commands.SourceSnapshot{
+			Kind:      "secret",
+			Namespace: secret.Namespace,
+			Name:      secret.Name,
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled):     "true",
+				api.Key(api.AnnotationReplicateTo): "team-*",
+			},
+			Replicas: []string{"team-a"},
+		}, snapshot.Sources[0])
+	})
+
+	t.Run("Should Detect Missing Replicas", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret, replica).
+			Build()
+
+		snapshot, err := commands.TakeSnapshot(ctx, client)
+		require.NoError(t, err)
+
+		differences, err := commands.VerifySnapshot(ctx, client, snapshot)
+		require.NoError(t, err)
+		assert.Empty(t, differences)
+
+		require.NoError(t, client.Delete(ctx, replica.DeepCopy()))
+
+		differences, err = commands.VerifySnapshot(ctx, client, snapshot)
+		require.NoError(t, err)
+
+		require.Len(t, differences, 1)
+		assert.Contains(t, differences[0].Message, "team-a")
+	})
+
+	t.Run("Should Restore Annotations", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret).
+			Build()
+
+		snapshot, err := commands.TakeSnapshot(ctx, client)
+		require.NoError(t, err)
+
+		var rebuilt corev1.Secret
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, &rebuilt))
+
+		rebuilt.Annotations = map[string]string{
+			api.Key(api.AnnotationReplicateKeys): "ca.crt",
+			"unrelated":                          "annotation",
+		}
+		require.NoError(t, client.Update(ctx, &rebuilt))
+
+		snapshot.Sources = append(snapshot.Sources, commands.SourceSnapshot{Kind: "configmap", Namespace: "test-namespace", Name: "missing"})
+
+		missing, err := commands.RestoreSnapshot(ctx, client, snapshot)
+		require.NoError(t, err)
+
+		require.Len(t, missing, 1)
+		assert.Equal(t, "missing", missing[0].Name)
+
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, &rebuilt))
+
+		assert.Equal(t, map[string]string{
+			api.Key(api.AnnotationEnabled):     "true",
+			api.Key(api.AnnotationReplicateTo): "team-*",
+			"unrelated":                        "annotation",
+		}, rebuilt.Annotations)
+	})
+}
@@ -0,0 +1,114 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/urfave/cli/v2"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Issue is a problem found with the replication configuration of an object.
+type Issue struct {
+	// Object is a human readable reference to the object, eg. "secret ns/name".
+	Object string
+	// Message describes the problem.
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Object, i.Message)
+}
+
+// ValidateCommand returns the command that lints replikator annotations across the cluster.
+func ValidateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "validate",
+		Usage: "Check the cluster for misconfigured replikator annotations",
+		Action: func(c *cli.Context) error {
+			k8sClient, err := newClient(c)
+			if err != nil {
+				return err
+			}
+
+			issues, err := Validate(c.Context, k8sClient)
+			if err != nil {
+				return err
+			}
+
+			for _, issue := range issues {
+				fmt.Fprintln(c.App.Writer, issue)
+			}
+
+			if len(issues) > 0 {
+				return cli.Exit(fmt.Sprintf("found %d issue(s)", len(issues)), 1)
+			}
+
+			fmt.Fprintln(c.App.Writer, "No issues found")
+
+			return nil
+		},
+	}
+}
+
+// Validate inspects every secret and configmap in the cluster and returns
+// any problems found with their replikator annotations.
+func Validate(ctx context.Context, c client.Client) ([]Issue, error) {
+	var namespaces corev1.NamespaceList
+	if err := c.List(ctx, &namespaces); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	objects, err := listObjects(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	for _, obj := range objects {
+		ref := fmt.Sprintf("%s %s/%s", kindOf(obj), obj.GetNamespace(), obj.GetName())
+
+		errs, warnings := controller.ValidateAnnotations(obj)
+		for _, msg := range append(errs, warnings...) {
+			issues = append(issues, Issue{Object: ref, Message: msg})
+		}
+
+		replicateTo, hasReplicateTo := obj.GetAnnotations()[api.Key(api.AnnotationReplicateTo)]
+		validFilters := !hasReplicateTo || api.ValidateFilters(replicateTo) == nil
+
+		if api.IsReplicationEnabled(obj) && validFilters {
+			var matched int
+			for _, namespace := range namespaces.Items {
+				if ok, err := api.ShouldReplicateTo(obj, namespace.Name); err == nil && ok {
+					matched++
+				}
+			}
+
+			if matched == 0 {
+				issues = append(issues, Issue{Object: ref, Message: "replication is enabled but no namespaces match"})
+			}
+		}
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,111 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/commands"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a",
+		},
+	}
+
+	newSecret := func(annotations map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-secret",
+				Namespace:   "test-namespace",
+				Annotations: annotations,
+			},
+		}
+	}
+
+	t.Run("Should Accept Valid Annotations", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(namespace, newSecret(map[string]string{
+				api.Key(api.AnnotationEnabled):       "true",
+				api.Key(api.AnnotationReplicateTo):   "team-*",
+				api.Key(api.AnnotationReplicateKeys): "ca*",
+			})).
+			Build()
+
+		issues, err := commands.Validate(ctx, client)
+		require.NoError(t, err)
+
+		assert.Empty(t, issues)
+	})
+
+	t.Run("Should Report Unknown Annotations", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(namespace, newSecret(map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+				api.KeyPrefix() + "replicate":  "team-*",
+			})).
+			Build()
+
+		issues, err := commands.Validate(ctx, client)
+		require.NoError(t, err)
+
+		require.Len(t, issues, 1)
+		assert.Contains(t, issues[0].Message, "unknown annotation")
+	})
+
+	t.Run("Should Report Malformed Filters", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(namespace, newSecret(map[string]string{
+				api.Key(api.AnnotationEnabled):     "true",
+				api.Key(api.AnnotationReplicateTo): "team-[",
+			})).
+			Build()
+
+		issues, err := commands.Validate(ctx, client)
+		require.NoError(t, err)
+
+		require.Len(t, issues, 1)
+		assert.Contains(t, issues[0].Message, "malformed")
+	})
+
+	t.Run("Should Report Sources Matching No Namespaces", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(namespace, newSecret(map[string]string{
+				api.Key(api.AnnotationEnabled):     "true",
+				api.Key(api.AnnotationReplicateTo): "team-b",
+			})).
+			Build()
+
+		issues, err := commands.Validate(ctx, client)
+		require.NoError(t, err)
+
+		require.Len(t, issues, 1)
+		assert.Contains(t, issues[0].Message, "no namespaces match")
+	})
+}
@@ -0,0 +1,75 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// FieldManager is the field manager replicas are applied as.
+const FieldManager = "replikator"
+
+// applyReplica creates or updates the desired replica with server-side apply,
+// taking ownership of every field set by the replica template. Fields added
+// to the replica by other field managers are left alone.
+func applyReplica(ctx context.Context, c client.Client, desired client.Object) error {
+	gvk, err := apiutil.GVKForObject(desired, c.Scheme())
+	if err != nil {
+		return fmt.Errorf("failed to determine kind of replica: %w", err)
+	}
+
+	desired.GetObjectKind().SetGroupVersionKind(gvk)
+
+	// Apply unconditionally, rather than failing if the replica was modified
+	// since it was read.
+	desired.SetResourceVersion("")
+	desired.SetManagedFields(nil)
+
+	return c.Patch(ctx, desired, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+}
+
+// removesFields returns true if the desired replica drops data keys or
+// replikator annotations present on the existing replica. These fields may
+// be owned by a previous update (rather than apply), in which case applying
+// the desired replica wouldn't remove them, so it must be written in full.
+func removesFields(existing, desired client.Object) bool {
+	desiredData := objectData(desired)
+	for key := range objectData(existing) {
+		if _, ok := desiredData[key]; !ok {
+			return true
+		}
+	}
+
+	for key := range existing.GetAnnotations() {
+		if !strings.HasPrefix(key, api.KeyPrefix()) {
+			continue
+		}
+
+		if _, ok := desired.GetAnnotations()[key]; !ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,266 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/internal/features"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestServerSideApply(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, true)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		Data: map[string][]byte{
+			"username": []byte("admin"),
+			"password": []byte("hunter2"),
+		},
+	}
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	replicaKey := types.NamespacedName{
+		Name:      secret.Name,
+		Namespace: anotherNamespace.Name,
+	}
+
+	ctx := context.Background()
+
+	reconcileSecret := func(t *testing.T, client ctrlclient.Client) {
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: ctrlclient.ObjectKeyFromObject(secret),
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("Should Create Replicas With Server-Side Apply", func(t *testing.T) {
+		client, applied := newApplyClient(secret, anotherNamespace)
+
+		reconcileSecret(t, client)
+
+		require.Len(t, *applied, 1)
+		assert.Equal(t, controller.FieldManager, (*applied)[0].FieldManager)
+		assert.True(t, (*applied)[0].Force)
+		assert.Equal(t, "Secret", (*applied)[0].Object.GetObjectKind().GroupVersionKind().Kind)
+
+		var replica corev1.Secret
+		require.NoError(t, client.Get(ctx, replicaKey, &replica))
+		assert.Equal(t, secret.Data, replica.Data)
+		assert.True(t, api.IsReplica(&replica))
+	})
+
+	t.Run("Should Keep Fields Added By Other Managers", func(t *testing.T) {
+		client, applied := newApplyClient(secret, anotherNamespace)
+
+		reconcileSecret(t, client)
+
+		// Another controller labels and annotates the replica.
+		var replica corev1.Secret
+		require.NoError(t, client.Get(ctx, replicaKey, &replica))
+
+		if replica.Labels == nil {
+			replica.Labels = map[string]string{}
+		}
+		replica.Labels["example.com/team"] = "team-a"
+		replica.Annotations["example.com/owner"] = "team-a"
+		require.NoError(t, client.Update(ctx, &replica))
+
+		// The source gains a key, so the replica is rewritten.
+		var source corev1.Secret
+		require.NoError(t, client.Get(ctx, ctrlclient.ObjectKeyFromObject(secret), &source))
+
+		source.Data["token"] = []byte("abc123")
+		require.NoError(t, client.Update(ctx, &source))
+
+		reconcileSecret(t, client)
+
+		require.NoError(t, client.Get(ctx, replicaKey, &replica))
+		assert.Equal(t, []byte("abc123"), replica.Data["token"])
+		assert.Equal(t, "team-a", replica.Labels["example.com/team"])
+		assert.Equal(t, "team-a", replica.Annotations["example.com/owner"])
+
+		require.Len(t, *applied, 2)
+	})
+
+	t.Run("Should Fall Back To A Full Update When Removing Keys", func(t *testing.T) {
+		client, applied := newApplyClient(secret, anotherNamespace)
+
+		reconcileSecret(t, client)
+
+		var source corev1.Secret
+		require.NoError(t, client.Get(ctx, ctrlclient.ObjectKeyFromObject(secret), &source))
+
+		source.Annotations[api.Key(api.AnnotationReplicateKeys)] = "username"
+		require.NoError(t, client.Update(ctx, &source))
+
+		reconcileSecret(t, client)
+
+		// Applying wouldn't remove the dropped key, so the replica is updated.
+		require.Len(t, *applied, 1)
+
+		var replica corev1.Secret
+		require.NoError(t, client.Get(ctx, replicaKey, &replica))
+		assert.Equal(t, map[string][]byte{"username": []byte("admin")}, replica.Data)
+	})
+
+	t.Run("Should Replace Replicas When Disabled", func(t *testing.T) {
+		setServerSideApply(t, false)
+
+		client, applied := newApplyClient(secret, anotherNamespace)
+
+		reconcileSecret(t, client)
+
+		assert.Empty(t, *applied)
+
+		var replica corev1.Secret
+		require.NoError(t, client.Get(ctx, replicaKey, &replica))
+		assert.Equal(t, secret.Data, replica.Data)
+	})
+}
+
+// setServerSideApply enables or disables server-side apply for the duration of
+// the test. The fake client doesn't support server-side apply, so tests using
+// it directly must write replicas with full updates instead.
+func setServerSideApply(t *testing.T, enabled bool) {
+	previous := features.Enabled(features.ServerSideApply)
+
+	require.NoError(t, features.DefaultFeatureGate.Set(fmt.Sprintf("%s=%t", features.ServerSideApply, enabled)))
+	t.Cleanup(func() {
+		require.NoError(t, features.DefaultFeatureGate.Set(fmt.Sprintf("%s=%t", features.ServerSideApply, previous)))
+	})
+}
+
+// appliedPatch is a server-side apply patch received by the fake client.
+type appliedPatch struct {
+	Object       ctrlclient.Object
+	FieldManager string
+	Force        bool
+}
+
+// newApplyClient returns a fake client that emulates server-side apply (which
+// the fake client doesn't support), along with the apply patches it received.
+// Fields set by an apply patch are merged into the existing object, fields
+// that it doesn't set are left alone.
+func newApplyClient(objects ...ctrlclient.Object) (ctrlclient.Client, *[]appliedPatch) {
+	var applied []appliedPatch
+
+	client := fake.NewClientBuilder().
+		WithObjects(objects...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, patch ctrlclient.Patch, opts ...ctrlclient.PatchOption) error {
+				if patch.Type() != types.ApplyPatchType {
+					return c.Patch(ctx, obj, patch, opts...)
+				}
+
+				patchOpts := &ctrlclient.PatchOptions{}
+				patchOpts.ApplyOptions(opts)
+
+				applied = append(applied, appliedPatch{
+					Object:       obj.DeepCopyObject().(ctrlclient.Object),
+					FieldManager: patchOpts.FieldManager,
+					Force:        patchOpts.Force != nil && *patchOpts.Force,
+				})
+
+				existing := obj.DeepCopyObject().(ctrlclient.Object)
+				if err := c.Get(ctx, ctrlclient.ObjectKeyFromObject(obj), existing); err != nil {
+					if !apierrors.IsNotFound(err) {
+						return err
+					}
+
+					return c.Create(ctx, obj)
+				}
+
+				existingFields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+				if err != nil {
+					return err
+				}
+
+				appliedFields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+				if err != nil {
+					return err
+				}
+
+				mergeFields(existingFields, appliedFields)
+
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(existingFields, obj); err != nil {
+					return err
+				}
+
+				return c.Update(ctx, obj)
+			},
+		}).
+		Build()
+
+	return client, &applied
+}
+
+// mergeFields recursively sets the (non-nil) fields of src on dst.
+func mergeFields(dst, src map[string]any) {
+	for key, value := range src {
+		if value == nil {
+			continue
+		}
+
+		if srcMap, ok := value.(map[string]any); ok {
+			if dstMap, ok := dst[key].(map[string]any); ok {
+				mergeFields(dstMap, srcMap)
+				continue
+			}
+		}
+
+		dst[key] = value
+	}
+}
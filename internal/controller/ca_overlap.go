@@ -0,0 +1,178 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"time"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// caOverlapKey is the key of the CA certificates retained across rotations.
+const caOverlapKey = "ca.crt"
+
+// retainPreviousCA keeps the CA certificates that were removed from a source
+// annotated with ca-overlap in its replicas, for the grace period of the
+// annotation (or until they expire, if sooner), so that clients presenting
+// certificates issued by the previous CA keep working while they are reissued.
+// The desired replica is modified in place, and the time at which the retained
+// certificates must next be pruned is returned (zero if none are retained).
+//
+// Certificates are only retained from existing replicas whose ca.crt is
+// unmodified since it was last written by replikator (according to the
+// checksum recorded on the replica), so tampering with a replica never causes
+// additional certificates to be trusted.
+func retainPreviousCA(source, existing, desired client.Object, now time.Time) time.Time {
+	value, ok := api.GetAnnotation(source, api.Key(api.AnnotationCAOverlap))
+	if !ok {
+		return time.Time{}
+	}
+
+	grace, err := time.ParseDuration(value)
+	if err != nil || grace <= 0 {
+		return time.Time{}
+	}
+
+	current, ok := objectData(desired)[caOverlapKey]
+	if !ok {
+		return time.Time{}
+	}
+
+	var retained [][]byte
+	var until, next time.Time
+
+	if existing != nil {
+		previous := objectData(existing)[caOverlapKey]
+		if caChecksum(previous) == existing.GetAnnotations()[api.Key(api.AnnotationCAChecksum)] {
+			currentCerts := pemCertificates(current)
+			previousCerts := pemCertificates(previous)
+
+			if !containsAll(previousCerts, currentCerts) {
+				// The CA has (just) been rotated.
+				until = now.Add(grace)
+			} else if t, err := time.Parse(time.RFC3339, existing.GetAnnotations()[api.Key(api.AnnotationCAOverlapUntil)]); err == nil {
+				until = t
+			}
+
+			for _, der := range previousCerts {
+				if containsAll(currentCerts, [][]byte{der}) {
+					continue
+				}
+
+				cert, err := x509.ParseCertificate(der)
+				if err != nil || !now.Before(until) || !now.Before(cert.NotAfter) {
+					continue
+				}
+
+				retained = append(retained, der)
+
+				expiry := until
+				if cert.NotAfter.Before(expiry) {
+					expiry = cert.NotAfter
+				}
+
+				if next.IsZero() || expiry.Before(next) {
+					next = expiry
+				}
+			}
+		}
+	}
+
+	bundle := bytes.Clone(current)
+	for _, der := range retained {
+		if len(bundle) > 0 && !bytes.HasSuffix(bundle, []byte("\n")) {
+			bundle = append(bundle, '\n')
+		}
+
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+
+	switch desired := desired.(type) {
+	case *corev1.Secret:
+		desired.Data[caOverlapKey] = bundle
+	case *corev1.ConfigMap:
+		if _, ok := desired.BinaryData[caOverlapKey]; ok {
+			desired.BinaryData[caOverlapKey] = bundle
+		} else {
+			desired.Data[caOverlapKey] = string(bundle)
+		}
+	}
+
+	annotations := desired.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[api.Key(api.AnnotationCAChecksum)] = caChecksum(bundle)
+	if len(retained) > 0 {
+		annotations[api.Key(api.AnnotationCAOverlapUntil)] = until.UTC().Format(time.RFC3339)
+	} else {
+		delete(annotations, api.Key(api.AnnotationCAOverlapUntil))
+	}
+
+	desired.SetAnnotations(annotations)
+
+	return next
+}
+
+// pemCertificates returns the DER encoded certificates in the PEM bundle.
+func pemCertificates(data []byte) [][]byte {
+	var certs [][]byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, block.Bytes)
+		}
+	}
+}
+
+// containsAll returns true if every certificate in subset is in certs.
+func containsAll(certs, subset [][]byte) bool {
+	for _, der := range subset {
+		var found bool
+		for _, other := range certs {
+			if bytes.Equal(der, other) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func caChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,167 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestCAOverlap(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	previousCA := generateCA(t, "previous", time.Now().Add(365*24*time.Hour))
+	currentCA := generateCA(t, "current", time.Now().Add(365*24*time.Hour))
+
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-ca",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled):   "true",
+				api.Key(api.AnnotationCAOverlap): "24h",
+			},
+		},
+		Data: map[string][]byte{
+			"ca.crt": previousCA,
+		},
+	}
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	ctx := context.Background()
+
+	client := fake.NewClientBuilder().
+		WithObjects(source, anotherNamespace).
+		Build()
+
+	r := &controller.SecretReconciler{
+		Client: client,
+		Scheme: scheme.Scheme,
+	}
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: source.Name, Namespace: source.Namespace}}
+
+	getReplica := func(t *testing.T) *corev1.Secret {
+		var replica corev1.Secret
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: source.Name, Namespace: anotherNamespace.Name}, &replica))
+
+		return &replica
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	assert.Equal(t, previousCA, getReplica(t).Data["ca.crt"])
+
+	// Rotate the CA.
+	var rotated corev1.Secret
+	require.NoError(t, client.Get(ctx, req.NamespacedName, &rotated))
+	rotated.Data["ca.crt"] = currentCA
+	require.NoError(t, client.Update(ctx, &rotated))
+
+	t.Run("Should Retain The Previous CA After Rotation", func(t *testing.T) {
+		resp, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		assert.InDelta(t, (24 * time.Hour).Seconds(), resp.RequeueAfter.Seconds(), 60)
+
+		replica := getReplica(t)
+		assert.Equal(t, append(append([]byte{}, currentCA...), previousCA...), replica.Data["ca.crt"])
+		assert.NotEmpty(t, replica.Annotations[api.Key(api.AnnotationCAOverlapUntil)])
+
+		// The overlap isn't reported as drift.
+		drift, err := controller.DiffSource(ctx, client, controller.Policy{}, &rotated)
+		require.NoError(t, err)
+		assert.Empty(t, drift)
+	})
+
+	t.Run("Should Prune The Previous CA After The Grace Period", func(t *testing.T) {
+		replica := getReplica(t)
+		replica.Annotations[api.Key(api.AnnotationCAOverlapUntil)] = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+		require.NoError(t, client.Update(ctx, replica))
+
+		resp, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		replica = getReplica(t)
+		assert.Equal(t, currentCA, replica.Data["ca.crt"])
+		assert.NotContains(t, replica.Annotations, api.Key(api.AnnotationCAOverlapUntil))
+	})
+
+	t.Run("Should Not Retain Certificates Added To A Replica", func(t *testing.T) {
+		replica := getReplica(t)
+		replica.Data["ca.crt"] = append(append([]byte{}, currentCA...), generateCA(t, "rogue", time.Now().Add(time.Hour))...)
+		require.NoError(t, client.Update(ctx, replica))
+
+		_, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		assert.Equal(t, currentCA, getReplica(t).Data["ca.crt"])
+	})
+}
+
+// generateCA returns a PEM encoded self-signed CA certificate.
+func generateCA(t *testing.T, commonName string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
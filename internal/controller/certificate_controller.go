@@ -0,0 +1,198 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch
+
+// CertificateGVK is the group, version and kind of cert-manager Certificates.
+var CertificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// certManagerCertificateNameAnnotation is the annotation cert-manager records
+// the name of the Certificate that issued a secret in.
+const certManagerCertificateNameAnnotation = "cert-manager.io/certificate-name"
+
+// CertificateReconciler copies the replikator annotations of cert-manager
+// Certificates to the secrets they issue, so that replication can be enabled
+// on a Certificate (rather than on a secret that doesn't yet exist). Secrets
+// are annotated once issued, and if the secret name of a Certificate changes
+// (or its annotations are removed) replication of the previous secret is
+// disabled.
+type CertificateReconciler struct {
+	client.Client
+}
+
+func (r *CertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+
+	certificate := newCertificate()
+	if err := r.Get(ctx, req.NamespacedName, certificate); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, r.releaseSecrets(ctx, logger, req.NamespacedName, "")
+	}
+
+	annotations := replikatorAnnotations(certificate.GetAnnotations())
+	secretName, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+
+	if len(annotations) == 0 || !certificate.GetDeletionTimestamp().IsZero() || secretName == "" {
+		return ctrl.Result{}, r.releaseSecrets(ctx, logger, req.NamespacedName, "")
+	}
+
+	// Secrets issued for a previous secret name are no longer replicated.
+	if err := r.releaseSecrets(ctx, logger, req.NamespacedName, secretName); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: secretName}, &secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The secret is annotated once it has been issued.
+			logger.Debug("Waiting for certificate to be issued", "secret", secretName)
+
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	if owner, ok := secret.Annotations[api.Key(api.AnnotationCertificate)]; ok && owner != req.Name {
+		logger.Warn("Secret is already managed by another certificate", "secret", secretName, "certificate", owner)
+
+		return ctrl.Result{}, nil
+	}
+
+	logger.Debug("Copying annotations to secret", "secret", secretName)
+
+	patch := client.MergeFrom(secret.DeepCopy())
+
+	for key := range replikatorAnnotations(secret.Annotations) {
+		if _, ok := annotations[key]; !ok {
+			delete(secret.Annotations, key)
+		}
+	}
+
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+
+	for key, value := range annotations {
+		secret.Annotations[key] = value
+	}
+	secret.Annotations[api.Key(api.AnnotationCertificate)] = req.Name
+
+	if err := r.Patch(ctx, &secret, patch); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to annotate secret: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *CertificateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("certificate-controller").
+		For(newCertificate()).
+		// Requeue the certificate when the secret it issued is created or modified.
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(func(_ context.Context, obj client.Object) []ctrl.Request {
+			var reqs []ctrl.Request
+			for _, key := range []string{certManagerCertificateNameAnnotation, api.Key(api.AnnotationCertificate)} {
+				if name, ok := obj.GetAnnotations()[key]; ok {
+					reqs = append(reqs, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}})
+				}
+			}
+
+			return reqs
+		})).
+		Complete(r)
+}
+
+// releaseSecrets removes the replikator annotations copied from the
+// certificate from every secret in its namespace, other than the named secret.
+func (r *CertificateReconciler) releaseSecrets(ctx context.Context, logger *slog.Logger, certificate types.NamespacedName, except string) error {
+	var secrets corev1.SecretList
+	if err := r.List(ctx, &secrets, client.InNamespace(certificate.Namespace)); err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if secret.Name == except || secret.Annotations[api.Key(api.AnnotationCertificate)] != certificate.Name {
+			continue
+		}
+
+		logger.Info("Disabling replication of secret no longer issued for certificate", "secret", secret.Name)
+
+		patch := client.MergeFrom(secret.DeepCopy())
+
+		for key := range replikatorAnnotations(secret.Annotations) {
+			delete(secret.Annotations, key)
+		}
+		delete(secret.Annotations, api.Key(api.AnnotationCertificate))
+
+		if err := r.Patch(ctx, secret, patch); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to remove annotations from secret: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// replikatorAnnotations returns the replikator (and legacy) annotations, other
+// than the certificate annotation itself and provenance (which is recorded by
+// the provenance webhook).
+func replikatorAnnotations(annotations map[string]string) map[string]string {
+	filtered := make(map[string]string)
+	for key, value := range annotations {
+		if key == api.Key(api.AnnotationCertificate) || key == api.Key(api.AnnotationEnabledBy) {
+			continue
+		}
+
+		if strings.HasPrefix(key, api.KeyPrefix()) || api.IsLegacyAnnotation(key) {
+			filtered[key] = value
+		}
+	}
+
+	return filtered
+}
+
+func newCertificate() *unstructured.Unstructured {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(CertificateGVK)
+
+	return certificate
+}
@@ -0,0 +1,184 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestCertificateReconciler(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(controller.CertificateGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(controller.CertificateGVK.GroupVersion().WithKind("CertificateList"), &unstructured.UnstructuredList{})
+
+	newCertificate := func(secretName string, annotations map[string]string) *unstructured.Unstructured {
+		certificate := &unstructured.Unstructured{}
+		certificate.SetGroupVersionKind(controller.CertificateGVK)
+		certificate.SetName("test-certificate")
+		certificate.SetNamespace("test-namespace")
+		certificate.SetAnnotations(annotations)
+		require.NoError(t, unstructured.SetNestedField(certificate.Object, secretName, "spec", "secretName"))
+
+		return certificate
+	}
+
+	newSecret := func(name string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					"cert-manager.io/certificate-name": "test-certificate",
+				},
+			},
+			Type: corev1.SecretTypeTLS,
+		}
+	}
+
+	annotations := map[string]string{
+		api.Key(api.AnnotationEnabled):     "true",
+		api.Key(api.AnnotationReplicateTo): "app-*",
+		"example.com/unrelated":            "true",
+	}
+
+	ctx := context.Background()
+
+	reconcileCertificate := func(t *testing.T, client ctrlclient.Client) {
+		r := &controller.CertificateReconciler{Client: client}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      "test-certificate",
+				Namespace: "test-namespace",
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	getSecret := func(t *testing.T, client ctrlclient.Client, name string) *corev1.Secret {
+		var secret corev1.Secret
+		err := client.Get(ctx, types.NamespacedName{Name: name, Namespace: "test-namespace"}, &secret)
+		require.NoError(t, err)
+
+		return &secret
+	}
+
+	t.Run("Should Wait For Issuance", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(newCertificate("test-secret", annotations)).
+			Build()
+
+		reconcileCertificate(t, client)
+
+		require.NoError(t, client.Create(ctx, newSecret("test-secret")))
+
+		reconcileCertificate(t, client)
+
+		secret := getSecret(t, client, "test-secret")
+		assert.True(t, api.IsReplicationEnabled(secret))
+		assert.Equal(t, "app-*", secret.Annotations[api.Key(api.AnnotationReplicateTo)])
+		assert.Equal(t, "test-certificate", secret.Annotations[api.Key(api.AnnotationCertificate)])
+		assert.NotContains(t, secret.Annotations, "example.com/unrelated")
+	})
+
+	t.Run("Should Follow Secret Name Changes", func(t *testing.T) {
+		certificate := newCertificate("test-secret", annotations)
+
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(certificate, newSecret("test-secret"), newSecret("renamed-secret")).
+			Build()
+
+		reconcileCertificate(t, client)
+
+		require.NoError(t, client.Get(ctx, ctrlclient.ObjectKeyFromObject(certificate), certificate))
+		require.NoError(t, unstructured.SetNestedField(certificate.Object, "renamed-secret", "spec", "secretName"))
+		require.NoError(t, client.Update(ctx, certificate))
+
+		reconcileCertificate(t, client)
+
+		previous := getSecret(t, client, "test-secret")
+		assert.False(t, api.IsReplicationEnabled(previous))
+		assert.NotContains(t, previous.Annotations, api.Key(api.AnnotationReplicateTo))
+		assert.NotContains(t, previous.Annotations, api.Key(api.AnnotationCertificate))
+
+		assert.True(t, api.IsReplicationEnabled(getSecret(t, client, "renamed-secret")))
+	})
+
+	t.Run("Should Disable Replication When Annotations Are Removed", func(t *testing.T) {
+		certificate := newCertificate("test-secret", annotations)
+
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(certificate, newSecret("test-secret")).
+			Build()
+
+		reconcileCertificate(t, client)
+
+		require.NoError(t, client.Get(ctx, ctrlclient.ObjectKeyFromObject(certificate), certificate))
+		certificate.SetAnnotations(map[string]string{api.Key(api.AnnotationReplicateTo): "app-*"})
+		require.NoError(t, client.Update(ctx, certificate))
+
+		reconcileCertificate(t, client)
+
+		secret := getSecret(t, client, "test-secret")
+		assert.False(t, api.IsReplicationEnabled(secret))
+		assert.Equal(t, "app-*", secret.Annotations[api.Key(api.AnnotationReplicateTo)])
+	})
+
+	t.Run("Should Disable Replication When The Certificate Is Deleted", func(t *testing.T) {
+		certificate := newCertificate("test-secret", annotations)
+
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(certificate, newSecret("test-secret")).
+			Build()
+
+		reconcileCertificate(t, client)
+
+		require.NoError(t, client.Delete(ctx, certificate))
+
+		reconcileCertificate(t, client)
+
+		secret := getSecret(t, client, "test-secret")
+		assert.False(t, api.IsReplicationEnabled(secret))
+		assert.NotContains(t, secret.Annotations, api.Key(api.AnnotationCertificate))
+	})
+}
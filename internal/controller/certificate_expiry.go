@@ -0,0 +1,120 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"log/slog"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// certificateExpiryWarnings are the points before the certificate of a source
+// expires at which increasingly urgent warnings are recorded (in descending order).
+var certificateExpiryWarnings = []struct {
+	within time.Duration
+	label  string
+}{
+	{7 * 24 * time.Hour, "7 days"},
+	{24 * time.Hour, "24 hours"},
+	{time.Hour, "1 hour"},
+}
+
+// expiredCertificateInterval is how often a warning is repeated once the
+// certificate of a source has expired without being renewed.
+const expiredCertificateInterval = time.Hour
+
+// certificateExpiry returns the time at which the leaf certificate in the
+// tls.crt key of the object expires (if it has a well-formed one).
+func certificateExpiry(obj client.Object) (time.Time, bool) {
+	data, ok := objectData(obj)[corev1.TLSCertKey]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	certs, err := parseCertificates(corev1.TLSCertKey, data)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return certs[0].NotAfter, true
+}
+
+// isWithdrawn returns true if the replicas of the source should be removed as
+// its certificate has expired (according to its withdraw-expired annotation).
+func isWithdrawn(source client.Object, now time.Time) bool {
+	if !ShouldWithdrawExpired(source) {
+		return false
+	}
+
+	notAfter, ok := certificateExpiry(source)
+	return ok && !now.Before(notAfter)
+}
+
+// checkCertificateExpiry records escalating warnings as the certificate of the
+// source approaches expiry (and once it has expired), and updates the expired
+// certificate metric. It returns the time at which the source should next be
+// checked (zero if it has no certificate).
+func (r *Reconciler[T]) checkCertificateExpiry(logger *slog.Logger, source client.Object, now time.Time) time.Time {
+	kind := r.Replicator.Kind()
+
+	notAfter, ok := certificateExpiry(source)
+	if !ok {
+		certificateExpired.DeleteLabelValues(kind, source.GetNamespace(), source.GetName())
+
+		return time.Time{}
+	}
+
+	remaining := notAfter.Sub(now)
+	if remaining <= 0 {
+		certificateExpired.WithLabelValues(kind, source.GetNamespace(), source.GetName()).Set(1)
+
+		logger.Warn("Certificate has expired", "notAfter", notAfter)
+
+		if ShouldWithdrawExpired(source) {
+			r.event(source, corev1.EventTypeWarning, EventReasonCertificateExpired,
+				"Certificate expired at %s, withdrawing replicas until it is renewed", notAfter.UTC().Format(time.RFC3339))
+		} else {
+			r.event(source, corev1.EventTypeWarning, EventReasonCertificateExpired,
+				"Certificate expired at %s and is still being replicated", notAfter.UTC().Format(time.RFC3339))
+		}
+
+		return now.Add(expiredCertificateInterval)
+	}
+
+	certificateExpired.WithLabelValues(kind, source.GetNamespace(), source.GetName()).Set(0)
+
+	next := notAfter
+	for i, warning := range certificateExpiryWarnings {
+		if remaining > warning.within {
+			next = notAfter.Add(-warning.within)
+			break
+		}
+
+		// Only the most urgent applicable warning is recorded.
+		if i == len(certificateExpiryWarnings)-1 || remaining > certificateExpiryWarnings[i+1].within {
+			logger.Warn("Certificate is about to expire", "notAfter", notAfter)
+
+			r.event(source, corev1.EventTypeWarning, EventReasonCertificateExpiring,
+				"Certificate expires within %s (at %s)", warning.label, notAfter.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return next
+}
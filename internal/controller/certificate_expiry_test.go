@@ -0,0 +1,183 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestCertificateExpiry(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	ca, caKey, _, _ := issueCertificate(t, "ca", nil, nil, time.Now().Add(365*24*time.Hour))
+	_, _, expiringPEM, _ := issueCertificate(t, "expiring", ca, caKey, time.Now().Add(12*time.Hour))
+	_, _, expiredPEM, _ := issueCertificate(t, "expired", ca, caKey, time.Now().Add(-time.Minute))
+	_, _, renewedPEM, _ := issueCertificate(t, "renewed", ca, caKey, time.Now().Add(90*24*time.Hour))
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	ctx := context.Background()
+
+	newSource := func(cert []byte, withdraw bool) *corev1.Secret {
+		source := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-tls",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled):       "true",
+					api.Key(api.AnnotationReplicateKeys): corev1.TLSCertKey,
+				},
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey: cert,
+			},
+		}
+
+		if withdraw {
+			source.Annotations[api.Key(api.AnnotationWithdrawExpired)] = "true"
+		}
+
+		return source
+	}
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test-tls", Namespace: "test-namespace"}}
+	replicaKey := types.NamespacedName{Name: "test-tls", Namespace: anotherNamespace.Name}
+
+	t.Run("Should Warn As The Certificate Approaches Expiry", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(newSource(expiringPEM, false), anotherNamespace).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.SecretReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+		}
+
+		resp, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		// Requeued to escalate the warning an hour before expiry.
+		assert.InDelta(t, (11 * time.Hour).Seconds(), resp.RequeueAfter.Seconds(), 60)
+
+		require.Len(t, recorder.Events, 1)
+		event := <-recorder.Events
+		assert.Contains(t, event, controller.EventReasonCertificateExpiring)
+		assert.Contains(t, event, "24 hours")
+
+		require.NoError(t, client.Get(ctx, replicaKey, &corev1.Secret{}))
+	})
+
+	t.Run("Should Keep Replicating An Expired Certificate By Default", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(newSource(expiredPEM, false), anotherNamespace).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.SecretReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+		}
+
+		resp, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		assert.InDelta(t, time.Hour.Seconds(), resp.RequeueAfter.Seconds(), 60)
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonCertificateExpired)
+
+		require.NoError(t, client.Get(ctx, replicaKey, &corev1.Secret{}))
+	})
+
+	t.Run("Should Withdraw Replicas Until The Certificate Is Renewed", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(newSource(expiringPEM, true), anotherNamespace).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		_, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		require.NoError(t, client.Get(ctx, replicaKey, &corev1.Secret{}))
+
+		setCertificate := func(t *testing.T, cert []byte) *corev1.Secret {
+			var source corev1.Secret
+			require.NoError(t, client.Get(ctx, req.NamespacedName, &source))
+			source.Data[corev1.TLSCertKey] = cert
+			require.NoError(t, client.Update(ctx, &source))
+
+			return &source
+		}
+
+		source := setCertificate(t, expiredPEM)
+
+		_, err = r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		err = client.Get(ctx, replicaKey, &corev1.Secret{})
+		require.True(t, apierrors.IsNotFound(err))
+
+		// Withdrawn replicas aren't reported as drift.
+		drift, err := controller.DiffSource(ctx, client, controller.Policy{}, source)
+		require.NoError(t, err)
+		assert.Empty(t, drift)
+
+		setCertificate(t, renewedPEM)
+
+		_, err = r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		var replica corev1.Secret
+		require.NoError(t, client.Get(ctx, replicaKey, &replica))
+		assert.Equal(t, renewedPEM, replica.Data[corev1.TLSCertKey])
+	})
+}
@@ -0,0 +1,117 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// validateCertificates checks that the certificate material of a TLS secret is
+// fit to be replicated: tls.crt (and ca.crt, if present) must be well-formed
+// PEM encoded certificates, the leaf certificate must not have expired, tls.key
+// (if present) must match the leaf certificate, and the chain in tls.crt must
+// verify against ca.crt.
+func validateCertificates(secret *corev1.Secret, now time.Time) error {
+	chain, err := parseCertificates(corev1.TLSCertKey, secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		return err
+	}
+
+	leaf := chain[0]
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("%s expired at %s", corev1.TLSCertKey, leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	if key := secret.Data[corev1.TLSPrivateKeyKey]; len(key) > 0 {
+		if _, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], key); err != nil {
+			return fmt.Errorf("%s does not match %s: %w", corev1.TLSPrivateKeyKey, corev1.TLSCertKey, err)
+		}
+	}
+
+	caData, ok := secret.Data[corev1.ServiceAccountRootCAKey]
+	if !ok {
+		return nil
+	}
+
+	cas, err := parseCertificates(corev1.ServiceAccountRootCAKey, caData)
+	if err != nil {
+		return err
+	}
+
+	roots := x509.NewCertPool()
+	for _, ca := range cas {
+		roots.AddCert(ca)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("%s is not issued by %s: %w", corev1.TLSCertKey, corev1.ServiceAccountRootCAKey, err)
+	}
+
+	return nil
+}
+
+// parseCertificates parses the PEM encoded certificates of a key, rejecting
+// blocks of any other type and trailing data.
+func parseCertificates(key string, data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("%s contains an unexpected %s block", key, block.Type)
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s contains a malformed certificate: %w", key, err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(bytes.TrimSpace(data)) > 0 {
+		return nil, fmt.Errorf("%s contains data that is not PEM encoded", key)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s contains no certificates", key)
+	}
+
+	return certs, nil
+}
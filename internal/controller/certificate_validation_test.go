@@ -0,0 +1,191 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestCertificateValidation(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	ca, caKey, caPEM, _ := issueCertificate(t, "ca", nil, nil, time.Now().Add(365*24*time.Hour))
+	_, _, leafPEM, leafKeyPEM := issueCertificate(t, "leaf", ca, caKey, time.Now().Add(30*24*time.Hour))
+	_, _, expiredPEM, expiredKeyPEM := issueCertificate(t, "expired", ca, caKey, time.Now().Add(-time.Minute))
+	_, _, otherCAPEM, _ := issueCertificate(t, "other-ca", nil, nil, time.Now().Add(365*24*time.Hour))
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	ctx := context.Background()
+
+	replicate := func(t *testing.T, data map[string][]byte) (*record.FakeRecorder, error) {
+		source := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-tls",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+				},
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: data,
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(source, anotherNamespace).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.SecretReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+			Policy: controller.Policy{
+				ValidateCertificates: true,
+			},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: source.Name, Namespace: source.Namespace},
+		})
+		require.NoError(t, err)
+
+		return recorder, client.Get(ctx, types.NamespacedName{Name: source.Name, Namespace: anotherNamespace.Name}, &corev1.Secret{})
+	}
+
+	t.Run("Should Replicate Valid Certificates", func(t *testing.T) {
+		recorder, err := replicate(t, map[string][]byte{
+			corev1.TLSCertKey:       leafPEM,
+			corev1.TLSPrivateKeyKey: leafKeyPEM,
+			"ca.crt":                caPEM,
+		})
+		require.NoError(t, err)
+
+		assert.Empty(t, recorder.Events)
+	})
+
+	for _, tc := range []struct {
+		name string
+		data map[string][]byte
+	}{
+		{
+			name: "Malformed",
+			data: map[string][]byte{
+				corev1.TLSCertKey:       []byte("not a certificate"),
+				corev1.TLSPrivateKeyKey: leafKeyPEM,
+			},
+		},
+		{
+			name: "Expired",
+			data: map[string][]byte{
+				corev1.TLSCertKey:       expiredPEM,
+				corev1.TLSPrivateKeyKey: expiredKeyPEM,
+				"ca.crt":                caPEM,
+			},
+		},
+		{
+			name: "Mismatched Key",
+			data: map[string][]byte{
+				corev1.TLSCertKey:       leafPEM,
+				corev1.TLSPrivateKeyKey: expiredKeyPEM,
+			},
+		},
+		{
+			name: "Untrusted",
+			data: map[string][]byte{
+				corev1.TLSCertKey:       leafPEM,
+				corev1.TLSPrivateKeyKey: leafKeyPEM,
+				"ca.crt":                otherCAPEM,
+			},
+		},
+	} {
+		t.Run("Should Refuse "+tc.name+" Certificates", func(t *testing.T) {
+			recorder, err := replicate(t, tc.data)
+			require.True(t, apierrors.IsNotFound(err))
+
+			require.Len(t, recorder.Events, 1)
+			assert.Contains(t, <-recorder.Events, controller.EventReasonInvalidCertificate)
+		})
+	}
+}
+
+// issueCertificate returns a certificate signed by the parent (or self-signed
+// CA certificate if parent is nil), along with its PEM encoding and key.
+func issueCertificate(t *testing.T, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, notAfter time.Time) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
@@ -0,0 +1,133 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/dpeckett/replikator/api/v1alpha1"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups=replikator.pecke.tt,resources=replikatorconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=replikator.pecke.tt,resources=replikatorconfigs/status,verbs=get;update;patch
+
+// ConfigReconciler applies the ReplikatorConfig named "default" to the
+// runtime configuration of the other reconcilers.
+type ConfigReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Runtime  *RuntimeConfig
+	// Events, if set, receives events instead of the recorder.
+	Events *EventBus
+}
+
+func (r *ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+
+	if req.Name != v1alpha1.ReplikatorConfigName {
+		logger.Info("Ignoring config", "expected", v1alpha1.ReplikatorConfigName)
+
+		return ctrl.Result{}, nil
+	}
+
+	var config v1alpha1.ReplikatorConfig
+	if err := r.Get(ctx, req.NamespacedName, &config); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("Config deleted, reverting to command line settings")
+
+			r.Runtime.Set(nil)
+
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	if err := ValidateConfig(&config.Spec); err != nil {
+		logger.Warn("Ignoring invalid config", "error", err)
+
+		recordEvent(r.Events, r.Recorder, &config, corev1.EventTypeWarning, EventReasonInvalidConfig,
+			"Config is invalid and will not be applied: %s", err)
+
+		return ctrl.Result{}, nil
+	}
+
+	logger.Info("Applying config", "generation", config.Generation)
+
+	r.Runtime.Set(&config.Spec)
+
+	if config.Status.ObservedGeneration != config.Generation {
+		config.Status.ObservedGeneration = config.Generation
+		if err := r.Status().Update(ctx, &config); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update status: %w", err)
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// ValidateConfig returns an error if the config contains malformed patterns
+// or negative write rates.
+func ValidateConfig(spec *v1alpha1.ReplikatorConfigSpec) error {
+	for _, pattern := range spec.ProtectedNamespaces {
+		if err := api.ValidateFilters(pattern); err != nil {
+			return fmt.Errorf("invalid protected namespace: %w", err)
+		}
+	}
+
+	for _, pattern := range spec.ExcludeNamespaces {
+		if err := api.ValidateFilters(pattern); err != nil {
+			return fmt.Errorf("invalid excluded namespace: %w", err)
+		}
+	}
+
+	if spec.DefaultReplicateTo != nil && *spec.DefaultReplicateTo != "" {
+		if err := api.ValidateFilters(*spec.DefaultReplicateTo); err != nil {
+			return fmt.Errorf("invalid default replicate-to: %w", err)
+		}
+	}
+
+	for secretType, pattern := range spec.DefaultReplicateKeys {
+		if err := api.ValidateFilters(pattern); err != nil {
+			return fmt.Errorf("invalid default replicate-keys for %s: %w", secretType, err)
+		}
+	}
+
+	if spec.NamespaceWriteRate != nil && spec.NamespaceWriteRate.Sign() < 0 {
+		return fmt.Errorf("invalid namespace write rate: %s is negative", spec.NamespaceWriteRate)
+	}
+
+	return nil
+}
+
+func (r *ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("config-controller").
+		For(&v1alpha1.ReplikatorConfig{}).
+		Complete(r)
+}
@@ -0,0 +1,232 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/api/v1alpha1"
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestConfigReconciler(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		Data: map[string]string{
+			"key": "test-value",
+		},
+	}
+
+	protectedNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "kube-system",
+		},
+	}
+
+	config := &v1alpha1.ReplikatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       v1alpha1.ReplikatorConfigName,
+			Generation: 2,
+		},
+		Spec: v1alpha1.ReplikatorConfigSpec{
+			ProtectedNamespaces: []string{"kube-*"},
+		},
+	}
+
+	ctx := context.Background()
+
+	t.Run("Should Apply Config At Runtime", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(cm, protectedNamespace, config).
+			WithStatusSubresource(config).
+			Build()
+
+		runtimeConfig := controller.NewRuntimeConfig()
+
+		configReconciler := &controller.ConfigReconciler{
+			Client:  client,
+			Runtime: runtimeConfig,
+		}
+
+		_, err := configReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: config.Name},
+		})
+		require.NoError(t, err)
+
+		var updatedConfig v1alpha1.ReplikatorConfig
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: config.Name}, &updatedConfig))
+		assert.Equal(t, updatedConfig.Generation, updatedConfig.Status.ObservedGeneration)
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme,
+			Policy: controller.Policy{Runtime: runtimeConfig},
+		}
+
+		_, err = r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		var replica corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: protectedNamespace.Name,
+		}, &replica)
+		require.Error(t, err)
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Revert When Config Is Deleted", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(cm, protectedNamespace).
+			Build()
+
+		runtimeConfig := controller.NewRuntimeConfig()
+		runtimeConfig.Set(&config.Spec)
+
+		configReconciler := &controller.ConfigReconciler{
+			Client:  client,
+			Runtime: runtimeConfig,
+		}
+
+		_, err := configReconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: config.Name},
+		})
+		require.NoError(t, err)
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme,
+			Policy: controller.Policy{Runtime: runtimeConfig},
+		}
+
+		_, err = r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		var replica corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: protectedNamespace.Name,
+		}, &replica)
+		require.NoError(t, err)
+	})
+
+	t.Run("Should Ignore Invalid Config", func(t *testing.T) {
+		invalidConfig := config.DeepCopy()
+		invalidConfig.Spec.ProtectedNamespaces = []string{"kube-["}
+
+		assert.Error(t, controller.ValidateConfig(&invalidConfig.Spec))
+		assert.NoError(t, controller.ValidateConfig(&config.Spec))
+
+		invalidConfig = config.DeepCopy()
+		invalidConfig.Spec.NamespaceWriteRate = ptr.To(resource.MustParse("-1"))
+
+		assert.Error(t, controller.ValidateConfig(&invalidConfig.Spec))
+	})
+
+	t.Run("Should Apply Fractional Write Rates", func(t *testing.T) {
+		rateLimitedConfig := config.DeepCopy()
+		rateLimitedConfig.Spec.NamespaceWriteRate = ptr.To(resource.MustParse("500m"))
+
+		require.NoError(t, controller.ValidateConfig(&rateLimitedConfig.Spec))
+
+		objects := []ctrlclient.Object{cm}
+		for i := 0; i < 2; i++ {
+			objects = append(objects, &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fmt.Sprintf("team-%d", i),
+				},
+			})
+		}
+
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objects...).
+			Build()
+
+		runtimeConfig := controller.NewRuntimeConfig()
+		runtimeConfig.Set(&rateLimitedConfig.Spec)
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme,
+			Policy: controller.Policy{Runtime: runtimeConfig},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		var replicas corev1.ConfigMapList
+		require.NoError(t, client.List(ctx, &replicas, ctrlclient.MatchingLabels{api.LabelManagedByKey: api.LabelManagedByValue}))
+		assert.Len(t, replicas.Items, 1)
+
+		// At half a write per second, the next write must wait two seconds
+		// (the requeue allows an extra second).
+		assert.InDelta(t, (3 * time.Second).Seconds(), resp.RequeueAfter.Seconds(), 0.5)
+	})
+}
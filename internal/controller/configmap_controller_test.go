@@ -19,9 +19,12 @@ package controller_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
 	"github.com/go-logr/logr"
 	"github.com/neilotoole/slogt"
 	"github.com/stretchr/testify/assert"
@@ -31,20 +34,25 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func TestConfigMapReconciler(t *testing.T) {
 	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
 
+	setServerSideApply(t, false)
+
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test-configmap",
 			Namespace: "test-namespace",
 			Annotations: map[string]string{
-				controller.AnnotationEnabledKey: "true",
+				api.Key(api.AnnotationEnabled): "true",
 			},
 		},
 		Data: map[string]string{
@@ -92,7 +100,7 @@ func TestConfigMapReconciler(t *testing.T) {
 
 	t.Run("Should Not Replicate When Not Enabled", func(t *testing.T) {
 		unreplicateConfigMap := cm.DeepCopy()
-		delete(unreplicateConfigMap.Annotations, controller.AnnotationEnabledKey)
+		delete(unreplicateConfigMap.Annotations, api.Key(api.AnnotationEnabled))
 
 		client := fake.NewClientBuilder().
 			WithObjects(unreplicateConfigMap, anotherNamespace).
@@ -121,9 +129,52 @@ func TestConfigMapReconciler(t *testing.T) {
 		assert.True(t, apierrors.IsNotFound(err))
 	})
 
+	t.Run("Should Delete Replicas When Disabled", func(t *testing.T) {
+		disabledConfigMap := cm.DeepCopy()
+		disabledConfigMap.Annotations[api.Key(api.AnnotationEnabled)] = "false"
+		disabledConfigMap.Finalizers = []string{api.Finalizer()}
+
+		replica, err := api.ConfigMapTemplate(cm, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = anotherNamespace.Name
+
+		client := fake.NewClientBuilder().
+			WithObjects(disabledConfigMap, anotherNamespace, replica).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      disabledConfigMap.Name,
+				Namespace: disabledConfigMap.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      disabledConfigMap.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.Error(t, err)
+		assert.True(t, apierrors.IsNotFound(err))
+
+		var updatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, ctrlclient.ObjectKeyFromObject(disabledConfigMap), &updatedConfigMap)
+		require.NoError(t, err)
+
+		assert.Empty(t, updatedConfigMap.Finalizers)
+	})
+
 	t.Run("Should Only Replicate Specified Keys", func(t *testing.T) {
 		configMapWithKeys := cm.DeepCopy()
-		configMapWithKeys.Annotations[controller.AnnotationReplicateKeysKey] = "ca*"
+		configMapWithKeys.Annotations[api.Key(api.AnnotationReplicateKeys)] = "key-*"
 
 		client := fake.NewClientBuilder().
 			WithObjects(configMapWithKeys, anotherNamespace).
@@ -150,9 +201,165 @@ func TestConfigMapReconciler(t *testing.T) {
 		}, &replicatedConfigMap)
 		require.NoError(t, err)
 
-		assert.Empty(t, replicatedConfigMap.Data[corev1.TLSCertKey])
-		assert.Empty(t, replicatedConfigMap.Data[corev1.TLSPrivateKeyKey])
-		assert.Equal(t, cm.Data["ca.crt"], replicatedConfigMap.Data["ca.crt"])
+		assert.Equal(t, map[string]string{"key-2": cm.Data["key-2"]}, replicatedConfigMap.Data)
+	})
+
+	t.Run("Should Replicate Binary Data", func(t *testing.T) {
+		binaryConfigMap := cm.DeepCopy()
+		binaryConfigMap.BinaryData = map[string][]byte{
+			"binary-key":   {0x00, 0xff},
+			"binary-key-2": {0xde, 0xad},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(binaryConfigMap, anotherNamespace).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		req := reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		}
+
+		_, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		replicaKey := types.NamespacedName{Name: cm.Name, Namespace: anotherNamespace.Name}
+
+		var replicatedConfigMap corev1.ConfigMap
+		require.NoError(t, client.Get(ctx, replicaKey, &replicatedConfigMap))
+
+		assert.Equal(t, cm.Data, replicatedConfigMap.Data)
+		assert.Equal(t, binaryConfigMap.BinaryData, replicatedConfigMap.BinaryData)
+
+		// Tampering with the binary data of a replica is repaired.
+		replicatedConfigMap.BinaryData["binary-key"] = []byte{0x01}
+		require.NoError(t, client.Update(ctx, &replicatedConfigMap))
+
+		_, err = r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		require.NoError(t, client.Get(ctx, replicaKey, &replicatedConfigMap))
+		assert.Equal(t, binaryConfigMap.BinaryData, replicatedConfigMap.BinaryData)
+	})
+
+	t.Run("Should Only Replicate Specified Binary Keys", func(t *testing.T) {
+		binaryConfigMap := cm.DeepCopy()
+		binaryConfigMap.Annotations[api.Key(api.AnnotationReplicateKeys)] = "*-2"
+		binaryConfigMap.BinaryData = map[string][]byte{
+			"binary-key":   {0x00, 0xff},
+			"binary-key-2": {0xde, 0xad},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(binaryConfigMap, anotherNamespace).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{"key-2": cm.Data["key-2"]}, replicatedConfigMap.Data)
+		assert.Equal(t, map[string][]byte{"binary-key-2": {0xde, 0xad}}, replicatedConfigMap.BinaryData)
+	})
+
+	t.Run("Should Replicate When Only Binary Keys Match", func(t *testing.T) {
+		binaryConfigMap := cm.DeepCopy()
+		binaryConfigMap.Annotations[api.Key(api.AnnotationReplicateKeys)] = "binary-*"
+		binaryConfigMap.BinaryData = map[string][]byte{
+			"binary-key": {0x00, 0xff},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(binaryConfigMap, anotherNamespace).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.NoError(t, err)
+
+		assert.Empty(t, replicatedConfigMap.Data)
+		assert.Equal(t, binaryConfigMap.BinaryData, replicatedConfigMap.BinaryData)
+	})
+
+	t.Run("Should Not Replicate When No Keys Match", func(t *testing.T) {
+		filteredConfigMap := cm.DeepCopy()
+		filteredConfigMap.Annotations[api.Key(api.AnnotationReplicateKeys)] = "missing-*"
+
+		replica, err := api.ConfigMapTemplate(cm, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = anotherNamespace.Name
+
+		recorder := record.NewFakeRecorder(10)
+
+		client := fake.NewClientBuilder().
+			WithObjects(filteredConfigMap, anotherNamespace, replica).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      filteredConfigMap.Name,
+				Namespace: filteredConfigMap.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      filteredConfigMap.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.Error(t, err)
+		assert.True(t, apierrors.IsNotFound(err))
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonEmptyReplica)
 	})
 
 	t.Run("Should Only Replicate To Specified Namespaces", func(t *testing.T) {
@@ -163,7 +370,7 @@ func TestConfigMapReconciler(t *testing.T) {
 		}
 
 		configMapWithNamespaces := cm.DeepCopy()
-		configMapWithNamespaces.Annotations[controller.AnnotationReplicateToKey] = "third-*"
+		configMapWithNamespaces.Annotations[api.Key(api.AnnotationReplicateTo)] = "third-*"
 
 		client := fake.NewClientBuilder().
 			WithObjects(configMapWithNamespaces, anotherNamespace, thirdNamespace).
@@ -197,4 +404,568 @@ func TestConfigMapReconciler(t *testing.T) {
 		}, &replicatedConfigMap)
 		require.Error(t, err)
 	})
+
+	t.Run("Should Not Replicate To Protected Namespaces", func(t *testing.T) {
+		kubeSystem := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "kube-system",
+			},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace, kubeSystem).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.ConfigMapReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+			Policy: controller.Policy{
+				ProtectedNamespaces: []string{"kube-*"},
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.NoError(t, err)
+
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: kubeSystem.Name,
+		}, &replicatedConfigMap)
+		require.True(t, apierrors.IsNotFound(err))
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonSkippedTarget)
+	})
+
+	t.Run("Should Ignore Invalid Filter Patterns", func(t *testing.T) {
+		filteredConfigMap := cm.DeepCopy()
+		filteredConfigMap.Annotations[api.Key(api.AnnotationReplicateTo)] = "another-*,[invalid"
+
+		recorder := record.NewFakeRecorder(10)
+
+		client := fake.NewClientBuilder().
+			WithObjects(filteredConfigMap, anotherNamespace).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      filteredConfigMap.Name,
+				Namespace: filteredConfigMap.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      filteredConfigMap.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.NoError(t, err)
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonInvalidFilter)
+	})
+
+	t.Run("Should Use Default Replicate To", func(t *testing.T) {
+		defaultNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "shared-namespace",
+			},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace, defaultNamespace).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{
+				DefaultReplicateTo: "shared-*",
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: defaultNamespace.Name,
+		}, &replicatedConfigMap)
+		require.NoError(t, err)
+
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.Error(t, err)
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Refuse To Replicate Beyond Limits", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.ConfigMapReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+			Policy: controller.Policy{
+				MaxReplicaSize: 16,
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.True(t, apierrors.IsNotFound(err))
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonLimitExceeded)
+	})
+
+	t.Run("Should Not Replicate A Replica", func(t *testing.T) {
+		replica := cm.DeepCopy()
+		replica.Labels = map[string]string{
+			api.LabelManagedByKey: api.LabelManagedByValue,
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(replica, anotherNamespace).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.ConfigMapReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.True(t, apierrors.IsNotFound(err))
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonReplicationLoop)
+	})
+
+	t.Run("Should Respect Conflict Policy", func(t *testing.T) {
+		unmanagedConfigMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cm.Name,
+				Namespace: anotherNamespace.Name,
+			},
+			Data: map[string]string{
+				"key": "user-owned",
+			},
+		}
+
+		for _, tc := range []struct {
+			conflictPolicy string
+			adoptExisting  bool
+			expectedValue  string
+			expectError    bool
+		}{
+			{conflictPolicy: "", expectedValue: "user-owned"},
+			{conflictPolicy: api.ConflictPolicySkip, expectedValue: "user-owned"},
+			{conflictPolicy: api.ConflictPolicyFail, expectedValue: "user-owned", expectError: true},
+			{conflictPolicy: api.ConflictPolicyOverwrite, expectedValue: cm.Data["key"]},
+			{conflictPolicy: api.ConflictPolicyFail, adoptExisting: true, expectedValue: cm.Data["key"]},
+		} {
+			cm := cm.DeepCopy()
+			if tc.conflictPolicy != "" {
+				cm.Annotations[api.Key(api.AnnotationConflictPolicy)] = tc.conflictPolicy
+			}
+
+			if tc.adoptExisting {
+				cm.Annotations[api.Key(api.AnnotationAdoptExisting)] = "true"
+			}
+
+			client := fake.NewClientBuilder().
+				WithObjects(cm, anotherNamespace, unmanagedConfigMap).
+				Build()
+
+			r := &controller.ConfigMapReconciler{
+				Client: client,
+				Scheme: scheme.Scheme,
+			}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      cm.Name,
+					Namespace: cm.Namespace,
+				},
+			})
+			if tc.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			var existingConfigMap corev1.ConfigMap
+			err = client.Get(ctx, types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: anotherNamespace.Name,
+			}, &existingConfigMap)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedValue, existingConfigMap.Data["key"], tc.conflictPolicy)
+
+			if tc.expectedValue == cm.Data["key"] {
+				assert.True(t, api.IsReplica(&existingConfigMap))
+			}
+		}
+	})
+
+	t.Run("Should Repair Drifted Replicas", func(t *testing.T) {
+		replica, err := api.ConfigMapTemplate(cm, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = anotherNamespace.Name
+		replica.Data["key"] = "tampered"
+
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace, replica).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.NoError(t, err)
+
+		assert.Equal(t, cm.Data, replicatedConfigMap.Data)
+	})
+
+	t.Run("Should Not Rewrite Unchanged Replicas", func(t *testing.T) {
+		replica, err := api.ConfigMapTemplate(cm, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = anotherNamespace.Name
+		controller.StampSourceHash(replica)
+
+		var writes int
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace, replica).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Update: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, opts ...ctrlclient.UpdateOption) error {
+					if obj.GetNamespace() == anotherNamespace.Name {
+						writes++
+					}
+
+					return c.Update(ctx, obj, opts...)
+				},
+				Patch: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, patch ctrlclient.Patch, opts ...ctrlclient.PatchOption) error {
+					if obj.GetNamespace() == anotherNamespace.Name {
+						writes++
+					}
+
+					return c.Patch(ctx, obj, patch, opts...)
+				},
+			}).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		_, err = r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		assert.Zero(t, writes)
+	})
+
+	t.Run("Should Rewrite Replicas When Source Labels Are Removed", func(t *testing.T) {
+		labeledConfigMap := cm.DeepCopy()
+		labeledConfigMap.Labels = map[string]string{"team": "platform"}
+
+		replica, err := api.ConfigMapTemplate(labeledConfigMap, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = anotherNamespace.Name
+		controller.StampSourceHash(replica)
+
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace, replica).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		_, err = r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.NoError(t, err)
+
+		assert.NotContains(t, replicatedConfigMap.Labels, "team")
+		assert.NotEqual(t, replica.Annotations[api.Key(api.AnnotationSourceHash)], replicatedConfigMap.Annotations[api.Key(api.AnnotationSourceHash)])
+	})
+
+	t.Run("Should Retry Replica Updates On Conflict", func(t *testing.T) {
+		replica, err := api.ConfigMapTemplate(cm, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = anotherNamespace.Name
+		replica.Data["key"] = "tampered"
+
+		var conflicts int
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace, replica).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Update: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, opts ...ctrlclient.UpdateOption) error {
+					if obj.GetNamespace() == anotherNamespace.Name && conflicts == 0 {
+						conflicts++
+						return apierrors.NewConflict(corev1.Resource("configmaps"), obj.GetName(), fmt.Errorf("object has been modified"))
+					}
+
+					return c.Update(ctx, obj, opts...)
+				},
+			}).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+		assert.Equal(t, 1, conflicts)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.NoError(t, err)
+
+		assert.Equal(t, cm.Data, replicatedConfigMap.Data)
+	})
+
+	t.Run("Should Keep Concurrent Changes When Retrying On Conflict", func(t *testing.T) {
+		replica, err := api.ConfigMapTemplate(cm, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = anotherNamespace.Name
+		replica.Data["key"] = "tampered"
+
+		var conflicts int
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace, replica).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Update: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, opts ...ctrlclient.UpdateOption) error {
+					if obj.GetNamespace() == anotherNamespace.Name && conflicts == 0 {
+						conflicts++
+
+						// Another controller labels the replica concurrently.
+						var latest corev1.ConfigMap
+						require.NoError(t, c.Get(ctx, ctrlclient.ObjectKeyFromObject(obj), &latest))
+
+						latest.Labels["example.com/owner"] = "team-a"
+						require.NoError(t, c.Update(ctx, &latest))
+
+						return apierrors.NewConflict(corev1.Resource("configmaps"), obj.GetName(), fmt.Errorf("object has been modified"))
+					}
+
+					return c.Update(ctx, obj, opts...)
+				},
+			}).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client:    client,
+			Scheme:    scheme.Scheme,
+			APIReader: client,
+		}
+
+		_, err = r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, conflicts)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.NoError(t, err)
+
+		assert.Equal(t, cm.Data, replicatedConfigMap.Data)
+		assert.Equal(t, "team-a", replicatedConfigMap.Labels["example.com/owner"])
+	})
+
+	t.Run("Should Requeue When Reconcile Times Out", func(t *testing.T) {
+		yetAnotherNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "yet-another-namespace",
+			},
+		}
+
+		var creates int
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace, yetAnotherNamespace).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, opts ...ctrlclient.CreateOption) error {
+					creates++
+					<-ctx.Done()
+
+					return c.Create(ctx, obj, opts...)
+				},
+			}).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{
+				ReconcileTimeout: 10 * time.Millisecond,
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.True(t, resp.Requeue)
+		assert.Equal(t, 1, creates)
+	})
+
+	t.Run("Should Record Source On Replicas", func(t *testing.T) {
+		cm := cm.DeepCopy()
+		cm.UID = "3c6d9d1e-6b36-4d5f-a5a8-0d6f4a3e6f51"
+
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedConfigMap corev1.ConfigMap
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedConfigMap)
+		require.NoError(t, err)
+
+		ref, uid, ok := api.GetSourceReference(&replicatedConfigMap)
+		require.True(t, ok)
+		assert.Equal(t, types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace}, ref)
+		assert.Equal(t, cm.UID, uid)
+		assert.NotEmpty(t, replicatedConfigMap.Annotations[api.Key(api.AnnotationSyncedAt)])
+	})
 }
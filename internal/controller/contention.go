@@ -0,0 +1,127 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ContentionTracker detects replicas that are repeatedly modified by another
+// writer (eg. a second controller managing the same object), so that replikator
+// can stop fighting over them instead of repairing them forever.
+type ContentionTracker struct {
+	// Clock is the clock used to measure the window (defaults to the real clock).
+	Clock     clock.WithTicker
+	threshold int
+	window    time.Duration
+	mu        sync.Mutex
+	repairs   map[string]*repairHistory
+	lastPrune time.Time
+}
+
+type repairHistory struct {
+	sourceVersion string
+	times         []time.Time
+}
+
+// NewContentionTracker creates a new ContentionTracker that considers a replica
+// contended once it has been repaired more than threshold times within the
+// window (without its source having changed).
+func NewContentionTracker(threshold int, window time.Duration) *ContentionTracker {
+	return &ContentionTracker{
+		threshold: threshold,
+		window:    window,
+		repairs:   make(map[string]*repairHistory),
+	}
+}
+
+// Record records that the replica had to be repaired against the given version
+// of its source, and returns true if the replica is contended. Changes to the
+// source reset the history, as repairs are then expected.
+func (t *ContentionTracker) Record(replica, sourceVersion string) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	history, ok := t.repairs[replica]
+	if !ok || history.sourceVersion != sourceVersion {
+		history = &repairHistory{sourceVersion: sourceVersion}
+		t.repairs[replica] = history
+	}
+
+	now := clockOrDefault(t.Clock).Now()
+	history.expire(now, t.window)
+	history.times = append(history.times, now)
+
+	// Replicas that are no longer being repaired are forgotten (at most once
+	// per window), so that the history doesn't grow without limit.
+	if now.Sub(t.lastPrune) > t.window {
+		for key, history := range t.repairs {
+			if history.expire(now, t.window); len(history.times) == 0 {
+				delete(t.repairs, key)
+			}
+		}
+
+		t.lastPrune = now
+	}
+
+	return len(history.times) > t.threshold
+}
+
+// Forget discards the repair history of a replica (eg. once it is deleted).
+func (t *ContentionTracker) Forget(replica string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.repairs, replica)
+}
+
+// Tracked returns the number of replicas with a repair history.
+func (t *ContentionTracker) Tracked() int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.repairs)
+}
+
+// expire discards the repairs that happened before the window.
+func (h *repairHistory) expire(now time.Time, window time.Duration) {
+	for len(h.times) > 0 && now.Sub(h.times[0]) > window {
+		h.times = h.times[1:]
+	}
+}
+
+// contentionKey returns the key identifying a replica in the contention tracker.
+func contentionKey(kind string, replica client.Object) string {
+	return kind + "/" + replica.GetNamespace() + "/" + replica.GetName()
+}
@@ -0,0 +1,89 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/stretchr/testify/assert"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestContentionTracker(t *testing.T) {
+	t.Run("Should Detect Repeated Repairs", func(t *testing.T) {
+		tracker := controller.NewContentionTracker(2, time.Minute)
+
+		assert.False(t, tracker.Record("secret/tenant-a/test", "1"))
+		assert.False(t, tracker.Record("secret/tenant-a/test", "1"))
+		assert.True(t, tracker.Record("secret/tenant-a/test", "1"))
+	})
+
+	t.Run("Should Reset When Source Changes", func(t *testing.T) {
+		tracker := controller.NewContentionTracker(2, time.Minute)
+
+		assert.False(t, tracker.Record("secret/tenant-a/test", "1"))
+		assert.False(t, tracker.Record("secret/tenant-a/test", "1"))
+		assert.False(t, tracker.Record("secret/tenant-a/test", "2"))
+	})
+
+	t.Run("Should Forget Repairs Outside Of Window", func(t *testing.T) {
+		clock := testingclock.NewFakeClock(time.Now())
+
+		tracker := controller.NewContentionTracker(1, time.Minute)
+		tracker.Clock = clock
+
+		assert.False(t, tracker.Record("secret/tenant-a/test", "1"))
+		clock.Step(2 * time.Minute)
+		assert.False(t, tracker.Record("secret/tenant-a/test", "1"))
+	})
+
+	t.Run("Should Prune Replicas No Longer Repaired", func(t *testing.T) {
+		clock := testingclock.NewFakeClock(time.Now())
+
+		tracker := controller.NewContentionTracker(1, time.Minute)
+		tracker.Clock = clock
+
+		tracker.Record("secret/tenant-a/test", "1")
+		tracker.Record("secret/tenant-b/test", "1")
+		assert.Equal(t, 2, tracker.Tracked())
+
+		clock.Step(2 * time.Minute)
+		tracker.Record("secret/tenant-a/test", "1")
+		assert.Equal(t, 1, tracker.Tracked())
+	})
+
+	t.Run("Should Forget Deleted Replicas", func(t *testing.T) {
+		tracker := controller.NewContentionTracker(1, time.Minute)
+
+		assert.False(t, tracker.Record("secret/tenant-a/test", "1"))
+		tracker.Forget("secret/tenant-a/test")
+		assert.Zero(t, tracker.Tracked())
+
+		assert.False(t, tracker.Record("secret/tenant-a/test", "1"))
+	})
+
+	t.Run("Should Not Track When Disabled", func(t *testing.T) {
+		var tracker *controller.ContentionTracker
+
+		assert.False(t, tracker.Record("secret/tenant-a/test", "1"))
+		tracker.Forget("secret/tenant-a/test")
+		assert.Zero(t, tracker.Tracked())
+	})
+}
@@ -0,0 +1,200 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// TestDesiredState compares the replicas written for representative
+// combinations of annotations against golden files (in testdata/golden). Both
+// kinds share the same golden files, as their replicas must only differ in
+// type. Run with -update to regenerate the golden files.
+func TestDesiredState(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	ctx := context.Background()
+
+	data := map[string]string{
+		"ca.crt":      "ca",
+		"config.yaml": "config",
+		"tls.crt":     "cert",
+	}
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+	}{
+		{name: "all-namespaces"},
+		{name: "replicate-to", annotations: map[string]string{
+			api.Key(api.AnnotationReplicateTo): "team-*",
+		}},
+		{name: "replicate-keys", annotations: map[string]string{
+			api.Key(api.AnnotationReplicateKeys): "ca.crt,config.*",
+		}},
+		{name: "replicate-to-and-keys", annotations: map[string]string{
+			api.Key(api.AnnotationReplicateTo):   "team-a,other",
+			api.Key(api.AnnotationReplicateKeys): "ca.crt",
+		}},
+		{name: "no-matching-namespaces", annotations: map[string]string{
+			api.Key(api.AnnotationReplicateTo): "nothing-*",
+		}},
+		{name: "invalid-patterns", annotations: map[string]string{
+			api.Key(api.AnnotationReplicateTo): "team-[,team-b",
+		}},
+	}
+
+	kinds := []struct {
+		name      string
+		source    func(meta metav1.ObjectMeta) ctrlclient.Object
+		reconcile func(c ctrlclient.Client) reconcile.Reconciler
+		replicas  func(c ctrlclient.Client) (map[string]map[string]string, error)
+	}{
+		{
+			name: "secret",
+			source: func(meta metav1.ObjectMeta) ctrlclient.Object {
+				secret := &corev1.Secret{ObjectMeta: meta, Data: make(map[string][]byte)}
+				for key, value := range data {
+					secret.Data[key] = []byte(value)
+				}
+				return secret
+			},
+			reconcile: func(c ctrlclient.Client) reconcile.Reconciler {
+				return &controller.SecretReconciler{Client: c, Scheme: scheme.Scheme}
+			},
+			replicas: func(c ctrlclient.Client) (map[string]map[string]string, error) {
+				var secrets corev1.SecretList
+				if err := c.List(ctx, &secrets); err != nil {
+					return nil, err
+				}
+
+				replicas := make(map[string]map[string]string)
+				for _, secret := range secrets.Items {
+					if !api.IsReplica(&secret) {
+						continue
+					}
+
+					replicas[secret.Namespace] = make(map[string]string)
+					for key, value := range secret.Data {
+						replicas[secret.Namespace][key] = string(value)
+					}
+				}
+				return replicas, nil
+			},
+		},
+		{
+			name: "configmap",
+			source: func(meta metav1.ObjectMeta) ctrlclient.Object {
+				return &corev1.ConfigMap{ObjectMeta: meta, Data: data}
+			},
+			reconcile: func(c ctrlclient.Client) reconcile.Reconciler {
+				return &controller.ConfigMapReconciler{Client: c, Scheme: scheme.Scheme}
+			},
+			replicas: func(c ctrlclient.Client) (map[string]map[string]string, error) {
+				var configMaps corev1.ConfigMapList
+				if err := c.List(ctx, &configMaps); err != nil {
+					return nil, err
+				}
+
+				replicas := make(map[string]map[string]string)
+				for _, cm := range configMaps.Items {
+					if api.IsReplica(&cm) {
+						replicas[cm.Namespace] = cm.Data
+					}
+				}
+				return replicas, nil
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		for _, kind := range kinds {
+			t.Run(tc.name+"/"+kind.name, func(t *testing.T) {
+				annotations := map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+				}
+				for key, value := range tc.annotations {
+					annotations[key] = value
+				}
+
+				source := kind.source(metav1.ObjectMeta{
+					Name:        "test-source",
+					Namespace:   "test-namespace",
+					Annotations: annotations,
+				})
+
+				objects := []ctrlclient.Object{source}
+				for _, name := range []string{"test-namespace", "team-a", "team-b", "other"} {
+					objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+				}
+
+				client := fake.NewClientBuilder().
+					WithObjects(objects...).
+					Build()
+
+				_, err := kind.reconcile(client).Reconcile(ctx, reconcile.Request{
+					NamespacedName: types.NamespacedName{
+						Name:      source.GetName(),
+						Namespace: source.GetNamespace(),
+					},
+				})
+				require.NoError(t, err)
+
+				replicas, err := kind.replicas(client)
+				require.NoError(t, err)
+
+				actual, err := yaml.Marshal(replicas)
+				require.NoError(t, err)
+
+				path := filepath.Join("testdata", "golden", tc.name+".yaml")
+				if *update {
+					require.NoError(t, os.WriteFile(path, actual, 0o644))
+				}
+
+				expected, err := os.ReadFile(path)
+				require.NoError(t, err)
+
+				assert.Equal(t, string(expected), string(actual))
+			})
+		}
+	}
+}
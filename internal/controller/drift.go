@@ -0,0 +1,227 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/gpu-ninja/operator-utils/updater"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// nameField is the field index used to look up objects by name.
+const nameField = "metadata.name"
+
+// CompareReplica compares a replica against the template it should match and
+// returns a description of each difference found. Labels and annotations that
+// are present on the replica but not on the template are ignored.
+func CompareReplica(template, replica client.Object) []string {
+	var messages []string
+
+	if templateSecret, ok := template.(*corev1.Secret); ok {
+		if replicaSecret := replica.(*corev1.Secret); replicaSecret.Type != templateSecret.Type {
+			messages = append(messages, fmt.Sprintf("type is %q, expected %q", replicaSecret.Type, templateSecret.Type))
+		}
+	}
+
+	desiredData := objectData(template)
+	actualData := objectData(replica)
+
+	for _, key := range sortedKeys(desiredData) {
+		actual, ok := actualData[key]
+		if !ok {
+			messages = append(messages, fmt.Sprintf("key %q is missing", key))
+		} else if !bytes.Equal(desiredData[key], actual) {
+			messages = append(messages, fmt.Sprintf("key %q has hash %s, expected %s", key, shortHash(actual), shortHash(desiredData[key])))
+		}
+	}
+
+	for _, key := range sortedKeys(actualData) {
+		if _, ok := desiredData[key]; !ok {
+			messages = append(messages, fmt.Sprintf("key %q is not present in the source", key))
+		}
+	}
+
+	desiredLabels := template.GetLabels()
+	actualLabels := replica.GetLabels()
+
+	var labelKeys []string
+	for key := range desiredLabels {
+		labelKeys = append(labelKeys, key)
+	}
+	sort.Strings(labelKeys)
+
+	for _, key := range labelKeys {
+		if actual, ok := actualLabels[key]; !ok || actual != desiredLabels[key] {
+			messages = append(messages, fmt.Sprintf("label %q is stale", key))
+		}
+	}
+
+	desiredAnnotations := template.GetAnnotations()
+	actualAnnotations := replica.GetAnnotations()
+
+	var annotationKeys []string
+	for key := range desiredAnnotations {
+		annotationKeys = append(annotationKeys, key)
+	}
+	sort.Strings(annotationKeys)
+
+	for _, key := range annotationKeys {
+		if actual, ok := actualAnnotations[key]; !ok || actual != desiredAnnotations[key] {
+			messages = append(messages, fmt.Sprintf("annotation %q is stale", key))
+		}
+	}
+
+	return messages
+}
+
+// objectData returns the data of a secret or configmap (including its binary
+// data) as raw bytes.
+func objectData(obj client.Object) map[string][]byte {
+	data := make(map[string][]byte)
+
+	switch obj := obj.(type) {
+	case *corev1.Secret:
+		for key, value := range obj.Data {
+			data[key] = value
+		}
+	case *corev1.ConfigMap:
+		for key, value := range obj.Data {
+			data[key] = []byte(value)
+		}
+
+		for key, value := range obj.BinaryData {
+			data[key] = value
+		}
+	}
+
+	return data
+}
+
+func sortedKeys(data map[string][]byte) []string {
+	var keys []string
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func shortHash(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// StampSourceHash records a hash of the content of a replica (its type, data,
+// labels and annotations) on it. As the hash is compared along with the rest
+// of the replica, replicas are rewritten when labels or annotations are
+// removed from their source (not only when they are added or changed).
+func StampSourceHash(replica client.Object) {
+	annotations := replica.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[api.Key(api.AnnotationSourceHash)] = sourceHash(replica)
+
+	replica.SetAnnotations(annotations)
+}
+
+// isTampered returns true if the existing replica was modified directly, rather
+// than its source having changed since it was written (its recorded source hash
+// still matches the desired replica).
+func isTampered(existing, desired client.Object) bool {
+	if existing == nil {
+		return false
+	}
+
+	hash, ok := existing.GetAnnotations()[api.Key(api.AnnotationSourceHash)]
+
+	return ok && hash == desired.GetAnnotations()[api.Key(api.AnnotationSourceHash)]
+}
+
+// sourceHash returns a hash of the content of a replica, ignoring the
+// annotations that change every time it is written.
+func sourceHash(replica client.Object) string {
+	annotations := make(map[string]string)
+	for key, value := range replica.GetAnnotations() {
+		switch key {
+		case api.Key(api.AnnotationSourceHash), api.Key(api.AnnotationSyncedAt), updater.AnnotationKey:
+		default:
+			annotations[key] = value
+		}
+	}
+
+	var secretType corev1.SecretType
+	if secret, ok := replica.(*corev1.Secret); ok {
+		secretType = secret.Type
+	}
+
+	// Maps are encoded with sorted keys, so the encoding is deterministic.
+	content, _ := json.Marshal(struct {
+		Type        corev1.SecretType
+		Data        map[string][]byte
+		Labels      map[string]string
+		Annotations map[string]string
+	}{secretType, objectData(replica), replica.GetLabels(), annotations})
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// driftedObjects returns the desired objects whose existing counterpart has
+// drifted from them (eg. due to a change to the source, or tampering). The
+// returned objects are ready to be written with an update. Replicas with the
+// source hash of the desired object, that haven't been tampered with, are
+// left alone.
+func driftedObjects[T client.Object](existingObjects, desiredObjects []T) []T {
+	var drifted []T
+	for _, existingObject := range existingObjects {
+		for _, desiredObject := range desiredObjects {
+			if desiredObject.GetNamespace() != existingObject.GetNamespace() || len(CompareReplica(desiredObject, existingObject)) == 0 {
+				continue
+			}
+
+			if err := updater.StoreHash(desiredObject, updater.HashObject(desiredObject)); err != nil {
+				continue
+			}
+
+			desiredObject.SetResourceVersion(existingObject.GetResourceVersion())
+			drifted = append(drifted, desiredObject)
+		}
+	}
+
+	return drifted
+}
+
+// indexByName indexes objects of the given type by name.
+func indexByName(mgr ctrl.Manager, obj client.Object) error {
+	return mgr.GetFieldIndexer().IndexField(context.Background(), obj, nameField, func(obj client.Object) []string {
+		return []string{obj.GetName()}
+	})
+}
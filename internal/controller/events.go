@@ -0,0 +1,209 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+const (
+	// EventReasonSkippedTarget is recorded when a target namespace is skipped due to policy.
+	EventReasonSkippedTarget = "SkippedTarget"
+	// EventReasonTenancyViolation is recorded when a target namespace belongs to a different tenant.
+	EventReasonTenancyViolation = "TenancyViolation"
+	// EventReasonLimitExceeded is recorded when a source exceeds the replica size or count limits.
+	EventReasonLimitExceeded = "LimitExceeded"
+	// EventReasonPrivateKeyRefused is recorded when a secret containing a private key is not replicated due to policy.
+	EventReasonPrivateKeyRefused = "PrivateKeyRefused"
+	// EventReasonInvalidCertificate is recorded when a TLS secret is not replicated as its certificates failed validation.
+	EventReasonInvalidCertificate = "InvalidCertificate"
+	// EventReasonCertificateExpiring is recorded as the certificate of a source approaches expiry.
+	EventReasonCertificateExpiring = "CertificateExpiring"
+	// EventReasonCertificateExpired is recorded when the certificate of a source has expired without being renewed.
+	EventReasonCertificateExpired = "CertificateExpired"
+	// EventReasonReplicationLoop is recorded when replication is enabled on a replica.
+	EventReasonReplicationLoop = "ReplicationLoop"
+	// EventReasonConflict is recorded when a target namespace contains an unmanaged object with the same name.
+	EventReasonConflict = "Conflict"
+	// EventReasonAdopted is recorded when a pre-existing object is adopted as a replica.
+	EventReasonAdopted = "Adopted"
+	// EventReasonCleanupFailed is recorded when a replica could not be deleted.
+	EventReasonCleanupFailed = "CleanupFailed"
+	// EventReasonForcedCleanup is recorded when cleanup is forced, orphaning replicas that could not be deleted.
+	EventReasonForcedCleanup = "ForcedCleanup"
+	// EventReasonTypeChanged is recorded when a replica is recreated because the type of its source changed.
+	EventReasonTypeChanged = "TypeChanged"
+	// EventReasonEmptyReplica is recorded when a key filter matches none of the keys of a source.
+	EventReasonEmptyReplica = "EmptyReplica"
+	// EventReasonInvalidFilter is recorded when a filter annotation contains malformed patterns.
+	EventReasonInvalidFilter = "InvalidFilter"
+	// EventReasonContended is recorded when a replica is repeatedly modified by another writer.
+	EventReasonContended = "Contended"
+	// EventReasonVerificationFailed is recorded when verification finds a replica that doesn't match its source.
+	EventReasonVerificationFailed = "VerificationFailed"
+	// EventReasonInvalidConfig is recorded when a ReplikatorConfig contains invalid settings.
+	EventReasonInvalidConfig = "InvalidConfig"
+	// EventReasonHookRefused is recorded when a replication hook refuses to replicate a source.
+	EventReasonHookRefused = "HookRefused"
+	// EventReasonTransformFailed is recorded when a replica could not be transformed.
+	EventReasonTransformFailed = "TransformFailed"
+	// EventReasonExternallyManaged is recorded when a target namespace contains an object managed by external-secrets.
+	EventReasonExternallyManaged = "ExternallyManaged"
+	// EventReasonTrustBundleRefused is recorded when a source can't be replicated as a trust-manager Bundle.
+	EventReasonTrustBundleRefused = "TrustBundleRefused"
+	// EventReasonManifestWorkRefused is recorded when a source can't be distributed to managed clusters.
+	EventReasonManifestWorkRefused = "ManifestWorkRefused"
+	// EventReasonManifestWorkFailed is recorded when the replicas of a source couldn't be applied to a managed cluster.
+	EventReasonManifestWorkFailed = "ManifestWorkFailed"
+	// EventReasonPullRefused is recorded when an object annotated with replicate-from can't be filled from its source.
+	EventReasonPullRefused = "PullRefused"
+)
+
+// The reasons of lifecycle events that are published to subscribers, but not
+// recorded as Kubernetes Events.
+const (
+	// EventReasonReplicaWritten is published when a replica is created or updated.
+	EventReasonReplicaWritten = "ReplicaWritten"
+	// EventReasonReplicaRepaired is published when a replica that was modified directly is reverted to match its source.
+	EventReasonReplicaRepaired = "ReplicaRepaired"
+	// EventReasonReplicaDeleted is published when a replica is deleted.
+	EventReasonReplicaDeleted = "ReplicaDeleted"
+	// EventReasonThrottled is published when a reconcile is delayed due to write rate limiting.
+	EventReasonThrottled = "Throttled"
+	// EventReasonTimedOut is published when a reconcile times out before all replicas were written.
+	EventReasonTimedOut = "TimedOut"
+)
+
+// Event is a replication lifecycle event.
+type Event struct {
+	// Type is the Kubernetes event type (eg. Warning), or empty for lifecycle
+	// events that aren't recorded as Kubernetes Events.
+	Type string
+	// Reason is the reason for the event (eg. EventReasonConflict).
+	Reason string
+	// Kind is the lowercase kind of the source (eg. "secret"), if any.
+	Kind string
+	// Object is the object the event is about (typically the source), if any.
+	Object client.Object
+	// Namespace is the target namespace the event relates to, if any.
+	Namespace string
+	// Message describes the event.
+	Message string
+}
+
+// EventBus delivers replication lifecycle events to in-process subscribers
+// (eg. the Kubernetes Event recorder and metrics), so that each doesn't need
+// to instrument the reconcilers independently. Events are delivered
+// synchronously, so subscribers must not block.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]func(Event)
+	next        int
+}
+
+// NewEventBus returns a new event bus without any subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[int]func(Event))}
+}
+
+// Subscribe registers a function to be called with every published event. The
+// returned function unsubscribes it.
+func (b *EventBus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	b.subscribers[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers, id)
+	}
+}
+
+// Publish delivers the event to every subscriber.
+func (b *EventBus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, fn := range b.subscribers {
+		fn(event)
+	}
+}
+
+// RecordEvents returns a subscriber that records events as Kubernetes Events
+// on the objects they are about (if an event recorder is configured).
+func RecordEvents(recorder record.EventRecorder) func(Event) {
+	return func(event Event) {
+		if recorder == nil || event.Type == "" || event.Object == nil {
+			return
+		}
+
+		recorder.Event(event.Object, event.Type, event.Reason, event.Message)
+	}
+}
+
+// RecordMetrics is a subscriber that updates metrics from events.
+func RecordMetrics(event Event) {
+	switch event.Reason {
+	case EventReasonThrottled:
+		if event.Object != nil {
+			throttledReconcilesTotal.WithLabelValues(event.Object.GetNamespace()).Inc()
+		}
+	case EventReasonReplicaRepaired:
+		replicaRepairsTotal.WithLabelValues(event.Kind).Inc()
+	case EventReasonInvalidFilter:
+		invalidFiltersTotal.WithLabelValues(event.Kind).Inc()
+	case EventReasonContended:
+		contendedReplicasTotal.WithLabelValues(event.Kind, event.Namespace).Inc()
+	case EventReasonTimedOut:
+		reconcileTimeoutsTotal.WithLabelValues(event.Kind).Inc()
+	}
+}
+
+// publishEvent publishes the event to the bus. Without a bus, the event is
+// delivered directly to the default subscribers instead (recording it with
+// the recorder and updating metrics).
+func publishEvent(bus *EventBus, recorder record.EventRecorder, event Event) {
+	if bus != nil {
+		bus.Publish(event)
+		return
+	}
+
+	RecordEvents(recorder)(event)
+	RecordMetrics(event)
+}
+
+// recordEvent publishes an event about the object that is recorded as a Kubernetes Event.
+func recordEvent(bus *EventBus, recorder record.EventRecorder, obj client.Object, eventType, reason, messageFmt string, args ...any) {
+	publishEvent(bus, recorder, Event{
+		Type:    eventType,
+		Reason:  reason,
+		Object:  obj,
+		Message: fmt.Sprintf(messageFmt, args...),
+	})
+}
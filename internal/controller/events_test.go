@@ -0,0 +1,185 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestEventBus(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	ctx := context.Background()
+
+	t.Run("Should Deliver Events To Subscribers", func(t *testing.T) {
+		bus := controller.NewEventBus()
+
+		var first, second []controller.Event
+		bus.Subscribe(func(event controller.Event) { first = append(first, event) })
+		unsubscribe := bus.Subscribe(func(event controller.Event) { second = append(second, event) })
+
+		bus.Publish(controller.Event{Reason: controller.EventReasonReplicaWritten})
+		unsubscribe()
+		bus.Publish(controller.Event{Reason: controller.EventReasonReplicaDeleted})
+
+		assert.Len(t, first, 2)
+		assert.Len(t, second, 1)
+	})
+
+	t.Run("Should Only Record Events With A Type", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		recordEvents := controller.RecordEvents(recorder)
+
+		source := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-secret", Namespace: "test-namespace"}}
+
+		recordEvents(controller.Event{Reason: controller.EventReasonReplicaWritten, Object: source})
+		recordEvents(controller.Event{Type: corev1.EventTypeWarning, Reason: controller.EventReasonConflict, Object: source, Message: "conflict"})
+
+		require.Len(t, recorder.Events, 1)
+		assert.Equal(t, "Warning Conflict conflict", <-recorder.Events)
+	})
+
+	t.Run("Should Publish Lifecycle Events", func(t *testing.T) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-secret",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+				},
+			},
+			Data: map[string][]byte{"key": []byte("value")},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "another-namespace"}}).
+			Build()
+
+		var mu sync.Mutex
+		var events []controller.Event
+
+		bus := controller.NewEventBus()
+		bus.Subscribe(func(event controller.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			events = append(events, event)
+		})
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{Events: bus},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace},
+		})
+		require.NoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		require.Len(t, events, 1)
+		assert.Equal(t, controller.EventReasonReplicaWritten, events[0].Reason)
+		assert.Equal(t, "secret", events[0].Kind)
+		assert.Equal(t, "another-namespace", events[0].Namespace)
+		assert.Equal(t, secret.Name, events[0].Object.GetName())
+	})
+
+	t.Run("Should Only Publish Repairs Of Replicas Modified Directly", func(t *testing.T) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-secret",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+				},
+			},
+			Data: map[string][]byte{"key": []byte("value")},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "another-namespace"}}).
+			Build()
+
+		var reasons []string
+
+		bus := controller.NewEventBus()
+		bus.Subscribe(func(event controller.Event) { reasons = append(reasons, event.Reason) })
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{Events: bus},
+		}
+
+		reconcileSecret := func() {
+			_, err := r.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace},
+			})
+			require.NoError(t, err)
+		}
+
+		reconcileSecret()
+
+		t.Log("Modifying the replica directly")
+
+		var replica corev1.Secret
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: "another-namespace"}, &replica))
+
+		replica.Data["key"] = []byte("tampered")
+		require.NoError(t, client.Update(ctx, &replica))
+
+		reconcileSecret()
+
+		t.Log("Modifying the source")
+
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, secret))
+
+		secret.Data["key"] = []byte("updated")
+		require.NoError(t, client.Update(ctx, secret))
+
+		reconcileSecret()
+
+		assert.Equal(t, []string{
+			controller.EventReasonReplicaWritten,
+			controller.EventReasonReplicaRepaired,
+			controller.EventReasonReplicaWritten,
+		}, reasons)
+	})
+}
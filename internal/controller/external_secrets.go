@@ -0,0 +1,52 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// externalSecretsGroup is the API group of the external-secrets operator.
+const externalSecretsGroup = "external-secrets.io"
+
+// externalSecretsMetadataPrefix is the prefix of the labels and annotations
+// the external-secrets operator records on the secrets it materializes.
+const externalSecretsMetadataPrefix = "reconcile.external-secrets.io/"
+
+// isExternalSecret returns true if the object was materialized by (and so is
+// reconciled by) the external-secrets operator.
+func isExternalSecret(obj client.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if gv, err := schema.ParseGroupVersion(ref.APIVersion); err == nil && gv.Group == externalSecretsGroup {
+			return true
+		}
+	}
+
+	for _, metadata := range []map[string]string{obj.GetLabels(), obj.GetAnnotations()} {
+		for key := range metadata {
+			if strings.HasPrefix(key, externalSecretsMetadataPrefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,173 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// GarbageCollector periodically deletes orphaned replicas. Orphans are left
+// behind if a source is deleted while replikator isn't running (and its
+// finalizer is forcibly removed).
+type GarbageCollector struct {
+	client.Client
+	Policy Policy
+	// Interval is the time between collections. If zero, garbage is only
+	// collected once on startup.
+	Interval time.Duration
+	// Clock is the clock used to schedule collections (defaults to the real clock).
+	Clock clock.WithTicker
+}
+
+// Start implements manager.Runnable.
+func (gc *GarbageCollector) Start(ctx context.Context) error {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx))).With("component", "garbage-collector")
+
+	for {
+		if err := gc.Collect(ctx); err != nil {
+			logger.Error("Failed to collect orphaned replicas", "error", err)
+		}
+
+		if gc.Interval == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-clockOrDefault(gc.Clock).After(gc.Interval):
+		}
+	}
+}
+
+// Collect deletes every orphaned replica (in namespaces replikator is permitted to modify).
+func (gc *GarbageCollector) Collect(ctx context.Context) error {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx))).With("component", "garbage-collector")
+	policy := gc.Policy.current()
+
+	orphans, err := FindOrphans(ctx, gc.Client)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range orphans {
+		if policy.IsProtectedNamespace(obj.GetNamespace()) || !policy.InScope(obj.GetNamespace()) {
+			continue
+		}
+
+		logger.Info("Deleting orphaned replica", "namespace", obj.GetNamespace(), "name", obj.GetName())
+
+		if err := gc.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			if policy.IsOptedOut(err) {
+				continue
+			}
+
+			return fmt.Errorf("failed to delete orphaned replica: %w", err)
+		}
+
+		orphansDeletedTotal.Inc()
+	}
+
+	return nil
+}
+
+// FindOrphans returns all managed replicas that are no longer backed by a
+// replication enabled source (of the same kind and name) targeting their namespace.
+func FindOrphans(ctx context.Context, c client.Client) ([]client.Object, error) {
+	objects, err := listObjects(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return findOrphans(objects), nil
+}
+
+// listObjects lists every secret and configmap in the cluster (matching the options).
+func listObjects(ctx context.Context, c client.Reader, opts ...client.ListOption) ([]client.Object, error) {
+	var secrets corev1.SecretList
+	if err := c.List(ctx, &secrets, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	var configMaps corev1.ConfigMapList
+	if err := c.List(ctx, &configMaps, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list configmaps: %w", err)
+	}
+
+	var objects []client.Object
+	for i := range secrets.Items {
+		objects = append(objects, &secrets.Items[i])
+	}
+
+	for i := range configMaps.Items {
+		objects = append(objects, &configMaps.Items[i])
+	}
+
+	return objects, nil
+}
+
+func findOrphans(objects []client.Object) []client.Object {
+	sourcesByName := make(map[string][]client.Object)
+	for _, obj := range objects {
+		if api.IsReplicationEnabled(obj) {
+			key := fmt.Sprintf("%T/%s", obj, obj.GetName())
+			sourcesByName[key] = append(sourcesByName[key], obj)
+		}
+	}
+
+	var orphans []client.Object
+	for _, obj := range objects {
+		if !api.IsReplica(obj) || api.IsReplicationEnabled(obj) {
+			continue
+		}
+
+		var found bool
+		for _, source := range sourcesByName[fmt.Sprintf("%T/%s", obj, obj.GetName())] {
+			// If the replica records its source, only that source can back it.
+			if ref, uid, ok := api.GetSourceReference(obj); ok {
+				if source.GetNamespace() != ref.Namespace || source.GetName() != ref.Name || (uid != "" && source.GetUID() != uid) {
+					continue
+				}
+			}
+
+			ok, err := api.ShouldReplicateTo(source, obj.GetNamespace())
+			// If the source has a malformed filter, err on the side of caution.
+			if err != nil || ok {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			orphans = append(orphans, obj)
+		}
+	}
+
+	return orphans
+}
@@ -0,0 +1,203 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	testingclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGarbageCollector(t *testing.T) {
+	ctx := context.Background()
+
+	replica := func(namespace string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-configmap",
+				Namespace: namespace,
+				Labels: map[string]string{
+					api.LabelManagedByKey: api.LabelManagedByValue,
+				},
+			},
+		}
+	}
+
+	t.Run("Should Delete Orphaned Replicas", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(replica("team-a"), replica("kube-system")).
+			Build()
+
+		gc := &controller.GarbageCollector{
+			Client: client,
+			Policy: controller.Policy{
+				ProtectedNamespaces: []string{"kube-*"},
+			},
+		}
+
+		require.NoError(t, gc.Collect(ctx))
+
+		var cm corev1.ConfigMap
+		err := client.Get(ctx, types.NamespacedName{Name: "test-configmap", Namespace: "team-a"}, &cm)
+		require.True(t, apierrors.IsNotFound(err))
+
+		// Protected namespaces are never touched.
+		err = client.Get(ctx, types.NamespacedName{Name: "test-configmap", Namespace: "kube-system"}, &cm)
+		require.NoError(t, err)
+	})
+
+	t.Run("Should Delete Replicas Whose Recorded Source No Longer Exists", func(t *testing.T) {
+		source := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-configmap",
+				Namespace: "test-namespace",
+				UID:       "new-uid",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+				},
+			},
+		}
+
+		staleReplica := replica("team-a")
+		staleReplica.Labels[api.Key(api.LabelSourceUID)] = "old-uid"
+		staleReplica.Annotations = map[string]string{
+			api.Key(api.AnnotationSourceNamespace): source.Namespace,
+			api.Key(api.AnnotationSourceName):      source.Name,
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(source, staleReplica, replica("team-b")).
+			Build()
+
+		gc := &controller.GarbageCollector{Client: client}
+
+		require.NoError(t, gc.Collect(ctx))
+
+		var cm corev1.ConfigMap
+		err := client.Get(ctx, types.NamespacedName{Name: "test-configmap", Namespace: "team-a"}, &cm)
+		require.True(t, apierrors.IsNotFound(err))
+
+		err = client.Get(ctx, types.NamespacedName{Name: "test-configmap", Namespace: "team-b"}, &cm)
+		require.NoError(t, err)
+	})
+
+	t.Run("Should Delete Replicas Whose Source Is No Longer Replicated", func(t *testing.T) {
+		source := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-configmap",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "false",
+				},
+			},
+		}
+
+		replicaWithSource := replica("team-a")
+		replicaWithSource.Annotations = map[string]string{
+			api.Key(api.AnnotationSourceNamespace): source.Namespace,
+			api.Key(api.AnnotationSourceName):      source.Name,
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(source, replicaWithSource).
+			Build()
+
+		gc := &controller.GarbageCollector{Client: client}
+
+		require.NoError(t, gc.Collect(ctx))
+
+		var cm corev1.ConfigMap
+		err := client.Get(ctx, types.NamespacedName{Name: "test-configmap", Namespace: "team-a"}, &cm)
+		require.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Delete Replicas In Excepted Namespaces", func(t *testing.T) {
+		source := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-configmap",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled):         "true",
+					api.Key(api.AnnotationReplicateExcept): "team-a",
+				},
+			},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(source, replica("team-a"), replica("team-b")).
+			Build()
+
+		gc := &controller.GarbageCollector{Client: client}
+
+		require.NoError(t, gc.Collect(ctx))
+
+		var cm corev1.ConfigMap
+		err := client.Get(ctx, types.NamespacedName{Name: "test-configmap", Namespace: "team-a"}, &cm)
+		require.True(t, apierrors.IsNotFound(err))
+
+		err = client.Get(ctx, types.NamespacedName{Name: "test-configmap", Namespace: "team-b"}, &cm)
+		require.NoError(t, err)
+	})
+
+	t.Run("Should Collect Periodically", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(replica("team-a")).
+			Build()
+
+		clock := testingclock.NewFakeClock(time.Now())
+
+		gc := &controller.GarbageCollector{
+			Client:   client,
+			Interval: time.Hour,
+			Clock:    clock,
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+
+		go func() {
+			_ = gc.Start(ctx)
+		}()
+
+		// Wait for the initial collection to complete.
+		require.Eventually(t, clock.HasWaiters, time.Second, time.Millisecond)
+
+		var cm corev1.ConfigMap
+		err := client.Get(ctx, types.NamespacedName{Name: "test-configmap", Namespace: "team-a"}, &cm)
+		require.True(t, apierrors.IsNotFound(err))
+
+		require.NoError(t, client.Create(ctx, replica("team-b")))
+
+		clock.Step(time.Hour)
+
+		require.Eventually(t, func() bool {
+			err := client.Get(ctx, types.NamespacedName{Name: "test-configmap", Namespace: "team-b"}, &cm)
+			return apierrors.IsNotFound(err)
+		}, time.Second, time.Millisecond)
+	})
+}
@@ -0,0 +1,203 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Hook is invoked around replication, enabling custom mutations, notifications
+// and policy checks without modifying the reconcilers.
+type Hook interface {
+	// BeforeTemplate is called with a copy of the source before the replica
+	// template is built from it. The source may be mutated, and returning a
+	// *HookRefusedError prevents the source from being replicated.
+	BeforeTemplate(ctx context.Context, source client.Object) error
+	// AfterWrite is called after a replica of the source has been written.
+	AfterWrite(ctx context.Context, source, replica client.Object) error
+}
+
+// HookRefusedError is returned by a hook to prevent a source from being replicated.
+type HookRefusedError struct {
+	Reason string
+}
+
+func (e *HookRefusedError) Error() string {
+	return fmt.Sprintf("refused by hook: %s", e.Reason)
+}
+
+// HookPhase identifies the point in replication an exec or webhook hook is invoked.
+type HookPhase string
+
+const (
+	// HookPhaseBeforeTemplate is before the replica template is built from the source.
+	HookPhaseBeforeTemplate HookPhase = "BeforeTemplate"
+	// HookPhaseAfterWrite is after a replica has been written.
+	HookPhaseAfterWrite HookPhase = "AfterWrite"
+)
+
+// HookRequest is provided (as JSON) to exec and webhook hooks.
+type HookRequest struct {
+	Phase   HookPhase     `json:"phase"`
+	Source  client.Object `json:"source"`
+	Replica client.Object `json:"replica,omitempty"`
+}
+
+// HookResponse is returned (as JSON) by exec and webhook hooks in the
+// BeforeTemplate phase. An empty response leaves the source unchanged.
+type HookResponse struct {
+	// Refused, if set, prevents the source from being replicated for the given reason.
+	Refused string `json:"refused,omitempty"`
+	// Source, if set, replaces the source the replica template is built from.
+	// The name, namespace and UID of the source can't be changed.
+	Source json.RawMessage `json:"source,omitempty"`
+}
+
+// ExecHook runs a program for each hook, writing a HookRequest to its stdin
+// and reading a HookResponse from its stdout. A non-zero exit status is
+// treated as an error.
+type ExecHook struct {
+	// Command is the program and its arguments.
+	Command []string
+	// Timeout, if set, limits how long the program may run for.
+	Timeout time.Duration
+}
+
+func (h *ExecHook) BeforeTemplate(ctx context.Context, source client.Object) error {
+	var resp HookResponse
+	if err := h.call(ctx, &HookRequest{Phase: HookPhaseBeforeTemplate, Source: source}, &resp); err != nil {
+		return err
+	}
+
+	return resp.apply(source)
+}
+
+func (h *ExecHook) AfterWrite(ctx context.Context, source, replica client.Object) error {
+	return h.call(ctx, &HookRequest{Phase: HookPhaseAfterWrite, Source: source, Replica: replica}, nil)
+}
+
+func (h *ExecHook) call(ctx context.Context, req *HookRequest, resp *HookResponse) error {
+	if err := execJSON(ctx, h.Command, h.Timeout, req, resp); err != nil {
+		return fmt.Errorf("hook failed: %w", err)
+	}
+
+	return nil
+}
+
+// WebhookHook POSTs a HookRequest to an HTTP endpoint for each hook and reads
+// a HookResponse from the response body. A non-2xx status is treated as an error.
+type WebhookHook struct {
+	// URL is the endpoint of the webhook.
+	URL string
+	// Timeout, if set, limits how long each request may take.
+	Timeout time.Duration
+	// Client, if set, is used to make requests (instead of the default client).
+	Client *http.Client
+}
+
+func (h *WebhookHook) BeforeTemplate(ctx context.Context, source client.Object) error {
+	var resp HookResponse
+	if err := h.call(ctx, &HookRequest{Phase: HookPhaseBeforeTemplate, Source: source}, &resp); err != nil {
+		return err
+	}
+
+	return resp.apply(source)
+}
+
+func (h *WebhookHook) AfterWrite(ctx context.Context, source, replica client.Object) error {
+	return h.call(ctx, &HookRequest{Phase: HookPhaseAfterWrite, Source: source, Replica: replica}, nil)
+}
+
+func (h *WebhookHook) call(ctx context.Context, req *HookRequest, resp *HookResponse) error {
+	if err := postJSON(ctx, h.Client, h.URL, h.Timeout, req, resp); err != nil {
+		return fmt.Errorf("hook failed: %w", err)
+	}
+
+	return nil
+}
+
+// apply applies the response of a hook to the source.
+func (r *HookResponse) apply(source client.Object) error {
+	if r.Refused != "" {
+		return &HookRefusedError{Reason: r.Refused}
+	}
+
+	if len(r.Source) == 0 {
+		return nil
+	}
+
+	mutated, err := decodeObjectLike(source, r.Source)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal source returned by hook: %w", err)
+	}
+
+	mutated.SetName(source.GetName())
+	mutated.SetNamespace(source.GetNamespace())
+	mutated.SetUID(source.GetUID())
+
+	reflect.ValueOf(source).Elem().Set(reflect.ValueOf(mutated).Elem())
+
+	return nil
+}
+
+// runBeforeTemplateHooks runs the BeforeTemplate phase of each hook in order.
+func runBeforeTemplateHooks(ctx context.Context, c client.Client, hooks []Hook, source client.Object) error {
+	if len(hooks) > 0 {
+		setKind(c, source)
+	}
+
+	for _, hook := range hooks {
+		if err := hook.BeforeTemplate(ctx, source); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runAfterWriteHooks runs the AfterWrite phase of each hook. As the replica
+// has already been written, failures are only logged.
+func runAfterWriteHooks(ctx context.Context, logger *slog.Logger, c client.Client, hooks []Hook, source, replica client.Object) {
+	if len(hooks) > 0 {
+		setKind(c, source)
+		setKind(c, replica)
+	}
+
+	for _, hook := range hooks {
+		if err := hook.AfterWrite(ctx, source, replica); err != nil {
+			logger.Warn("Replication hook failed", "namespace", replica.GetNamespace(), "error", err)
+		}
+	}
+}
+
+// setKind populates the (otherwise typically empty) kind of a typed object,
+// so that it is included when the object is provided to hooks.
+func setKind(c client.Client, obj client.Object) {
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+}
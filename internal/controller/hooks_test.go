@@ -0,0 +1,206 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestHooks(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		Data: map[string]string{
+			"greeting": "hello",
+		},
+	}
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	ctx := context.Background()
+
+	reconcileConfigMap := func(t *testing.T, hooks ...controller.Hook) (ctrlclient.Client, *record.FakeRecorder) {
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.ConfigMapReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+			Policy:   controller.Policy{Hooks: hooks},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		return client, recorder
+	}
+
+	t.Run("Should Build Replicas From The Mutated Source", func(t *testing.T) {
+		hook := &recordingHook{mutate: func(source ctrlclient.Object) {
+			source.(*corev1.ConfigMap).Data["greeting"] = "bonjour"
+		}}
+
+		client, _ := reconcileConfigMap(t, hook)
+
+		var replica corev1.ConfigMap
+		err := client.Get(ctx, types.NamespacedName{Name: cm.Name, Namespace: anotherNamespace.Name}, &replica)
+		require.NoError(t, err)
+
+		assert.Equal(t, "bonjour", replica.Data["greeting"])
+		assert.Equal(t, []string{anotherNamespace.Name}, hook.written)
+	})
+
+	t.Run("Should Not Replicate When Refused", func(t *testing.T) {
+		hook := &recordingHook{refuse: "not today"}
+
+		client, recorder := reconcileConfigMap(t, hook)
+
+		err := client.Get(ctx, types.NamespacedName{Name: cm.Name, Namespace: anotherNamespace.Name}, &corev1.ConfigMap{})
+		assert.True(t, apierrors.IsNotFound(err))
+		assert.Empty(t, hook.written)
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonHookRefused)
+	})
+
+	t.Run("Should Mutate The Source Using An Exec Hook", func(t *testing.T) {
+		source := cm.DeepCopy()
+
+		hook := &controller.ExecHook{
+			Command: []string{"sh", "-c", `cat >/dev/null; echo '{"source": {"metadata": {"name": "renamed"}, "data": {"greeting": "hola"}}}'`},
+		}
+
+		require.NoError(t, hook.BeforeTemplate(ctx, source))
+
+		assert.Equal(t, cm.Name, source.Name)
+		assert.Equal(t, map[string]string{"greeting": "hola"}, source.Data)
+	})
+
+	t.Run("Should Fail When An Exec Hook Fails", func(t *testing.T) {
+		hook := &controller.ExecHook{
+			Command: []string{"sh", "-c", "echo oops >&2; exit 1"},
+		}
+
+		err := hook.BeforeTemplate(ctx, cm.DeepCopy())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "oops")
+	})
+
+	t.Run("Should Refuse Using A Webhook Hook", func(t *testing.T) {
+		var req controller.HookRequest
+		req.Source = &corev1.ConfigMap{}
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+			_ = json.NewEncoder(w).Encode(controller.HookResponse{Refused: "not today"})
+		}))
+		t.Cleanup(srv.Close)
+
+		hook := &controller.WebhookHook{URL: srv.URL}
+
+		err := hook.BeforeTemplate(ctx, cm.DeepCopy())
+
+		var refused *controller.HookRefusedError
+		require.ErrorAs(t, err, &refused)
+		assert.Equal(t, "not today", refused.Reason)
+
+		assert.Equal(t, controller.HookPhaseBeforeTemplate, req.Phase)
+		assert.Equal(t, cm.Name, req.Source.GetName())
+	})
+
+	t.Run("Should Fail When A Webhook Hook Returns An Error Status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(srv.Close)
+
+		hook := &controller.WebhookHook{URL: srv.URL}
+
+		err := hook.AfterWrite(ctx, cm, cm)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "503")
+	})
+}
+
+type recordingHook struct {
+	mutate  func(source ctrlclient.Object)
+	refuse  string
+	written []string
+}
+
+func (h *recordingHook) BeforeTemplate(_ context.Context, source ctrlclient.Object) error {
+	if h.refuse != "" {
+		return &controller.HookRefusedError{Reason: h.refuse}
+	}
+
+	if h.mutate != nil {
+		h.mutate(source)
+	}
+
+	return nil
+}
+
+func (h *recordingHook) AfterWrite(_ context.Context, _, replica ctrlclient.Object) error {
+	h.written = append(h.written, replica.GetNamespace())
+
+	return nil
+}
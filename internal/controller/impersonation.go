@@ -0,0 +1,103 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// +kubebuilder:rbac:groups=core,resources=serviceaccounts;groups,verbs=impersonate
+
+// WriterFactory returns the client used to write replicas into the given namespace.
+type WriterFactory func(namespace string) (client.Client, error)
+
+// NewImpersonatingWriterFactory returns a WriterFactory whose clients impersonate
+// the named service account in each target namespace. This limits replica writes
+// to the permissions that have been delegated to that service account.
+func NewImpersonatingWriterFactory(config *rest.Config, scheme *runtime.Scheme, serviceAccountName string) WriterFactory {
+	var mu sync.Mutex
+	var mapper meta.RESTMapper
+	writers := make(map[string]client.Client)
+
+	return func(namespace string) (client.Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		impersonate := ImpersonationConfigFor(namespace, serviceAccountName)
+		if writer, ok := writers[impersonate.UserName]; ok {
+			return writer, nil
+		}
+
+		// Share a single RESTMapper between the clients, rather than each
+		// discovering the API for itself.
+		if mapper == nil {
+			httpClient, err := rest.HTTPClientFor(config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create http client: %w", err)
+			}
+
+			mapper, err = apiutil.NewDynamicRESTMapper(config, httpClient)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create rest mapper: %w", err)
+			}
+		}
+
+		impersonatingConfig := rest.CopyConfig(config)
+		impersonatingConfig.Impersonate = impersonate
+
+		writer, err := client.New(impersonatingConfig, client.Options{Scheme: scheme, Mapper: mapper})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create impersonating client: %w", err)
+		}
+
+		writers[impersonate.UserName] = writer
+
+		return writer, nil
+	}
+}
+
+// ImpersonationConfigFor returns the impersonation config for the named service
+// account, including the groups it would be authenticated with, so that RBAC
+// bound to the service accounts of a namespace also applies.
+func ImpersonationConfigFor(namespace, serviceAccountName string) rest.ImpersonationConfig {
+	return rest.ImpersonationConfig{
+		UserName: fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccountName),
+		Groups: []string{
+			"system:serviceaccounts",
+			"system:serviceaccounts:" + namespace,
+			"system:authenticated",
+		},
+	}
+}
+
+// writerFor returns the client used to write replicas into the given namespace,
+// falling back to the reconciler's own client if no writer factory is configured.
+func writerFor(c client.Client, writers WriterFactory, namespace string) (client.Client, error) {
+	if writers == nil {
+		return c, nil
+	}
+
+	return writers(namespace)
+}
@@ -0,0 +1,53 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+func TestImpersonatingWriterFactory(t *testing.T) {
+	t.Run("Should Impersonate Service Account And Its Groups", func(t *testing.T) {
+		impersonate := controller.ImpersonationConfigFor("team-a", "replikator-writer")
+
+		assert.Equal(t, "system:serviceaccount:team-a:replikator-writer", impersonate.UserName)
+		assert.ElementsMatch(t, []string{"system:serviceaccounts", "system:serviceaccounts:team-a", "system:authenticated"}, impersonate.Groups)
+	})
+
+	t.Run("Should Cache Clients Per Service Account", func(t *testing.T) {
+		writers := controller.NewImpersonatingWriterFactory(&rest.Config{Host: "https://127.0.0.1:6443"}, scheme.Scheme, "replikator-writer")
+
+		teamA, err := writers("team-a")
+		require.NoError(t, err)
+
+		again, err := writers("team-a")
+		require.NoError(t, err)
+
+		teamB, err := writers("team-b")
+		require.NoError(t, err)
+
+		assert.Same(t, teamA, again)
+		assert.NotSame(t, teamA, teamB)
+	})
+}
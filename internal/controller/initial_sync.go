@@ -0,0 +1,133 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// InitialSync reconciles every source once on startup, in priority order (see
+// listSources), so that replikator converges predictably when it is upgraded
+// or restarted. Until it completes, reconciles triggered by the controllers
+// are held back (see Policy.InitialSync) and the readiness check fails.
+type InitialSync struct {
+	Secrets    *SecretReconciler
+	ConfigMaps *ConfigMapReconciler
+	// Elected, if set, is closed once this instance is elected leader. Only
+	// the leader syncs, so other instances are always reported as ready.
+	Elected <-chan struct{}
+
+	once sync.Once
+	done chan struct{}
+}
+
+// Start implements manager.Runnable.
+func (s *InitialSync) Start(ctx context.Context) error {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx))).With("component", "initial-sync")
+	defer close(s.doneCh())
+
+	// The initial sync must not wait for itself.
+	secrets, configMaps := *s.Secrets, *s.ConfigMaps
+	secrets.Policy.InitialSync, configMaps.Policy.InitialSync = nil, nil
+
+	start := time.Now()
+
+	sources, err := listSources(ctx, &secrets, &configMaps)
+	if err != nil {
+		logger.Error("Failed to list sources, skipping initial sync", "error", err)
+
+		return nil
+	}
+
+	logger.Info("Starting initial sync", "sources", len(sources))
+
+	var synced, failed int
+	for i, obj := range sources {
+		initialSyncSources.WithLabelValues("pending").Set(float64(len(sources) - i))
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err := reconcileSource(ctx, &secrets, &configMaps, obj); err != nil {
+			logger.Warn("Failed to sync source", "error", err)
+
+			failed++
+			initialSyncSources.WithLabelValues("failed").Set(float64(failed))
+		} else {
+			synced++
+			initialSyncSources.WithLabelValues("synced").Set(float64(synced))
+		}
+	}
+
+	initialSyncSources.WithLabelValues("pending").Set(0)
+
+	logger.Info("Initial sync complete",
+		"sources", len(sources), "synced", synced, "failed", failed, "duration", time.Since(start))
+
+	return nil
+}
+
+// Wait blocks until the initial sync has completed (or the context is done).
+func (s *InitialSync) Wait(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	select {
+	case <-s.doneCh():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ready is a readiness check (healthz.Checker) that fails until the initial
+// sync has completed.
+func (s *InitialSync) Ready(_ *http.Request) error {
+	if s.Elected != nil {
+		select {
+		case <-s.Elected:
+		default:
+			return nil
+		}
+	}
+
+	select {
+	case <-s.doneCh():
+		return nil
+	default:
+		return errors.New("initial sync in progress")
+	}
+}
+
+func (s *InitialSync) doneCh() chan struct{} {
+	s.once.Do(func() {
+		s.done = make(chan struct{})
+	})
+
+	return s.done
+}
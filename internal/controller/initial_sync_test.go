@@ -0,0 +1,116 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestInitialSync(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		}
+	}
+
+	objects := []ctrlclient.Object{
+		&corev1.ConfigMap{ObjectMeta: objectMeta("a-config"), Data: map[string]string{"foo": "bar"}},
+		&corev1.Secret{ObjectMeta: objectMeta("b-opaque"), Data: map[string][]byte{"foo": []byte("bar")}},
+		&corev1.Secret{ObjectMeta: objectMeta("c-pull"), Type: corev1.SecretTypeDockerConfigJson, Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")}},
+		&corev1.Secret{ObjectMeta: objectMeta("d-tls"), Type: corev1.SecretTypeTLS, Data: map[string][]byte{corev1.TLSCertKey: []byte("test-cert")}},
+		&corev1.Secret{ObjectMeta: objectMeta("e-ca"), Data: map[string][]byte{"ca.crt": []byte("test-ca")}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "another-namespace"}},
+	}
+
+	ctx := context.Background()
+
+	client := fake.NewClientBuilder().
+		WithObjects(objects...).
+		Build()
+
+	var synced []string
+	hook := &recordingHook{mutate: func(source ctrlclient.Object) {
+		synced = append(synced, source.GetName())
+	}}
+
+	initialSync := &controller.InitialSync{}
+
+	policy := controller.Policy{
+		Hooks:       []controller.Hook{hook},
+		InitialSync: initialSync,
+	}
+
+	initialSync.Secrets = &controller.SecretReconciler{Client: client, Scheme: scheme.Scheme, Policy: policy}
+	initialSync.ConfigMaps = &controller.ConfigMapReconciler{Client: client, Scheme: scheme.Scheme, Policy: policy}
+
+	t.Run("Should Hold Back Reconciles Until Complete", func(t *testing.T) {
+		require.Error(t, initialSync.Ready(nil))
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := initialSync.Secrets.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: "b-opaque", Namespace: "test-namespace"},
+		})
+		require.ErrorIs(t, err, context.Canceled)
+
+		assert.Empty(t, synced)
+	})
+
+	t.Run("Should Sync Sources In Priority Order", func(t *testing.T) {
+		require.NoError(t, initialSync.Start(ctx))
+
+		assert.Equal(t, []string{"d-tls", "e-ca", "c-pull", "b-opaque", "a-config"}, synced)
+
+		require.NoError(t, initialSync.Ready(nil))
+		require.NoError(t, initialSync.Wait(ctx))
+
+		var replica corev1.Secret
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: "d-tls", Namespace: "another-namespace"}, &replica))
+	})
+
+	t.Run("Should Be Ready Unless Elected", func(t *testing.T) {
+		notElected := &controller.InitialSync{Elected: make(chan struct{})}
+
+		require.NoError(t, notElected.Ready(nil))
+	})
+}
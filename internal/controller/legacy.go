@@ -0,0 +1,89 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"strings"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// hasFinalizer returns true if the object has either the current or a legacy finalizer.
+func hasFinalizer(obj client.Object) bool {
+	if controllerutil.ContainsFinalizer(obj, api.Finalizer()) {
+		return true
+	}
+
+	for _, finalizer := range api.LegacyFinalizers() {
+		if controllerutil.ContainsFinalizer(obj, finalizer) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeFinalizers removes both the current and any legacy finalizers from the object.
+func removeFinalizers(obj client.Object) {
+	controllerutil.RemoveFinalizer(obj, api.Finalizer())
+
+	for _, finalizer := range api.LegacyFinalizers() {
+		controllerutil.RemoveFinalizer(obj, finalizer)
+	}
+}
+
+// MigrateAnnotations rewrites any legacy annotations (and finalizers) on the
+// object to their current equivalents. Returns true if the object was modified.
+func MigrateAnnotations(obj client.Object) bool {
+	var changed bool
+
+	annotations := obj.GetAnnotations()
+	for key, value := range annotations {
+		if !api.IsLegacyAnnotation(key) {
+			continue
+		}
+
+		for _, prefix := range api.LegacyKeyPrefixes() {
+			if strings.HasPrefix(key, prefix) {
+				currentKey := api.KeyPrefix() + strings.TrimPrefix(key, prefix)
+				if _, ok := annotations[currentKey]; !ok {
+					annotations[currentKey] = value
+				}
+				break
+			}
+		}
+
+		delete(annotations, key)
+		changed = true
+	}
+
+	if changed {
+		obj.SetAnnotations(annotations)
+	}
+
+	for _, finalizer := range api.LegacyFinalizers() {
+		if controllerutil.RemoveFinalizer(obj, finalizer) {
+			controllerutil.AddFinalizer(obj, api.Finalizer())
+			changed = true
+		}
+	}
+
+	return changed
+}
@@ -0,0 +1,64 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLegacyAnnotations(t *testing.T) {
+	legacySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				"v1alpha1.tls-replicator.gpuninja.com/enabled":        "true",
+				"v1alpha1.tls-replicator.gpuninja.com/replicate-keys": "ca.crt",
+			},
+			Finalizers: []string{"replikator.gpu-ninja.com/finalizer"},
+		},
+	}
+
+	t.Run("Should Recognize Legacy Annotations", func(t *testing.T) {
+		assert.True(t, api.IsReplicationEnabled(legacySecret))
+
+		ok, err := api.ShouldReplicateKey(legacySecret, "tls.key")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Should Migrate Legacy Annotations", func(t *testing.T) {
+		secret := legacySecret.DeepCopy()
+
+		assert.True(t, controller.MigrateAnnotations(secret))
+
+		assert.Equal(t, map[string]string{
+			api.Key(api.AnnotationEnabled):       "true",
+			api.Key(api.AnnotationReplicateKeys): "ca.crt",
+		}, secret.Annotations)
+		assert.Equal(t, []string{api.Finalizer()}, secret.Finalizers)
+
+		assert.False(t, controller.MigrateAnnotations(secret))
+	})
+}
@@ -0,0 +1,409 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups=work.open-cluster-management.io,resources=manifestworks,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclusters,verbs=get;list;watch
+
+var (
+	// ManifestWorkGVK is the group, version and kind of Open Cluster Management ManifestWorks.
+	ManifestWorkGVK = schema.GroupVersionKind{Group: "work.open-cluster-management.io", Version: "v1", Kind: "ManifestWork"}
+	// ManagedClusterGVK is the group, version and kind of Open Cluster Management ManagedClusters.
+	ManagedClusterGVK = schema.GroupVersionKind{Group: "cluster.open-cluster-management.io", Version: "v1", Kind: "ManagedCluster"}
+)
+
+// ManifestWorkReconciler distributes sources annotated with
+// replicate-to-clusters to Open Cluster Management managed clusters, by
+// managing a ManifestWork containing the replicas of the source in the
+// namespace of each matching cluster on the hub. The work agent of each
+// cluster then applies the replicas (and deletes them along with the
+// ManifestWork), and reports whether they were applied.
+//
+// As the namespaces of managed clusters can't be listed from the hub, replicas
+// are written to the namespace of the source, or to the literal namespace
+// names of its replicate-to filter. Tenancy is not enforced.
+type ManifestWorkReconciler[T client.Object] struct {
+	client.Client
+	Recorder record.EventRecorder
+	Policy   Policy
+	// Replicator implements the kind specific parts of replication.
+	Replicator Replicator[T]
+}
+
+func (r *ManifestWorkReconciler[T]) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+	policy := r.Policy.current()
+
+	obj := r.Replicator.NewObject()
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, r.deleteWorks(ctx, logger, req.NamespacedName, nil)
+	}
+
+	if !api.IsReplicationEnabled(obj) || !api.IsManifestWorkSource(obj) || api.IsReplica(obj) ||
+		!obj.GetDeletionTimestamp().IsZero() || !policy.InScope(obj.GetNamespace()) {
+		return ctrl.Result{}, r.deleteWorks(ctx, logger, req.NamespacedName, nil)
+	}
+
+	source := obj.DeepCopyObject().(T)
+	policy.applyDefaults(source)
+
+	if reason, message, refused := r.Replicator.Refuse(&policy, source); refused {
+		recordEvent(r.Policy.Events, r.Recorder, obj, corev1.EventTypeWarning, reason, "%s", message)
+
+		return ctrl.Result{}, nil
+	}
+
+	manifests, err := r.manifests(&policy, source)
+	if err != nil {
+		logger.Warn("Refusing to distribute to managed clusters", "error", err)
+
+		r.event(obj, "Not distributing to managed clusters: %s", err)
+
+		return ctrl.Result{}, nil
+	}
+
+	clusters, err := r.targetClusters(ctx, source)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	name := manifestWorkName(r.Replicator.Kind(), req.NamespacedName)
+
+	for _, cluster := range clusters {
+		if err := r.writeWork(ctx, logger, obj, cluster, name, manifests); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Clusters that are no longer targeted have their replicas deleted.
+	return ctrl.Result{}, r.deleteWorks(ctx, logger, req.NamespacedName, clusters)
+}
+
+func (r *ManifestWorkReconciler[T]) SetupWithManager(mgr ctrl.Manager) error {
+	kind := r.Replicator.Kind()
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(kind+"-manifestwork-controller").
+		For(r.Replicator.NewObject()).
+		// Requeue the source when one of its ManifestWorks is modified (eg. its
+		// status is updated by the work agent) or deleted.
+		Watches(newManifestWork(), handler.EnqueueRequestsFromMapFunc(func(_ context.Context, obj client.Object) []ctrl.Request {
+			ref, _, ok := api.GetSourceReference(obj)
+			if !ok || !api.IsReplica(obj) || obj.GetName() != manifestWorkName(kind, ref) {
+				return nil
+			}
+
+			return []ctrl.Request{{NamespacedName: ref}}
+		})).
+		// Requeue every distributed source when a cluster joins (or leaves) the hub.
+		Watches(newManagedCluster(), handler.EnqueueRequestsFromMapFunc(r.allSources)).
+		Complete(r)
+}
+
+func (r *ManifestWorkReconciler[T]) allSources(ctx context.Context, _ client.Object) []ctrl.Request {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+
+	sources, err := r.Replicator.ListExisting(ctx, r.Client)
+	if err != nil {
+		logger.Error("Failed to list sources", "error", err)
+
+		return nil
+	}
+
+	var reqs []ctrl.Request
+	for _, source := range sources {
+		if api.IsManifestWorkSource(source) {
+			reqs = append(reqs, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(source)})
+		}
+	}
+
+	return reqs
+}
+
+// manifests returns the replicas of the source to be applied to each managed cluster.
+func (r *ManifestWorkReconciler[T]) manifests(policy *Policy, source T) ([]any, error) {
+	namespaces := []string{source.GetNamespace()}
+	if replicateTo, ok := api.GetAnnotation(source, api.Key(api.AnnotationReplicateTo)); ok {
+		literal, err := literalNamespaces(api.ParseFilters(replicateTo))
+		if err != nil {
+			return nil, fmt.Errorf("replicate-to: %w", err)
+		}
+
+		namespaces = nil
+		for _, namespace := range literal {
+			namespaces = append(namespaces, namespace.(string))
+		}
+	}
+
+	template, err := r.Replicator.GetTemplate(source, policy.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	gvk, err := apiutil.GVKForObject(template, r.Scheme())
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []any
+	for _, namespace := range namespaces {
+		if policy.IsProtectedNamespace(namespace) || !policy.InScope(namespace) {
+			continue
+		}
+
+		if excluded, err := api.IsExcludedNamespace(source, namespace); err != nil {
+			return nil, err
+		} else if excluded {
+			continue
+		}
+
+		replica := template.DeepCopyObject().(client.Object)
+		replica.SetNamespace(namespace)
+		api.SetHelmOwnership(source, replica)
+
+		manifest, err := runtime.DefaultUnstructuredConverter.ToUnstructured(replica)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert replica: %w", err)
+		}
+
+		manifest["apiVersion"] = gvk.GroupVersion().String()
+		manifest["kind"] = gvk.Kind
+		unstructured.RemoveNestedField(manifest, "metadata", "creationTimestamp")
+
+		manifests = append(manifests, manifest)
+	}
+
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no permitted target namespaces")
+	}
+
+	return manifests, nil
+}
+
+// targetClusters returns the names of the managed clusters the source is distributed to.
+func (r *ManifestWorkReconciler[T]) targetClusters(ctx context.Context, source T) ([]string, error) {
+	managedClusters := &unstructured.UnstructuredList{}
+	managedClusters.SetGroupVersionKind(ManagedClusterGVK.GroupVersion().WithKind(ManagedClusterGVK.Kind + "List"))
+	if err := r.List(ctx, managedClusters); err != nil {
+		return nil, fmt.Errorf("failed to list managed clusters: %w", err)
+	}
+
+	var clusters []string
+	for _, managedCluster := range managedClusters.Items {
+		if !managedCluster.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+
+		ok, err := api.ShouldReplicateToCluster(source, managedCluster.GetName())
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			clusters = append(clusters, managedCluster.GetName())
+		}
+	}
+
+	return clusters, nil
+}
+
+// writeWork creates or updates the ManifestWork of the source in the
+// namespace of the managed cluster, and reports if it failed to apply.
+func (r *ManifestWorkReconciler[T]) writeWork(ctx context.Context, logger *slog.Logger, source client.Object, cluster, name string, manifests []any) error {
+	logger = logger.With("cluster", cluster)
+	ref := client.ObjectKeyFromObject(source)
+
+	work := newManifestWork()
+	if err := r.Get(ctx, types.NamespacedName{Namespace: cluster, Name: name}, work); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get manifestwork: %w", err)
+		}
+
+		work = newManifestWork()
+		work.SetNamespace(cluster)
+		work.SetName(name)
+	} else if !isManifestWorkOf(work, ref) {
+		logger.Warn("ManifestWork already exists and is not managed by replikator")
+
+		r.event(source, "Not distributing to cluster %s, a ManifestWork named %s that is not managed by replikator already exists", cluster, name)
+
+		return nil
+	} else if reason, failed := manifestWorkFailure(work); failed {
+		recordEvent(r.Policy.Events, r.Recorder, source, corev1.EventTypeWarning, EventReasonManifestWorkFailed,
+			"Replicas could not be applied to cluster %s: %s", cluster, reason)
+	}
+
+	desired := work.DeepCopy()
+
+	labels := desired.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[api.LabelManagedByKey] = api.LabelManagedByValue
+	labels[api.Key(api.LabelSourceUID)] = string(source.GetUID())
+	desired.SetLabels(labels)
+
+	annotations := desired.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[api.Key(api.AnnotationSourceNamespace)] = ref.Namespace
+	annotations[api.Key(api.AnnotationSourceName)] = ref.Name
+	desired.SetAnnotations(annotations)
+
+	if err := unstructured.SetNestedSlice(desired.Object, manifests, "spec", "workload", "manifests"); err != nil {
+		return fmt.Errorf("failed to set manifestwork manifests: %w", err)
+	}
+
+	if desired.GetResourceVersion() == "" {
+		logger.Info("Creating manifestwork")
+
+		if err := r.Create(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create manifestwork: %w", err)
+		}
+
+		return nil
+	}
+
+	if reflect.DeepEqual(work, desired) {
+		return nil
+	}
+
+	logger.Info("Updating manifestwork")
+
+	if err := r.Update(ctx, desired); err != nil {
+		return fmt.Errorf("failed to update manifestwork: %w", err)
+	}
+
+	return nil
+}
+
+// deleteWorks deletes the ManifestWorks of the source, other than those in
+// the namespaces of the given clusters.
+func (r *ManifestWorkReconciler[T]) deleteWorks(ctx context.Context, logger *slog.Logger, source types.NamespacedName, except []string) error {
+	works := &unstructured.UnstructuredList{}
+	works.SetGroupVersionKind(ManifestWorkGVK.GroupVersion().WithKind(ManifestWorkGVK.Kind + "List"))
+	if err := r.List(ctx, works, client.MatchingLabels{api.LabelManagedByKey: api.LabelManagedByValue}); err != nil {
+		return fmt.Errorf("failed to list manifestworks: %w", err)
+	}
+
+	name := manifestWorkName(r.Replicator.Kind(), source)
+
+	for i := range works.Items {
+		work := &works.Items[i]
+		if work.GetName() != name || !isManifestWorkOf(work, source) || slices.Contains(except, work.GetNamespace()) {
+			continue
+		}
+
+		logger.Info("Deleting manifestwork", "cluster", work.GetNamespace())
+
+		if err := r.Delete(ctx, work); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete manifestwork: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *ManifestWorkReconciler[T]) event(obj client.Object, messageFmt string, args ...any) {
+	recordEvent(r.Policy.Events, r.Recorder, obj, corev1.EventTypeWarning, EventReasonManifestWorkRefused, messageFmt, args...)
+}
+
+// manifestWorkName returns the name of the ManifestWorks of a source. Names
+// are derived from a hash, as the namespace and name of the source may not
+// both fit in a name.
+func manifestWorkName(kind string, source types.NamespacedName) string {
+	sum := sha256.Sum256([]byte(source.String()))
+
+	return fmt.Sprintf("replikator-%s-%s", kind, hex.EncodeToString(sum[:])[:10])
+}
+
+// manifestWorkFailure returns the message of the Applied condition of the
+// ManifestWork, if the work agent failed to apply it.
+func manifestWorkFailure(work *unstructured.Unstructured) (string, bool) {
+	conditions, _, _ := unstructured.NestedSlice(work.Object, "status", "conditions")
+	for _, condition := range conditions {
+		condition, ok := condition.(map[string]any)
+		if !ok || condition["type"] != "Applied" {
+			continue
+		}
+
+		if condition["status"] == string(metav1.ConditionFalse) {
+			message, _ := condition["message"].(string)
+			return message, true
+		}
+	}
+
+	return "", false
+}
+
+// isManifestWorkSource returns true if the source is distributed to managed
+// clusters by the ManifestWorkReconciler (rather than copied to each namespace).
+func isManifestWorkSource(policy *Policy, obj client.Object) bool {
+	return policy.ManifestWorks && api.IsManifestWorkSource(obj)
+}
+
+// isManifestWorkOf returns true if the ManifestWork is managed by replikator on behalf of the source.
+func isManifestWorkOf(work client.Object, source types.NamespacedName) bool {
+	ref, _, ok := api.GetSourceReference(work)
+	return api.IsReplica(work) && ok && ref == source
+}
+
+func newManifestWork() *unstructured.Unstructured {
+	work := &unstructured.Unstructured{}
+	work.SetGroupVersionKind(ManifestWorkGVK)
+
+	return work
+}
+
+func newManagedCluster() *unstructured.Unstructured {
+	managedCluster := &unstructured.Unstructured{}
+	managedCluster.SetGroupVersionKind(ManagedClusterGVK)
+
+	return managedCluster
+}
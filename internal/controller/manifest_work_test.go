@@ -0,0 +1,184 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestManifestWorkReconciler(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(controller.ManifestWorkGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(controller.ManifestWorkGVK.GroupVersion().WithKind("ManifestWorkList"), &unstructured.UnstructuredList{})
+	scheme.AddKnownTypeWithName(controller.ManagedClusterGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(controller.ManagedClusterGVK.GroupVersion().WithKind("ManagedClusterList"), &unstructured.UnstructuredList{})
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-namespace",
+			UID:       "test-uid",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled):             "true",
+				api.Key(api.AnnotationReplicateToClusters): "cluster-*",
+			},
+		},
+		Data: map[string][]byte{
+			"password": []byte("hunter2"),
+		},
+	}
+
+	newManagedCluster := func(name string) *unstructured.Unstructured {
+		managedCluster := &unstructured.Unstructured{}
+		managedCluster.SetGroupVersionKind(controller.ManagedClusterGVK)
+		managedCluster.SetName(name)
+
+		return managedCluster
+	}
+
+	managedClusters := []ctrlclient.Object{newManagedCluster("cluster-a"), newManagedCluster("cluster-b"), newManagedCluster("other")}
+
+	ctx := context.Background()
+
+	reconcileSecret := func(t *testing.T, client ctrlclient.Client, recorder *record.FakeRecorder) {
+		r := &controller.ManifestWorkReconciler[*corev1.Secret]{
+			Client:     client,
+			Recorder:   recorder,
+			Policy:     controller.Policy{ManifestWorks: true},
+			Replicator: controller.SecretReplicator{},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	listWorks := func(t *testing.T, client ctrlclient.Client, cluster string) []unstructured.Unstructured {
+		works := &unstructured.UnstructuredList{}
+		works.SetGroupVersionKind(controller.ManifestWorkGVK.GroupVersion().WithKind("ManifestWorkList"))
+		require.NoError(t, client.List(ctx, works, ctrlclient.InNamespace(cluster)))
+
+		return works.Items
+	}
+
+	t.Run("Should Create ManifestWorks For Matching Clusters", func(t *testing.T) {
+		source := secret.DeepCopy()
+		source.Annotations[api.Key(api.AnnotationReplicateTo)] = "app-a,app-b"
+
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(append([]ctrlclient.Object{source}, managedClusters...)...).
+			Build()
+
+		reconcileSecret(t, client, record.NewFakeRecorder(10))
+
+		for _, cluster := range []string{"cluster-a", "cluster-b"} {
+			works := listWorks(t, client, cluster)
+			require.Len(t, works, 1, cluster)
+
+			work := works[0]
+			assert.True(t, api.IsReplica(&work))
+
+			manifests, _, err := unstructured.NestedSlice(work.Object, "spec", "workload", "manifests")
+			require.NoError(t, err)
+			require.Len(t, manifests, 2)
+
+			var namespaces []string
+			for _, manifest := range manifests {
+				replica := &unstructured.Unstructured{Object: manifest.(map[string]any)}
+				assert.Equal(t, "Secret", replica.GetKind())
+				assert.Equal(t, secret.Name, replica.GetName())
+				assert.Equal(t, "test-uid", replica.GetLabels()[api.Key(api.LabelSourceUID)])
+
+				namespaces = append(namespaces, replica.GetNamespace())
+			}
+			assert.ElementsMatch(t, []string{"app-a", "app-b"}, namespaces)
+		}
+
+		assert.Empty(t, listWorks(t, client, "other"))
+	})
+
+	t.Run("Should Delete ManifestWorks When Disabled", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(append([]ctrlclient.Object{secret.DeepCopy()}, managedClusters...)...).
+			Build()
+
+		reconcileSecret(t, client, record.NewFakeRecorder(10))
+		require.Len(t, listWorks(t, client, "cluster-a"), 1)
+
+		var source corev1.Secret
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, &source))
+
+		source.Annotations[api.Key(api.AnnotationReplicateToClusters)] = "cluster-b"
+		require.NoError(t, client.Update(ctx, &source))
+
+		reconcileSecret(t, client, record.NewFakeRecorder(10))
+		assert.Empty(t, listWorks(t, client, "cluster-a"))
+		assert.Len(t, listWorks(t, client, "cluster-b"), 1)
+
+		require.NoError(t, client.Delete(ctx, &source))
+
+		reconcileSecret(t, client, record.NewFakeRecorder(10))
+		assert.Empty(t, listWorks(t, client, "cluster-b"))
+	})
+
+	t.Run("Should Refuse Glob Target Namespaces", func(t *testing.T) {
+		source := secret.DeepCopy()
+		source.Annotations[api.Key(api.AnnotationReplicateTo)] = "app-*"
+
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(append([]ctrlclient.Object{source}, managedClusters...)...).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+		reconcileSecret(t, client, recorder)
+
+		assert.Empty(t, listWorks(t, client, "cluster-a"))
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonManifestWorkRefused)
+	})
+}
@@ -0,0 +1,72 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var throttledReconcilesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "replikator_throttled_reconciles_total",
+	Help: "Number of reconciles delayed due to per-namespace write rate limiting",
+}, []string{"namespace"})
+
+var replicaRepairsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "replikator_replica_repairs_total",
+	Help: "Number of replicas reverted as they were modified directly (rather than their source having changed)",
+}, []string{"kind"})
+
+var orphansDeletedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "replikator_orphans_deleted_total",
+	Help: "Number of orphaned replicas deleted by the garbage collector",
+})
+
+var invalidFiltersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "replikator_invalid_filters_total",
+	Help: "Number of reconciles that ignored malformed filter patterns",
+}, []string{"kind"})
+
+var contendedReplicasTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "replikator_contended_replicas_total",
+	Help: "Number of times a replica was found to be contended by another writer (and left unrepaired)",
+}, []string{"kind", "namespace"})
+
+var replicaDiscrepancies = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "replikator_replica_discrepancies",
+	Help: "Number of replicas that did not match their source during the last verification",
+}, []string{"kind", "type"})
+
+var reconcileTimeoutsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "replikator_reconcile_timeouts_total",
+	Help: "Number of reconciles that timed out before all replicas were written",
+}, []string{"kind"})
+
+var certificateExpired = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "replikator_certificate_expired",
+	Help: "Whether the certificate of a source has expired without being renewed (1) or not (0)",
+}, []string{"kind", "namespace", "name"})
+
+var initialSyncSources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "replikator_initial_sync_sources",
+	Help: "Number of sources pending, synced and failed during the initial sync on startup",
+}, []string{"state"})
+
+func init() {
+	metrics.Registry.MustRegister(throttledReconcilesTotal, replicaRepairsTotal, orphansDeletedTotal, invalidFiltersTotal, contendedReplicasTotal, replicaDiscrepancies, reconcileTimeoutsTotal, certificateExpired, initialSyncSources)
+}
@@ -0,0 +1,143 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReconcileOnce performs a single full reconciliation pass over every
+// replication source in the cluster (rather than running as a long-lived
+// controller), in priority order (see listSources). An error is returned if
+// any source failed to reconcile.
+func ReconcileOnce(ctx context.Context, secretReconciler *SecretReconciler, configMapReconciler *ConfigMapReconciler) error {
+	sources, err := listSources(ctx, secretReconciler, configMapReconciler)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, obj := range sources {
+		if err := reconcileSource(ctx, secretReconciler, configMapReconciler, obj); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// listSources lists every replication source in the cluster, ordered so that
+// the sources other workloads are most likely to depend on are replicated
+// first: CA and TLS secrets, then image pull secrets, then other secrets, and
+// finally configmaps. Sources of the same priority are ordered by namespace
+// and name.
+func listSources(ctx context.Context, secretReconciler *SecretReconciler, configMapReconciler *ConfigMapReconciler) ([]client.Object, error) {
+	var secretListOpts []client.ListOption
+	if secretReconciler.Policy.SecretSelector != nil {
+		secretListOpts = append(secretListOpts, client.MatchingLabelsSelector{Selector: secretReconciler.Policy.SecretSelector})
+	}
+
+	var secrets corev1.SecretList
+	if err := secretReconciler.List(ctx, &secrets, secretListOpts...); err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	var configMaps corev1.ConfigMapList
+	if err := configMapReconciler.List(ctx, &configMaps); err != nil {
+		return nil, fmt.Errorf("failed to list configmaps: %w", err)
+	}
+
+	var sources []client.Object
+	for i := range secrets.Items {
+		if isSource(&secrets.Items[i]) {
+			sources = append(sources, &secrets.Items[i])
+		}
+	}
+
+	for i := range configMaps.Items {
+		if isSource(&configMaps.Items[i]) {
+			sources = append(sources, &configMaps.Items[i])
+		}
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		if pi, pj := sourcePriority(sources[i]), sourcePriority(sources[j]); pi != pj {
+			return pi < pj
+		}
+
+		if sources[i].GetNamespace() != sources[j].GetNamespace() {
+			return sources[i].GetNamespace() < sources[j].GetNamespace()
+		}
+
+		return sources[i].GetName() < sources[j].GetName()
+	})
+
+	return sources, nil
+}
+
+// sourcePriority returns the priority of a source (lower is replicated first).
+func sourcePriority(obj client.Object) int {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return 3
+	}
+
+	switch {
+	case secret.Type == corev1.SecretTypeTLS || len(secret.Data[corev1.ServiceAccountRootCAKey]) > 0:
+		return 0
+	case secret.Type == corev1.SecretTypeDockerConfigJson || secret.Type == corev1.SecretTypeDockercfg:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// reconcileSource reconciles a single source with the reconciler of its kind.
+func reconcileSource(ctx context.Context, secretReconciler *SecretReconciler, configMapReconciler *ConfigMapReconciler, obj client.Object) error {
+	logger := log.FromContext(ctx)
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)}
+
+	switch obj.(type) {
+	case *corev1.Secret:
+		ctx := log.IntoContext(ctx, logger.WithValues("secret", req.NamespacedName))
+		if _, err := secretReconciler.Reconcile(ctx, req); err != nil {
+			return fmt.Errorf("failed to reconcile secret %s: %w", req.NamespacedName, err)
+		}
+	case *corev1.ConfigMap:
+		ctx := log.IntoContext(ctx, logger.WithValues("configmap", req.NamespacedName))
+		if _, err := configMapReconciler.Reconcile(ctx, req); err != nil {
+			return fmt.Errorf("failed to reconcile configmap %s: %w", req.NamespacedName, err)
+		}
+	}
+
+	return nil
+}
+
+// isSource returns true if the object is (or was recently) a replication source.
+func isSource(obj client.Object) bool {
+	return api.IsReplicationEnabled(obj) || hasFinalizer(obj)
+}
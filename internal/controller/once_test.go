@@ -0,0 +1,103 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileOnce(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		Data: map[string][]byte{
+			"foo": []byte("bar"),
+		},
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		Data: map[string]string{
+			"foo": "bar",
+		},
+	}
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	ctx := context.Background()
+
+	client := fake.NewClientBuilder().
+		WithObjects(secret, cm, anotherNamespace).
+		Build()
+
+	err := controller.ReconcileOnce(ctx,
+		&controller.SecretReconciler{Client: client, Scheme: scheme.Scheme},
+		&controller.ConfigMapReconciler{Client: client, Scheme: scheme.Scheme})
+	require.NoError(t, err)
+
+	var replicatedSecret corev1.Secret
+	err = client.Get(ctx, types.NamespacedName{
+		Name:      secret.Name,
+		Namespace: anotherNamespace.Name,
+	}, &replicatedSecret)
+	require.NoError(t, err)
+
+	assert.Equal(t, secret.Data, replicatedSecret.Data)
+
+	var replicatedConfigMap corev1.ConfigMap
+	err = client.Get(ctx, types.NamespacedName{
+		Name:      cm.Name,
+		Namespace: anotherNamespace.Name,
+	}, &replicatedConfigMap)
+	require.NoError(t, err)
+
+	assert.Equal(t, cm.Data, replicatedConfigMap.Data)
+}
@@ -0,0 +1,169 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Changes are the writes the controller would make to the replicas of a source.
+type Changes struct {
+	// Create are the replicas that would be created (or adopted).
+	Create []client.Object
+	// Update are the replicas that would be updated.
+	Update []client.Object
+	// Delete are the replicas that would be deleted.
+	Delete []client.Object
+}
+
+// Empty returns true if there are no changes.
+func (c *Changes) Empty() bool {
+	return len(c.Create) == 0 && len(c.Update) == 0 && len(c.Delete) == 0
+}
+
+// Plan returns the changes the controller would make to the replicas of the
+// source, without modifying the cluster. It runs the same reconciliation as
+// the controller, but with writes recorded rather than performed. The given
+// source is used in place of the source in the cluster (which need not exist),
+// so the effect of changing a source can be previewed before it is applied.
+func Plan(ctx context.Context, c client.Client, policy Policy, source client.Object) (*Changes, error) {
+	// Planning must not consume the write budget, or be mistaken for contention.
+	policy.WriteLimiter = nil
+	policy.Contention = nil
+	// Nor should it record events or update metrics for writes that never happen.
+	policy.Events = NewEventBus()
+
+	// Nothing is written, so there is nothing to notify hooks of.
+	hooks := make([]Hook, 0, len(policy.Hooks))
+	for _, hook := range policy.Hooks {
+		hooks = append(hooks, beforeTemplateOnly{hook})
+	}
+	policy.Hooks = hooks
+
+	planner := &planningClient{Client: c, source: source, changes: &Changes{}}
+
+	var reconciler reconcile.Reconciler
+	switch source.(type) {
+	case *corev1.Secret:
+		reconciler = (&SecretReconciler{Client: planner, Policy: policy}).reconciler()
+	case *corev1.ConfigMap:
+		reconciler = (&ConfigMapReconciler{Client: planner, Policy: policy}).reconciler()
+	default:
+		return nil, fmt.Errorf("unsupported object type %T", source)
+	}
+
+	if _, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(source)}); err != nil {
+		return planner.changes, err
+	}
+
+	return planner.changes, nil
+}
+
+// beforeTemplateOnly runs only the BeforeTemplate phase of a hook.
+type beforeTemplateOnly struct {
+	Hook
+}
+
+func (beforeTemplateOnly) AfterWrite(_ context.Context, _, _ client.Object) error {
+	return nil
+}
+
+// planningClient records writes to replicas (rather than performing them),
+// ignores writes to the source, and reads the given source in place of the
+// source in the cluster.
+type planningClient struct {
+	client.Client
+	source  client.Object
+	changes *Changes
+}
+
+func (c *planningClient) Get(ctx context.Context, key types.NamespacedName, obj client.Object, opts ...client.GetOption) error {
+	if c.isSource(obj, key) {
+		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(c.source.DeepCopyObject()).Elem())
+
+		return nil
+	}
+
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *planningClient) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	if !c.isSource(obj, client.ObjectKeyFromObject(obj)) {
+		c.changes.Create = append(c.changes.Create, obj.DeepCopyObject().(client.Object))
+	}
+
+	return nil
+}
+
+func (c *planningClient) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	if !c.isSource(obj, client.ObjectKeyFromObject(obj)) {
+		c.changes.Update = append(c.changes.Update, obj.DeepCopyObject().(client.Object))
+	}
+
+	return nil
+}
+
+func (c *planningClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, _ ...client.PatchOption) error {
+	if c.isSource(obj, client.ObjectKeyFromObject(obj)) {
+		return nil
+	}
+
+	// Replicas are created, as well as updated, with server-side apply.
+	if patch.Type() == types.ApplyPatchType {
+		existing := obj.DeepCopyObject().(client.Object)
+		if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+
+			c.changes.Create = append(c.changes.Create, obj.DeepCopyObject().(client.Object))
+
+			return nil
+		}
+	}
+
+	c.changes.Update = append(c.changes.Update, obj.DeepCopyObject().(client.Object))
+
+	return nil
+}
+
+func (c *planningClient) Delete(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	if !c.isSource(obj, client.ObjectKeyFromObject(obj)) {
+		c.changes.Delete = append(c.changes.Delete, obj.DeepCopyObject().(client.Object))
+	}
+
+	return nil
+}
+
+func (c *planningClient) DeleteAllOf(_ context.Context, _ client.Object, _ ...client.DeleteAllOfOption) error {
+	return nil
+}
+
+// isSource returns true if the object (with the given key) is the source.
+func (c *planningClient) isSource(obj client.Object, key types.NamespacedName) bool {
+	return reflect.TypeOf(obj) == reflect.TypeOf(c.source) && key == client.ObjectKeyFromObject(c.source)
+}
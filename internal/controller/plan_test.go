@@ -0,0 +1,124 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPlan(t *testing.T) {
+	ctx := context.Background()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		Data: map[string][]byte{
+			"password": []byte("hunter2"),
+		},
+	}
+
+	teamA := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	teamB := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}}
+
+	replica, err := api.SecretTemplate(secret, api.MetadataFilter{})
+	require.NoError(t, err)
+	replica.Namespace = teamB.Name
+	controller.StampSourceHash(replica)
+
+	t.Run("Should Plan Creations Without Writing", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret, teamA).
+			Build()
+
+		changes, err := controller.Plan(ctx, client, controller.Policy{}, secret)
+		require.NoError(t, err)
+
+		require.Len(t, changes.Create, 1)
+		assert.Equal(t, teamA.Name, changes.Create[0].GetNamespace())
+		assert.Empty(t, changes.Update)
+		assert.Empty(t, changes.Delete)
+
+		var replica corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: teamA.Name}, &replica)
+		assert.Error(t, err)
+
+		// Nor should the finalizer have been added to the source.
+		var source corev1.Secret
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, &source))
+		assert.Empty(t, source.Finalizers)
+	})
+
+	t.Run("Should Plan Using The Given Source", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret, teamA, teamB, replica).
+			Build()
+
+		narrowed := secret.DeepCopy()
+		narrowed.Annotations[api.Key(api.AnnotationReplicateTo)] = teamA.Name
+
+		changes, err := controller.Plan(ctx, client, controller.Policy{}, narrowed)
+		require.NoError(t, err)
+
+		require.Len(t, changes.Create, 1)
+		assert.Equal(t, teamA.Name, changes.Create[0].GetNamespace())
+		require.Len(t, changes.Delete, 1)
+		assert.Equal(t, teamB.Name, changes.Delete[0].GetNamespace())
+	})
+
+	t.Run("Should Plan Updates Of Stale Replicas", func(t *testing.T) {
+		staleReplica := replica.DeepCopy()
+		staleReplica.Data["password"] = []byte("hunter1")
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, teamB, staleReplica).
+			Build()
+
+		changes, err := controller.Plan(ctx, client, controller.Policy{}, secret)
+		require.NoError(t, err)
+
+		assert.Empty(t, changes.Create)
+		require.Len(t, changes.Update, 1)
+		assert.Equal(t, []byte("hunter2"), changes.Update[0].(*corev1.Secret).Data["password"])
+	})
+
+	t.Run("Should Plan Nothing For Sources In Sync", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret, teamB, replica).
+			Build()
+
+		changes, err := controller.Plan(ctx, client, controller.Policy{}, secret)
+		require.NoError(t, err)
+
+		assert.True(t, changes.Empty())
+	})
+}
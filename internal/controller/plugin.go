@@ -0,0 +1,133 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// execJSON runs a program, writing the request (as JSON) to its stdin and
+// reading the response (as JSON) from its stdout. An empty response leaves
+// resp unchanged, and a non-zero exit status is treated as an error.
+func execJSON(ctx context.Context, command []string, timeout time.Duration, req, resp any) error {
+	if len(command) == 0 {
+		return errors.New("no command specified")
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	if resp == nil || len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return fmt.Errorf("failed to unmarshal response of %s: %w", command[0], err)
+	}
+
+	return nil
+}
+
+// postJSON POSTs the request (as JSON) to an HTTP endpoint and reads the
+// response (as JSON) from the response body. An empty response leaves resp
+// unchanged, and a non-2xx status is treated as an error.
+func postJSON(ctx context.Context, httpClient *http.Client, url string, timeout time.Duration, req, resp any) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(input))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", url, err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s: %w", url, err)
+	}
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		return fmt.Errorf("%s failed with status %d: %s", url, httpResp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if resp == nil || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(body, resp); err != nil {
+		return fmt.Errorf("failed to unmarshal response of %s: %w", url, err)
+	}
+
+	return nil
+}
+
+// decodeObjectLike unmarshals data into a new object of the same type as obj.
+func decodeObjectLike[T any](obj T, data []byte) (T, error) {
+	decoded := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(T)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		return obj, err
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,271 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Policy holds the cluster-wide replication settings shared by the reconcilers.
+type Policy struct {
+	// ProtectedNamespaces is a list of namespace glob patterns that replikator
+	// will never write to (or delete from), regardless of annotations.
+	ProtectedNamespaces []string
+	// WatchNamespaces, if set, restricts replikator to sources and targets
+	// in the listed namespaces.
+	WatchNamespaces []string
+	// ExcludeNamespaces is a list of namespace glob patterns whose sources
+	// are ignored and which are never selected as targets.
+	ExcludeNamespaces []string
+	// TenantLabel, if set, is a namespace label that must have the same value
+	// on both the source and target namespaces for replication to occur.
+	TenantLabel string
+	// MaxReplicaSize, if non-zero, is the maximum size (in bytes) of the data
+	// of any replica.
+	MaxReplicaSize int
+	// MaxReplicasPerSource, if non-zero, is the maximum number of namespaces
+	// any single source may be replicated to.
+	MaxReplicasPerSource int
+	// RequireKeyFilterForPrivateKeys prevents secrets containing a TLS private
+	// key from being replicated unless they have a replicate-keys annotation
+	// (or explicitly allow their private key to be replicated).
+	RequireKeyFilterForPrivateKeys bool
+	// ValidateCertificates prevents TLS secrets with malformed, expired or
+	// inconsistent certificates from being replicated (see validateCertificates).
+	ValidateCertificates bool
+	// Metadata decides which metadata is copied from sources to replicas.
+	Metadata api.MetadataFilter
+	// WriteLimiter, if set, rate limits replica writes per source namespace.
+	WriteLimiter *WriteLimiter
+	// Contention, if set, stops replikator from repairing replicas that are
+	// repeatedly modified by another writer.
+	Contention *ContentionTracker
+	// NamespacedRBAC treats a lack of permission to access replicas in a
+	// namespace as that namespace having opted out of replication (rather
+	// than as an error).
+	NamespacedRBAC bool
+	// SecretSelector, if set, restricts replikator to reading secrets matching
+	// the selector. As replicas inherit the labels of their source, replicas
+	// of matching sources will also match. It doesn't narrow the permissions
+	// replikator requires, as RBAC can't restrict list and watch by label.
+	SecretSelector labels.Selector
+	// DefaultReplicateTo, if set, is used as the replicate-to filter of sources
+	// that don't specify their own (instead of replicating to all namespaces).
+	DefaultReplicateTo string
+	// DefaultReplicateKeys, if set, provides the replicate-keys filter (by secret
+	// type) of secrets that don't specify their own.
+	DefaultReplicateKeys map[corev1.SecretType]string
+	// ReconcileTimeout, if set, limits the time spent reconciling a single source.
+	ReconcileTimeout time.Duration
+	// Hooks are invoked before the replica template of each source is built
+	// and after each replica is written.
+	Hooks []Hook
+	// Transformations are applied in order to each replica before it is written.
+	Transformations []Transformation
+	// OverwriteExternalSecrets permits objects materialized by the
+	// external-secrets operator to be overwritten by replicas (according to
+	// the conflict policy of the source). By default they are never touched.
+	OverwriteExternalSecrets bool
+	// TrustManager replicates secrets annotated with trust-bundle as
+	// trust-manager Bundles (see TrustBundleReconciler), rather than by
+	// copying them to each namespace.
+	TrustManager bool
+	// ManifestWorks distributes sources annotated with replicate-to-clusters
+	// to Open Cluster Management managed clusters (see ManifestWorkReconciler),
+	// rather than copying them to each namespace.
+	ManifestWorks bool
+	// Runtime, if set, provides settings that override the above while
+	// replikator is running.
+	Runtime *RuntimeConfig
+	// InitialSync, if set, holds back reconciles until the initial sync of
+	// every source (in priority order) has completed.
+	InitialSync *InitialSync
+	// Events, if set, receives replication lifecycle events. Otherwise events
+	// are recorded with the reconciler's event recorder and metrics directly.
+	Events *EventBus
+}
+
+// MatchesSecretSelector returns true if the secret is visible to replikator.
+func (p *Policy) MatchesSecretSelector(secret *corev1.Secret) bool {
+	return p.SecretSelector == nil || p.SecretSelector.Matches(labels.Set(secret.Labels))
+}
+
+// IsOptedOut returns true if the error indicates the target namespace has
+// opted out of replication (by not granting replikator access).
+func (p *Policy) IsOptedOut(err error) bool {
+	return p.NamespacedRBAC && apierrors.IsForbidden(err)
+}
+
+// CheckLimits returns an error if a source with the given data size and
+// number of replicas would exceed the configured guardrails.
+func (p *Policy) CheckLimits(size, replicas int) error {
+	if p.MaxReplicaSize > 0 && size > p.MaxReplicaSize {
+		return fmt.Errorf("replica size of %d bytes exceeds the maximum of %d bytes", size, p.MaxReplicaSize)
+	}
+
+	if p.MaxReplicasPerSource > 0 && replicas > p.MaxReplicasPerSource {
+		return fmt.Errorf("%d target namespaces exceeds the maximum of %d", replicas, p.MaxReplicasPerSource)
+	}
+
+	return nil
+}
+
+// SameTenant returns true if the source and target namespaces belong to the
+// same tenant (or if no tenant label has been configured).
+func (p *Policy) SameTenant(source, target *corev1.Namespace) bool {
+	if p.TenantLabel == "" {
+		return true
+	}
+
+	if source == nil || target == nil {
+		return false
+	}
+
+	sourceTenant, ok := source.Labels[p.TenantLabel]
+	if !ok {
+		return false
+	}
+
+	targetTenant, ok := target.Labels[p.TenantLabel]
+	return ok && sourceTenant == targetTenant
+}
+
+// PermitsTenancy returns true if the source and target namespaces belong to
+// the same tenant, or if the target namespace has consented (with its
+// accept-from-tenants annotation) to replicas from the source's tenant.
+func (p *Policy) PermitsTenancy(source, target *corev1.Namespace) bool {
+	if p.SameTenant(source, target) {
+		return true
+	}
+
+	ok, err := api.AcceptsFromTenant(target, p.TenantOf(source))
+	return err == nil && ok
+}
+
+// TenantOf returns the tenant the namespace belongs to (if any), according to
+// the tenant label (or the Capsule tenant label if none has been configured).
+func (p *Policy) TenantOf(namespace *corev1.Namespace) string {
+	if namespace == nil {
+		return ""
+	}
+
+	if p.TenantLabel == "" {
+		return namespace.Labels[api.CapsuleTenantLabel]
+	}
+
+	return namespace.Labels[p.TenantLabel]
+}
+
+// ShouldReplicateTo returns true if the source should be replicated to the
+// namespace (according to its replicate-to, replicate-to-tenant, and preset
+// annotations).
+func (p *Policy) ShouldReplicateTo(source client.Object, namespace *corev1.Namespace) (bool, error) {
+	// The istio-ca preset only targets namespaces enrolled in the mesh.
+	if api.IsIstioCAPreset(source) && !api.IsMeshEnabled(namespace) {
+		return false, nil
+	}
+
+	if ok, err := api.ShouldReplicateTo(source, namespace.Name); err != nil || !ok {
+		return false, err
+	}
+
+	return api.ShouldReplicateToTenant(source, p.TenantOf(namespace))
+}
+
+// InScope returns true if replikator is permitted to operate on the namespace
+// (as either a source or a target).
+func (p *Policy) InScope(namespace string) bool {
+	if len(p.WatchNamespaces) > 0 && !slices.Contains(p.WatchNamespaces, namespace) {
+		return false
+	}
+
+	for _, pattern := range p.ExcludeNamespaces {
+		if ok, err := filepath.Match(pattern, namespace); err == nil && ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsProtectedNamespace returns true if the namespace matches one of the protected namespace patterns.
+func (p *Policy) IsProtectedNamespace(namespace string) bool {
+	for _, pattern := range p.ProtectedNamespaces {
+		if ok, err := filepath.Match(pattern, namespace); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyDefaults sets the default filters on a source object, where the source
+// doesn't specify its own. The object should be a copy of the source.
+func (p *Policy) applyDefaults(obj client.Object) {
+	if p.DefaultReplicateTo != "" {
+		setDefaultAnnotation(obj, api.Key(api.AnnotationReplicateTo), p.DefaultReplicateTo)
+	}
+
+	if secret, ok := obj.(*corev1.Secret); ok {
+		if replicateKeys, ok := p.DefaultReplicateKeys[secret.Type]; ok {
+			setDefaultAnnotation(obj, api.Key(api.AnnotationReplicateKeys), replicateKeys)
+		}
+	}
+}
+
+func setDefaultAnnotation(obj client.Object, key, value string) {
+	if _, ok := api.GetAnnotation(obj, key); ok {
+		return
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[key] = value
+	obj.SetAnnotations(annotations)
+}
+
+func findNamespace(namespaces *corev1.NamespaceList, name string) *corev1.Namespace {
+	for i := range namespaces.Items {
+		if namespaces.Items[i].Name == name {
+			return &namespaces.Items[i]
+		}
+	}
+
+	return nil
+}
+
+func dataSize[V string | []byte](data map[string]V) int {
+	var size int
+	for key, value := range data {
+		size += len(key) + len(value)
+	}
+
+	return size
+}
@@ -0,0 +1,307 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// replicateFromField is the field index of objects by the source they pull from.
+const replicateFromField = "metadata.annotations.replicate-from"
+
+// PullReconciler fills objects annotated with replicate-from with the data of
+// the named source, so that namespace admins can request a copy of a source
+// without its owner enumerating every target namespace. The source must
+// consent with its replication-allowed annotation.
+//
+// Unlike replicas, pulled objects belong to the namespace that created them:
+// only their data is written (their metadata is left alone), and they are
+// kept (with the last data pulled) if the source is deleted.
+type PullReconciler[T client.Object] struct {
+	client.Client
+	Recorder record.EventRecorder
+	Policy   Policy
+	// Writers, if set, provides the clients used to write pulled objects.
+	Writers WriterFactory
+	// APIReader, if set, reads pulled objects directly from the API server
+	// when writes conflict (otherwise they are read with the client).
+	APIReader client.Reader
+	// Replicator implements the kind specific parts of replication.
+	Replicator Replicator[T]
+}
+
+func (r *PullReconciler[T]) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+	policy := r.Policy.current()
+	kind := r.Replicator.Kind()
+
+	obj := r.Replicator.NewObject()
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	ref, ok, err := api.GetReplicateFrom(obj)
+	if !ok || !obj.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if err != nil {
+		r.refuse(logger, obj, "%v", err)
+
+		return ctrl.Result{}, nil
+	}
+
+	if !r.selects(&policy, obj) || !policy.InScope(obj.GetNamespace()) || policy.IsProtectedNamespace(obj.GetNamespace()) {
+		logger.Debug("Namespace is out of scope")
+
+		return ctrl.Result{}, nil
+	}
+
+	// Objects managed by replikator are written by their own source.
+	if api.IsReplica(obj) {
+		r.refuse(logger, obj, "Not pulling into a %s that is managed by replikator", kind)
+
+		return ctrl.Result{}, nil
+	}
+
+	source := r.Replicator.NewObject()
+	if err := r.Get(ctx, ref, source); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		r.refuse(logger, obj, "Source %s %s does not exist", kind, ref)
+
+		return ctrl.Result{}, nil
+	}
+
+	if !r.selects(&policy, source) || !policy.InScope(source.GetNamespace()) {
+		r.refuse(logger, obj, "Source %s %s is out of scope", kind, ref)
+
+		return ctrl.Result{}, nil
+	}
+
+	allowed, err := api.AllowsReplicationTo(source, obj.GetNamespace())
+	if err != nil || !allowed {
+		r.refuse(logger, obj, "Source %s %s does not allow replication to namespace %s", kind, ref, obj.GetNamespace())
+
+		return ctrl.Result{}, nil
+	}
+
+	if !policy.PermitsTenancy(r.namespace(ctx, source.GetNamespace()), r.namespace(ctx, obj.GetNamespace())) {
+		r.refuse(logger, obj, "Source %s %s belongs to another tenant", kind, ref)
+
+		return ctrl.Result{}, nil
+	}
+
+	policy.applyDefaults(source)
+
+	if reason, message, refused := r.Replicator.Refuse(&policy, source); refused {
+		logger.Warn("Refusing to pull", "reason", reason)
+
+		recordEvent(r.Policy.Events, r.Recorder, obj, corev1.EventTypeWarning, reason, "%s", message)
+
+		return ctrl.Result{}, nil
+	}
+
+	template, err := r.Replicator.GetTemplate(source, policy.Metadata)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Stubs are usually created empty (eg. as an Opaque secret), so their type
+	// is only authoritative once they hold data.
+	change, immutable := r.Replicator.ImmutableChange(obj, template)
+	if immutable && len(objectData(obj)) > 0 {
+		r.refuse(logger, obj, "Not pulling from %s %s as %s", kind, ref, change)
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := policy.CheckLimits(r.Replicator.DataSize(template), 1); err != nil {
+		r.refuse(logger, obj, "Not pulling from %s %s: %v", kind, ref, err)
+
+		return ctrl.Result{}, nil
+	}
+
+	if !immutable && sameData(objectData(obj), objectData(template)) {
+		return ctrl.Result{}, nil
+	}
+
+	logger.Info("Pulling data from source", "source", ref)
+
+	desired := obj.DeepCopyObject().(T)
+	copyData(desired, template)
+	stampSyncedAt(desired)
+
+	writer, err := writerFor(r.Client, r.Writers, obj.GetNamespace())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if immutable {
+		logger.Info("Recreating empty object with immutable change", "change", change)
+
+		recordEvent(r.Policy.Events, r.Recorder, obj, corev1.EventTypeNormal, EventReasonTypeChanged,
+			"Recreating %s as %s", req.NamespacedName, change)
+
+		// Preconditions, so that a stub filled in the meantime isn't lost.
+		uid, resourceVersion := obj.GetUID(), obj.GetResourceVersion()
+		if err := writer.Delete(ctx, obj, client.Preconditions{UID: &uid, ResourceVersion: &resourceVersion}); err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to delete %s: %w", kind, err)
+		}
+
+		desired.SetUID("")
+		desired.SetResourceVersion("")
+		desired.SetCreationTimestamp(metav1.Time{})
+		desired.SetManagedFields(nil)
+
+		if err := writer.Create(ctx, desired); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to recreate %s: %w", kind, err)
+		}
+
+		r.publish(Event{Reason: EventReasonReplicaWritten, Object: source, Namespace: obj.GetNamespace(), Message: "Pulled into " + req.NamespacedName.String()})
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := updateWithRetry(ctx, writer, r.APIReader, desired); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to pull %s: %w", kind, err)
+	}
+
+	r.publish(Event{Reason: EventReasonReplicaWritten, Object: source, Namespace: obj.GetNamespace(), Message: "Pulled into " + req.NamespacedName.String()})
+
+	return ctrl.Result{}, nil
+}
+
+func (r *PullReconciler[T]) SetupWithManager(mgr ctrl.Manager) error {
+	kind := r.Replicator.Kind()
+
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), r.Replicator.NewObject(), replicateFromField, func(obj client.Object) []string {
+		ref, ok, err := api.GetReplicateFrom(obj)
+		if !ok || err != nil {
+			return nil
+		}
+
+		return []string{ref.String()}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index %ss: %w", kind, err)
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(kind+"-pull-controller").
+		For(r.Replicator.NewObject()).
+		// Requeue the objects pulling from a source when it is modified.
+		Watches(r.Replicator.NewObject(), handler.EnqueueRequestsFromMapFunc(r.pullersOf)).
+		Complete(r)
+}
+
+// pullersOf returns the objects pulling from the source.
+func (r *PullReconciler[T]) pullersOf(ctx context.Context, source client.Object) []ctrl.Request {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+
+	pullers, err := r.Replicator.ListExisting(ctx, r.Client, client.MatchingFields{replicateFromField: client.ObjectKeyFromObject(source).String()})
+	if err != nil {
+		logger.Error("Failed to list objects pulling from source", "error", err)
+
+		return nil
+	}
+
+	var reqs []ctrl.Request
+	for _, obj := range pullers {
+		reqs = append(reqs, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+	}
+
+	return reqs
+}
+
+// selects returns true if the object is visible to replikator.
+func (r *PullReconciler[T]) selects(policy *Policy, obj client.Object) bool {
+	selector := r.Replicator.Selector(policy)
+	return selector == nil || selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+// namespace returns the namespace with the given name (or nil if it can't be read).
+func (r *PullReconciler[T]) namespace(ctx context.Context, name string) *corev1.Namespace {
+	var namespace corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, &namespace); err != nil {
+		return nil
+	}
+
+	return &namespace
+}
+
+// refuse records a warning event on an object that could not be filled from its source.
+func (r *PullReconciler[T]) refuse(logger *slog.Logger, obj client.Object, messageFmt string, args ...any) {
+	message := fmt.Sprintf(messageFmt, args...)
+
+	logger.Warn("Refusing to pull", "reason", message)
+
+	recordEvent(r.Policy.Events, r.Recorder, obj, corev1.EventTypeWarning, EventReasonPullRefused, "%s", message)
+}
+
+func (r *PullReconciler[T]) publish(event Event) {
+	event.Kind = r.Replicator.Kind()
+
+	publishEvent(r.Policy.Events, r.Recorder, event)
+}
+
+// copyData replaces the data (and type) of a secret or configmap with that of
+// the template.
+func copyData(dst, template client.Object) {
+	switch dst := dst.(type) {
+	case *corev1.Secret:
+		dst.Type = template.(*corev1.Secret).Type
+		dst.Data = template.(*corev1.Secret).Data
+	case *corev1.ConfigMap:
+		dst.Data = template.(*corev1.ConfigMap).Data
+		dst.BinaryData = template.(*corev1.ConfigMap).BinaryData
+	}
+}
+
+// sameData returns true if both objects have the same keys and values.
+func sameData(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for key, value := range a {
+		if other, ok := b[key]; !ok || !bytes.Equal(value, other) {
+			return false
+		}
+	}
+
+	return true
+}
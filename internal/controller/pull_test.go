@@ -0,0 +1,191 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestPullReconciler(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "registry-credentials",
+			Namespace: "platform",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationReplicationAllowed): "true",
+			},
+		},
+		Data: map[string][]byte{
+			"username": []byte("robot"),
+			"password": []byte("hunter2"),
+		},
+	}
+
+	stub := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "credentials",
+			Namespace: "team-a",
+			Labels: map[string]string{
+				"app.kubernetes.io/name": "my-app",
+			},
+			Annotations: map[string]string{
+				api.Key(api.AnnotationReplicateFrom): "platform/registry-credentials",
+			},
+		},
+	}
+
+	ctx := context.Background()
+
+	pull := func(t *testing.T, source, stub *corev1.Secret) (*corev1.Secret, *record.FakeRecorder) {
+		client := fake.NewClientBuilder().
+			WithObjects(source, stub).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.PullReconciler[*corev1.Secret]{
+			Client:     client,
+			Recorder:   recorder,
+			Replicator: controller.SecretReplicator{},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: stub.Name, Namespace: stub.Namespace}})
+		require.NoError(t, err)
+
+		var pulled corev1.Secret
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: stub.Name, Namespace: stub.Namespace}, &pulled))
+
+		return &pulled, recorder
+	}
+
+	t.Run("Should Fill Object From Source", func(t *testing.T) {
+		pulled, recorder := pull(t, source, stub)
+
+		assert.Equal(t, source.Data, pulled.Data)
+		assert.Empty(t, recorder.Events)
+
+		// Pulled objects keep their own metadata, and aren't managed by replikator.
+		assert.Equal(t, stub.Labels, pulled.Labels)
+		assert.False(t, api.IsReplica(pulled))
+		assert.NotEmpty(t, pulled.Annotations[api.Key(api.AnnotationSyncedAt)])
+	})
+
+	t.Run("Should Only Pull Specified Keys", func(t *testing.T) {
+		filteredSource := source.DeepCopy()
+		filteredSource.Annotations[api.Key(api.AnnotationReplicateKeys)] = "username"
+
+		pulled, _ := pull(t, filteredSource, stub)
+
+		assert.Equal(t, map[string][]byte{"username": []byte("robot")}, pulled.Data)
+	})
+
+	t.Run("Should Refuse Unless Allowed By Source", func(t *testing.T) {
+		disallowedSource := source.DeepCopy()
+		delete(disallowedSource.Annotations, api.Key(api.AnnotationReplicationAllowed))
+
+		pulled, recorder := pull(t, disallowedSource, stub)
+
+		assert.Empty(t, pulled.Data)
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonPullRefused)
+	})
+
+	t.Run("Should Refuse Namespaces Not Allowed By Source", func(t *testing.T) {
+		restrictedSource := source.DeepCopy()
+		restrictedSource.Annotations[api.Key(api.AnnotationReplicationAllowedNamespaces)] = "team-b,team-c"
+
+		pulled, recorder := pull(t, restrictedSource, stub)
+
+		assert.Empty(t, pulled.Data)
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonPullRefused)
+
+		restrictedSource.Annotations[api.Key(api.AnnotationReplicationAllowedNamespaces)] = "team-*"
+
+		pulled, _ = pull(t, restrictedSource, stub)
+
+		assert.Equal(t, source.Data, pulled.Data)
+	})
+
+	t.Run("Should Refuse Missing Sources", func(t *testing.T) {
+		missingStub := stub.DeepCopy()
+		missingStub.Annotations[api.Key(api.AnnotationReplicateFrom)] = "platform/missing"
+
+		pulled, recorder := pull(t, source, missingStub)
+
+		assert.Empty(t, pulled.Data)
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonPullRefused)
+	})
+
+	t.Run("Should Recreate Empty Objects Of Another Type", func(t *testing.T) {
+		tlsSource := source.DeepCopy()
+		tlsSource.Type = corev1.SecretTypeTLS
+		tlsSource.Data = map[string][]byte{
+			corev1.TLSCertKey:       []byte("cert"),
+			corev1.TLSPrivateKeyKey: []byte("key"),
+		}
+
+		opaqueStub := stub.DeepCopy()
+		opaqueStub.Type = corev1.SecretTypeOpaque
+
+		pulled, _ := pull(t, tlsSource, opaqueStub)
+
+		assert.Equal(t, corev1.SecretTypeTLS, pulled.Type)
+		assert.Equal(t, tlsSource.Data, pulled.Data)
+		assert.Equal(t, stub.Labels, pulled.Labels)
+		assert.Equal(t, stub.Annotations[api.Key(api.AnnotationReplicateFrom)], pulled.Annotations[api.Key(api.AnnotationReplicateFrom)])
+	})
+
+	t.Run("Should Refuse To Change The Type Of Filled Objects", func(t *testing.T) {
+		tlsSource := source.DeepCopy()
+		tlsSource.Type = corev1.SecretTypeTLS
+
+		filledStub := stub.DeepCopy()
+		filledStub.Type = corev1.SecretTypeOpaque
+		filledStub.Data = map[string][]byte{"token": []byte("s3cr3t")}
+
+		pulled, recorder := pull(t, tlsSource, filledStub)
+
+		assert.Equal(t, corev1.SecretTypeOpaque, pulled.Type)
+		assert.Equal(t, filledStub.Data, pulled.Data)
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonPullRefused)
+	})
+}
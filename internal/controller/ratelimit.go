@@ -0,0 +1,88 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/utils/clock"
+)
+
+// WriteLimiter rate limits replica writes per source namespace, so that a
+// single noisy tenant cannot consume the operator's entire API budget.
+type WriteLimiter struct {
+	// Clock is the clock used to measure delays (defaults to the real clock).
+	Clock    clock.WithTicker
+	limit    rate.Limit
+	burst    int
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewWriteLimiter creates a new WriteLimiter permitting the given sustained
+// number of writes per second (and burst) for each source namespace.
+func NewWriteLimiter(writesPerSecond float64, burst int) *WriteLimiter {
+	return &WriteLimiter{
+		limit:    rate.Limit(writesPerSecond),
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// Reserve reserves up to n writes on behalf of the source namespace, and
+// returns the number of writes that may be performed immediately. If fewer
+// than n writes were admitted, the duration to wait before the next batch of
+// writes can be admitted is also returned. Large fan-outs are therefore
+// written in batches (of at most the burst size), at the sustained rate.
+func (l *WriteLimiter) Reserve(namespace string, n int) (int, time.Duration) {
+	if l == nil || n <= 0 {
+		return n, 0
+	}
+
+	l.mu.Lock()
+	limiter, ok := l.limiters[namespace]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[namespace] = limiter
+	}
+	l.mu.Unlock()
+
+	now := clockOrDefault(l.Clock).Now()
+
+	admitted := max(min(n, int(limiter.TokensAt(now))), 0)
+	if admitted > 0 {
+		limiter.ReserveN(now, admitted)
+	}
+
+	if admitted == n {
+		return n, 0
+	}
+
+	// The time until the next batch of writes will be admitted.
+	reservation := limiter.ReserveN(now, min(n-admitted, l.burst))
+	if !reservation.OK() {
+		return admitted, time.Second
+	}
+
+	delay := reservation.DelayFrom(now)
+	reservation.CancelAt(now)
+
+	return admitted, delay
+}
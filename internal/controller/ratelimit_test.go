@@ -0,0 +1,116 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/stretchr/testify/assert"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestWriteLimiter(t *testing.T) {
+	t.Run("Should Allow Writes Within Burst", func(t *testing.T) {
+		limiter := controller.NewWriteLimiter(1, 10)
+
+		admitted, delay := limiter.Reserve("tenant-a", 10)
+		assert.Equal(t, 10, admitted)
+		assert.Zero(t, delay)
+	})
+
+	t.Run("Should Throttle Writes Beyond Burst", func(t *testing.T) {
+		limiter := controller.NewWriteLimiter(1, 10)
+
+		admitted, _ := limiter.Reserve("tenant-a", 10)
+		assert.Equal(t, 10, admitted)
+
+		admitted, delay := limiter.Reserve("tenant-a", 5)
+		assert.Zero(t, admitted)
+		assert.Positive(t, delay)
+	})
+
+	t.Run("Should Permit Writes Once Delay Has Elapsed", func(t *testing.T) {
+		clock := testingclock.NewFakeClock(time.Now())
+
+		limiter := controller.NewWriteLimiter(1, 10)
+		limiter.Clock = clock
+
+		limiter.Reserve("tenant-a", 10)
+
+		admitted, delay := limiter.Reserve("tenant-a", 5)
+		assert.Zero(t, admitted)
+		assert.Equal(t, 5*time.Second, delay)
+
+		clock.Step(delay)
+
+		admitted, delay = limiter.Reserve("tenant-a", 5)
+		assert.Equal(t, 5, admitted)
+		assert.Zero(t, delay)
+	})
+
+	t.Run("Should Delay Large Batches In Proportion To Their Size", func(t *testing.T) {
+		// The total time taken to admit every write of a batch.
+		writeAll := func(n int) time.Duration {
+			clock := testingclock.NewFakeClock(time.Now())
+
+			limiter := controller.NewWriteLimiter(10, 100)
+			limiter.Clock = clock
+
+			var total time.Duration
+			for n > 0 {
+				admitted, delay := limiter.Reserve("tenant-a", n)
+				assert.LessOrEqual(t, admitted, 100)
+
+				n -= admitted
+				if n > 0 {
+					assert.Positive(t, delay)
+
+					clock.Step(delay)
+					total += delay
+				}
+			}
+
+			return total
+		}
+
+		// Writes beyond the burst are admitted at the sustained rate.
+		assert.Zero(t, writeAll(100))
+		assert.Equal(t, 90*time.Second, writeAll(1000))
+		assert.Equal(t, 490*time.Second, writeAll(5000))
+	})
+
+	t.Run("Should Limit Namespaces Independently", func(t *testing.T) {
+		limiter := controller.NewWriteLimiter(1, 10)
+
+		admitted, _ := limiter.Reserve("tenant-a", 10)
+		assert.Equal(t, 10, admitted)
+
+		admitted, _ = limiter.Reserve("tenant-b", 10)
+		assert.Equal(t, 10, admitted)
+	})
+
+	t.Run("Should Not Limit When Disabled", func(t *testing.T) {
+		var limiter *controller.WriteLimiter
+
+		admitted, delay := limiter.Reserve("tenant-a", 1000)
+		assert.Equal(t, 1000, admitted)
+		assert.Zero(t, delay)
+	})
+}
@@ -0,0 +1,132 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// stampSyncedAt records the current time on a replica that is about to be written.
+func stampSyncedAt(replica metav1.Object) {
+	annotations := replica.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[api.Key(api.AnnotationSyncedAt)] = time.Now().UTC().Format(time.RFC3339)
+
+	replica.SetAnnotations(annotations)
+}
+
+// ShouldAdoptExisting returns true if the source object should take over pre-existing
+// unmanaged objects in target namespaces (according to its adopt-existing annotation).
+func ShouldAdoptExisting(obj metav1.Object) bool {
+	adoptStr, ok := api.GetAnnotation(obj, api.Key(api.AnnotationAdoptExisting))
+	return ok && strings.ToLower(adoptStr) == "true"
+}
+
+// ShouldForceDelete returns true if the source object should be cleaned up even if some
+// of its replicas could not be deleted (according to its force-delete annotation).
+func ShouldForceDelete(obj metav1.Object) bool {
+	forceStr, ok := api.GetAnnotation(obj, api.Key(api.AnnotationForceDelete))
+	return ok && strings.ToLower(forceStr) == "true"
+}
+
+// ShouldWithdrawExpired returns true if the replicas of the source object should be removed
+// once its certificate has expired (according to its withdraw-expired annotation).
+func ShouldWithdrawExpired(obj metav1.Object) bool {
+	withdrawStr, ok := api.GetAnnotation(obj, api.Key(api.AnnotationWithdrawExpired))
+	return ok && strings.ToLower(withdrawStr) == "true"
+}
+
+// GetConflictPolicy returns the conflict policy of the source object
+// (according to its conflict-policy annotation).
+func GetConflictPolicy(obj metav1.Object) (string, error) {
+	conflictPolicy, ok := api.GetAnnotation(obj, api.Key(api.AnnotationConflictPolicy))
+	if !ok {
+		return api.ConflictPolicySkip, nil
+	}
+
+	switch conflictPolicy = strings.ToLower(conflictPolicy); conflictPolicy {
+	case api.ConflictPolicyFail, api.ConflictPolicySkip, api.ConflictPolicyOverwrite:
+		return conflictPolicy, nil
+	default:
+		return "", fmt.Errorf("invalid conflict policy %q (expected fail, skip, or overwrite)", conflictPolicy)
+	}
+}
+
+// filtersAllKeys returns true if the object has a replicate-keys filter that
+// doesn't match any of its keys (and so would produce an empty replica).
+func filtersAllKeys[V string | []byte](obj metav1.Object, data map[string]V) (bool, error) {
+	if _, ok := api.GetAnnotation(obj, api.Key(api.AnnotationReplicateKeys)); !ok {
+		return false, nil
+	}
+
+	for key := range data {
+		replicate, err := api.ShouldReplicateKey(obj, key)
+		if err != nil {
+			return false, err
+		}
+
+		if replicate {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// withoutInvalidFilters returns a copy of the source object with any malformed
+// patterns removed from its filter annotations, along with the removed patterns.
+func withoutInvalidFilters[T client.Object](obj T) (T, []string) {
+	sanitized := obj.DeepCopyObject().(T)
+
+	var invalid []string
+	for _, key := range []string{api.Key(api.AnnotationReplicateTo), api.Key(api.AnnotationReplicateToTenant), api.Key(api.AnnotationReplicateKeys)} {
+		value, ok := api.GetAnnotation(obj, key)
+		if !ok {
+			continue
+		}
+
+		filters := api.ParseFilters(value)
+
+		var valid []string
+		for _, filter := range filters {
+			if err := api.ValidatePattern(filter); err != nil {
+				invalid = append(invalid, filter)
+				continue
+			}
+
+			valid = append(valid, filter)
+		}
+
+		if len(valid) != len(filters) {
+			annotations := sanitized.GetAnnotations()
+			annotations[key] = strings.Join(valid, ",")
+			sanitized.SetAnnotations(annotations)
+		}
+	}
+
+	return sanitized, invalid
+}
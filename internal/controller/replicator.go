@@ -0,0 +1,823 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/features"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/gpu-ninja/operator-utils/updater"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Replicator implements the kind specific parts of replication. Supporting a
+// new kind only requires a Replicator for it, which is then registered with
+// the manager through a Reconciler.
+type Replicator[T client.Object] interface {
+	// Kind returns the lowercase name of the kind (eg. "secret"), as used in
+	// messages and metrics.
+	Kind() string
+	// NewObject returns a new, empty object of the kind.
+	NewObject() T
+	// GetTemplate returns the replica template (sans namespace) for the source.
+	GetTemplate(source T, metadata api.MetadataFilter) (T, error)
+	// ListExisting lists the objects of the kind matching the given options.
+	ListExisting(ctx context.Context, c client.Reader, opts ...client.ListOption) ([]T, error)
+	// Write creates (or takes over) the desired replica if existing is nil,
+	// otherwise it updates the existing replica to match the desired replica.
+	// The reader is used to read the latest version of a replica (bypassing
+	// the cache) if the update conflicts with a concurrent writer.
+	Write(ctx context.Context, c client.Client, reader client.Reader, existing, desired T) error
+	// Delete deletes the replica.
+	Delete(ctx context.Context, c client.Client, replica T) error
+	// DataSize returns the size of the data of the object.
+	DataSize(obj T) int
+	// FiltersAllKeys returns true if the key filter of the source matches none of its keys.
+	FiltersAllKeys(source T) (bool, error)
+	// Selector returns the label selector restricting which objects are
+	// visible to replikator (or nil if all objects are visible).
+	Selector(policy *Policy) labels.Selector
+	// Refuse returns an event reason and message if policy forbids the source
+	// from being replicated.
+	Refuse(policy *Policy, source T) (reason, message string, refused bool)
+	// ImmutableChange returns a description of the change if the existing
+	// replica must be recreated (rather than updated) to match the desired replica.
+	ImmutableChange(existing, desired T) (string, bool)
+}
+
+// Reconciler reconciles the replicas of sources of a single kind.
+type Reconciler[T client.Object] struct {
+	client.Client
+	Recorder record.EventRecorder
+	Policy   Policy
+	// Writers, if set, provides the clients used to write replicas.
+	Writers WriterFactory
+	// APIReader, if set, reads replicas directly from the API server when
+	// writes conflict (otherwise they are read with the client).
+	APIReader client.Reader
+	// Replicator implements the kind specific parts of replication.
+	Replicator Replicator[T]
+}
+
+func (r *Reconciler[T]) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Sources are replicated in priority order on startup, rather than in the
+	// order they happen to be queued.
+	if err := r.Policy.InitialSync.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	timedOut := func() {
+		r.publish(Event{Reason: EventReasonTimedOut, Message: fmt.Sprintf("Reconcile of %s timed out", req.NamespacedName)})
+	}
+
+	return reconcileWithTimeout(ctx, r.Policy.ReconcileTimeout, timedOut, func(ctx context.Context) (ctrl.Result, error) {
+		return r.reconcile(ctx, req)
+	})
+}
+
+func (r *Reconciler[T]) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+	policy := r.Policy.current()
+	kind := r.Replicator.Kind()
+
+	logger.Debug("Reconciling")
+
+	obj := r.Replicator.NewObject()
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			certificateExpired.DeleteLabelValues(kind, req.Namespace, req.Name)
+
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	if selector := r.Replicator.Selector(&policy); selector != nil && !selector.Matches(labels.Set(obj.GetLabels())) {
+		logger.Debug("Object does not match selector")
+
+		return ctrl.Result{}, nil
+	}
+
+	if !policy.InScope(obj.GetNamespace()) && obj.GetDeletionTimestamp().IsZero() {
+		logger.Debug("Namespace is out of scope")
+
+		return ctrl.Result{}, nil
+	}
+
+	// Disabling replication on a source cleans up its replicas, as though it were deleted.
+	disabled := !api.IsReplicationEnabled(obj) || isTrustBundleSource(&policy, obj) || isManifestWorkSource(&policy, obj)
+	if disabled && !hasFinalizer(obj) {
+		logger.Debug("Replication not enabled")
+
+		return ctrl.Result{}, nil
+	}
+
+	// Replicating a replica would lead to a copy-of-a-copy loop.
+	if api.IsReplica(obj) {
+		logger.Warn("Refusing to replicate a replica")
+
+		r.event(obj, corev1.EventTypeWarning, EventReasonReplicationLoop,
+			"Refusing to replicate an object that is itself managed by replikator")
+
+		if hasFinalizer(obj) {
+			err := patchWithRetry(ctx, r.Client, obj, func() error {
+				removeFinalizers(obj)
+
+				return nil
+			})
+			if err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
+			}
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	if !disabled && !controllerutil.ContainsFinalizer(obj, api.Finalizer()) {
+		logger.Info("Adding Finalizer")
+
+		err := patchWithRetry(ctx, r.Client, obj, func() error {
+			controllerutil.AddFinalizer(obj, api.Finalizer())
+
+			return nil
+		})
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := r.List(ctx, &namespaces); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	optedOut := make(map[string]bool)
+	unmanaged := make(map[string]bool)
+	externallyManaged := make(map[string]bool)
+	existing := make(map[string]T)
+	var existingReplicas []T
+	for _, namespace := range namespaces.Items {
+		// Never touch objects in protected or out of scope namespaces.
+		if namespace.Name == obj.GetNamespace() || policy.IsProtectedNamespace(namespace.Name) || !policy.InScope(namespace.Name) {
+			continue
+		}
+
+		replica := r.Replicator.NewObject()
+		key := types.NamespacedName{Name: obj.GetName(), Namespace: namespace.Name}
+		if err := r.Get(ctx, key, replica); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			if policy.IsOptedOut(err) {
+				logger.Info("Namespace has opted out", "namespace", namespace.Name)
+
+				optedOut[namespace.Name] = true
+				continue
+			}
+
+			return ctrl.Result{}, fmt.Errorf("failed to check for replicated %s: %w", kind, err)
+		}
+
+		// Objects materialized by external-secrets are never overwritten (or
+		// deleted), as both operators would otherwise fight over them.
+		if !policy.OverwriteExternalSecrets && isExternalSecret(replica) {
+			externallyManaged[namespace.Name] = true
+			continue
+		}
+
+		// Objects not managed by replikator are never deleted.
+		if !api.IsReplica(replica) {
+			unmanaged[namespace.Name] = true
+			continue
+		}
+
+		existing[namespace.Name] = replica
+		existingReplicas = append(existingReplicas, replica)
+	}
+
+	if disabled || !obj.GetDeletionTimestamp().IsZero() {
+		certificateExpired.DeleteLabelValues(kind, obj.GetNamespace(), obj.GetName())
+
+		if disabled {
+			logger.Info("Replication disabled, removing replicas")
+		} else {
+			logger.Info("Deleting")
+		}
+
+		var failedNamespaces []string
+		for _, replica := range existingReplicas {
+			writer, err := writerFor(r.Client, r.Writers, replica.GetNamespace())
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+
+			err = retryTransient(func() error {
+				return r.Replicator.Delete(ctx, writer, replica)
+			})
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+
+				logger.Warn("Failed to delete replica", "namespace", replica.GetNamespace(), "error", err)
+
+				r.event(obj, corev1.EventTypeWarning, EventReasonCleanupFailed,
+					"Failed to delete replica in namespace %s: %v", replica.GetNamespace(), err)
+
+				failedNamespaces = append(failedNamespaces, replica.GetNamespace())
+
+				continue
+			}
+
+			policy.Contention.Forget(contentionKey(kind, replica))
+
+			r.publish(Event{Reason: EventReasonReplicaDeleted, Object: obj, Namespace: replica.GetNamespace(), Message: "Deleted replica"})
+		}
+
+		if len(failedNamespaces) > 0 {
+			if !ShouldForceDelete(obj) {
+				return ctrl.Result{}, fmt.Errorf("failed to delete replicated %ss in namespaces: %s",
+					kind, strings.Join(failedNamespaces, ", "))
+			}
+
+			logger.Warn("Forcing cleanup, orphaning replicas", "namespaces", failedNamespaces)
+
+			r.event(obj, corev1.EventTypeWarning, EventReasonForcedCleanup,
+				"Orphaning replicas in namespaces: %s", strings.Join(failedNamespaces, ", "))
+		}
+
+		if hasFinalizer(obj) {
+			logger.Info("Removing Finalizer")
+
+			err := patchWithRetry(ctx, r.Client, obj, func() error {
+				removeFinalizers(obj)
+
+				return nil
+			})
+			if err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
+			}
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	logger.Debug("Creating or updating")
+
+	// A malformed filter pattern shouldn't halt replication, so it is reported and ignored.
+	source, invalidFilters := withoutInvalidFilters(obj)
+	if len(invalidFilters) > 0 {
+		logger.Warn("Ignoring invalid filter patterns", "patterns", invalidFilters)
+
+		r.event(obj, corev1.EventTypeWarning, EventReasonInvalidFilter,
+			"Ignoring invalid filter patterns: %s", strings.Join(invalidFilters, ", "))
+	}
+
+	// Unlike other filters, ignoring a malformed exclusion would widen replication.
+	if replicateExcept, ok := api.GetAnnotation(obj, api.Key(api.AnnotationReplicateExcept)); ok {
+		if err := api.ValidateFilters(replicateExcept); err != nil {
+			logger.Warn("Refusing to replicate with invalid namespace exclusions", "error", err)
+
+			r.event(obj, corev1.EventTypeWarning, EventReasonInvalidFilter,
+				"Not replicating as %s is malformed: %v", api.Key(api.AnnotationReplicateExcept), err)
+
+			return ctrl.Result{}, nil
+		}
+	}
+
+	policy.applyDefaults(source)
+
+	if reason, message, refused := r.Replicator.Refuse(&policy, source); refused {
+		logger.Warn("Refusing to replicate", "reason", reason)
+
+		r.event(obj, corev1.EventTypeWarning, reason, "%s", message)
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := runBeforeTemplateHooks(ctx, r.Client, policy.Hooks, source); err != nil {
+		var refused *HookRefusedError
+		if errors.As(err, &refused) {
+			logger.Warn("Refusing to replicate", "reason", refused.Reason)
+
+			r.event(obj, corev1.EventTypeWarning, EventReasonHookRefused,
+				"Refused by replication hook: %s", refused.Reason)
+
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("failed to run replication hooks: %w", err)
+	}
+
+	template, err := r.Replicator.GetTemplate(source, policy.Metadata)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	conflictPolicy, err := GetConflictPolicy(obj)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	adoptExisting := ShouldAdoptExisting(obj)
+
+	// Don't create empty replicas (and prune any existing ones) if the key filter matches nothing.
+	empty, err := r.Replicator.FiltersAllKeys(source)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if empty {
+		replicateKeys, _ := api.GetAnnotation(source, api.Key(api.AnnotationReplicateKeys))
+
+		logger.Warn("Key filter matches no keys, not replicating", "filter", replicateKeys)
+
+		r.event(obj, corev1.EventTypeWarning, EventReasonEmptyReplica,
+			"Not replicating as the key filter %q matches none of the keys of the %s", replicateKeys, kind)
+	}
+
+	now := time.Now()
+
+	checkExpiry := r.checkCertificateExpiry(logger, obj, now)
+	withdrawn := isWithdrawn(source, now)
+
+	sourceNamespace := findNamespace(&namespaces, obj.GetNamespace())
+
+	var desiredReplicas []T
+
+	// The earliest time at which previous CA certificates retained in replicas must be pruned.
+	var pruneCA time.Time
+
+	for _, namespace := range namespaces.Items {
+		replicate, err := policy.ShouldReplicateTo(source, &namespace)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if empty || withdrawn || !policy.InScope(namespace.Name) || optedOut[namespace.Name] {
+			continue
+		}
+
+		if replicate && !policy.PermitsTenancy(sourceNamespace, &namespace) {
+			logger.Info("Skipping namespace belonging to another tenant", "namespace", namespace.Name)
+
+			r.event(obj, corev1.EventTypeWarning, EventReasonTenancyViolation,
+				"Not replicating to namespace %s as it belongs to another tenant", namespace.Name)
+
+			continue
+		}
+
+		if replicate && policy.IsProtectedNamespace(namespace.Name) {
+			logger.Info("Skipping protected namespace", "namespace", namespace.Name)
+
+			r.event(obj, corev1.EventTypeWarning, EventReasonSkippedTarget,
+				"Not replicating to protected namespace %s", namespace.Name)
+
+			continue
+		}
+
+		if replicate && externallyManaged[namespace.Name] {
+			logger.Warn("Skipping namespace with object managed by external-secrets", "namespace", namespace.Name)
+
+			r.event(obj, corev1.EventTypeWarning, EventReasonExternallyManaged,
+				"Not replicating to namespace %s as a %s with the same name is managed by external-secrets", namespace.Name, kind)
+
+			continue
+		}
+
+		if replicate && unmanaged[namespace.Name] && adoptExisting {
+			logger.Info("Adopting existing object", "namespace", namespace.Name)
+
+			r.event(obj, corev1.EventTypeNormal, EventReasonAdopted,
+				"Adopting existing %s in namespace %s", kind, namespace.Name)
+		} else if replicate && unmanaged[namespace.Name] {
+			switch conflictPolicy {
+			case api.ConflictPolicySkip:
+				logger.Warn("Skipping namespace with conflicting object", "namespace", namespace.Name)
+
+				r.event(obj, corev1.EventTypeWarning, EventReasonConflict,
+					"Not replicating to namespace %s as an unmanaged %s with the same name already exists", namespace.Name, kind)
+
+				continue
+			case api.ConflictPolicyFail:
+				r.event(obj, corev1.EventTypeWarning, EventReasonConflict,
+					"An unmanaged %s with the same name already exists in namespace %s", kind, namespace.Name)
+
+				return ctrl.Result{}, fmt.Errorf("unmanaged %s already exists in namespace %s", kind, namespace.Name)
+			case api.ConflictPolicyOverwrite:
+				logger.Info("Overwriting conflicting object", "namespace", namespace.Name)
+			}
+		}
+
+		if replicate {
+			replica := template.DeepCopyObject().(T)
+			replica.SetNamespace(namespace.Name)
+			api.SetHelmOwnership(source, replica)
+
+			replica, ignored, err := transformReplica(ctx, r.Client, policy.Transformations, source, replica)
+			for _, err := range ignored {
+				logger.Warn("Ignoring failed transformation", "namespace", namespace.Name, "error", err)
+
+				r.event(obj, corev1.EventTypeWarning, EventReasonTransformFailed,
+					"Ignoring failed transformation of replica in namespace %s: %v", namespace.Name, err)
+			}
+			if err != nil {
+				r.event(obj, corev1.EventTypeWarning, EventReasonTransformFailed,
+					"Failed to transform replica in namespace %s: %v", namespace.Name, err)
+
+				return ctrl.Result{}, fmt.Errorf("failed to transform replica: %w", err)
+			}
+
+			var existingReplica client.Object
+			if current, ok := existing[namespace.Name]; ok {
+				existingReplica = current
+			}
+
+			pruneCA = earliest(pruneCA, retainPreviousCA(source, existingReplica, replica, now))
+
+			StampSourceHash(replica)
+
+			desiredReplicas = append(desiredReplicas, replica)
+		}
+	}
+
+	if err := policy.CheckLimits(r.Replicator.DataSize(template), len(desiredReplicas)); err != nil {
+		logger.Warn("Refusing to replicate", "error", err)
+
+		r.event(obj, corev1.EventTypeWarning, EventReasonLimitExceeded,
+			"Refusing to replicate: %v", err)
+
+		return ctrl.Result{}, nil
+	}
+
+	removedReplicas, addedReplicas := diffObjects(existingReplicas, desiredReplicas)
+	driftedReplicas := driftedObjects(existingReplicas, desiredReplicas)
+
+	// The earliest time at which writes held back by the write limiter can be performed.
+	var throttled time.Time
+
+	pending := len(removedReplicas) + len(addedReplicas) + len(driftedReplicas)
+	if admitted, delay := policy.WriteLimiter.Reserve(obj.GetNamespace(), pending); admitted < pending {
+		logger.Info("Throttling writes", "admitted", admitted, "pending", pending, "delay", delay)
+
+		r.publish(Event{Reason: EventReasonThrottled, Object: obj,
+			Message: fmt.Sprintf("Delaying %d of %d writes by %s", pending-admitted, pending, delay)})
+
+		// Deletions are performed first, then creations, then repairs.
+		limitWrites(admitted, &removedReplicas, &addedReplicas, &driftedReplicas)
+
+		throttled = now.Add(delay)
+	}
+
+	for _, replica := range removedReplicas {
+		writer, err := writerFor(r.Client, r.Writers, replica.GetNamespace())
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.Replicator.Delete(ctx, writer, replica); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return ctrl.Result{}, fmt.Errorf("failed to delete replicated %s: %w", kind, err)
+		}
+
+		policy.Contention.Forget(contentionKey(kind, replica))
+
+		r.publish(Event{Reason: EventReasonReplicaDeleted, Object: obj, Namespace: replica.GetNamespace(), Message: "Deleted replica"})
+	}
+
+	for _, replica := range addedReplicas {
+		if err := ctx.Err(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		writer, err := writerFor(r.Client, r.Writers, replica.GetNamespace())
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		stampSyncedAt(replica)
+
+		var none T
+		if err := r.Replicator.Write(ctx, writer, r.APIReader, none, replica); err != nil {
+			if policy.IsOptedOut(err) {
+				logger.Info("Namespace has opted out", "namespace", replica.GetNamespace())
+
+				continue
+			}
+
+			// When reads are restricted by a selector, an object with the same
+			// name may exist without being visible to replikator.
+			if r.Replicator.Selector(&policy) != nil && apierrors.IsAlreadyExists(err) {
+				logger.Warn("Skipping namespace with conflicting object", "namespace", replica.GetNamespace())
+
+				r.event(obj, corev1.EventTypeWarning, EventReasonSkippedTarget,
+					"Not replicating to namespace %s as a %s not matching the selector already exists", replica.GetNamespace(), kind)
+
+				continue
+			}
+
+			return ctrl.Result{}, fmt.Errorf("failed to replicate %s: %w", kind, err)
+		}
+
+		runAfterWriteHooks(ctx, logger, r.Client, policy.Hooks, obj, replica)
+
+		r.publish(Event{Reason: EventReasonReplicaWritten, Object: obj, Namespace: replica.GetNamespace(), Message: "Wrote replica"})
+	}
+
+	for _, replica := range driftedReplicas {
+		if err := ctx.Err(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if policy.Contention.Record(contentionKey(kind, replica), obj.GetResourceVersion()) {
+			logger.Warn("Not repairing replica that is repeatedly modified by another writer", "namespace", replica.GetNamespace())
+
+			r.publish(Event{
+				Type:      corev1.EventTypeWarning,
+				Reason:    EventReasonContended,
+				Object:    obj,
+				Namespace: replica.GetNamespace(),
+				Message:   fmt.Sprintf("Not repairing replica in namespace %s as it is repeatedly modified by another writer", replica.GetNamespace()),
+			})
+
+			continue
+		}
+
+		logger.Info("Repairing drifted replica", "namespace", replica.GetNamespace())
+
+		// Replicas that were modified directly are repaired, others are only
+		// being updated after their source changed.
+		reason := EventReasonReplicaWritten
+		if isTampered(existing[replica.GetNamespace()], replica) {
+			reason = EventReasonReplicaRepaired
+		}
+
+		writer, err := writerFor(r.Client, r.Writers, replica.GetNamespace())
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		stampSyncedAt(replica)
+
+		if change, ok := r.Replicator.ImmutableChange(existing[replica.GetNamespace()], replica); ok {
+			logger.Info("Recreating replica with immutable change", "namespace", replica.GetNamespace(), "change", change)
+
+			r.event(obj, corev1.EventTypeNormal, EventReasonTypeChanged,
+				"Recreating replica in namespace %s as %s", replica.GetNamespace(), change)
+
+			if err := r.Replicator.Delete(ctx, writer, replica); err != nil && !apierrors.IsNotFound(err) {
+				return ctrl.Result{}, fmt.Errorf("failed to delete replicated %s: %w", kind, err)
+			}
+
+			replica.SetResourceVersion("")
+
+			// Created directly, as the deleted replica may linger in the cache.
+			if err := writer.Create(ctx, replica); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to recreate replicated %s: %w", kind, err)
+			}
+
+			runAfterWriteHooks(ctx, logger, r.Client, policy.Hooks, obj, replica)
+
+			r.publish(Event{Reason: reason, Object: obj, Namespace: replica.GetNamespace(), Message: "Recreated replica"})
+
+			continue
+		}
+
+		if err := r.Replicator.Write(ctx, writer, r.APIReader, existing[replica.GetNamespace()], replica); err != nil {
+			if policy.IsOptedOut(err) {
+				logger.Info("Namespace has opted out", "namespace", replica.GetNamespace())
+
+				continue
+			}
+
+			return ctrl.Result{}, fmt.Errorf("failed to update replicated %s: %w", kind, err)
+		}
+
+		runAfterWriteHooks(ctx, logger, r.Client, policy.Hooks, obj, replica)
+
+		r.publish(Event{Reason: reason, Object: obj, Namespace: replica.GetNamespace(), Message: "Updated replica"})
+	}
+
+	// Requeue to write replicas held back by the write limiter, to prune previous
+	// CA certificates once they are no longer retained, and to warn about the
+	// certificate of the source expiring.
+	if next := earliest(throttled, pruneCA, checkExpiry); !next.IsZero() {
+		return ctrl.Result{RequeueAfter: next.Sub(now) + time.Second}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *Reconciler[T]) SetupWithManager(mgr ctrl.Manager) error {
+	kind := r.Replicator.Kind()
+
+	if err := indexByName(mgr, r.Replicator.NewObject()); err != nil {
+		return fmt.Errorf("failed to index %ss: %w", kind, err)
+	}
+
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		Named(kind+"-controller").
+		For(r.Replicator.NewObject()).
+		// Requeue the source when a replica is modified or deleted.
+		Watches(r.Replicator.NewObject(), handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+			return r.sourcesForReplica(ctx, obj)
+		})).
+		// Requeue when a namespace is created.
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+			// Ignore deletions (there's nothing we need to do).
+			if !obj.GetDeletionTimestamp().IsZero() {
+				return nil
+			}
+
+			return r.allSources(ctx, obj)
+		}))
+
+	if r.Policy.Runtime != nil {
+		// Requeue every source when the runtime configuration changes.
+		bldr = bldr.WatchesRawSource(&source.Channel{Source: r.Policy.Runtime.subscribe()},
+			handler.EnqueueRequestsFromMapFunc(r.allSources))
+	}
+
+	return bldr.Complete(r)
+}
+
+func (r *Reconciler[T]) allSources(ctx context.Context, _ client.Object) []ctrl.Request {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+
+	sources, err := r.Replicator.ListExisting(ctx, r.Client)
+	if err != nil {
+		logger.Error("Failed to list sources", "error", err)
+
+		return nil
+	}
+
+	var reqs []ctrl.Request
+	for _, obj := range sources {
+		// Only sources that are (or until recently were) replicated need to be
+		// reconciled, rather than every object of the kind in the cluster.
+		if !api.IsReplicationEnabled(obj) && !hasFinalizer(obj) {
+			continue
+		}
+
+		reqs = append(reqs, ctrl.Request{
+			NamespacedName: client.ObjectKeyFromObject(obj),
+		})
+	}
+
+	return reqs
+}
+
+// event publishes an event about the source that is also recorded as a Kubernetes Event.
+func (r *Reconciler[T]) event(obj client.Object, eventType, reason, messageFmt string, args ...any) {
+	r.publish(Event{
+		Type:    eventType,
+		Reason:  reason,
+		Object:  obj,
+		Message: fmt.Sprintf(messageFmt, args...),
+	})
+}
+
+// publish publishes a lifecycle event of the kind.
+func (r *Reconciler[T]) publish(event Event) {
+	event.Kind = r.Replicator.Kind()
+
+	publishEvent(r.Policy.Events, r.Recorder, event)
+}
+
+// sourcesForReplica returns reconcile requests for every source that the
+// given replica could have been replicated from.
+func (r *Reconciler[T]) sourcesForReplica(ctx context.Context, replica client.Object) []ctrl.Request {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+
+	if !api.IsReplica(replica) {
+		return nil
+	}
+
+	// Replicas record their source, so only it needs to be requeued.
+	if ref, _, ok := api.GetSourceReference(replica); ok {
+		if ref.Namespace == replica.GetNamespace() {
+			return nil
+		}
+
+		return []ctrl.Request{{NamespacedName: ref}}
+	}
+
+	// Older replicas may not record their source, so fall back to requeueing
+	// every enabled source of the same name.
+	sources, err := r.Replicator.ListExisting(ctx, r.Client, client.MatchingFields{nameField: replica.GetName()})
+	if err != nil {
+		logger.Error("Failed to list sources", "error", err)
+
+		return nil
+	}
+
+	var reqs []ctrl.Request
+	for _, source := range sources {
+		if source.GetNamespace() == replica.GetNamespace() || !api.IsReplicationEnabled(source) {
+			continue
+		}
+
+		reqs = append(reqs, ctrl.Request{
+			NamespacedName: client.ObjectKeyFromObject(source),
+		})
+	}
+
+	return reqs
+}
+
+// writeReplica creates (or takes over) the desired replica if there is no
+// existing replica (nil), otherwise it updates the existing replica.
+func writeReplica[T client.Object](ctx context.Context, c client.Client, reader client.Reader, existing, desired T) error {
+	// A nil pointer of type T isn't a nil client.Object, so compare against the zero value.
+	var none T
+	create := client.Object(existing) == client.Object(none)
+
+	if features.Enabled(features.ServerSideApply) && (create || !removesFields(existing, desired)) {
+		return applyReplica(ctx, c, desired)
+	}
+
+	if create {
+		_, err := updater.CreateOrUpdateFromTemplate(ctx, c, desired)
+		return err
+	}
+
+	return updateWithRetry(ctx, c, reader, desired)
+}
+
+// limitWrites truncates the batches of pending writes, in order, so that at
+// most n writes remain.
+func limitWrites[T any](n int, batches ...*[]T) {
+	for _, batch := range batches {
+		*batch = (*batch)[:min(len(*batch), n)]
+		n -= len(*batch)
+	}
+}
+
+func diffObjects[T client.Object](existingObjects, desiredObjects []T) (removedObjects, addedObjects []T) {
+	for _, existingObject := range existingObjects {
+		var found bool
+		for _, desiredObject := range desiredObjects {
+			if desiredObject.GetNamespace() == existingObject.GetNamespace() {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			removedObjects = append(removedObjects, existingObject)
+		}
+	}
+
+	for _, desiredObject := range desiredObjects {
+		var found bool
+		for _, existingObject := range existingObjects {
+			if desiredObject.GetNamespace() == existingObject.GetNamespace() {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			addedObjects = append(addedObjects, desiredObject)
+		}
+	}
+
+	return
+}
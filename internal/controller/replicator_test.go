@@ -0,0 +1,186 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconciler(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-serviceaccount",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry-credentials"}},
+	}
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	ctx := context.Background()
+
+	t.Run("Should Replicate A Kind Registered Through A Replicator", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(serviceAccount, anotherNamespace).
+			Build()
+
+		r := &controller.Reconciler[*corev1.ServiceAccount]{
+			Client:     client,
+			Replicator: serviceAccountReplicator{},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      serviceAccount.Name,
+				Namespace: serviceAccount.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replica corev1.ServiceAccount
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      serviceAccount.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replica)
+		require.NoError(t, err)
+
+		assert.True(t, api.IsReplica(&replica))
+		assert.Equal(t, serviceAccount.ImagePullSecrets, replica.ImagePullSecrets)
+	})
+
+	t.Run("Should Delete Replicas Of A Kind Registered Through A Replicator", func(t *testing.T) {
+		disabledServiceAccount := serviceAccount.DeepCopy()
+		disabledServiceAccount.Annotations[api.Key(api.AnnotationEnabled)] = "false"
+		disabledServiceAccount.Finalizers = []string{api.Finalizer()}
+
+		replica, err := serviceAccountReplicator{}.GetTemplate(serviceAccount, api.MetadataFilter{})
+		require.NoError(t, err)
+		replica.Namespace = anotherNamespace.Name
+
+		client := fake.NewClientBuilder().
+			WithObjects(disabledServiceAccount, anotherNamespace, replica).
+			Build()
+
+		r := &controller.Reconciler[*corev1.ServiceAccount]{
+			Client:     client,
+			Replicator: serviceAccountReplicator{},
+		}
+
+		_, err = r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      serviceAccount.Name,
+				Namespace: serviceAccount.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		err = client.Get(ctx, ctrlclient.ObjectKeyFromObject(replica), &corev1.ServiceAccount{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+}
+
+// serviceAccountReplicator replicates the image pull secrets of service accounts.
+type serviceAccountReplicator struct{}
+
+func (serviceAccountReplicator) Kind() string {
+	return "serviceaccount"
+}
+
+func (serviceAccountReplicator) NewObject() *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{}
+}
+
+func (serviceAccountReplicator) GetTemplate(source *corev1.ServiceAccount, metadata api.MetadataFilter) (*corev1.ServiceAccount, error) {
+	return &corev1.ServiceAccount{
+		ObjectMeta:       api.ReplicaObjectMeta(source, metadata),
+		ImagePullSecrets: source.ImagePullSecrets,
+	}, nil
+}
+
+func (serviceAccountReplicator) ListExisting(ctx context.Context, c ctrlclient.Reader, opts ...ctrlclient.ListOption) ([]*corev1.ServiceAccount, error) {
+	var list corev1.ServiceAccountList
+	if err := c.List(ctx, &list, opts...); err != nil {
+		return nil, err
+	}
+
+	var items []*corev1.ServiceAccount
+	for i := range list.Items {
+		items = append(items, &list.Items[i])
+	}
+
+	return items, nil
+}
+
+func (serviceAccountReplicator) Write(ctx context.Context, c ctrlclient.Client, _ ctrlclient.Reader, existing, desired *corev1.ServiceAccount) error {
+	if existing == nil {
+		return c.Create(ctx, desired)
+	}
+
+	return c.Update(ctx, desired)
+}
+
+func (serviceAccountReplicator) Delete(ctx context.Context, c ctrlclient.Client, replica *corev1.ServiceAccount) error {
+	return c.Delete(ctx, replica)
+}
+
+func (serviceAccountReplicator) DataSize(_ *corev1.ServiceAccount) int {
+	return 0
+}
+
+func (serviceAccountReplicator) FiltersAllKeys(_ *corev1.ServiceAccount) (bool, error) {
+	return false, nil
+}
+
+func (serviceAccountReplicator) Selector(_ *controller.Policy) labels.Selector {
+	return nil
+}
+
+func (serviceAccountReplicator) Refuse(_ *controller.Policy, _ *corev1.ServiceAccount) (string, string, bool) {
+	return "", "", false
+}
+
+func (serviceAccountReplicator) ImmutableChange(_, _ *corev1.ServiceAccount) (string, bool) {
+	return "", false
+}
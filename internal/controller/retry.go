@@ -0,0 +1,112 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// patchWithRetry patches the object using the mutate function, retrying if
+// there is a conflict with a concurrent writer.
+func patchWithRetry(ctx context.Context, c client.Client, obj client.Object, mutate controllerutil.MutateFn) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		_, err := controllerutil.CreateOrPatch(ctx, c, obj, mutate)
+		return err
+	})
+}
+
+// updateWithRetry updates the object, retrying if there is a conflict with a
+// concurrent writer. On conflict, the latest version of the object is read
+// with the reader (which should bypass the cache, as it may lag behind) and
+// the fields written by replikator are reapplied to it, so that concurrent
+// changes to other fields aren't lost.
+func updateWithRetry(ctx context.Context, c client.Client, reader client.Reader, obj client.Object) error {
+	if reader == nil {
+		reader = c
+	}
+
+	desired := obj.DeepCopyObject().(client.Object)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := c.Update(ctx, obj)
+		if apierrors.IsConflict(err) {
+			latest := desired.DeepCopyObject().(client.Object)
+			if err := reader.Get(ctx, client.ObjectKeyFromObject(obj), latest); err != nil {
+				return err
+			}
+
+			reapplyFields(latest, desired)
+			obj = latest
+		}
+
+		return err
+	})
+}
+
+// reapplyFields copies the fields written by replikator (the data, labels and
+// annotations) from the desired object onto the latest version of the object.
+// Replikator annotations missing from the desired object are removed.
+func reapplyFields(latest, desired client.Object) {
+	copyData(latest, desired)
+
+	labels := latest.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	for key, value := range desired.GetLabels() {
+		labels[key] = value
+	}
+
+	latest.SetLabels(labels)
+
+	annotations := latest.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	for key := range annotations {
+		if _, ok := desired.GetAnnotations()[key]; !ok && strings.HasPrefix(key, api.KeyPrefix()) {
+			delete(annotations, key)
+		}
+	}
+
+	for key, value := range desired.GetAnnotations() {
+		annotations[key] = value
+	}
+
+	latest.SetAnnotations(annotations)
+}
+
+// retryTransient calls fn, retrying transient errors with backoff.
+func retryTransient(fn func() error) error {
+	return retry.OnError(retry.DefaultBackoff, isTransient, fn)
+}
+
+func isTransient(err error) bool {
+	return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) || apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err)
+}
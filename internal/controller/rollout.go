@@ -0,0 +1,308 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=apps.openshift.io,resources=deploymentconfigs,verbs=get;list;watch;patch
+
+// DeploymentConfigGVK is the group, version and kind of OpenShift DeploymentConfigs.
+var DeploymentConfigGVK = schema.GroupVersionKind{Group: "apps.openshift.io", Version: "v1", Kind: "DeploymentConfig"}
+
+// RolloutHook restarts the Deployments and StatefulSets that consume a replica
+// (through a volume, env or envFrom) when it is written, so that changes (eg.
+// a rotated certificate) reach running pods. Only workloads annotated with
+// rollout-on-change are restarted. On OpenShift, DeploymentConfigs can also be
+// restarted (provided they have a ConfigChange trigger).
+//
+// A checksum of the replicas a workload consumes is recorded on its pod
+// template, so it is only restarted when their contents actually change (and
+// not eg. when a replica is repaired). The checksum is first recorded when one
+// of the replicas is next written, which restarts the workload once.
+type RolloutHook struct {
+	Client client.Client
+	// DeploymentConfigs also restarts OpenShift DeploymentConfigs.
+	DeploymentConfigs bool
+}
+
+func (h *RolloutHook) BeforeTemplate(_ context.Context, _ client.Object) error {
+	return nil
+}
+
+func (h *RolloutHook) AfterWrite(ctx context.Context, _, replica client.Object) error {
+	var deployments appsv1.DeploymentList
+	if err := h.Client.List(ctx, &deployments, client.InNamespace(replica.GetNamespace())); err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if err := h.rollout(ctx, deployment, &deployment.Spec.Template, replica); err != nil {
+			return err
+		}
+	}
+
+	var statefulSets appsv1.StatefulSetList
+	if err := h.Client.List(ctx, &statefulSets, client.InNamespace(replica.GetNamespace())); err != nil {
+		return fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+
+	for i := range statefulSets.Items {
+		statefulSet := &statefulSets.Items[i]
+		if err := h.rollout(ctx, statefulSet, &statefulSet.Spec.Template, replica); err != nil {
+			return err
+		}
+	}
+
+	if h.DeploymentConfigs {
+		deploymentConfigs := &unstructured.UnstructuredList{}
+		deploymentConfigs.SetGroupVersionKind(DeploymentConfigGVK.GroupVersion().WithKind(DeploymentConfigGVK.Kind + "List"))
+		if err := h.Client.List(ctx, deploymentConfigs, client.InNamespace(replica.GetNamespace())); err != nil {
+			return fmt.Errorf("failed to list deploymentconfigs: %w", err)
+		}
+
+		for i := range deploymentConfigs.Items {
+			if err := h.rolloutDeploymentConfig(ctx, &deploymentConfigs.Items[i], replica); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// rolloutDeploymentConfig restarts the DeploymentConfig if it consumes the
+// replica and the replicas it consumes have changed. DeploymentConfigs are
+// only available on OpenShift, so are handled as unstructured objects.
+func (h *RolloutHook) rolloutDeploymentConfig(ctx context.Context, deploymentConfig *unstructured.Unstructured, replica client.Object) error {
+	rawTemplate, _, err := unstructured.NestedMap(deploymentConfig.Object, "spec", "template")
+	if err != nil {
+		return fmt.Errorf("malformed pod template of %s: %w", deploymentConfig.GetName(), err)
+	}
+
+	var template corev1.PodTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawTemplate, &template); err != nil {
+		return fmt.Errorf("malformed pod template of %s: %w", deploymentConfig.GetName(), err)
+	}
+
+	checksum, changed, err := h.changedChecksum(ctx, deploymentConfig, &template, replica)
+	if err != nil || !changed {
+		return err
+	}
+
+	patch := client.MergeFrom(deploymentConfig.DeepCopy())
+
+	if err := unstructured.SetNestedField(deploymentConfig.Object, checksum,
+		"spec", "template", "metadata", "annotations", api.Key(api.AnnotationRolloutChecksum)); err != nil {
+		return fmt.Errorf("failed to set checksum of %s: %w", deploymentConfig.GetName(), err)
+	}
+
+	if err := h.Client.Patch(ctx, deploymentConfig, patch); err != nil {
+		return fmt.Errorf("failed to restart %s: %w", deploymentConfig.GetName(), err)
+	}
+
+	return nil
+}
+
+// rollout restarts the workload (by updating the checksum on its pod template)
+// if it consumes the replica and the replicas it consumes have changed.
+func (h *RolloutHook) rollout(ctx context.Context, workload client.Object, template *corev1.PodTemplateSpec, replica client.Object) error {
+	checksum, changed, err := h.changedChecksum(ctx, workload, template, replica)
+	if err != nil || !changed {
+		return err
+	}
+
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	template.Annotations[api.Key(api.AnnotationRolloutChecksum)] = checksum
+
+	if err := h.Client.Patch(ctx, workload, patch); err != nil {
+		return fmt.Errorf("failed to restart %s: %w", workload.GetName(), err)
+	}
+
+	return nil
+}
+
+// changedChecksum returns the checksum of the replicas consumed by the pod
+// template of the workload, and whether it differs from the recorded checksum
+// (always false if the workload isn't annotated with rollout-on-change, or
+// doesn't consume the replica).
+func (h *RolloutHook) changedChecksum(ctx context.Context, workload client.Object, template *corev1.PodTemplateSpec, replica client.Object) (string, bool, error) {
+	if enabled, ok := api.GetAnnotation(workload, api.Key(api.AnnotationRolloutOnChange)); !ok || strings.ToLower(enabled) != "true" {
+		return "", false, nil
+	}
+
+	refs := podReferences(&template.Spec)
+	if !refs.consumes(replica) {
+		return "", false, nil
+	}
+
+	checksum, err := h.checksum(ctx, workload.GetNamespace(), refs, replica)
+	if err != nil {
+		return "", false, err
+	}
+
+	return checksum, template.Annotations[api.Key(api.AnnotationRolloutChecksum)] != checksum, nil
+}
+
+// checksum returns the checksum of the contents of the replicas referenced by
+// a pod. The just written replica is used as is, as the cache may not yet
+// reflect the write. Referenced objects that aren't replicas are ignored.
+func (h *RolloutHook) checksum(ctx context.Context, namespace string, refs *podRefs, replica client.Object) (string, error) {
+	hash := sha256.New()
+
+	checksumObjects := func(names []string, newObject func() client.Object) error {
+		for _, name := range names {
+			obj := newObject()
+			if name == replica.GetName() && reflect.TypeOf(obj) == reflect.TypeOf(replica) {
+				obj = replica
+			} else if err := h.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+
+				return fmt.Errorf("failed to get %s: %w", name, err)
+			}
+
+			if !api.IsReplica(obj) {
+				continue
+			}
+
+			data := objectData(obj)
+
+			fmt.Fprintf(hash, "%T/%s\n", obj, name)
+			for _, key := range sortedKeys(data) {
+				fmt.Fprintf(hash, "%s=%s\n", key, hex.EncodeToString(data[key]))
+			}
+		}
+
+		return nil
+	}
+
+	if err := checksumObjects(refs.secrets, func() client.Object { return &corev1.Secret{} }); err != nil {
+		return "", err
+	}
+
+	if err := checksumObjects(refs.configMaps, func() client.Object { return &corev1.ConfigMap{} }); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// podRefs are the names of the secrets and configmaps referenced by a pod.
+type podRefs struct {
+	secrets    []string
+	configMaps []string
+}
+
+func (r *podRefs) consumes(obj client.Object) bool {
+	switch obj.(type) {
+	case *corev1.Secret:
+		return slices.Contains(r.secrets, obj.GetName())
+	case *corev1.ConfigMap:
+		return slices.Contains(r.configMaps, obj.GetName())
+	default:
+		return false
+	}
+}
+
+// podReferences returns the (sorted and deduplicated) names of the secrets and
+// configmaps mounted as volumes by the pod, or consumed by its containers as
+// environment variables.
+func podReferences(spec *corev1.PodSpec) *podRefs {
+	var refs podRefs
+
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil {
+			refs.secrets = append(refs.secrets, volume.Secret.SecretName)
+		}
+
+		if volume.ConfigMap != nil {
+			refs.configMaps = append(refs.configMaps, volume.ConfigMap.Name)
+		}
+
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					refs.secrets = append(refs.secrets, source.Secret.Name)
+				}
+
+				if source.ConfigMap != nil {
+					refs.configMaps = append(refs.configMaps, source.ConfigMap.Name)
+				}
+			}
+		}
+	}
+
+	for _, container := range append(slices.Clone(spec.InitContainers), spec.Containers...) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				refs.secrets = append(refs.secrets, envFrom.SecretRef.Name)
+			}
+
+			if envFrom.ConfigMapRef != nil {
+				refs.configMaps = append(refs.configMaps, envFrom.ConfigMapRef.Name)
+			}
+		}
+
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+
+			if env.ValueFrom.SecretKeyRef != nil {
+				refs.secrets = append(refs.secrets, env.ValueFrom.SecretKeyRef.Name)
+			}
+
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				refs.configMaps = append(refs.configMaps, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+	}
+
+	slices.Sort(refs.secrets)
+	refs.secrets = slices.Compact(refs.secrets)
+
+	slices.Sort(refs.configMaps)
+	refs.configMaps = slices.Compact(refs.configMaps)
+
+	return &refs
+}
@@ -0,0 +1,241 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRolloutHook(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		Data: map[string]string{
+			"greeting": "hello",
+		},
+	}
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	newDeployment := func(name string, annotations map[string]string, podSpec corev1.PodSpec) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   anotherNamespace.Name,
+				Annotations: annotations,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{Spec: podSpec},
+			},
+		}
+	}
+
+	consumingPodSpec := corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name: "app",
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name},
+				},
+			}},
+		}},
+	}
+
+	optedIn := map[string]string{api.Key(api.AnnotationRolloutOnChange): "true"}
+
+	consumer := newDeployment("consumer", optedIn, consumingPodSpec)
+	notOptedIn := newDeployment("not-opted-in", nil, consumingPodSpec)
+	notConsuming := newDeployment("not-consuming", optedIn, corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "app"}},
+	})
+
+	consumingStatefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "consumer",
+			Namespace:   anotherNamespace.Name,
+			Annotations: optedIn,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{{
+						Name: "config",
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	ctx := context.Background()
+
+	client := fake.NewClientBuilder().
+		WithObjects(cm, anotherNamespace, consumer, notOptedIn, notConsuming, consumingStatefulSet).
+		Build()
+
+	hook := &controller.RolloutHook{Client: client}
+
+	r := &controller.ConfigMapReconciler{
+		Client:   client,
+		Scheme:   scheme.Scheme,
+		Recorder: record.NewFakeRecorder(10),
+		Policy:   controller.Policy{Hooks: []controller.Hook{hook}},
+	}
+
+	_, err := r.Reconcile(ctx, reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      cm.Name,
+			Namespace: cm.Namespace,
+		},
+	})
+	require.NoError(t, err)
+
+	checksumOf := func(t *testing.T, name string) string {
+		var deployment appsv1.Deployment
+		err := client.Get(ctx, types.NamespacedName{Name: name, Namespace: anotherNamespace.Name}, &deployment)
+		require.NoError(t, err)
+
+		return deployment.Spec.Template.Annotations[api.Key(api.AnnotationRolloutChecksum)]
+	}
+
+	var replica corev1.ConfigMap
+	err = client.Get(ctx, types.NamespacedName{Name: cm.Name, Namespace: anotherNamespace.Name}, &replica)
+	require.NoError(t, err)
+
+	initialChecksum := checksumOf(t, consumer.Name)
+
+	t.Run("Should Restart Opted In Workloads Consuming The Replica", func(t *testing.T) {
+		assert.NotEmpty(t, initialChecksum)
+
+		var statefulSet appsv1.StatefulSet
+		err := client.Get(ctx, types.NamespacedName{Name: consumingStatefulSet.Name, Namespace: anotherNamespace.Name}, &statefulSet)
+		require.NoError(t, err)
+
+		assert.Equal(t, initialChecksum, statefulSet.Spec.Template.Annotations[api.Key(api.AnnotationRolloutChecksum)])
+	})
+
+	t.Run("Should Not Restart Other Workloads", func(t *testing.T) {
+		assert.Empty(t, checksumOf(t, notOptedIn.Name))
+		assert.Empty(t, checksumOf(t, notConsuming.Name))
+	})
+
+	t.Run("Should Not Restart When The Contents Are Unchanged", func(t *testing.T) {
+		var before appsv1.Deployment
+		err := client.Get(ctx, types.NamespacedName{Name: consumer.Name, Namespace: anotherNamespace.Name}, &before)
+		require.NoError(t, err)
+
+		replica := replica.DeepCopy()
+		replica.Annotations[api.Key(api.AnnotationSyncedAt)] = "2024-01-01T00:00:00Z"
+
+		require.NoError(t, hook.AfterWrite(ctx, cm, replica))
+
+		var after appsv1.Deployment
+		err = client.Get(ctx, types.NamespacedName{Name: consumer.Name, Namespace: anotherNamespace.Name}, &after)
+		require.NoError(t, err)
+
+		assert.Equal(t, before.ResourceVersion, after.ResourceVersion)
+	})
+
+	t.Run("Should Restart When The Contents Change", func(t *testing.T) {
+		replica := replica.DeepCopy()
+		replica.Data["greeting"] = "bonjour"
+
+		require.NoError(t, hook.AfterWrite(ctx, cm, replica))
+
+		checksum := checksumOf(t, consumer.Name)
+		assert.NotEmpty(t, checksum)
+		assert.NotEqual(t, initialChecksum, checksum)
+	})
+
+	t.Run("Should Restart DeploymentConfigs", func(t *testing.T) {
+		openshiftScheme := runtime.NewScheme()
+		require.NoError(t, scheme.AddToScheme(openshiftScheme))
+		openshiftScheme.AddKnownTypeWithName(controller.DeploymentConfigGVK, &unstructured.Unstructured{})
+		openshiftScheme.AddKnownTypeWithName(controller.DeploymentConfigGVK.GroupVersion().WithKind("DeploymentConfigList"), &unstructured.UnstructuredList{})
+
+		deploymentConfig := &unstructured.Unstructured{Object: map[string]any{
+			"spec": map[string]any{
+				"template": map[string]any{
+					"spec": map[string]any{
+						"containers": []any{map[string]any{
+							"name":    "app",
+							"envFrom": []any{map[string]any{"configMapRef": map[string]any{"name": cm.Name}}},
+						}},
+					},
+				},
+			},
+		}}
+		deploymentConfig.SetGroupVersionKind(controller.DeploymentConfigGVK)
+		deploymentConfig.SetName("consumer")
+		deploymentConfig.SetNamespace(anotherNamespace.Name)
+		deploymentConfig.SetAnnotations(optedIn)
+
+		openshiftClient := fake.NewClientBuilder().
+			WithScheme(openshiftScheme).
+			WithObjects(deploymentConfig).
+			Build()
+
+		hook := &controller.RolloutHook{Client: openshiftClient, DeploymentConfigs: true}
+		require.NoError(t, hook.AfterWrite(ctx, cm, replica.DeepCopy()))
+
+		updated := &unstructured.Unstructured{}
+		updated.SetGroupVersionKind(controller.DeploymentConfigGVK)
+		err := openshiftClient.Get(ctx, types.NamespacedName{Name: "consumer", Namespace: anotherNamespace.Name}, updated)
+		require.NoError(t, err)
+
+		checksum, _, err := unstructured.NestedString(updated.Object, "spec", "template", "metadata", "annotations", api.Key(api.AnnotationRolloutChecksum))
+		require.NoError(t, err)
+		assert.NotEmpty(t, checksum)
+	})
+}
@@ -0,0 +1,178 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"sync"
+
+	"github.com/dpeckett/replikator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// RuntimeConfig holds settings that override the policy while replikator is
+// running (from the ReplikatorConfig resource), so that they can be tuned
+// without a restart.
+type RuntimeConfig struct {
+	mu           sync.RWMutex
+	spec         *v1alpha1.ReplikatorConfigSpec
+	writeLimiter *WriteLimiter
+	subscribers  []chan event.GenericEvent
+}
+
+// NewRuntimeConfig creates a new RuntimeConfig with no overrides.
+func NewRuntimeConfig() *RuntimeConfig {
+	return &RuntimeConfig{}
+}
+
+// Set replaces the current overrides. A nil spec reverts to the policy's
+// own settings.
+func (rc *RuntimeConfig) Set(spec *v1alpha1.ReplikatorConfigSpec) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	defer rc.notify()
+
+	if spec == nil {
+		rc.spec = nil
+		rc.writeLimiter = nil
+		return
+	}
+
+	// Only replace the write limiter if its settings changed, so that the
+	// write history of each namespace isn't lost on every update.
+	if rc.spec == nil || !equalQuantity(rc.spec.NamespaceWriteRate, spec.NamespaceWriteRate) ||
+		!equalInt32(rc.spec.NamespaceWriteBurst, spec.NamespaceWriteBurst) {
+		rc.writeLimiter = nil
+		if writesPerSecond := writeRate(spec.NamespaceWriteRate); writesPerSecond > 0 {
+			burst := 1
+			if spec.NamespaceWriteBurst != nil && *spec.NamespaceWriteBurst > 0 {
+				burst = int(*spec.NamespaceWriteBurst)
+			}
+
+			rc.writeLimiter = NewWriteLimiter(writesPerSecond, burst)
+		}
+	}
+
+	rc.spec = spec.DeepCopy()
+}
+
+// subscribe returns a channel that receives an event whenever the overrides
+// change. It must be called before the overrides are first set.
+func (rc *RuntimeConfig) subscribe() <-chan event.GenericEvent {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	ch := make(chan event.GenericEvent, 1)
+	rc.subscribers = append(rc.subscribers, ch)
+
+	return ch
+}
+
+func (rc *RuntimeConfig) notify() {
+	ev := event.GenericEvent{
+		Object: &v1alpha1.ReplikatorConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: v1alpha1.ReplikatorConfigName},
+		},
+	}
+
+	for _, ch := range rc.subscribers {
+		// A pending event will already requeue every source.
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// current returns the policy with any runtime overrides applied.
+func (p *Policy) current() Policy {
+	policy := *p
+	if p.Runtime == nil {
+		return policy
+	}
+
+	p.Runtime.mu.RLock()
+	defer p.Runtime.mu.RUnlock()
+
+	spec := p.Runtime.spec
+	if spec == nil {
+		return policy
+	}
+
+	if spec.ProtectedNamespaces != nil {
+		policy.ProtectedNamespaces = spec.ProtectedNamespaces
+	}
+
+	if spec.ExcludeNamespaces != nil {
+		policy.ExcludeNamespaces = spec.ExcludeNamespaces
+	}
+
+	if spec.DefaultReplicateTo != nil {
+		policy.DefaultReplicateTo = *spec.DefaultReplicateTo
+	}
+
+	if spec.DefaultReplicateKeys != nil {
+		policy.DefaultReplicateKeys = make(map[corev1.SecretType]string, len(spec.DefaultReplicateKeys))
+		for secretType, pattern := range spec.DefaultReplicateKeys {
+			policy.DefaultReplicateKeys[corev1.SecretType(secretType)] = pattern
+		}
+	}
+
+	if spec.MaxReplicaSize != nil {
+		policy.MaxReplicaSize = int(*spec.MaxReplicaSize)
+	}
+
+	if spec.MaxReplicasPerSource != nil {
+		policy.MaxReplicasPerSource = int(*spec.MaxReplicasPerSource)
+	}
+
+	if spec.NamespaceWriteRate != nil {
+		policy.WriteLimiter = p.Runtime.writeLimiter
+	}
+
+	return policy
+}
+
+// writeRate returns the number of writes per second of a write rate quantity
+// (0 if it isn't set).
+func writeRate(q *resource.Quantity) float64 {
+	if q == nil {
+		return 0
+	}
+
+	return q.AsApproximateFloat64()
+}
+
+func equalQuantity(a, b *resource.Quantity) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Cmp(*b) == 0
+}
+
+func equalInt32(a, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -20,22 +20,16 @@ package controller
 import (
 	"context"
 	"fmt"
-	"log/slog"
-	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/go-logr/logr"
-	"github.com/gpu-ninja/operator-utils/updater"
+	"github.com/dpeckett/replikator/pkg/api"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	"sigs.k8s.io/controller-runtime/pkg/handler"
-	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // Allow reading of namespaces.
@@ -45,252 +39,122 @@ import (
 // +kubebuilder:rbac:groups=core,resources=secrets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=secrets/finalizers,verbs=update
 
-const (
-	// AnnotationEnabledKey is the annotation that enables secret replication.
-	AnnotationEnabledKey = "v1alpha1.replikator.pecke.tt/enabled"
-	// AnnotationReplicateToKey is the annotation that specifies the target namespace/s to replicate to.
-	// The value of this annotation should be a comma-separated list of values / glob patterns.
-	// If this annotation is not present, the secret will be replicated to all namespaces.
-	AnnotationReplicateToKey = "v1alpha1.replikator.pecke.tt/replicate-to"
-	// AnnotationReplicateKeysKey is the annotation that specifies the keys to replicate.
-	// The value of this annotation should be a comma-separated list of values / glob patterns.
-	// If this annotation is not present, all keys will be replicated.
-	AnnotationReplicateKeysKey = "v1alpha1.replikator.pecke.tt/replicate-keys"
-	// FinalizerName is the name of the finalizer that will be added to the secret.
-	FinalizerName = "replikator.pecke.tt/finalizer"
-)
-
 type SecretReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Policy   Policy
+	// Writers, if set, provides the clients used to write replicas.
+	Writers WriterFactory
+	// APIReader, if set, reads replicas directly from the API server when
+	// writes conflict (otherwise they are read with the client).
+	APIReader client.Reader
 }
 
 func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
-
-	logger.Info("Reconciling")
-
-	var secret corev1.Secret
-	if err := r.Get(ctx, req.NamespacedName, &secret); err != nil {
-		if apierrors.IsNotFound(err) {
-			return ctrl.Result{}, nil
-		}
-
-		return ctrl.Result{}, err
-	}
-
-	var enabled bool
-	if secret.Annotations != nil {
-		if enabledStr, ok := secret.Annotations[AnnotationEnabledKey]; ok && strings.ToLower(enabledStr) == "true" {
-			enabled = true
-		}
-	}
-
-	if !enabled {
-		logger.Info("Replication not enabled")
-
-		return ctrl.Result{}, nil
-	}
-
-	if !controllerutil.ContainsFinalizer(&secret, FinalizerName) {
-		logger.Info("Adding Finalizer")
+	return r.reconciler().Reconcile(ctx, req)
+}
 
-		_, err := controllerutil.CreateOrPatch(ctx, r.Client, &secret, func() error {
-			controllerutil.AddFinalizer(&secret, FinalizerName)
+func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return r.reconciler().SetupWithManager(mgr)
+}
 
-			return nil
-		})
-		if err != nil {
-			return ctrl.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
-		}
+func (r *SecretReconciler) reconciler() *Reconciler[*corev1.Secret] {
+	return &Reconciler[*corev1.Secret]{
+		Client:     r.Client,
+		Recorder:   r.Recorder,
+		Policy:     r.Policy,
+		Writers:    r.Writers,
+		APIReader:  r.APIReader,
+		Replicator: SecretReplicator{},
 	}
+}
 
-	var namespaces corev1.NamespaceList
-	if err := r.List(ctx, &namespaces); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to list namespaces: %w", err)
+// allowsPrivateKeyReplication returns true if the secret either contains no TLS private key,
+// explicitly filters its keys, or has been explicitly permitted to replicate its private key.
+// The private key of an istio-ca preset secret is never replicated.
+func allowsPrivateKeyReplication(secret *corev1.Secret) bool {
+	if len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 || api.IsIstioCAPreset(secret) {
+		return true
 	}
 
-	var existingSecrets []*corev1.Secret
-	for _, namespace := range namespaces.Items {
-		if namespace.Name == secret.Namespace {
-			continue
-		}
-
-		secret := corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      secret.Name,
-				Namespace: namespace.Name,
-			},
-		}
-
-		if err := r.Get(ctx, client.ObjectKeyFromObject(&secret), &secret); err != nil {
-			if apierrors.IsNotFound(err) {
-				continue
-			}
-
-			return ctrl.Result{}, fmt.Errorf("failed to check for replicated secret: %w", err)
-		}
-
-		existingSecrets = append(existingSecrets, &secret)
+	if _, ok := api.GetAnnotation(secret, api.Key(api.AnnotationReplicateKeys)); ok {
+		return true
 	}
 
-	if !secret.GetDeletionTimestamp().IsZero() {
-		logger.Info("Deleting")
-
-		for _, secret := range existingSecrets {
-			if err := r.Delete(ctx, secret); err != nil {
-				if apierrors.IsNotFound(err) {
-					continue
-				}
-
-				return ctrl.Result{}, fmt.Errorf("failed to delete replicated secret: %w", err)
-			}
-		}
-
-		if controllerutil.ContainsFinalizer(&secret, FinalizerName) {
-			logger.Info("Removing Finalizer")
-
-			_, err := controllerutil.CreateOrPatch(ctx, r.Client, &secret, func() error {
-				controllerutil.RemoveFinalizer(&secret, FinalizerName)
-
-				return nil
-			})
-			if err != nil {
-				return ctrl.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
-			}
-		}
-
-		return ctrl.Result{}, nil
-	}
-
-	logger.Info("Creating or updating")
-
-	var keyFilters []string
-	if replicatedKeysAnnotation, ok := secret.Annotations[AnnotationReplicateKeysKey]; ok {
-		keyFilters = strings.Split(replicatedKeysAnnotation, ",")
-	}
+	allowStr, ok := api.GetAnnotation(secret, api.Key(api.AnnotationAllowPrivateKey))
+	return ok && strings.ToLower(allowStr) == "true"
+}
 
-	template := corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   secret.Name,
-			Labels: make(map[string]string),
-		},
-		Type: secret.Type,
-		Data: make(map[string][]byte),
-	}
+// SecretReplicator implements the secret specific parts of replication.
+type SecretReplicator struct{}
 
-	for key, value := range secret.ObjectMeta.Labels {
-		template.ObjectMeta.Labels[key] = value
-	}
+func (SecretReplicator) Kind() string {
+	return "secret"
+}
 
-	template.ObjectMeta.Labels["app.kubernetes.io/managed-by"] = "replikator"
+func (SecretReplicator) NewObject() *corev1.Secret {
+	return &corev1.Secret{}
+}
 
-	// For tls secrets, we need to ensure that the cert and private key are present.
-	if secret.Type == corev1.SecretTypeTLS {
-		template.Data[corev1.TLSCertKey] = []byte("")
-		template.Data[corev1.TLSPrivateKeyKey] = []byte("")
-	}
+func (SecretReplicator) GetTemplate(source *corev1.Secret, metadata api.MetadataFilter) (*corev1.Secret, error) {
+	return api.SecretTemplate(source, metadata)
+}
 
-	for key, value := range secret.Data {
-		if len(keyFilters) > 0 {
-			for _, keyFilter := range keyFilters {
-				if ok, err := filepath.Match(keyFilter, key); err != nil {
-					return ctrl.Result{}, fmt.Errorf("failed to evaluate key filter: %w", err)
-				} else if ok {
-					template.Data[key] = value
-					break
-				}
-			}
-		} else {
-			template.Data[key] = value
-		}
+func (SecretReplicator) ListExisting(ctx context.Context, c client.Reader, opts ...client.ListOption) ([]*corev1.Secret, error) {
+	var list corev1.SecretList
+	if err := c.List(ctx, &list, opts...); err != nil {
+		return nil, err
 	}
 
-	var namespaceFilters []string
-	if replicateTo, ok := secret.Annotations[AnnotationReplicateToKey]; ok {
-		namespaceFilters = strings.Split(replicateTo, ",")
+	var items []*corev1.Secret
+	for i := range list.Items {
+		items = append(items, &list.Items[i])
 	}
 
-	var desiredSecrets []*corev1.Secret
-	for _, namespace := range namespaces.Items {
-		if namespace.Name == secret.Namespace {
-			continue
-		}
+	return items, nil
+}
 
-		var replicate bool
-		if len(namespaceFilters) > 0 {
-			for _, filter := range namespaceFilters {
-				if ok, err := filepath.Match(filter, namespace.Name); err != nil {
-					return ctrl.Result{}, fmt.Errorf("failed to evaluate namespace filter: %w", err)
-				} else if ok {
-					replicate = true
-					break
-				}
-			}
-		} else {
-			replicate = true
-		}
+func (SecretReplicator) Write(ctx context.Context, c client.Client, reader client.Reader, existing, desired *corev1.Secret) error {
+	return writeReplica(ctx, c, reader, existing, desired)
+}
 
-		if replicate {
-			secret := template.DeepCopy()
-			secret.ObjectMeta.Namespace = namespace.Name
+func (SecretReplicator) Delete(ctx context.Context, c client.Client, replica *corev1.Secret) error {
+	return c.Delete(ctx, replica)
+}
 
-			desiredSecrets = append(desiredSecrets, secret)
-		}
-	}
+func (SecretReplicator) DataSize(secret *corev1.Secret) int {
+	return dataSize(secret.Data)
+}
 
-	removedSecrets, addedSecrets := diffObjects(existingSecrets, desiredSecrets)
+func (SecretReplicator) FiltersAllKeys(source *corev1.Secret) (bool, error) {
+	return filtersAllKeys(source, source.Data)
+}
 
-	for _, secret := range removedSecrets {
-		if err := r.Delete(ctx, secret); err != nil {
-			if apierrors.IsNotFound(err) {
-				continue
-			}
+func (SecretReplicator) Selector(policy *Policy) labels.Selector {
+	return policy.SecretSelector
+}
 
-			return ctrl.Result{}, fmt.Errorf("failed to delete replicated secret: %w", err)
-		}
+func (SecretReplicator) Refuse(policy *Policy, source *corev1.Secret) (string, string, bool) {
+	if policy.RequireKeyFilterForPrivateKeys && !allowsPrivateKeyReplication(source) {
+		return EventReasonPrivateKeyRefused, fmt.Sprintf("Refusing to replicate %s without a %s annotation",
+			corev1.TLSPrivateKeyKey, api.Key(api.AnnotationReplicateKeys)), true
 	}
 
-	for _, secret := range addedSecrets {
-		if _, err := updater.CreateOrUpdateFromTemplate(ctx, r.Client, secret); err != nil {
-			return ctrl.Result{}, fmt.Errorf("failed to replicate secret: %w", err)
+	if policy.ValidateCertificates && source.Type == corev1.SecretTypeTLS {
+		if err := validateCertificates(source, time.Now()); err != nil {
+			return EventReasonInvalidCertificate, fmt.Sprintf("Refusing to replicate invalid certificate: %v", err), true
 		}
 	}
 
-	return ctrl.Result{}, nil
+	return "", "", false
 }
 
-func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		Named("secret-controller").
-		For(&corev1.Secret{}).
-		// Requeue when a namespace is created.
-		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
-			logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
-
-			// Ignore deletions (there's nothing we need to do).
-			if !obj.GetDeletionTimestamp().IsZero() {
-				return nil
-			}
-
-			var secrets corev1.SecretList
-			if err := r.List(ctx, &secrets); err != nil {
-				logger.Error("Failed to list secrets", "error", err)
-
-				return nil
-			}
-
-			var reqs []ctrl.Request
-			for _, secret := range secrets.Items {
-				reqs = append(reqs, ctrl.Request{
-					NamespacedName: types.NamespacedName{
-						Name:      secret.Name,
-						Namespace: secret.Namespace,
-					},
-				})
-			}
+// The type of a secret is immutable, so the replica must be recreated if it changes.
+func (SecretReplicator) ImmutableChange(existing, desired *corev1.Secret) (string, bool) {
+	if existing == nil || existing.Type == desired.Type {
+		return "", false
+	}
 
-			return reqs
-		})).
-		Complete(r)
+	return fmt.Sprintf("its type changed from %s to %s", existing.Type, desired.Type), true
 }
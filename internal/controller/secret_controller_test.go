@@ -19,9 +19,12 @@ package controller_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
 	"github.com/go-logr/logr"
 	"github.com/neilotoole/slogt"
 	"github.com/stretchr/testify/assert"
@@ -29,22 +32,29 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func TestSecretReconciler(t *testing.T) {
 	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
 
+	setServerSideApply(t, false)
+
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test-secret",
 			Namespace: "test-namespace",
 			Annotations: map[string]string{
-				controller.AnnotationEnabledKey: "true",
+				api.Key(api.AnnotationEnabled): "true",
 			},
 		},
 		Type: corev1.SecretTypeTLS,
@@ -94,7 +104,7 @@ func TestSecretReconciler(t *testing.T) {
 
 	t.Run("Should Not Replicate When Not Enabled", func(t *testing.T) {
 		unreplicateSecret := secret.DeepCopy()
-		delete(unreplicateSecret.Annotations, controller.AnnotationEnabledKey)
+		delete(unreplicateSecret.Annotations, api.Key(api.AnnotationEnabled))
 
 		client := fake.NewClientBuilder().
 			WithObjects(unreplicateSecret, anotherNamespace).
@@ -125,7 +135,7 @@ func TestSecretReconciler(t *testing.T) {
 
 	t.Run("Should Only Replicate Specified Keys", func(t *testing.T) {
 		secretWithKeys := secret.DeepCopy()
-		secretWithKeys.Annotations[controller.AnnotationReplicateKeysKey] = "ca*"
+		secretWithKeys.Annotations[api.Key(api.AnnotationReplicateKeys)] = "ca*"
 
 		client := fake.NewClientBuilder().
 			WithObjects(secretWithKeys, anotherNamespace).
@@ -165,7 +175,7 @@ func TestSecretReconciler(t *testing.T) {
 		}
 
 		secretWithNamespaces := secret.DeepCopy()
-		secretWithNamespaces.Annotations[controller.AnnotationReplicateToKey] = "third-*"
+		secretWithNamespaces.Annotations[api.Key(api.AnnotationReplicateTo)] = "third-*"
 
 		client := fake.NewClientBuilder().
 			WithObjects(secretWithNamespaces, anotherNamespace, thirdNamespace).
@@ -199,4 +209,940 @@ func TestSecretReconciler(t *testing.T) {
 		}, &replicatedSecret)
 		require.Error(t, err)
 	})
+
+	t.Run("Should Not Replicate To Excepted Namespaces", func(t *testing.T) {
+		kubeNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "kube-public",
+			},
+		}
+
+		secretWithExceptions := secret.DeepCopy()
+		secretWithExceptions.Annotations[api.Key(api.AnnotationReplicateExcept)] = "kube-*,cattle-*"
+
+		client := fake.NewClientBuilder().
+			WithObjects(secretWithExceptions, anotherNamespace, kubeNamespace).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		var replicatedSecret corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.NoError(t, err)
+
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: kubeNamespace.Name,
+		}, &replicatedSecret)
+		require.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Not Replicate With Malformed Exceptions", func(t *testing.T) {
+		secretWithExceptions := secret.DeepCopy()
+		secretWithExceptions.Annotations[api.Key(api.AnnotationReplicateExcept)] = "kube-*,["
+
+		client := fake.NewClientBuilder().
+			WithObjects(secretWithExceptions, anotherNamespace).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.SecretReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		var replicatedSecret corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.True(t, apierrors.IsNotFound(err))
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonInvalidFilter)
+	})
+
+	t.Run("Should Write Replicas With Server-Side Apply", func(t *testing.T) {
+		setServerSideApply(t, true)
+
+		staleReplica, err := api.SecretTemplate(secret, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		staleReplica.Namespace = "third-namespace"
+		staleReplica.Data["stale.crt"] = []byte("stale")
+
+		thirdNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: staleReplica.Namespace,
+			},
+		}
+
+		var applied []*corev1.Secret
+		client := fake.NewClientBuilder().
+			WithObjects(secret, anotherNamespace, thirdNamespace, staleReplica).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Patch: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, patch ctrlclient.Patch, opts ...ctrlclient.PatchOption) error {
+					if patch.Type() != types.ApplyPatchType {
+						return c.Patch(ctx, obj, patch, opts...)
+					}
+
+					patchOpts := &ctrlclient.PatchOptions{}
+					patchOpts.ApplyOptions(opts)
+
+					assert.Equal(t, controller.FieldManager, patchOpts.FieldManager)
+					assert.True(t, *patchOpts.Force)
+					assert.Equal(t, "Secret", obj.GetObjectKind().GroupVersionKind().Kind)
+
+					// The fake client doesn't support server-side apply.
+					applied = append(applied, obj.(*corev1.Secret))
+					return nil
+				},
+			}).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		_, err = r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, applied, 1)
+		assert.Equal(t, anotherNamespace.Name, applied[0].Namespace)
+		assert.Equal(t, secret.Data, applied[0].Data)
+
+		// Keys dropped from an existing replica are removed with a full update.
+		var replicatedSecret corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: thirdNamespace.Name,
+		}, &replicatedSecret)
+		require.NoError(t, err)
+		assert.Equal(t, secret.Data, replicatedSecret.Data)
+	})
+
+	t.Run("Should Write Large Fan-Outs In Batches", func(t *testing.T) {
+		objects := []ctrlclient.Object{secret}
+		for i := 0; i < 5; i++ {
+			objects = append(objects, &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: fmt.Sprintf("team-%d", i),
+				},
+			})
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(objects...).
+			Build()
+
+		clock := testingclock.NewFakeClock(time.Now())
+
+		limiter := controller.NewWriteLimiter(1, 2)
+		limiter.Clock = clock
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{
+				WriteLimiter: limiter,
+			},
+		}
+
+		replicas := func() int {
+			var secrets corev1.SecretList
+			require.NoError(t, client.List(ctx, &secrets, ctrlclient.MatchingLabels{api.LabelManagedByKey: api.LabelManagedByValue}))
+
+			return len(secrets.Items)
+		}
+
+		for _, expected := range []int{2, 4, 5} {
+			resp, err := r.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      secret.Name,
+					Namespace: secret.Namespace,
+				},
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, expected, replicas())
+
+			if expected < 5 {
+				assert.Positive(t, resp.RequeueAfter)
+			}
+
+			clock.Step(2 * time.Second)
+		}
+	})
+
+	t.Run("Should Not Replicate To Protected Namespaces", func(t *testing.T) {
+		kubeSystem := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "kube-system",
+			},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, anotherNamespace, kubeSystem).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.SecretReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+			Policy: controller.Policy{
+				ProtectedNamespaces: []string{"kube-*"},
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedSecret corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.NoError(t, err)
+
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: kubeSystem.Name,
+		}, &replicatedSecret)
+		require.True(t, apierrors.IsNotFound(err))
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonSkippedTarget)
+	})
+
+	t.Run("Should Not Replicate Outside Of Scope", func(t *testing.T) {
+		thirdNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "third-namespace",
+			},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, anotherNamespace, thirdNamespace).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{
+				ExcludeNamespaces: []string{"third-*"},
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedSecret corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.NoError(t, err)
+
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: thirdNamespace.Name,
+		}, &replicatedSecret)
+		require.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Ignore Sources Outside Of Scope", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret, anotherNamespace).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{
+				WatchNamespaces: []string{anotherNamespace.Name},
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedSecret corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Not Replicate To Other Tenants", func(t *testing.T) {
+		sourceNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   secret.Namespace,
+				Labels: map[string]string{"tenant": "a"},
+			},
+		}
+
+		sameTenantNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "same-tenant",
+				Labels: map[string]string{"tenant": "a"},
+			},
+		}
+
+		otherTenantNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "other-tenant",
+				Labels: map[string]string{"tenant": "b"},
+			},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, sourceNamespace, sameTenantNamespace, otherTenantNamespace, anotherNamespace).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.SecretReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+			Policy: controller.Policy{
+				TenantLabel: "tenant",
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedSecret corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: sameTenantNamespace.Name,
+		}, &replicatedSecret)
+		require.NoError(t, err)
+
+		for _, namespace := range []string{otherTenantNamespace.Name, anotherNamespace.Name} {
+			err = client.Get(ctx, types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: namespace,
+			}, &replicatedSecret)
+			require.True(t, apierrors.IsNotFound(err))
+		}
+
+		require.Len(t, recorder.Events, 2)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonTenancyViolation)
+	})
+
+	t.Run("Should Replicate To Capsule Tenants", func(t *testing.T) {
+		source := secret.DeepCopy()
+		source.Annotations[api.Key(api.AnnotationReplicateToTenant)] = "b"
+
+		sourceNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   secret.Namespace,
+				Labels: map[string]string{api.CapsuleTenantLabel: "a"},
+			},
+		}
+
+		sameTenantNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "same-tenant",
+				Labels: map[string]string{api.CapsuleTenantLabel: "a"},
+			},
+		}
+
+		consentingNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "consenting-tenant",
+				Labels:      map[string]string{api.CapsuleTenantLabel: "b"},
+				Annotations: map[string]string{api.Key(api.AnnotationAcceptFromTenants): "a"},
+			},
+		}
+
+		otherTenantNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "other-tenant",
+				Labels: map[string]string{api.CapsuleTenantLabel: "b"},
+			},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(source, sourceNamespace, sameTenantNamespace, consentingNamespace, otherTenantNamespace, anotherNamespace).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.SecretReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+			Policy: controller.Policy{
+				TenantLabel: api.CapsuleTenantLabel,
+			},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		// Only the namespaces of tenant b are targeted, of which only one consents.
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: consentingNamespace.Name,
+		}, &corev1.Secret{})
+		require.NoError(t, err)
+
+		for _, namespace := range []string{sameTenantNamespace.Name, otherTenantNamespace.Name, anotherNamespace.Name} {
+			err = client.Get(ctx, types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: namespace,
+			}, &corev1.Secret{})
+			require.True(t, apierrors.IsNotFound(err), namespace)
+		}
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonTenancyViolation)
+	})
+
+	t.Run("Should Replicate Mesh CA To Mesh Enabled Namespaces", func(t *testing.T) {
+		source := secret.DeepCopy()
+		source.Annotations[api.Key(api.AnnotationPreset)] = api.PresetIstioCA
+
+		sidecarNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "sidecar",
+				Labels: map[string]string{api.IstioInjectionLabel: "enabled"},
+			},
+		}
+
+		revisionNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "revision",
+				Labels: map[string]string{api.IstioRevisionLabel: "canary"},
+			},
+		}
+
+		ambientNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "ambient",
+				Labels: map[string]string{api.IstioDataplaneModeLabel: "ambient"},
+			},
+		}
+
+		disabledNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "disabled",
+				Labels: map[string]string{
+					api.IstioInjectionLabel: "disabled",
+					api.IstioRevisionLabel:  "canary",
+				},
+			},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(source, sidecarNamespace, revisionNamespace, ambientNamespace, disabledNamespace, anotherNamespace).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{
+				RequireKeyFilterForPrivateKeys: true,
+			},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		for _, namespace := range []string{sidecarNamespace.Name, revisionNamespace.Name, ambientNamespace.Name} {
+			var replica corev1.Secret
+			err = client.Get(ctx, types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: namespace,
+			}, &replica)
+			require.NoError(t, err, namespace)
+
+			assert.Equal(t, corev1.SecretTypeOpaque, replica.Type)
+			assert.Equal(t, map[string][]byte{api.IstioRootCertKey: []byte("test-ca")}, replica.Data)
+		}
+
+		for _, namespace := range []string{disabledNamespace.Name, anotherNamespace.Name} {
+			err = client.Get(ctx, types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: namespace,
+			}, &corev1.Secret{})
+			require.True(t, apierrors.IsNotFound(err), namespace)
+		}
+	})
+
+	t.Run("Should Stamp Helm Ownership Metadata", func(t *testing.T) {
+		source := secret.DeepCopy()
+		source.UID = "test-uid"
+		source.Annotations[api.Key(api.AnnotationHelmRelease)] = "my-app"
+
+		client := fake.NewClientBuilder().
+			WithObjects(source, anotherNamespace).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		var replica corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replica)
+		require.NoError(t, err)
+
+		assert.Equal(t, api.HelmManagedByValue, replica.Labels[api.LabelManagedByKey])
+		assert.Equal(t, "my-app", replica.Annotations[api.HelmReleaseNameAnnotation])
+		assert.Equal(t, anotherNamespace.Name, replica.Annotations[api.HelmReleaseNamespaceAnnotation])
+		assert.True(t, api.IsReplica(&replica))
+
+		// The stamped replica isn't considered to have drifted.
+		drift, err := controller.DiffSource(ctx, client, controller.Policy{}, source)
+		require.NoError(t, err)
+		assert.Empty(t, drift)
+	})
+
+	t.Run("Should Write Replicas Using The Configured Writer", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret, anotherNamespace).
+			Build()
+
+		var writerNamespaces []string
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Writers: func(namespace string) (ctrlclient.Client, error) {
+				writerNamespaces = append(writerNamespaces, namespace)
+				return client, nil
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		assert.Equal(t, []string{anotherNamespace.Name}, writerNamespaces)
+	})
+
+	t.Run("Should Require Key Filter For Private Keys", func(t *testing.T) {
+		allowedSecret := secret.DeepCopy()
+		allowedSecret.Name = "allowed-secret"
+		allowedSecret.Annotations[api.Key(api.AnnotationAllowPrivateKey)] = "true"
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, allowedSecret, anotherNamespace).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controller.SecretReconciler{
+			Client:   client,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+			Policy: controller.Policy{
+				RequireKeyFilterForPrivateKeys: true,
+			},
+		}
+
+		for _, s := range []*corev1.Secret{secret, allowedSecret} {
+			resp, err := r.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      s.Name,
+					Namespace: s.Namespace,
+				},
+			})
+			require.NoError(t, err)
+			assert.Zero(t, resp)
+		}
+
+		var replicatedSecret corev1.Secret
+		err := client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.True(t, apierrors.IsNotFound(err))
+
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      allowedSecret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.NoError(t, err)
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonPrivateKeyRefused)
+	})
+
+	t.Run("Should Use Default Replicate Keys", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret, anotherNamespace).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{
+				RequireKeyFilterForPrivateKeys: true,
+				DefaultReplicateKeys: map[corev1.SecretType]string{
+					corev1.SecretTypeTLS: "ca.crt",
+				},
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedSecret corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.NoError(t, err)
+
+		assert.Equal(t, []byte("test-ca"), replicatedSecret.Data["ca.crt"])
+		assert.Empty(t, replicatedSecret.Data["tls.key"])
+	})
+
+	t.Run("Should Treat Forbidden Namespaces As Opted Out", func(t *testing.T) {
+		optedOutNamespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "opted-out",
+			},
+		}
+
+		forbidden := func(namespace string) error {
+			return apierrors.NewForbidden(corev1.Resource("secrets"), secret.Name, fmt.Errorf("no access to namespace %s", namespace))
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, anotherNamespace, optedOutNamespace).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Get: func(ctx context.Context, c ctrlclient.WithWatch, key ctrlclient.ObjectKey, obj ctrlclient.Object, opts ...ctrlclient.GetOption) error {
+					if key.Namespace == optedOutNamespace.Name {
+						return forbidden(key.Namespace)
+					}
+
+					return c.Get(ctx, key, obj, opts...)
+				},
+				Create: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, opts ...ctrlclient.CreateOption) error {
+					if obj.GetNamespace() == optedOutNamespace.Name {
+						return forbidden(obj.GetNamespace())
+					}
+
+					return c.Create(ctx, obj, opts...)
+				},
+			}).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{
+				NamespacedRBAC: true,
+			},
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedSecret corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.NoError(t, err)
+	})
+
+	t.Run("Should Force Cleanup When Replicas Cannot Be Deleted", func(t *testing.T) {
+		deletedSecret := secret.DeepCopy()
+		deletedSecret.Finalizers = []string{api.Finalizer()}
+		deletedSecret.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+		replica, err := api.SecretTemplate(secret, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = anotherNamespace.Name
+
+		client := fake.NewClientBuilder().
+			WithObjects(deletedSecret, anotherNamespace, replica).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Delete: func(ctx context.Context, c ctrlclient.WithWatch, obj ctrlclient.Object, opts ...ctrlclient.DeleteOption) error {
+					if obj.GetNamespace() == anotherNamespace.Name {
+						return apierrors.NewInternalError(fmt.Errorf("etcd unavailable"))
+					}
+
+					return c.Delete(ctx, obj, opts...)
+				},
+			}).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		req := reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      deletedSecret.Name,
+				Namespace: deletedSecret.Namespace,
+			},
+		}
+
+		_, err = r.Reconcile(ctx, req)
+		require.Error(t, err)
+
+		var updatedSecret corev1.Secret
+		err = client.Get(ctx, req.NamespacedName, &updatedSecret)
+		require.NoError(t, err)
+
+		assert.Contains(t, updatedSecret.Finalizers, api.Finalizer())
+
+		updatedSecret.Annotations[api.Key(api.AnnotationForceDelete)] = "true"
+		err = client.Update(ctx, &updatedSecret)
+		require.NoError(t, err)
+
+		resp, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		err = client.Get(ctx, req.NamespacedName, &updatedSecret)
+		require.Error(t, err)
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Recreate Replicas When Type Changes", func(t *testing.T) {
+		replica, err := api.SecretTemplate(secret, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = anotherNamespace.Name
+		replica.Type = corev1.SecretTypeOpaque
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, anotherNamespace, replica).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+		}
+
+		resp, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+		assert.Zero(t, resp)
+
+		var replicatedSecret corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.NoError(t, err)
+
+		assert.Equal(t, secret.Type, replicatedSecret.Type)
+		assert.Equal(t, secret.Data, replicatedSecret.Data)
+	})
+
+	t.Run("Should Ignore Secrets Not Matching Selector", func(t *testing.T) {
+		labeledSecret := secret.DeepCopy()
+		labeledSecret.Name = "labeled-secret"
+		labeledSecret.Labels = map[string]string{"replikator.pecke.tt/source": "true"}
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, labeledSecret, anotherNamespace).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{
+				SecretSelector: labels.SelectorFromSet(labels.Set{"replikator.pecke.tt/source": "true"}),
+			},
+		}
+
+		for _, s := range []*corev1.Secret{secret, labeledSecret} {
+			resp, err := r.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      s.Name,
+					Namespace: s.Namespace,
+				},
+			})
+			require.NoError(t, err)
+			assert.Zero(t, resp)
+		}
+
+		var replicatedSecret corev1.Secret
+		err := client.Get(ctx, types.NamespacedName{
+			Name:      secret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.True(t, apierrors.IsNotFound(err))
+
+		err = client.Get(ctx, types.NamespacedName{
+			Name:      labeledSecret.Name,
+			Namespace: anotherNamespace.Name,
+		}, &replicatedSecret)
+		require.NoError(t, err)
+		assert.Equal(t, "true", replicatedSecret.Labels["replikator.pecke.tt/source"])
+	})
+
+	t.Run("Should Not Overwrite External Secrets", func(t *testing.T) {
+		source := secret.DeepCopy()
+		source.Annotations[api.Key(api.AnnotationConflictPolicy)] = api.ConflictPolicyOverwrite
+
+		externalSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secret.Name,
+				Namespace: anotherNamespace.Name,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "external-secrets.io/v1beta1",
+					Kind:       "ExternalSecret",
+					Name:       secret.Name,
+					UID:        "test-uid",
+				}},
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				"tls.crt": []byte("external-crt"),
+			},
+		}
+
+		for _, overwrite := range []bool{false, true} {
+			client := fake.NewClientBuilder().
+				WithObjects(source, anotherNamespace, externalSecret).
+				Build()
+
+			recorder := record.NewFakeRecorder(10)
+
+			r := &controller.SecretReconciler{
+				Client:   client,
+				Scheme:   scheme.Scheme,
+				Recorder: recorder,
+				Policy:   controller.Policy{OverwriteExternalSecrets: overwrite},
+			}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      source.Name,
+					Namespace: source.Namespace,
+				},
+			})
+			require.NoError(t, err)
+
+			var existingSecret corev1.Secret
+			err = client.Get(ctx, types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: anotherNamespace.Name,
+			}, &existingSecret)
+			require.NoError(t, err)
+
+			if overwrite {
+				assert.True(t, api.IsReplica(&existingSecret))
+				assert.Equal(t, secret.Data["tls.crt"], existingSecret.Data["tls.crt"])
+			} else {
+				assert.False(t, api.IsReplica(&existingSecret))
+				assert.Equal(t, []byte("external-crt"), existingSecret.Data["tls.crt"])
+
+				require.Len(t, recorder.Events, 1)
+				assert.Contains(t, <-recorder.Events, controller.EventReasonExternallyManaged)
+			}
+		}
+	})
 }
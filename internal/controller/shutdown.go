@@ -0,0 +1,143 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get
+
+// ShutdownCleanup deletes every replica (and removes replikator's finalizers
+// from sources) when the manager is stopped. This is useful for ephemeral
+// clusters, and for uninstalling replikator without leaving replicas behind.
+// As it requires leader election, only the leader cleans up.
+type ShutdownCleanup struct {
+	client.Client
+	Policy Policy
+	// Writers, if set, provides the clients used to delete replicas.
+	Writers WriterFactory
+	// Timeout is the maximum time to spend cleaning up.
+	Timeout time.Duration
+	// Deployment, if set, is the Deployment running replikator. Replicas are
+	// then only deleted once it has been deleted (ie. replikator is being
+	// uninstalled), rather than whenever the operator is stopped (eg. by a
+	// rolling upgrade, a node drain or a leader handover).
+	Deployment *types.NamespacedName
+}
+
+// Start implements manager.Runnable.
+func (s *ShutdownCleanup) Start(ctx context.Context) error {
+	<-ctx.Done()
+
+	// The manager's context has been cancelled, so a fresh one is needed.
+	cleanupCtx, cancel := context.WithTimeout(log.IntoContext(context.Background(), log.FromContext(ctx)), s.Timeout)
+	defer cancel()
+
+	uninstalling, err := s.isUninstalling(cleanupCtx)
+	if err != nil {
+		return err
+	}
+
+	if !uninstalling {
+		logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx))).With("component", "shutdown-cleanup")
+		logger.Info("Not deleting replicas, as replikator is only restarting", "deployment", s.Deployment)
+
+		return nil
+	}
+
+	return s.Cleanup(cleanupCtx)
+}
+
+// isUninstalling returns true if replikator's Deployment has been (or is
+// being) deleted, or if there is no Deployment to check.
+func (s *ShutdownCleanup) isUninstalling(ctx context.Context) (bool, error) {
+	if s.Deployment == nil {
+		return true, nil
+	}
+
+	var deployment appsv1.Deployment
+	if err := s.Get(ctx, *s.Deployment, &deployment); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+
+		return false, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	return deployment.DeletionTimestamp != nil, nil
+}
+
+// Cleanup deletes every replica and removes replikator's finalizers from sources.
+func (s *ShutdownCleanup) Cleanup(ctx context.Context) error {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx))).With("component", "shutdown-cleanup")
+	policy := s.Policy.current()
+
+	logger.Info("Deleting all replicas")
+
+	objects, err := listObjects(ctx, s.Client)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if !api.IsReplica(obj) {
+			if hasFinalizer(obj) {
+				if err := patchWithRetry(ctx, s.Client, obj, func() error {
+					removeFinalizers(obj)
+
+					return nil
+				}); err != nil {
+					return fmt.Errorf("failed to remove finalizer: %w", err)
+				}
+			}
+
+			continue
+		}
+
+		if policy.IsProtectedNamespace(obj.GetNamespace()) || !policy.InScope(obj.GetNamespace()) {
+			continue
+		}
+
+		writer, err := writerFor(s.Client, s.Writers, obj.GetNamespace())
+		if err != nil {
+			return err
+		}
+
+		if err := writer.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			if policy.IsOptedOut(err) {
+				continue
+			}
+
+			return fmt.Errorf("failed to delete replica: %w", err)
+		}
+	}
+
+	return nil
+}
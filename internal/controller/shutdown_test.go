@@ -0,0 +1,121 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestShutdownCleanup(t *testing.T) {
+	ctx := context.Background()
+
+	source := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+			Finalizers: []string{api.Finalizer()},
+		},
+	}
+
+	t.Run("Should Delete Replicas And Remove Finalizers", func(t *testing.T) {
+		replica, err := api.ConfigMapTemplate(source, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = "team-a"
+
+		c := fake.NewClientBuilder().
+			WithObjects(source, replica).
+			Build()
+
+		cleanup := &controller.ShutdownCleanup{Client: c}
+
+		require.NoError(t, cleanup.Cleanup(ctx))
+
+		err = c.Get(ctx, client.ObjectKeyFromObject(replica), replica)
+		require.Error(t, err)
+		assert.True(t, apierrors.IsNotFound(err))
+
+		var updatedSource corev1.ConfigMap
+		err = c.Get(ctx, client.ObjectKeyFromObject(source), &updatedSource)
+		require.NoError(t, err)
+
+		assert.Empty(t, updatedSource.Finalizers)
+	})
+
+	t.Run("Should Only Delete Replicas When The Deployment Is Deleted", func(t *testing.T) {
+		replica, err := api.ConfigMapTemplate(source, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		replica.Namespace = "team-a"
+
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "replikator",
+				Namespace: "replikator",
+			},
+		}
+
+		c := fake.NewClientBuilder().
+			WithObjects(source, replica, deployment).
+			Build()
+
+		cleanup := &controller.ShutdownCleanup{
+			Client:     c,
+			Timeout:    time.Minute,
+			Deployment: ptr.To(client.ObjectKeyFromObject(deployment)),
+		}
+
+		stop := func() {
+			ctx, cancel := context.WithCancel(ctx)
+			cancel()
+
+			require.NoError(t, cleanup.Start(ctx))
+		}
+
+		// A restart (eg. a rolling upgrade) leaves replicas alone.
+		stop()
+
+		require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(replica), replica))
+
+		// But uninstalling deletes them.
+		require.NoError(t, c.Delete(ctx, deployment))
+
+		stop()
+
+		err = c.Get(ctx, client.ObjectKeyFromObject(replica), replica)
+		require.Error(t, err)
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+}
@@ -0,0 +1,173 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultSPIFFEBundleKey is the key SPIFFE trust bundles are written to by default.
+const DefaultSPIFFEBundleKey = "bundle.spiffe"
+
+// SPIFFEBundleTransformer adds a SPIFFE trust bundle, rendered from the CA
+// certificates in the ca.crt key, to the replicas of sources annotated with
+// spiffe-bundle. Bundles use the JWKS based format of the SPIFFE Trust Domain
+// and Bundle specification, as served by SPIRE federation endpoints. Replicas
+// of other sources are left unchanged.
+type SPIFFEBundleTransformer struct {
+	// Key is the key the bundle is written to (defaults to DefaultSPIFFEBundleKey).
+	Key string
+	// RefreshHint, if set, is advertised to consumers as how often they
+	// should poll for an updated bundle.
+	RefreshHint time.Duration
+}
+
+func (t *SPIFFEBundleTransformer) Transform(_ context.Context, source, replica client.Object) (client.Object, error) {
+	if !ShouldRenderSPIFFEBundle(source) {
+		return replica, nil
+	}
+
+	cas, ok := objectData(replica)[corev1.ServiceAccountRootCAKey]
+	if !ok {
+		return nil, fmt.Errorf("can't render SPIFFE bundle without a %s key", corev1.ServiceAccountRootCAKey)
+	}
+
+	bundle, err := spiffeBundle(cas, t.RefreshHint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render SPIFFE bundle: %w", err)
+	}
+
+	key := t.Key
+	if key == "" {
+		key = DefaultSPIFFEBundleKey
+	}
+
+	transformed := replica.DeepCopyObject().(client.Object)
+	switch transformed := transformed.(type) {
+	case *corev1.Secret:
+		if transformed.Data == nil {
+			transformed.Data = make(map[string][]byte)
+		}
+
+		transformed.Data[key] = bundle
+	case *corev1.ConfigMap:
+		if transformed.Data == nil {
+			transformed.Data = make(map[string]string)
+		}
+
+		transformed.Data[key] = string(bundle)
+	default:
+		return nil, fmt.Errorf("unsupported object type %T", replica)
+	}
+
+	return transformed, nil
+}
+
+// ShouldRenderSPIFFEBundle returns true if the replicas of the source object should
+// include a SPIFFE trust bundle (according to its spiffe-bundle annotation).
+func ShouldRenderSPIFFEBundle(obj client.Object) bool {
+	bundleStr, ok := api.GetAnnotation(obj, api.Key(api.AnnotationSPIFFEBundle))
+	return ok && strings.ToLower(bundleStr) == "true"
+}
+
+type spiffeBundleJSON struct {
+	Keys        []spiffeJWK `json:"keys"`
+	RefreshHint int64       `json:"spiffe_refresh_hint,omitempty"`
+}
+
+type spiffeJWK struct {
+	Use string   `json:"use"`
+	Kty string   `json:"kty"`
+	Crv string   `json:"crv,omitempty"`
+	X   string   `json:"x,omitempty"`
+	Y   string   `json:"y,omitempty"`
+	N   string   `json:"n,omitempty"`
+	E   string   `json:"e,omitempty"`
+	X5c []string `json:"x5c"`
+}
+
+// spiffeBundle renders the PEM encoded CA certificates as a SPIFFE trust bundle,
+// with an x509-svid authority for each certificate (in order).
+func spiffeBundle(data []byte, refreshHint time.Duration) ([]byte, error) {
+	certs, err := parseCertificates(corev1.ServiceAccountRootCAKey, data)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := spiffeBundleJSON{
+		Keys:        make([]spiffeJWK, 0, len(certs)),
+		RefreshHint: int64(refreshHint / time.Second),
+	}
+
+	for _, cert := range certs {
+		key, err := spiffeX509Authority(cert)
+		if err != nil {
+			return nil, err
+		}
+
+		bundle.Keys = append(bundle.Keys, key)
+	}
+
+	return json.Marshal(&bundle)
+}
+
+// spiffeX509Authority returns the JWK of an X.509 authority in a SPIFFE trust bundle.
+func spiffeX509Authority(cert *x509.Certificate) (spiffeJWK, error) {
+	key := spiffeJWK{
+		Use: "x509-svid",
+		X5c: []string{base64.StdEncoding.EncodeToString(cert.Raw)},
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+
+	switch pub := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+
+		key.Kty = "EC"
+		key.Crv = pub.Curve.Params().Name
+		key.X = encode(pub.X.FillBytes(make([]byte, size)))
+		key.Y = encode(pub.Y.FillBytes(make([]byte, size)))
+	case *rsa.PublicKey:
+		key.Kty = "RSA"
+		key.N = encode(pub.N.Bytes())
+		key.E = encode(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		key.Kty = "OKP"
+		key.Crv = "Ed25519"
+		key.X = encode(pub)
+	default:
+		return spiffeJWK{}, fmt.Errorf("unsupported public key type %T of certificate %q", pub, cert.Subject)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,152 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestSPIFFEBundle(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	caPEM := generateCA(t, "trust-anchor", time.Now().Add(365*24*time.Hour))
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	ctx := context.Background()
+
+	transformer := &controller.SPIFFEBundleTransformer{RefreshHint: 5 * time.Minute}
+
+	reconcileConfigMap := func(t *testing.T, cm *corev1.ConfigMap) *corev1.ConfigMap {
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{
+				Transformations: []controller.Transformation{{Transformer: transformer}},
+			},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace},
+		})
+		require.NoError(t, err)
+
+		var replica corev1.ConfigMap
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: cm.Name, Namespace: anotherNamespace.Name}, &replica))
+
+		return &replica
+	}
+
+	newConfigMap := func(spiffeBundle bool) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "trust-anchors",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+				},
+			},
+			Data: map[string]string{
+				"ca.crt": string(caPEM),
+			},
+		}
+
+		if spiffeBundle {
+			cm.Annotations[api.Key(api.AnnotationSPIFFEBundle)] = "true"
+		}
+
+		return cm
+	}
+
+	t.Run("Should Render A SPIFFE Trust Bundle", func(t *testing.T) {
+		replica := reconcileConfigMap(t, newConfigMap(true))
+
+		assert.Equal(t, string(caPEM), replica.Data["ca.crt"])
+		require.Contains(t, replica.Data, controller.DefaultSPIFFEBundleKey)
+
+		var bundle struct {
+			Keys []struct {
+				Use string   `json:"use"`
+				Kty string   `json:"kty"`
+				Crv string   `json:"crv"`
+				X   string   `json:"x"`
+				Y   string   `json:"y"`
+				X5c []string `json:"x5c"`
+			} `json:"keys"`
+			RefreshHint int64 `json:"spiffe_refresh_hint"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(replica.Data[controller.DefaultSPIFFEBundleKey]), &bundle))
+
+		assert.Equal(t, int64(300), bundle.RefreshHint)
+		require.Len(t, bundle.Keys, 1)
+
+		key := bundle.Keys[0]
+		assert.Equal(t, "x509-svid", key.Use)
+		assert.Equal(t, "EC", key.Kty)
+		assert.Equal(t, "P-256", key.Crv)
+		assert.NotEmpty(t, key.X)
+		assert.NotEmpty(t, key.Y)
+
+		block, _ := pem.Decode(caPEM)
+		require.Len(t, key.X5c, 1)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(block.Bytes), key.X5c[0])
+	})
+
+	t.Run("Should Not Render A SPIFFE Trust Bundle Unless Annotated", func(t *testing.T) {
+		replica := reconcileConfigMap(t, newConfigMap(false))
+
+		assert.NotContains(t, replica.Data, controller.DefaultSPIFFEBundleKey)
+	})
+
+	t.Run("Should Fail With Malformed CA Certificates", func(t *testing.T) {
+		cm := newConfigMap(true)
+		cm.Data = map[string]string{"ca.crt": "not a certificate"}
+
+		_, err := transformer.Transform(ctx, cm, cm)
+		require.Error(t, err)
+	})
+}
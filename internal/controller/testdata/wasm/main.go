@@ -0,0 +1,55 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// A transformation plugin used by the tests, built with GOOS=wasip1 GOARCH=wasm.
+// It records on the replica the namespace it was transformed for, and whether
+// the filesystem was accessible.
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+func main() {
+	var req struct {
+		Replica map[string]any `json:"replica"`
+	}
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		os.Exit(1)
+	}
+
+	metadata, _ := req.Replica["metadata"].(map[string]any)
+
+	data, _ := req.Replica["data"].(map[string]any)
+	if data == nil {
+		data = make(map[string]any)
+	}
+
+	data["namespace"] = metadata["namespace"]
+
+	data["filesystem"] = "denied"
+	if _, err := os.ReadFile("/etc/hostname"); err == nil {
+		data["filesystem"] = "allowed"
+	}
+
+	req.Replica["data"] = data
+
+	if err := json.NewEncoder(os.Stdout).Encode(map[string]any{"replica": req.Replica}); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,55 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reconcileWithTimeout bounds the time spent reconciling a single source, so that
+// a pathological fan-out cannot block a worker indefinitely. If the timeout is
+// reached the source is requeued, and the remaining targets are written by the
+// next reconcile (as it only writes replicas that are missing or out of date).
+// The timedOut function is called if the timeout is reached.
+func reconcileWithTimeout(ctx context.Context, timeout time.Duration, timedOut func(), reconcile func(ctx context.Context) (ctrl.Result, error)) (ctrl.Result, error) {
+	if timeout <= 0 {
+		return reconcile(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := reconcile(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+		logger.Warn("Reconcile timed out, requeuing remaining targets", "timeout", timeout)
+
+		timedOut()
+
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	return result, err
+}
@@ -0,0 +1,205 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Transformer rewrites replicas before they are written (eg. to rewrite their
+// data for the target namespace).
+type Transformer interface {
+	// Transform returns the replica to write in place of the given replica of
+	// the source. The replica must not be modified.
+	Transform(ctx context.Context, source, replica client.Object) (client.Object, error)
+}
+
+// FailurePolicy decides what happens when a transformation fails.
+type FailurePolicy string
+
+const (
+	// FailurePolicyFail doesn't write the replica, and requeues the source.
+	FailurePolicyFail FailurePolicy = "Fail"
+	// FailurePolicyIgnore writes the replica without the transformation.
+	FailurePolicyIgnore FailurePolicy = "Ignore"
+)
+
+// ParseFailurePolicy parses a failure policy (case insensitively).
+func ParseFailurePolicy(value string) (FailurePolicy, error) {
+	switch strings.ToLower(value) {
+	case "fail":
+		return FailurePolicyFail, nil
+	case "ignore":
+		return FailurePolicyIgnore, nil
+	default:
+		return "", fmt.Errorf("invalid failure policy %q (expected Fail or Ignore)", value)
+	}
+}
+
+// Transformation is a transformer along with how its failures are handled.
+type Transformation struct {
+	Transformer Transformer
+	// FailurePolicy decides what happens when the transformer fails (defaults to Fail).
+	FailurePolicy FailurePolicy
+}
+
+// TransformRequest is provided (as JSON) to exec and webhook transformers.
+type TransformRequest struct {
+	Source  client.Object `json:"source"`
+	Replica client.Object `json:"replica"`
+}
+
+// TransformResponse is returned (as JSON) by exec and webhook transformers.
+// An empty response leaves the replica unchanged.
+type TransformResponse struct {
+	// Replica, if set, replaces the replica. The name and namespace of the
+	// replica, along with the labels and annotations identifying it as a
+	// replica, can't be changed.
+	Replica json.RawMessage `json:"replica,omitempty"`
+}
+
+// ExecTransformer runs a program to transform each replica, writing a
+// TransformRequest to its stdin and reading a TransformResponse from its
+// stdout. A non-zero exit status is treated as a failure.
+type ExecTransformer struct {
+	// Command is the program and its arguments.
+	Command []string
+	// Timeout, if set, limits how long the program may run for.
+	Timeout time.Duration
+}
+
+func (t *ExecTransformer) Transform(ctx context.Context, source, replica client.Object) (client.Object, error) {
+	var resp TransformResponse
+	if err := execJSON(ctx, t.Command, t.Timeout, &TransformRequest{Source: source, Replica: replica}, &resp); err != nil {
+		return nil, fmt.Errorf("transformation failed: %w", err)
+	}
+
+	return resp.apply(replica)
+}
+
+// WebhookTransformer POSTs a TransformRequest to an HTTP endpoint to transform
+// each replica, and reads a TransformResponse from the response body. A non-2xx
+// status is treated as a failure.
+type WebhookTransformer struct {
+	// URL is the endpoint of the webhook.
+	URL string
+	// Timeout, if set, limits how long each request may take.
+	Timeout time.Duration
+	// Client, if set, is used to make requests (instead of the default client).
+	Client *http.Client
+}
+
+func (t *WebhookTransformer) Transform(ctx context.Context, source, replica client.Object) (client.Object, error) {
+	var resp TransformResponse
+	if err := postJSON(ctx, t.Client, t.URL, t.Timeout, &TransformRequest{Source: source, Replica: replica}, &resp); err != nil {
+		return nil, fmt.Errorf("transformation failed: %w", err)
+	}
+
+	return resp.apply(replica)
+}
+
+// apply returns the replica returned by a transformer (or the original replica
+// if none was returned).
+func (r *TransformResponse) apply(replica client.Object) (client.Object, error) {
+	if len(r.Replica) == 0 {
+		return replica, nil
+	}
+
+	transformed, err := decodeObjectLike(replica, r.Replica)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal replica returned by transformer: %w", err)
+	}
+
+	return transformed, nil
+}
+
+// transformReplica applies each transformation to the replica in order.
+func transformReplica[T client.Object](ctx context.Context, c client.Client, transformations []Transformation, source, replica T) (T, []error, error) {
+	if len(transformations) == 0 {
+		return replica, nil, nil
+	}
+
+	setKind(c, source)
+	setKind(c, replica)
+
+	var ignored []error
+	for _, transformation := range transformations {
+		transformed, err := transformation.Transformer.Transform(ctx, source, replica)
+		if err != nil {
+			if transformation.FailurePolicy == FailurePolicyIgnore {
+				ignored = append(ignored, err)
+				continue
+			}
+
+			return replica, ignored, err
+		}
+
+		typed, ok := transformed.(T)
+		if !ok {
+			return replica, ignored, fmt.Errorf("transformer returned a %T, expected a %T", transformed, replica)
+		}
+
+		preserveIdentity(replica, typed)
+		replica = typed
+	}
+
+	return replica, ignored, nil
+}
+
+// preserveIdentity restores the name, namespace and replikator's own labels and
+// annotations of the original replica on the transformed replica, so that a
+// transformer can't change which object is written or orphan the replica.
+func preserveIdentity(original, transformed client.Object) {
+	transformed.SetName(original.GetName())
+	transformed.SetNamespace(original.GetNamespace())
+	transformed.SetResourceVersion(original.GetResourceVersion())
+
+	labels := transformed.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	for key, value := range original.GetLabels() {
+		if key == api.LabelManagedByKey || strings.HasPrefix(key, api.KeyPrefix()) {
+			labels[key] = value
+		}
+	}
+
+	transformed.SetLabels(labels)
+
+	annotations := transformed.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	for key, value := range original.GetAnnotations() {
+		if strings.HasPrefix(key, api.KeyPrefix()) {
+			annotations[key] = value
+		}
+	}
+
+	transformed.SetAnnotations(annotations)
+}
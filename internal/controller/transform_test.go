@@ -0,0 +1,168 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestTransformations(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	setServerSideApply(t, false)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		Data: map[string]string{
+			"endpoint": "https://example.com",
+		},
+	}
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	ctx := context.Background()
+
+	replicaKey := types.NamespacedName{Name: cm.Name, Namespace: anotherNamespace.Name}
+
+	reconcileConfigMap := func(transformations ...controller.Transformation) (ctrlclient.Client, error) {
+		client := fake.NewClientBuilder().
+			WithObjects(cm, anotherNamespace).
+			Build()
+
+		r := &controller.ConfigMapReconciler{
+			Client: client,
+			Scheme: scheme.Scheme,
+			Policy: controller.Policy{Transformations: transformations},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      cm.Name,
+				Namespace: cm.Namespace,
+			},
+		})
+
+		return client, err
+	}
+
+	t.Run("Should Write Transformed Replicas", func(t *testing.T) {
+		client, err := reconcileConfigMap(controller.Transformation{
+			Transformer: transformerFunc(func(_ context.Context, _, replica ctrlclient.Object) (ctrlclient.Object, error) {
+				transformed := replica.DeepCopyObject().(*corev1.ConfigMap)
+				transformed.Data["endpoint"] = "https://" + transformed.Namespace + ".example.com"
+
+				// Attempts to rename the replica, or disown it, are ignored.
+				transformed.Name = "renamed"
+				delete(transformed.Labels, api.LabelManagedByKey)
+
+				return transformed, nil
+			}),
+		})
+		require.NoError(t, err)
+
+		var replica corev1.ConfigMap
+		require.NoError(t, client.Get(ctx, replicaKey, &replica))
+
+		assert.Equal(t, "https://another-namespace.example.com", replica.Data["endpoint"])
+		assert.True(t, api.IsReplica(&replica))
+	})
+
+	t.Run("Should Not Write Replicas When A Transformation Fails", func(t *testing.T) {
+		client, err := reconcileConfigMap(controller.Transformation{
+			Transformer: transformerFunc(func(_ context.Context, _, _ ctrlclient.Object) (ctrlclient.Object, error) {
+				return nil, errors.New("unavailable")
+			}),
+		})
+		require.Error(t, err)
+
+		err = client.Get(ctx, replicaKey, &corev1.ConfigMap{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Write Untransformed Replicas When Failures Are Ignored", func(t *testing.T) {
+		client, err := reconcileConfigMap(controller.Transformation{
+			Transformer: transformerFunc(func(_ context.Context, _, _ ctrlclient.Object) (ctrlclient.Object, error) {
+				return nil, errors.New("unavailable")
+			}),
+			FailurePolicy: controller.FailurePolicyIgnore,
+		})
+		require.NoError(t, err)
+
+		var replica corev1.ConfigMap
+		require.NoError(t, client.Get(ctx, replicaKey, &replica))
+
+		assert.Equal(t, cm.Data, replica.Data)
+	})
+
+	t.Run("Should Transform Using An Exec Transformer", func(t *testing.T) {
+		transformer := &controller.ExecTransformer{
+			Command: []string{"sh", "-c", `cat >/dev/null; echo '{"replica": {"data": {"endpoint": "https://internal.example.com"}}}'`},
+		}
+
+		replica := cm.DeepCopy()
+		replica.Namespace = anotherNamespace.Name
+
+		transformed, err := transformer.Transform(ctx, cm, replica)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{"endpoint": "https://internal.example.com"}, transformed.(*corev1.ConfigMap).Data)
+	})
+
+	t.Run("Should Reject Invalid Failure Policies", func(t *testing.T) {
+		failurePolicy, err := controller.ParseFailurePolicy("ignore")
+		require.NoError(t, err)
+		assert.Equal(t, controller.FailurePolicyIgnore, failurePolicy)
+
+		_, err = controller.ParseFailurePolicy("retry")
+		assert.Error(t, err)
+	})
+}
+
+type transformerFunc func(ctx context.Context, source, replica ctrlclient.Object) (ctrlclient.Object, error)
+
+func (f transformerFunc) Transform(ctx context.Context, source, replica ctrlclient.Object) (ctrlclient.Object, error) {
+	return f(ctx, source, replica)
+}
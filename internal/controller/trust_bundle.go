@@ -0,0 +1,330 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// +kubebuilder:rbac:groups=trust.cert-manager.io,resources=bundles,verbs=get;list;watch;create;update;delete
+
+// BundleGVK is the group, version and kind of trust-manager Bundles.
+var BundleGVK = schema.GroupVersionKind{Group: "trust.cert-manager.io", Version: "v1alpha1", Kind: "Bundle"}
+
+// DefaultTrustNamespace is the namespace trust-manager reads Bundle sources from by default.
+const DefaultTrustNamespace = "cert-manager"
+
+// trustBundleTargetKey is the key of the configmaps trust-manager writes the bundle to.
+const trustBundleTargetKey = "ca.crt"
+
+// TrustBundleReconciler replicates CA secrets annotated with trust-bundle by
+// managing a trust-manager Bundle (with the same name as the secret) sourcing
+// them, rather than copying them to each namespace. trust-manager then writes
+// the certificates to a configmap in each target namespace.
+//
+// As Bundles can only select namespaces by label, only the literal namespace
+// names of the replicate-to (and protected and excluded namespace) filters are
+// supported. Tenancy is not enforced.
+type TrustBundleReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Policy   Policy
+	// TrustNamespace is the namespace trust-manager reads Bundle sources from
+	// (defaults to DefaultTrustNamespace).
+	TrustNamespace string
+}
+
+func (r *TrustBundleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+	policy := r.Policy.current()
+
+	var secret corev1.Secret
+	if err := r.Get(ctx, req.NamespacedName, &secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, r.deleteBundle(ctx, logger, req.NamespacedName)
+	}
+
+	if !api.IsReplicationEnabled(&secret) || !api.IsTrustBundleSource(&secret) || api.IsReplica(&secret) || !secret.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.deleteBundle(ctx, logger, req.NamespacedName)
+	}
+
+	if trustNamespace := r.trustNamespace(); secret.Namespace != trustNamespace {
+		logger.Warn("Trust bundle source is not in the trust namespace", "trustNamespace", trustNamespace)
+
+		r.event(&secret, "Not replicating as a trust bundle, trust-manager only reads sources from namespace %s", trustNamespace)
+
+		return ctrl.Result{}, nil
+	}
+
+	source := secret.DeepCopy()
+	policy.applyDefaults(source)
+
+	spec, err := trustBundleSpec(&policy, source)
+	if err != nil {
+		logger.Warn("Refusing to replicate as a trust bundle", "error", err)
+
+		r.event(&secret, "Not replicating as a trust bundle: %s", err)
+
+		return ctrl.Result{}, nil
+	}
+
+	bundle := newBundle()
+	if err := r.Get(ctx, types.NamespacedName{Name: secret.Name}, bundle); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to get bundle: %w", err)
+		}
+
+		bundle = newBundle()
+		bundle.SetName(secret.Name)
+	} else if !isBundleOf(bundle, req.NamespacedName) {
+		logger.Warn("Bundle already exists and is not managed by replikator")
+
+		r.event(&secret, "Not replicating as a trust bundle, a Bundle named %s that is not managed by replikator already exists", secret.Name)
+
+		return ctrl.Result{}, nil
+	}
+
+	labels := bundle.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[api.LabelManagedByKey] = api.LabelManagedByValue
+	labels[api.Key(api.LabelSourceUID)] = string(secret.UID)
+	bundle.SetLabels(labels)
+
+	annotations := bundle.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[api.Key(api.AnnotationSourceNamespace)] = secret.Namespace
+	annotations[api.Key(api.AnnotationSourceName)] = secret.Name
+	bundle.SetAnnotations(annotations)
+
+	if err := unstructured.SetNestedField(bundle.Object, spec, "spec"); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to set bundle spec: %w", err)
+	}
+
+	if bundle.GetResourceVersion() == "" {
+		logger.Info("Creating trust bundle")
+
+		if err := r.Create(ctx, bundle); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to create bundle: %w", err)
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.Update(ctx, bundle); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update bundle: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *TrustBundleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("trust-bundle-controller").
+		For(&corev1.Secret{}).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return api.IsTrustBundleSource(e.Object)
+			},
+			// Removing the annotation must also remove the bundle.
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return api.IsTrustBundleSource(e.ObjectOld) || api.IsTrustBundleSource(e.ObjectNew)
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return api.IsTrustBundleSource(e.Object)
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return api.IsTrustBundleSource(e.Object)
+			},
+		}).
+		Complete(r)
+}
+
+// deleteBundle deletes the bundle of the source (if any).
+func (r *TrustBundleReconciler) deleteBundle(ctx context.Context, logger *slog.Logger, source types.NamespacedName) error {
+	bundle := newBundle()
+	if err := r.Get(ctx, types.NamespacedName{Name: source.Name}, bundle); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if !isBundleOf(bundle, source) {
+		return nil
+	}
+
+	logger.Info("Deleting trust bundle")
+
+	if err := r.Delete(ctx, bundle); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete bundle: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TrustBundleReconciler) trustNamespace() string {
+	if r.TrustNamespace != "" {
+		return r.TrustNamespace
+	}
+
+	return DefaultTrustNamespace
+}
+
+func (r *TrustBundleReconciler) event(obj client.Object, messageFmt string, args ...any) {
+	recordEvent(r.Policy.Events, r.Recorder, obj, corev1.EventTypeWarning, EventReasonTrustBundleRefused, messageFmt, args...)
+}
+
+// trustBundleSpec returns the spec of the Bundle for the source secret. Each
+// key of the secret (matching its replicate-keys filter) is a source of the
+// bundle, except for private keys which are never included.
+func trustBundleSpec(policy *Policy, secret *corev1.Secret) (map[string]any, error) {
+	var sources []any
+	for _, key := range sortedKeys(secret.Data) {
+		if key == corev1.TLSPrivateKeyKey {
+			continue
+		}
+
+		if ok, err := api.ShouldReplicateKey(secret, key); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+
+		sources = append(sources, map[string]any{
+			"secret": map[string]any{
+				"name": secret.Name,
+				"key":  key,
+			},
+		})
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no certificates to include in the bundle")
+	}
+
+	var matchExpressions []any
+
+	if replicateTo, ok := api.GetAnnotation(secret, api.Key(api.AnnotationReplicateTo)); ok {
+		namespaces, err := literalNamespaces(api.ParseFilters(replicateTo))
+		if err != nil {
+			return nil, fmt.Errorf("replicate-to: %w", err)
+		}
+
+		matchExpressions = append(matchExpressions, namespaceNameExpression("In", namespaces))
+	}
+
+	// Globs can't be expressed as a label selector, so only literal protected
+	// and excluded namespaces are honored.
+	var excluded []any
+	for _, pattern := range append(append([]string{secret.Namespace}, policy.ProtectedNamespaces...), policy.ExcludeNamespaces...) {
+		if !isGlob(pattern) {
+			excluded = append(excluded, pattern)
+		}
+	}
+
+	if replicateExcept, ok := api.GetAnnotation(secret, api.Key(api.AnnotationReplicateExcept)); ok {
+		namespaces, err := literalNamespaces(api.ParseFilters(replicateExcept))
+		if err != nil {
+			return nil, fmt.Errorf("replicate-except: %w", err)
+		}
+
+		excluded = append(excluded, namespaces...)
+	}
+
+	matchExpressions = append(matchExpressions, namespaceNameExpression("NotIn", excluded))
+
+	return map[string]any{
+		"sources": sources,
+		"target": map[string]any{
+			"configMap": map[string]any{
+				"key": trustBundleTargetKey,
+			},
+			"namespaceSelector": map[string]any{
+				"matchExpressions": matchExpressions,
+			},
+		},
+	}, nil
+}
+
+// literalNamespaces returns the namespace names in the filters, or an error
+// if any of them are glob patterns.
+func literalNamespaces(filters []string) ([]any, error) {
+	var namespaces []any
+	for _, filter := range filters {
+		if isGlob(filter) {
+			return nil, fmt.Errorf("glob pattern %q is not supported by trust bundles", filter)
+		}
+
+		namespaces = append(namespaces, filter)
+	}
+
+	return namespaces, nil
+}
+
+func namespaceNameExpression(operator string, namespaces []any) map[string]any {
+	return map[string]any{
+		"key":      corev1.LabelMetadataName,
+		"operator": operator,
+		"values":   namespaces,
+	}
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// isTrustBundleSource returns true if the source is replicated as a trust
+// bundle by the TrustBundleReconciler (rather than copied to each namespace).
+func isTrustBundleSource(policy *Policy, obj client.Object) bool {
+	_, isSecret := obj.(*corev1.Secret)
+	return policy.TrustManager && isSecret && api.IsTrustBundleSource(obj)
+}
+
+// isBundleOf returns true if the bundle is managed by replikator on behalf of the source.
+func isBundleOf(bundle client.Object, source types.NamespacedName) bool {
+	ref, _, ok := api.GetSourceReference(bundle)
+	return api.IsReplica(bundle) && ok && ref == source
+}
+
+func newBundle() *unstructured.Unstructured {
+	bundle := &unstructured.Unstructured{}
+	bundle.SetGroupVersionKind(BundleGVK)
+
+	return bundle
+}
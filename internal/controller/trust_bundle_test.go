@@ -0,0 +1,232 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestTrustBundleReconciler(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(controller.BundleGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(controller.BundleGVK.GroupVersion().WithKind("BundleList"), &unstructured.UnstructuredList{})
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "root-ca",
+			Namespace: controller.DefaultTrustNamespace,
+			UID:       "test-uid",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled):     "true",
+				api.Key(api.AnnotationTrustBundle): "true",
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			"ca.crt":  []byte("test-ca"),
+			"tls.crt": []byte("test-crt"),
+			"tls.key": []byte("test-key"),
+		},
+	}
+
+	anotherNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "another-namespace",
+		},
+	}
+
+	ctx := context.Background()
+
+	reconcileSecret := func(t *testing.T, client ctrlclient.Client, policy controller.Policy, recorder *record.FakeRecorder) {
+		r := &controller.TrustBundleReconciler{
+			Client:   client,
+			Recorder: recorder,
+			Policy:   policy,
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	getBundle := func(t *testing.T, client ctrlclient.Client) (*unstructured.Unstructured, error) {
+		bundle := &unstructured.Unstructured{}
+		bundle.SetGroupVersionKind(controller.BundleGVK)
+
+		return bundle, client.Get(ctx, types.NamespacedName{Name: secret.Name}, bundle)
+	}
+
+	t.Run("Should Create A Bundle", func(t *testing.T) {
+		source := secret.DeepCopy()
+		source.Annotations[api.Key(api.AnnotationReplicateTo)] = "another-namespace"
+
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(source, anotherNamespace).
+			Build()
+
+		policy := controller.Policy{
+			TrustManager:        true,
+			ProtectedNamespaces: []string{"kube-system", "kube-*"},
+		}
+
+		reconcileSecret(t, client, policy, record.NewFakeRecorder(10))
+
+		bundle, err := getBundle(t, client)
+		require.NoError(t, err)
+
+		assert.True(t, api.IsReplica(bundle))
+		assert.Equal(t, "test-uid", bundle.GetLabels()[api.Key(api.LabelSourceUID)])
+
+		sources, _, err := unstructured.NestedSlice(bundle.Object, "spec", "sources")
+		require.NoError(t, err)
+
+		// The private key is never included.
+		assert.Equal(t, []any{
+			map[string]any{"secret": map[string]any{"name": secret.Name, "key": "ca.crt"}},
+			map[string]any{"secret": map[string]any{"name": secret.Name, "key": "tls.crt"}},
+		}, sources)
+
+		matchExpressions, _, err := unstructured.NestedSlice(bundle.Object, "spec", "target", "namespaceSelector", "matchExpressions")
+		require.NoError(t, err)
+
+		assert.Equal(t, []any{
+			map[string]any{"key": corev1.LabelMetadataName, "operator": "In", "values": []any{"another-namespace"}},
+			map[string]any{"key": corev1.LabelMetadataName, "operator": "NotIn", "values": []any{secret.Namespace, "kube-system"}},
+		}, matchExpressions)
+	})
+
+	t.Run("Should Refuse Glob Patterns", func(t *testing.T) {
+		source := secret.DeepCopy()
+		source.Annotations[api.Key(api.AnnotationReplicateTo)] = "another-*"
+
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(source).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		reconcileSecret(t, client, controller.Policy{TrustManager: true}, recorder)
+
+		_, err := getBundle(t, client)
+		assert.True(t, apierrors.IsNotFound(err))
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonTrustBundleRefused)
+	})
+
+	t.Run("Should Not Overwrite Unmanaged Bundles", func(t *testing.T) {
+		unmanaged := &unstructured.Unstructured{}
+		unmanaged.SetGroupVersionKind(controller.BundleGVK)
+		unmanaged.SetName(secret.Name)
+
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(secret, unmanaged).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		reconcileSecret(t, client, controller.Policy{TrustManager: true}, recorder)
+
+		bundle, err := getBundle(t, client)
+		require.NoError(t, err)
+
+		assert.False(t, api.IsReplica(bundle))
+
+		require.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, controller.EventReasonTrustBundleRefused)
+	})
+
+	t.Run("Should Delete The Bundle When Disabled", func(t *testing.T) {
+		source := secret.DeepCopy()
+
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(source).
+			Build()
+
+		reconcileSecret(t, client, controller.Policy{TrustManager: true}, record.NewFakeRecorder(10))
+
+		_, err := getBundle(t, client)
+		require.NoError(t, err)
+
+		require.NoError(t, client.Get(ctx, ctrlclient.ObjectKeyFromObject(source), source))
+
+		delete(source.Annotations, api.Key(api.AnnotationTrustBundle))
+		require.NoError(t, client.Update(ctx, source))
+
+		reconcileSecret(t, client, controller.Policy{TrustManager: true}, record.NewFakeRecorder(10))
+
+		_, err = getBundle(t, client)
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Not Copy Trust Bundle Sources", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(secret, anotherNamespace).
+			Build()
+
+		r := &controller.SecretReconciler{
+			Client:   client,
+			Scheme:   scheme,
+			Recorder: record.NewFakeRecorder(10),
+			Policy:   controller.Policy{TrustManager: true},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      secret.Name,
+				Namespace: secret.Namespace,
+			},
+		})
+		require.NoError(t, err)
+
+		err = client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: anotherNamespace.Name}, &corev1.Secret{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+}
@@ -0,0 +1,133 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// isKnownAnnotation returns true if the key is a current replikator annotation.
+func isKnownAnnotation(key string) bool {
+	switch key {
+	case api.Key(api.AnnotationEnabled), api.Key(api.AnnotationReplicateTo), api.Key(api.AnnotationReplicateKeys),
+		api.Key(api.AnnotationAllowPrivateKey), api.Key(api.AnnotationEnabledBy), api.Key(api.AnnotationConflictPolicy),
+		api.Key(api.AnnotationAdoptExisting), api.Key(api.AnnotationForceDelete), api.Key(api.AnnotationSourceNamespace),
+		api.Key(api.AnnotationSourceName), api.Key(api.AnnotationSyncedAt), api.Key(api.AnnotationTrustBundle),
+		api.Key(api.AnnotationCertificate), api.Key(api.AnnotationReplicateToTenant), api.Key(api.AnnotationPreset),
+		api.Key(api.AnnotationReplicateToClusters), api.Key(api.AnnotationHelmRelease), api.Key(api.AnnotationCAOverlap),
+		api.Key(api.AnnotationCAOverlapUntil), api.Key(api.AnnotationCAChecksum), api.Key(api.AnnotationWithdrawExpired),
+		api.Key(api.AnnotationSPIFFEBundle), api.Key(api.AnnotationReplicateFrom), api.Key(api.AnnotationReplicationAllowed),
+		api.Key(api.AnnotationReplicationAllowedNamespaces), api.Key(api.AnnotationReplicateExcept), api.Key(api.AnnotationSourceHash):
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidateAnnotations checks the replikator annotations on an object.
+// Errors are problems that will prevent replication from working as intended,
+// warnings are problems that are likely (but not necessarily) mistakes.
+func ValidateAnnotations(obj metav1.Object) (errs []string, warnings []string) {
+	annotations := obj.GetAnnotations()
+
+	var keys []string
+	for key := range annotations {
+		if strings.Contains(key, "replikator") || strings.HasPrefix(key, api.KeyPrefix()) || api.IsLegacyAnnotation(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if api.IsLegacyAnnotation(key) {
+			warnings = append(warnings, fmt.Sprintf("legacy annotation %q (run replikator migrate-annotations)", key))
+		} else if !isKnownAnnotation(key) {
+			errs = append(errs, fmt.Sprintf("unknown annotation %q", key))
+		}
+	}
+
+	enabledStr, hasEnabled := annotations[api.Key(api.AnnotationEnabled)]
+	if hasEnabled && !isBool(enabledStr) {
+		errs = append(errs, fmt.Sprintf("invalid value %q for %s (expected true or false)", enabledStr, api.Key(api.AnnotationEnabled)))
+	}
+
+	for _, key := range []string{api.Key(api.AnnotationAllowPrivateKey), api.Key(api.AnnotationAdoptExisting), api.Key(api.AnnotationForceDelete), api.Key(api.AnnotationTrustBundle), api.Key(api.AnnotationWithdrawExpired), api.Key(api.AnnotationSPIFFEBundle), api.Key(api.AnnotationReplicationAllowed)} {
+		if value, ok := annotations[key]; ok && !isBool(value) {
+			errs = append(errs, fmt.Sprintf("invalid value %q for %s (expected true or false)", value, key))
+		}
+	}
+
+	if preset, ok := annotations[api.Key(api.AnnotationPreset)]; ok && !api.IsKnownPreset(preset) {
+		errs = append(errs, fmt.Sprintf("unknown preset %q for %s", preset, api.Key(api.AnnotationPreset)))
+	}
+
+	if value, ok := annotations[api.Key(api.AnnotationCAOverlap)]; ok {
+		if grace, err := time.ParseDuration(value); err != nil || grace <= 0 {
+			errs = append(errs, fmt.Sprintf("invalid value %q for %s (expected a positive duration, eg. 168h)", value, api.Key(api.AnnotationCAOverlap)))
+		}
+	}
+
+	if _, _, err := api.GetReplicateFrom(obj); err != nil {
+		errs = append(errs, fmt.Sprintf("malformed %s: %v", api.Key(api.AnnotationReplicateFrom), err))
+	}
+
+	if value, ok := annotations[api.Key(api.AnnotationReplicationAllowedNamespaces)]; ok {
+		if _, hasAllowed := annotations[api.Key(api.AnnotationReplicationAllowed)]; !hasAllowed {
+			warnings = append(warnings, fmt.Sprintf("%s has no effect without %s", api.Key(api.AnnotationReplicationAllowedNamespaces), api.Key(api.AnnotationReplicationAllowed)))
+		}
+
+		if err := api.ValidateFilters(value); err != nil {
+			errs = append(errs, fmt.Sprintf("malformed %s: %v", api.Key(api.AnnotationReplicationAllowedNamespaces), err))
+		}
+	}
+
+	if _, err := GetConflictPolicy(obj); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	for _, key := range []string{api.Key(api.AnnotationReplicateTo), api.Key(api.AnnotationReplicateExcept), api.Key(api.AnnotationReplicateToTenant), api.Key(api.AnnotationReplicateToClusters), api.Key(api.AnnotationReplicateKeys)} {
+		value, ok := annotations[key]
+		if !ok {
+			continue
+		}
+
+		if !hasEnabled {
+			warnings = append(warnings, fmt.Sprintf("%s has no effect without %s", key, api.Key(api.AnnotationEnabled)))
+		}
+
+		if err := api.ValidateFilters(value); err != nil {
+			errs = append(errs, fmt.Sprintf("malformed %s: %v", key, err))
+		}
+	}
+
+	if api.IsReplicationEnabled(obj) && api.IsReplica(obj) {
+		warnings = append(warnings, "replication is enabled on an object managed by replikator")
+	}
+
+	return errs, warnings
+}
+
+func isBool(value string) bool {
+	return strings.EqualFold(value, "true") || strings.EqualFold(value, "false")
+}
@@ -0,0 +1,402 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/dryrun"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// vclusterSecretPrefix is the prefix of the name of the secret, in the host
+// namespace, containing the kubeconfig of a virtual cluster.
+const vclusterSecretPrefix = "vc-"
+
+// vclusterKubeconfigKey is the key of the kubeconfig in the secret.
+const vclusterKubeconfigKey = "config"
+
+// VClusterKubeconfigField is the field index of secrets containing the
+// kubeconfig of a virtual cluster.
+const VClusterKubeconfigField = "vcluster.kubeconfig"
+
+// defaultVClusterNamespaces are the virtual namespaces replicas are copied to,
+// if the host namespace doesn't specify its own.
+const defaultVClusterNamespaces = "default"
+
+// VClusterSyncer copies the replicas in the host namespaces of virtual clusters
+// (vcluster) into the virtual clusters themselves, so that workloads running
+// in virtual clusters can consume them. The host namespace decides which
+// virtual namespaces replicas are copied to, with its vcluster-namespaces
+// annotation. Copies are updated (or deleted) along with the replicas in the
+// host namespace.
+type VClusterSyncer struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Policy Policy
+	// Interval is the time between syncs of every virtual cluster. Virtual
+	// clusters are also synced when replicas in their host namespace change.
+	Interval time.Duration
+	// Clock is the clock used to schedule syncs (defaults to the real clock).
+	Clock clock.WithTicker
+	// VirtualClient, if set, returns a client for the named virtual cluster
+	// hosted in the namespace. By default a client is built from the kubeconfig
+	// secret of the virtual cluster.
+	VirtualClient func(ctx context.Context, hostNamespace, name string) (client.Client, error)
+	// DryRun logs (rather than performs) writes to virtual clusters.
+	DryRun bool
+
+	clients map[string]cachedClient
+}
+
+type cachedClient struct {
+	resourceVersion string
+	client          client.Client
+}
+
+// IndexVClusterKubeconfig is the indexer of VClusterKubeconfigField.
+func IndexVClusterKubeconfig(obj client.Object) []string {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return nil
+	}
+
+	name, ok := strings.CutPrefix(secret.Name, vclusterSecretPrefix)
+	if !ok || strings.HasPrefix(name, "config-") {
+		return nil
+	}
+
+	if _, ok := secret.Data[vclusterKubeconfigKey]; !ok {
+		return nil
+	}
+
+	return []string{"true"}
+}
+
+// SetupWithManager indexes the kubeconfig secrets of virtual clusters, and
+// adds the syncer to the manager.
+func (s *VClusterSyncer) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Secret{}, VClusterKubeconfigField, IndexVClusterKubeconfig); err != nil {
+		return fmt.Errorf("failed to index vcluster kubeconfigs: %w", err)
+	}
+
+	return mgr.Add(s)
+}
+
+// Start implements manager.Runnable.
+func (s *VClusterSyncer) Start(ctx context.Context) error {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx))).With("component", "vcluster-syncer")
+
+	changed := make(chan string, 100)
+	if s.Policy.Events != nil {
+		unsubscribe := s.Policy.Events.Subscribe(func(event Event) {
+			switch event.Reason {
+			case EventReasonReplicaWritten, EventReasonReplicaRepaired, EventReasonReplicaDeleted:
+				// Dropped changes are picked up by the next periodic sync.
+				select {
+				case changed <- event.Namespace:
+				default:
+				}
+			}
+		})
+		defer unsubscribe()
+	}
+
+	if err := s.SyncAll(ctx); err != nil {
+		logger.Error("Failed to sync virtual clusters", "error", err)
+	}
+
+	// Without an interval, virtual clusters are only synced when replicas change.
+	var tick <-chan time.Time
+	if s.Interval > 0 {
+		ticker := clockOrDefault(s.Clock).NewTicker(s.Interval)
+		defer ticker.Stop()
+
+		tick = ticker.C()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick:
+			if err := s.SyncAll(ctx); err != nil {
+				logger.Error("Failed to sync virtual clusters", "error", err)
+			}
+		case namespace := <-changed:
+			if err := s.SyncNamespace(ctx, namespace); err != nil {
+				logger.Error("Failed to sync virtual clusters", "namespace", namespace, "error", err)
+			}
+		}
+	}
+}
+
+// SyncAll syncs every virtual cluster.
+func (s *VClusterSyncer) SyncAll(ctx context.Context) error {
+	return s.sync(ctx)
+}
+
+// SyncNamespace syncs the virtual clusters hosted in the namespace.
+func (s *VClusterSyncer) SyncNamespace(ctx context.Context, namespace string) error {
+	return s.sync(ctx, client.InNamespace(namespace))
+}
+
+func (s *VClusterSyncer) sync(ctx context.Context, opts ...client.ListOption) error {
+	vclusters, err := s.vclusters(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, vcluster := range vclusters {
+		if err := s.syncVCluster(ctx, vcluster.Namespace, vcluster.Name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync virtual cluster %s: %w", vcluster, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// vclusters returns the (host namespace and name of) virtual clusters, as
+// identified by their kubeconfig secrets.
+func (s *VClusterSyncer) vclusters(ctx context.Context, opts ...client.ListOption) ([]types.NamespacedName, error) {
+	var secrets corev1.SecretList
+	if err := s.List(ctx, &secrets, append(opts, client.MatchingFields{VClusterKubeconfigField: "true"})...); err != nil {
+		return nil, fmt.Errorf("failed to list vcluster kubeconfigs: %w", err)
+	}
+
+	policy := s.Policy.current()
+
+	var vclusters []types.NamespacedName
+	for _, secret := range secrets.Items {
+		name := strings.TrimPrefix(secret.Name, vclusterSecretPrefix)
+
+		if policy.IsProtectedNamespace(secret.Namespace) || !policy.InScope(secret.Namespace) {
+			continue
+		}
+
+		vclusters = append(vclusters, types.NamespacedName{Namespace: secret.Namespace, Name: name})
+	}
+
+	return vclusters, nil
+}
+
+// syncVCluster copies the replicas in the host namespace into the virtual
+// cluster, and deletes copies that are no longer required.
+func (s *VClusterSyncer) syncVCluster(ctx context.Context, hostNamespace, name string) error {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx))).
+		With("component", "vcluster-syncer", "namespace", hostNamespace, "vcluster", name)
+
+	var namespace corev1.Namespace
+	if err := s.Get(ctx, types.NamespacedName{Name: hostNamespace}, &namespace); err != nil {
+		return fmt.Errorf("failed to get host namespace: %w", err)
+	}
+
+	patterns, ok := api.GetAnnotation(&namespace, api.Key(api.AnnotationVClusterNamespaces))
+	if !ok {
+		patterns = defaultVClusterNamespaces
+	}
+
+	virtualClient, err := s.virtualClient(ctx, hostNamespace, name)
+	if err != nil {
+		return err
+	}
+
+	if s.DryRun {
+		virtualClient = dryrun.NewClient(virtualClient, logger)
+	}
+
+	var virtualNamespaces corev1.NamespaceList
+	if err := virtualClient.List(ctx, &virtualNamespaces); err != nil {
+		return fmt.Errorf("failed to list virtual namespaces: %w", err)
+	}
+
+	objects, err := listObjects(ctx, s.Client, client.InNamespace(hostNamespace))
+	if err != nil {
+		return err
+	}
+
+	desired := make(map[string]client.Object)
+	for _, virtualNamespace := range virtualNamespaces.Items {
+		if !matchesAnyFilter(patterns, virtualNamespace.Name) || !virtualNamespace.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		for _, obj := range objects {
+			if !api.IsReplica(obj) {
+				continue
+			}
+
+			virtualReplica := vclusterReplica(obj, virtualNamespace.Name)
+			desired[objectKey(virtualReplica)] = virtualReplica
+		}
+	}
+
+	for _, virtualReplica := range desired {
+		existing := virtualReplica.DeepCopyObject().(client.Object)
+		if err := virtualClient.Get(ctx, client.ObjectKeyFromObject(virtualReplica), existing); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to get virtual replica: %w", err)
+			}
+
+			logger.Info("Copying replica into virtual cluster", "virtualNamespace", virtualReplica.GetNamespace(), "name", virtualReplica.GetName())
+
+			if err := virtualClient.Create(ctx, virtualReplica); err != nil {
+				return fmt.Errorf("failed to create virtual replica: %w", err)
+			}
+
+			continue
+		}
+
+		// Objects not created by replikator are never overwritten.
+		if existing.GetLabels()[api.Key(api.LabelVClusterReplica)] == "" {
+			logger.Warn("Skipping virtual namespace with conflicting object", "virtualNamespace", virtualReplica.GetNamespace(), "name", virtualReplica.GetName())
+
+			continue
+		}
+
+		if len(CompareReplica(virtualReplica, existing)) == 0 {
+			continue
+		}
+
+		logger.Info("Updating replica in virtual cluster", "virtualNamespace", virtualReplica.GetNamespace(), "name", virtualReplica.GetName())
+
+		virtualReplica.SetResourceVersion(existing.GetResourceVersion())
+		if err := virtualClient.Update(ctx, virtualReplica); err != nil {
+			return fmt.Errorf("failed to update virtual replica: %w", err)
+		}
+	}
+
+	virtualReplicas, err := listObjects(ctx, virtualClient, client.HasLabels{api.Key(api.LabelVClusterReplica)})
+	if err != nil {
+		return err
+	}
+
+	for _, virtualReplica := range virtualReplicas {
+		if _, ok := desired[objectKey(virtualReplica)]; ok {
+			continue
+		}
+
+		logger.Info("Deleting replica from virtual cluster", "virtualNamespace", virtualReplica.GetNamespace(), "name", virtualReplica.GetName())
+
+		if err := virtualClient.Delete(ctx, virtualReplica); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete virtual replica: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// virtualClient returns a client for the virtual cluster.
+func (s *VClusterSyncer) virtualClient(ctx context.Context, hostNamespace, name string) (client.Client, error) {
+	if s.VirtualClient != nil {
+		return s.VirtualClient(ctx, hostNamespace, name)
+	}
+
+	var secret corev1.Secret
+	if err := s.Get(ctx, types.NamespacedName{Namespace: hostNamespace, Name: vclusterSecretPrefix + name}, &secret); err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
+	}
+
+	key := hostNamespace + "/" + name
+	if cached, ok := s.clients[key]; ok && cached.resourceVersion == secret.ResourceVersion {
+		return cached.client, nil
+	}
+
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(secret.Data[vclusterKubeconfigKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	// The kubeconfig addresses the virtual cluster from within its own pod,
+	// so it's reached through its service instead.
+	cfg.Host = fmt.Sprintf("https://%s.%s.svc", name, hostNamespace)
+
+	c, err := client.New(cfg, client.Options{Scheme: s.Scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	if s.clients == nil {
+		s.clients = make(map[string]cachedClient)
+	}
+	s.clients[key] = cachedClient{resourceVersion: secret.ResourceVersion, client: c}
+
+	return c, nil
+}
+
+// vclusterReplica returns the copy of the replica in the virtual namespace.
+// Copies are not themselves marked as replicas, so that they aren't mistaken
+// for orphans when the virtual cluster syncs them back to the host cluster.
+func vclusterReplica(replica client.Object, namespace string) client.Object {
+	labels := make(map[string]string)
+	for key, value := range replica.GetLabels() {
+		if key != api.LabelManagedByKey && key != api.Key(api.LabelSourceUID) {
+			labels[key] = value
+		}
+	}
+	labels[api.Key(api.LabelVClusterReplica)] = "true"
+
+	objectMeta := metav1.ObjectMeta{
+		Name:        replica.GetName(),
+		Namespace:   namespace,
+		Labels:      labels,
+		Annotations: replica.GetAnnotations(),
+	}
+
+	switch replica := replica.(type) {
+	case *corev1.Secret:
+		return &corev1.Secret{ObjectMeta: objectMeta, Type: replica.Type, Data: replica.Data}
+	case *corev1.ConfigMap:
+		return &corev1.ConfigMap{ObjectMeta: objectMeta, Data: replica.Data, BinaryData: replica.BinaryData}
+	default:
+		panic(fmt.Sprintf("unsupported kind %T", replica))
+	}
+}
+
+// objectKey uniquely identifies an object of any kind.
+func objectKey(obj client.Object) string {
+	return fmt.Sprintf("%T/%s/%s", obj, obj.GetNamespace(), obj.GetName())
+}
+
+func matchesAnyFilter(filters, value string) bool {
+	for _, filter := range api.ParseFilters(filters) {
+		if ok, err := filepath.Match(filter, value); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,214 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestVClusterSyncer(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	hostNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "vcluster-a",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationVClusterNamespaces): "app-*",
+			},
+		},
+	}
+
+	kubeconfig := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "vc-my-vcluster",
+			Namespace: hostNamespace.Name,
+		},
+		Data: map[string][]byte{
+			"config": []byte("test-kubeconfig"),
+		},
+	}
+
+	replica := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "root-ca",
+			Namespace: hostNamespace.Name,
+			Labels: map[string]string{
+				api.LabelManagedByKey:       api.LabelManagedByValue,
+				api.Key(api.LabelSourceUID): "test-uid",
+			},
+			Annotations: map[string]string{
+				api.Key(api.AnnotationSourceNamespace): "cert-manager",
+				api.Key(api.AnnotationSourceName):      "root-ca",
+			},
+		},
+		Data: map[string]string{
+			"ca.crt": "test-ca",
+		},
+	}
+
+	unmanaged := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "not-a-replica",
+			Namespace: hostNamespace.Name,
+		},
+	}
+
+	newVirtualNamespace := func(name string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	ctx := context.Background()
+
+	newSyncer := func(t *testing.T, virtualObjects ...ctrlclient.Object) (*controller.VClusterSyncer, ctrlclient.Client) {
+		hostClient := fake.NewClientBuilder().
+			WithObjects(hostNamespace, kubeconfig, replica, unmanaged).
+			WithIndex(&corev1.Secret{}, controller.VClusterKubeconfigField, controller.IndexVClusterKubeconfig).
+			Build()
+
+		virtualClient := fake.NewClientBuilder().
+			WithObjects(append(virtualObjects, newVirtualNamespace("default"), newVirtualNamespace("app-a"), newVirtualNamespace("app-b"))...).
+			Build()
+
+		return &controller.VClusterSyncer{
+			Client: hostClient,
+			VirtualClient: func(_ context.Context, namespace, name string) (ctrlclient.Client, error) {
+				assert.Equal(t, hostNamespace.Name, namespace)
+				assert.Equal(t, "my-vcluster", name)
+
+				return virtualClient, nil
+			},
+		}, virtualClient
+	}
+
+	t.Run("Should Copy Replicas Into Virtual Namespaces", func(t *testing.T) {
+		syncer, virtualClient := newSyncer(t)
+
+		require.NoError(t, syncer.SyncNamespace(ctx, hostNamespace.Name))
+
+		for _, namespace := range []string{"app-a", "app-b"} {
+			var virtualReplica corev1.ConfigMap
+			err := virtualClient.Get(ctx, types.NamespacedName{Name: replica.Name, Namespace: namespace}, &virtualReplica)
+			require.NoError(t, err)
+
+			assert.Equal(t, replica.Data, virtualReplica.Data)
+			assert.Equal(t, "true", virtualReplica.Labels[api.Key(api.LabelVClusterReplica)])
+			assert.False(t, api.IsReplica(&virtualReplica))
+		}
+
+		for _, key := range []types.NamespacedName{
+			{Name: replica.Name, Namespace: "default"},
+			{Name: unmanaged.Name, Namespace: "app-a"},
+		} {
+			err := virtualClient.Get(ctx, key, &corev1.ConfigMap{})
+			assert.True(t, apierrors.IsNotFound(err), key)
+		}
+	})
+
+	t.Run("Should Not Write To Virtual Clusters In Dry Run Mode", func(t *testing.T) {
+		syncer, virtualClient := newSyncer(t)
+		syncer.DryRun = true
+
+		require.NoError(t, syncer.SyncNamespace(ctx, hostNamespace.Name))
+
+		err := virtualClient.Get(ctx, types.NamespacedName{Name: replica.Name, Namespace: "app-a"}, &corev1.ConfigMap{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Update And Delete Virtual Replicas", func(t *testing.T) {
+		stale := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      replica.Name,
+				Namespace: "app-a",
+				Labels:    map[string]string{api.Key(api.LabelVClusterReplica): "true"},
+			},
+			Data: map[string]string{
+				"ca.crt": "old-ca",
+			},
+		}
+
+		deleted := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "deleted-source",
+				Namespace: "app-a",
+				Labels:    map[string]string{api.Key(api.LabelVClusterReplica): "true"},
+			},
+		}
+
+		syncer, virtualClient := newSyncer(t, stale, deleted)
+
+		require.NoError(t, syncer.SyncAll(ctx))
+
+		var virtualReplica corev1.ConfigMap
+		err := virtualClient.Get(ctx, ctrlclient.ObjectKeyFromObject(stale), &virtualReplica)
+		require.NoError(t, err)
+
+		assert.Equal(t, replica.Data, virtualReplica.Data)
+
+		err = virtualClient.Get(ctx, ctrlclient.ObjectKeyFromObject(deleted), &corev1.Secret{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Not Overwrite Unmanaged Objects", func(t *testing.T) {
+		conflicting := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      replica.Name,
+				Namespace: "app-b",
+			},
+			Data: map[string]string{
+				"ca.crt": "user-owned",
+			},
+		}
+
+		syncer, virtualClient := newSyncer(t, conflicting)
+
+		require.NoError(t, syncer.SyncNamespace(ctx, hostNamespace.Name))
+
+		var existing corev1.ConfigMap
+		err := virtualClient.Get(ctx, ctrlclient.ObjectKeyFromObject(conflicting), &existing)
+		require.NoError(t, err)
+
+		assert.Equal(t, "user-owned", existing.Data["ca.crt"])
+	})
+
+	t.Run("Should Only Index Kubeconfig Secrets", func(t *testing.T) {
+		assert.Equal(t, []string{"true"}, controller.IndexVClusterKubeconfig(kubeconfig))
+
+		for _, secret := range []*corev1.Secret{
+			{ObjectMeta: metav1.ObjectMeta{Name: "root-ca"}, Data: kubeconfig.Data},
+			{ObjectMeta: metav1.ObjectMeta{Name: "vc-config-my-vcluster"}, Data: kubeconfig.Data},
+			{ObjectMeta: metav1.ObjectMeta{Name: "vc-my-vcluster"}},
+		} {
+			assert.Empty(t, controller.IndexVClusterKubeconfig(secret), secret.Name)
+		}
+	})
+}
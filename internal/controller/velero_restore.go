@@ -0,0 +1,185 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// +kubebuilder:rbac:groups=velero.io,resources=restores,verbs=get;list;watch
+
+// RestoreGVK is the group, version and kind of Velero Restores.
+var RestoreGVK = schema.GroupVersionKind{Group: "velero.io", Version: "v1", Kind: "Restore"}
+
+// RestoreReconciler reconciles the replicas restored by Velero once a restore
+// has finished. Restored sources are replicated as usual, but replicas
+// restored from backups taken before they were excluded record the UID of a
+// source that no longer exists, and would otherwise be garbage collected (or
+// left with stale data). Restored replicas are relinked to their restored
+// source (which then repairs them), or deleted if their source wasn't restored.
+type RestoreReconciler struct {
+	client.Client
+	Policy Policy
+}
+
+func (r *RestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+
+	restore := newRestore()
+	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	phase, _, _ := unstructured.NestedString(restore.Object, "status", "phase")
+	if phase != "Completed" && phase != "PartiallyFailed" {
+		logger.Debug("Waiting for restore to finish", "phase", phase)
+
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, r.ReconcileRestored(ctx, req.Name)
+}
+
+func (r *RestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("restore-controller").
+		For(newRestore()).
+		Complete(r)
+}
+
+// ReconcileRestored relinks (or deletes) every replica created by the named restore.
+func (r *RestoreReconciler) ReconcileRestored(ctx context.Context, restoreName string) error {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx))).With("restore", restoreName)
+	policy := r.Policy.current()
+
+	objects, err := listObjects(ctx, r.Client, client.MatchingLabels{api.VeleroRestoreNameLabel: api.VeleroLabelValue(restoreName)})
+	if err != nil {
+		return err
+	}
+
+	for _, replica := range objects {
+		if !api.IsReplica(replica) || api.IsReplicationEnabled(replica) {
+			continue
+		}
+
+		if policy.IsProtectedNamespace(replica.GetNamespace()) || !policy.InScope(replica.GetNamespace()) {
+			continue
+		}
+
+		source, err := r.restoredSource(ctx, replica)
+		if err != nil {
+			return err
+		}
+
+		if source == nil {
+			logger.Info("Deleting restored replica without a source", "namespace", replica.GetNamespace(), "name", replica.GetName())
+
+			if err := r.Delete(ctx, replica); err != nil && !apierrors.IsNotFound(err) {
+				if policy.IsOptedOut(err) {
+					continue
+				}
+
+				return fmt.Errorf("failed to delete restored replica: %w", err)
+			}
+
+			continue
+		}
+
+		if replica.GetLabels()[api.Key(api.LabelSourceUID)] == string(source.GetUID()) {
+			continue
+		}
+
+		logger.Info("Relinking restored replica to its source", "namespace", replica.GetNamespace(), "name", replica.GetName())
+
+		// The source is requeued by the update, and repairs any data that
+		// changed since the backup was taken.
+		patch := client.MergeFrom(replica.DeepCopyObject().(client.Object))
+
+		labels := replica.GetLabels()
+		labels[api.Key(api.LabelSourceUID)] = string(source.GetUID())
+		replica.SetLabels(labels)
+
+		if err := r.Patch(ctx, replica, patch); err != nil && !apierrors.IsNotFound(err) {
+			if policy.IsOptedOut(err) {
+				continue
+			}
+
+			return fmt.Errorf("failed to relink restored replica: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// restoredSource returns the replication enabled source recorded by the
+// replica, if it targets the replica's namespace.
+func (r *RestoreReconciler) restoredSource(ctx context.Context, replica client.Object) (client.Object, error) {
+	ref, _, ok := api.GetSourceReference(replica)
+	if !ok {
+		return nil, nil
+	}
+
+	var source client.Object
+	switch replica.(type) {
+	case *corev1.Secret:
+		source = &corev1.Secret{}
+	case *corev1.ConfigMap:
+		source = &corev1.ConfigMap{}
+	default:
+		return nil, fmt.Errorf("unsupported replica type %T", replica)
+	}
+
+	if err := r.Get(ctx, ref, source); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get source: %w", err)
+	}
+
+	if !api.IsReplicationEnabled(source) {
+		return nil, nil
+	}
+
+	ok, err := api.ShouldReplicateTo(source, replica.GetNamespace())
+	// If the source has a malformed filter, err on the side of caution.
+	if err != nil || ok {
+		return source, nil
+	}
+
+	return nil, nil
+}
+
+func newRestore() *unstructured.Unstructured {
+	restore := &unstructured.Unstructured{}
+	restore.SetGroupVersionKind(RestoreGVK)
+
+	return restore
+}
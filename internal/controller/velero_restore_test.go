@@ -0,0 +1,148 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRestoreReconciler(t *testing.T) {
+	ctrl.SetLogger(logr.FromSlogHandler(slogt.New(t).Handler()))
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(controller.RestoreGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(controller.RestoreGVK.GroupVersion().WithKind("RestoreList"), &unstructured.UnstructuredList{})
+
+	newRestore := func(phase string) *unstructured.Unstructured {
+		restore := &unstructured.Unstructured{}
+		restore.SetGroupVersionKind(controller.RestoreGVK)
+		restore.SetName("test-restore")
+		restore.SetNamespace("velero")
+		require.NoError(t, unstructured.SetNestedField(restore.Object, phase, "status", "phase"))
+
+		return restore
+	}
+
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-namespace",
+			UID:       "restored-uid",
+			Labels: map[string]string{
+				api.VeleroRestoreNameLabel: "test-restore",
+			},
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled):     "true",
+				api.Key(api.AnnotationReplicateTo): "app-*",
+			},
+		},
+	}
+
+	newReplica := func(namespace, sourceName string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      sourceName,
+				Namespace: namespace,
+				Labels: map[string]string{
+					api.LabelManagedByKey:       api.LabelManagedByValue,
+					api.Key(api.LabelSourceUID): "backed-up-uid",
+					api.VeleroRestoreNameLabel:  "test-restore",
+				},
+				Annotations: map[string]string{
+					api.Key(api.AnnotationSourceNamespace): "test-namespace",
+					api.Key(api.AnnotationSourceName):      sourceName,
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "velero", Name: "test-restore"}}
+
+	t.Run("Should Relink Restored Replicas", func(t *testing.T) {
+		c := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(newRestore("Completed"), source.DeepCopy(), newReplica("app-a", "test-secret")).
+			Build()
+
+		r := &controller.RestoreReconciler{Client: c}
+
+		_, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		var replica corev1.Secret
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "app-a", Name: "test-secret"}, &replica))
+
+		assert.Equal(t, "restored-uid", replica.Labels[api.Key(api.LabelSourceUID)])
+	})
+
+	t.Run("Should Delete Restored Replicas Without A Source", func(t *testing.T) {
+		c := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(newRestore("PartiallyFailed"), source.DeepCopy(),
+				newReplica("app-a", "missing-secret"), newReplica("other-namespace", "test-secret")).
+			Build()
+
+		r := &controller.RestoreReconciler{Client: c}
+
+		_, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		var replica corev1.Secret
+		err = c.Get(ctx, types.NamespacedName{Namespace: "app-a", Name: "missing-secret"}, &replica)
+		assert.True(t, apierrors.IsNotFound(err))
+
+		// The source doesn't target this namespace.
+		err = c.Get(ctx, types.NamespacedName{Namespace: "other-namespace", Name: "test-secret"}, &replica)
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Wait For The Restore To Finish", func(t *testing.T) {
+		c := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(newRestore("InProgress"), newReplica("app-a", "missing-secret")).
+			Build()
+
+		r := &controller.RestoreReconciler{Client: c}
+
+		_, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+
+		var replica corev1.Secret
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "app-a", Name: "missing-secret"}, &replica))
+	})
+}
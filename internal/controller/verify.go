@@ -0,0 +1,270 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// DriftMissing is a targeted namespace without a replica.
+	DriftMissing = "missing"
+	// DriftStale is a replica that differs from its source.
+	DriftStale = "stale"
+	// DriftExtraneous is a replica in a namespace that is no longer targeted.
+	DriftExtraneous = "extraneous"
+	// DriftConflict is an unmanaged object with the same name in a targeted namespace.
+	DriftConflict = "conflict"
+)
+
+// Drift is a difference between a source and one of its replicas.
+type Drift struct {
+	// Namespace is the namespace of the (expected) replica.
+	Namespace string
+	// Type is the type of difference (eg. DriftMissing).
+	Type string
+	// Message describes the difference.
+	Message string
+}
+
+// DiffSource recomputes the desired state of a source and compares it against
+// its replicas (in namespaces replikator is permitted to modify).
+func DiffSource(ctx context.Context, c client.Client, policy Policy, source client.Object) ([]Drift, error) {
+	policy = policy.current()
+
+	source = source.DeepCopyObject().(client.Object)
+	policy.applyDefaults(source)
+
+	if err := runBeforeTemplateHooks(ctx, c, policy.Hooks, source); err != nil {
+		// Refused sources are left alone, along with their replicas.
+		var refused *HookRefusedError
+		if errors.As(err, &refused) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var template client.Object
+	var err error
+	switch source := source.(type) {
+	case *corev1.Secret:
+		template, err = api.SecretTemplate(source, policy.Metadata)
+	case *corev1.ConfigMap:
+		template, err = api.ConfigMapTemplate(source, policy.Metadata)
+	default:
+		return nil, fmt.Errorf("unsupported object type %T", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var namespaces corev1.NamespaceList
+	if err := c.List(ctx, &namespaces); err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	sourceNamespace := findNamespace(&namespaces, source.GetNamespace())
+	withdrawn := isWithdrawn(source, time.Now())
+
+	var drift []Drift
+	for _, namespace := range namespaces.Items {
+		if namespace.Name == source.GetNamespace() || policy.IsProtectedNamespace(namespace.Name) || !policy.InScope(namespace.Name) {
+			continue
+		}
+
+		targeted, err := policy.ShouldReplicateTo(source, &namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		targeted = targeted && !withdrawn && policy.PermitsTenancy(sourceNamespace, &namespace)
+
+		replica := template.DeepCopyObject().(client.Object)
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace.Name, Name: source.GetName()}, replica); err != nil {
+			if policy.IsOptedOut(err) {
+				continue
+			}
+
+			if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get replica: %w", err)
+			}
+
+			if targeted {
+				drift = append(drift, Drift{Namespace: namespace.Name, Type: DriftMissing, Message: "replica is missing"})
+			}
+
+			continue
+		}
+
+		if !targeted {
+			if api.IsReplica(replica) {
+				drift = append(drift, Drift{Namespace: namespace.Name, Type: DriftExtraneous, Message: "replica exists but namespace is not targeted"})
+			}
+
+			continue
+		}
+
+		if !api.IsReplica(replica) {
+			drift = append(drift, Drift{Namespace: namespace.Name, Type: DriftConflict, Message: "an unmanaged object with the same name exists"})
+			continue
+		}
+
+		desired := template.DeepCopyObject().(client.Object)
+		desired.SetNamespace(namespace.Name)
+		api.SetHelmOwnership(source, desired)
+
+		desired, _, err = transformReplica(ctx, c, policy.Transformations, source, desired)
+		if err != nil {
+			return nil, err
+		}
+
+		retainPreviousCA(source, replica, desired, time.Now())
+
+		for _, message := range CompareReplica(desired, replica) {
+			drift = append(drift, Drift{Namespace: namespace.Name, Type: DriftStale, Message: message})
+		}
+	}
+
+	return drift, nil
+}
+
+// Verifier periodically audits every replica against its source, as a safety
+// net in case watch events were missed. It only reports problems, repairs are
+// left to the controllers.
+type Verifier struct {
+	client.Client
+	Recorder record.EventRecorder
+	Policy   Policy
+	// Interval is the time between audits. If zero, replicas are only audited
+	// once on startup.
+	Interval time.Duration
+	// SkipInitial skips the audit on startup.
+	SkipInitial bool
+	// Clock is the clock used to schedule audits (defaults to the real clock).
+	Clock clock.WithTicker
+}
+
+// Start implements manager.Runnable.
+func (v *Verifier) Start(ctx context.Context) error {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx))).With("component", "verifier")
+
+	if !v.SkipInitial {
+		if err := v.Verify(ctx); err != nil {
+			logger.Error("Failed to verify replicas", "error", err)
+		}
+	}
+
+	if v.Interval == 0 {
+		return nil
+	}
+
+	ticker := clockOrDefault(v.Clock).NewTicker(v.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			if err := v.Verify(ctx); err != nil {
+				logger.Error("Failed to verify replicas", "error", err)
+			}
+		}
+	}
+}
+
+// Verify audits the replicas of every source, recording an event for each
+// difference found and updating the replica discrepancy metrics.
+func (v *Verifier) Verify(ctx context.Context) error {
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx))).With("component", "verifier")
+	policy := v.Policy.current()
+
+	var secrets corev1.SecretList
+	var listOpts []client.ListOption
+	if policy.SecretSelector != nil {
+		listOpts = append(listOpts, client.MatchingLabelsSelector{Selector: policy.SecretSelector})
+	}
+
+	if err := v.List(ctx, &secrets, listOpts...); err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	var configMaps corev1.ConfigMapList
+	if err := v.List(ctx, &configMaps); err != nil {
+		return fmt.Errorf("failed to list configmaps: %w", err)
+	}
+
+	var sources []client.Object
+	for i := range secrets.Items {
+		sources = append(sources, &secrets.Items[i])
+	}
+
+	for i := range configMaps.Items {
+		sources = append(sources, &configMaps.Items[i])
+	}
+
+	discrepancies := make(map[[2]string]int)
+	for _, source := range sources {
+		if !api.IsReplicationEnabled(source) || api.IsReplica(source) || !source.GetDeletionTimestamp().IsZero() || !policy.InScope(source.GetNamespace()) {
+			continue
+		}
+
+		drift, err := DiffSource(ctx, v.Client, policy, source)
+		if err != nil {
+			logger.Warn("Failed to verify source", "namespace", source.GetNamespace(), "name", source.GetName(), "error", err)
+			continue
+		}
+
+		kind := "configmap"
+		if _, ok := source.(*corev1.Secret); ok {
+			kind = "secret"
+		}
+
+		for _, d := range drift {
+			logger.Warn("Replica does not match source", "kind", kind, "namespace", source.GetNamespace(),
+				"name", source.GetName(), "targetNamespace", d.Namespace, "type", d.Type, "message", d.Message)
+
+			recordEvent(policy.Events, v.Recorder, source, corev1.EventTypeWarning, EventReasonVerificationFailed,
+				"Replica in namespace %s is %s: %s", d.Namespace, d.Type, d.Message)
+
+			discrepancies[[2]string{kind, d.Type}]++
+		}
+	}
+
+	replicaDiscrepancies.Reset()
+	for key, count := range discrepancies {
+		replicaDiscrepancies.WithLabelValues(key[0], key[1]).Set(float64(count))
+	}
+
+	return nil
+}
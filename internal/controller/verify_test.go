@@ -0,0 +1,128 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestVerifier(t *testing.T) {
+	ctx := context.Background()
+
+	source := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				api.Key(api.AnnotationEnabled): "true",
+			},
+		},
+		Data: map[string]string{
+			"foo": "bar",
+		},
+	}
+
+	namespace := func(name string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+		}
+	}
+
+	t.Run("Should Report Missing And Stale Replicas", func(t *testing.T) {
+		staleReplica, err := api.ConfigMapTemplate(source, api.MetadataFilter{})
+		require.NoError(t, err)
+
+		staleReplica.Namespace = "team-a"
+		staleReplica.Data["foo"] = "baz"
+
+		client := fake.NewClientBuilder().
+			WithObjects(source, namespace("team-a"), namespace("team-b"), staleReplica).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+
+		v := &controller.Verifier{
+			Client:   client,
+			Recorder: recorder,
+		}
+
+		require.NoError(t, v.Verify(ctx))
+
+		require.Len(t, recorder.Events, 2)
+	})
+
+	t.Run("Should Classify Differences", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(source, namespace("team-a")).
+			Build()
+
+		drift, err := controller.DiffSource(ctx, client, controller.Policy{}, source)
+		require.NoError(t, err)
+
+		require.Len(t, drift, 1)
+		assert.Equal(t, "team-a", drift[0].Namespace)
+		assert.Equal(t, controller.DriftMissing, drift[0].Type)
+	})
+
+	t.Run("Should Verify Periodically", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(source, namespace("team-a")).
+			Build()
+
+		recorder := record.NewFakeRecorder(10)
+		clock := testingclock.NewFakeClock(time.Now())
+
+		v := &controller.Verifier{
+			Client:      client,
+			Recorder:    recorder,
+			Interval:    time.Hour,
+			SkipInitial: true,
+			Clock:       clock,
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+
+		go func() {
+			_ = v.Start(ctx)
+		}()
+
+		require.Eventually(t, clock.HasWaiters, time.Second, time.Millisecond)
+		require.Empty(t, recorder.Events)
+
+		clock.Step(time.Hour)
+
+		require.Eventually(t, func() bool {
+			return len(recorder.Events) == 1
+		}, time.Second, time.Millisecond)
+	})
+}
@@ -0,0 +1,127 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// wasmMemoryLimitPages limits the memory of WebAssembly transformers to 64MiB.
+const wasmMemoryLimitPages = 1024
+
+// WASMTransformer transforms replicas using a WebAssembly (WASI) module. The
+// module is run once per replica, reading a TransformRequest from its stdin
+// and writing a TransformResponse to its stdout (just like an ExecTransformer),
+// but is sandboxed without access to the filesystem, network or environment.
+type WASMTransformer struct {
+	name    string
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+	timeout time.Duration
+}
+
+// NewWASMTransformer compiles the WebAssembly module at the given path. The
+// transformer must be closed once it is no longer needed.
+func NewWASMTransformer(ctx context.Context, path string, timeout time.Duration) (*WASMTransformer, error) {
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module: %w", err)
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(wasmMemoryLimitPages).
+		// Allows runaway modules to be interrupted by the timeout.
+		WithCloseOnContextDone(true))
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate WASI: %w", err)
+	}
+
+	module, err := runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to compile module %s: %w", path, err)
+	}
+
+	return &WASMTransformer{
+		name:    path,
+		runtime: runtime,
+		module:  module,
+		timeout: timeout,
+	}, nil
+}
+
+func (t *WASMTransformer) Transform(ctx context.Context, source, replica client.Object) (client.Object, error) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	input, err := json.Marshal(&TransformRequest{Source: source, Replica: replica})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	// Modules are anonymous so they can be instantiated concurrently. Nothing
+	// else (eg. the filesystem, clocks or environment variables) is provided.
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(&stdout).
+		WithStderr(&stderr)
+
+	mod, err := t.runtime.InstantiateModule(ctx, t.module, config)
+	if mod != nil {
+		_ = mod.Close(ctx)
+	}
+	if err != nil {
+		var exitErr *sys.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 0 {
+			return nil, fmt.Errorf("transformation failed: %s failed: %w: %s", t.name, err, strings.TrimSpace(stderr.String()))
+		}
+	}
+
+	var resp TransformResponse
+	if len(bytes.TrimSpace(stdout.Bytes())) > 0 {
+		if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response of %s: %w", t.name, err)
+		}
+	}
+
+	return resp.apply(replica)
+}
+
+// Close releases the resources of the transformer.
+func (t *WASMTransformer) Close(ctx context.Context) error {
+	return t.runtime.Close(ctx)
+}
@@ -0,0 +1,88 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWASMTransformer(t *testing.T) {
+	modulePath := filepath.Join(t.TempDir(), "transform.wasm")
+
+	build := exec.Command("go", "build", "-o", modulePath, "./testdata/wasm")
+	build.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Skipf("Unable to build test module: %v: %s", err, output)
+	}
+
+	ctx := context.Background()
+
+	transformer, err := controller.NewWASMTransformer(ctx, modulePath, time.Minute)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, transformer.Close(ctx))
+	})
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap",
+			Namespace: "test-namespace",
+		},
+		Data: map[string]string{
+			"greeting": "hello",
+		},
+	}
+
+	replica := cm.DeepCopy()
+	replica.Namespace = "another-namespace"
+
+	t.Run("Should Transform Replicas", func(t *testing.T) {
+		transformed, err := transformer.Transform(ctx, cm, replica)
+		require.NoError(t, err)
+
+		data := transformed.(*corev1.ConfigMap).Data
+		assert.Equal(t, "hello", data["greeting"])
+		assert.Equal(t, "another-namespace", data["namespace"])
+	})
+
+	t.Run("Should Not Allow Filesystem Access", func(t *testing.T) {
+		transformed, err := transformer.Transform(ctx, cm, replica)
+		require.NoError(t, err)
+
+		assert.Equal(t, "denied", transformed.(*corev1.ConfigMap).Data["filesystem"])
+	})
+
+	t.Run("Should Fail To Load Invalid Modules", func(t *testing.T) {
+		invalidPath := filepath.Join(t.TempDir(), "invalid.wasm")
+		require.NoError(t, os.WriteFile(invalidPath, []byte("not a module"), 0o600))
+
+		_, err := controller.NewWASMTransformer(ctx, invalidPath, time.Minute)
+		assert.Error(t, err)
+	})
+}
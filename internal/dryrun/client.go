@@ -0,0 +1,130 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dryrun provides a read-only Kubernetes client that logs (rather
+// than performs) every write.
+package dryrun
+
+import (
+	"context"
+	"log/slog"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type dryRunClient struct {
+	client.Client
+	logger *slog.Logger
+}
+
+// NewClient wraps the given client so that all writes are logged and discarded.
+// Reads are passed through to the underlying client.
+func NewClient(c client.Client, logger *slog.Logger) client.Client {
+	return &dryRunClient{Client: c, logger: logger}
+}
+
+func (c *dryRunClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.logWrite("create", obj, "")
+	return nil
+}
+
+func (c *dryRunClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.logWrite("delete", obj, "")
+	return nil
+}
+
+func (c *dryRunClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.logWrite("update", obj, "")
+	return nil
+}
+
+func (c *dryRunClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.logPatch(obj, patch, "")
+	return nil
+}
+
+func (c *dryRunClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	c.logWrite("delete all of", obj, "")
+	return nil
+}
+
+func (c *dryRunClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *dryRunClient) SubResource(subResource string) client.SubResourceClient {
+	return &dryRunSubResourceClient{
+		SubResourceClient: c.Client.SubResource(subResource),
+		parent:            c,
+		subResource:       subResource,
+	}
+}
+
+func (c *dryRunClient) logWrite(verb string, obj client.Object, subResource string) {
+	c.logger.Info("Dry run: skipping write", c.attrs(verb, obj, subResource)...)
+}
+
+func (c *dryRunClient) logPatch(obj client.Object, patch client.Patch, subResource string) {
+	attrs := c.attrs("patch", obj, subResource)
+	if data, err := patch.Data(obj); err == nil {
+		// The contents of secrets must never be logged.
+		if gvk, err := c.GroupVersionKindFor(obj); err != nil || gvk.Kind == "Secret" {
+			data = redactSecretPatch(data)
+		}
+
+		if data != nil {
+			attrs = append(attrs, "patch", string(data))
+		}
+	}
+
+	c.logger.Info("Dry run: skipping write", attrs...)
+}
+
+func (c *dryRunClient) attrs(verb string, obj client.Object, subResource string) []any {
+	attrs := []any{"verb", verb, "namespace", obj.GetNamespace(), "name", obj.GetName()}
+
+	if gvk, err := c.GroupVersionKindFor(obj); err == nil {
+		attrs = append(attrs, "kind", gvk.Kind)
+	}
+
+	if subResource != "" {
+		attrs = append(attrs, "subresource", subResource)
+	}
+
+	return attrs
+}
+
+type dryRunSubResourceClient struct {
+	client.SubResourceClient
+	parent      *dryRunClient
+	subResource string
+}
+
+func (c *dryRunSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	c.parent.logWrite("create", obj, c.subResource)
+	return nil
+}
+
+func (c *dryRunSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	c.parent.logWrite("update", obj, c.subResource)
+	return nil
+}
+
+func (c *dryRunSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	c.parent.logPatch(obj, patch, c.subResource)
+	return nil
+}
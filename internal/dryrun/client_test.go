@@ -0,0 +1,125 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dryrun_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/dryrun"
+	"github.com/neilotoole/slogt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "existing",
+			Namespace: "default",
+		},
+	}
+
+	k8sClient := dryrun.NewClient(fake.NewClientBuilder().
+		WithObjects(existing).
+		Build(), slogt.New(t))
+
+	t.Run("Should Pass Through Reads", func(t *testing.T) {
+		var cm corev1.ConfigMap
+		require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(existing), &cm))
+	})
+
+	t.Run("Should Discard Creates", func(t *testing.T) {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "new",
+				Namespace: "default",
+			},
+		}
+
+		require.NoError(t, k8sClient.Create(ctx, cm))
+
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Discard Deletes", func(t *testing.T) {
+		require.NoError(t, k8sClient.Delete(ctx, existing.DeepCopy()))
+
+		require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(existing), &corev1.ConfigMap{}))
+	})
+
+	t.Run("Should Redact Secret Patches", func(t *testing.T) {
+		var logs bytes.Buffer
+		k8sClient := dryrun.NewClient(fake.NewClientBuilder().Build(), slog.New(slog.NewJSONHandler(&logs, nil)))
+
+		secret := &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "credentials",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{
+				"password": []byte("hunter2"),
+			},
+			StringData: map[string]string{
+				"token": "s3cr3t",
+			},
+		}
+
+		require.NoError(t, k8sClient.Patch(ctx, secret, client.Apply, client.FieldOwner("replikator")))
+		require.NoError(t, k8sClient.Patch(ctx, secret, client.RawPatch(types.JSONPatchType,
+			[]byte(`[{"op":"replace","path":"/data/password","value":"aHVudGVyMg=="}]`))))
+
+		assert.Contains(t, logs.String(), "password")
+		assert.Contains(t, logs.String(), "token")
+
+		for _, value := range []string{"hunter2", "aHVudGVyMg==", "s3cr3t"} {
+			assert.NotContains(t, logs.String(), value)
+		}
+	})
+}
+
+func TestEventRecorder(t *testing.T) {
+	var logs bytes.Buffer
+	recorder := dryrun.NewEventRecorder(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "existing",
+			Namespace: "default",
+		},
+	}
+
+	t.Run("Should Log Events", func(t *testing.T) {
+		recorder.Eventf(cm, corev1.EventTypeNormal, "ReplicaWritten", "Wrote %d replicas", 3)
+
+		assert.Contains(t, logs.String(), "Wrote 3 replicas")
+		assert.Contains(t, logs.String(), "ReplicaWritten")
+	})
+}
@@ -0,0 +1,54 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dryrun
+
+import (
+	"fmt"
+	"log/slog"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type dryRunRecorder struct {
+	logger *slog.Logger
+}
+
+// NewEventRecorder returns an event recorder that logs (rather than records)
+// every Kubernetes Event.
+func NewEventRecorder(logger *slog.Logger) record.EventRecorder {
+	return &dryRunRecorder{logger: logger}
+}
+
+func (r *dryRunRecorder) Event(object runtime.Object, eventType, reason, message string) {
+	attrs := []any{"type", eventType, "reason", reason, "message", message}
+	if obj, ok := object.(client.Object); ok {
+		attrs = append(attrs, "namespace", obj.GetNamespace(), "name", obj.GetName())
+	}
+
+	r.logger.Info("Dry run: skipping event", attrs...)
+}
+
+func (r *dryRunRecorder) Eventf(object runtime.Object, eventType, reason, messageFmt string, args ...any) {
+	r.Event(object, eventType, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *dryRunRecorder) AnnotatedEventf(object runtime.Object, _ map[string]string, eventType, reason, messageFmt string, args ...any) {
+	r.Eventf(object, eventType, reason, messageFmt, args...)
+}
@@ -0,0 +1,91 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dryrun
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// secretDataFields are the fields of a secret that hold its contents.
+var secretDataFields = []string{"data", "stringData"}
+
+// redactSecretPatch replaces the values of the data and stringData fields in a
+// patch of a secret with a hash, so that the keys (and whether their values
+// change) are still visible. Nil is returned if the patch can't be parsed.
+func redactSecretPatch(data []byte) []byte {
+	var patch any
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil
+	}
+
+	switch patch := patch.(type) {
+	case map[string]any:
+		// Merge, strategic merge and apply patches.
+		for _, field := range secretDataFields {
+			if values, ok := patch[field].(map[string]any); ok {
+				for key, value := range values {
+					values[key] = redact(value)
+				}
+			}
+		}
+	case []any:
+		// JSON patches.
+		for _, op := range patch {
+			op, ok := op.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			path, _ := op["path"].(string)
+			for _, field := range secretDataFields {
+				if path == "/"+field || strings.HasPrefix(path, "/"+field+"/") {
+					if value, ok := op["value"]; ok {
+						op["value"] = redact(value)
+					}
+				}
+			}
+		}
+	default:
+		return nil
+	}
+
+	redacted, err := json.Marshal(patch)
+	if err != nil {
+		return nil
+	}
+
+	return redacted
+}
+
+func redact(value any) any {
+	if values, ok := value.(map[string]any); ok {
+		for key, value := range values {
+			values[key] = redact(value)
+		}
+
+		return values
+	}
+
+	raw, _ := json.Marshal(value)
+	sum := sha256.Sum256(raw)
+
+	return "redacted:sha256:" + hex.EncodeToString(sum[:])[:12]
+}
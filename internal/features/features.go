@@ -0,0 +1,175 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package features provides feature gates for experimental behaviors, so that
+// they can ship disabled by default and be enabled per cluster.
+package features
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Feature is the name of a gated behavior.
+type Feature string
+
+// Stage is the maturity of a feature.
+type Stage string
+
+const (
+	// Alpha features are experimental and disabled by default.
+	Alpha Stage = "ALPHA"
+	// Beta features are well tested and usually enabled by default.
+	Beta Stage = "BETA"
+	// GA features are stable and always enabled.
+	GA Stage = "GA"
+)
+
+// FeatureSpec describes a feature.
+type FeatureSpec struct {
+	// Default is whether the feature is enabled if not explicitly set.
+	Default bool
+	// Stage is the maturity of the feature.
+	Stage Stage
+}
+
+const (
+	// ServerSideApply writes replicas with server-side apply (as the replikator
+	// field manager), rather than replacing them with a full update, so that
+	// fields added to replicas by other controllers are left alone. Enabled by
+	// default, disabling it restores full updates.
+	ServerSideApply Feature = "ServerSideApply"
+	// PullModel lets namespaces pull secrets and configmaps from allowed
+	// sources, by annotating a stub object with replicate-from.
+	PullModel Feature = "PullModel"
+)
+
+// defaultFeatures are the features known to replikator. New experimental
+// behaviors should be added here (as alpha, disabled by default).
+var defaultFeatures = map[Feature]FeatureSpec{
+	ServerSideApply: {Default: true, Stage: Beta},
+	PullModel:       {Default: false, Stage: Alpha},
+}
+
+// DefaultFeatureGate is the feature gate used by replikator.
+var DefaultFeatureGate = NewFeatureGate(defaultFeatures)
+
+// Enabled returns true if the feature is enabled in the default feature gate.
+func Enabled(feature Feature) bool {
+	return DefaultFeatureGate.Enabled(feature)
+}
+
+// FeatureGate tracks which features are enabled. It implements cli.Generic,
+// accepting a comma separated list of feature=bool pairs (eg. "Foo=true,Bar=false").
+type FeatureGate struct {
+	mu      sync.RWMutex
+	known   map[Feature]FeatureSpec
+	enabled map[Feature]bool
+}
+
+// NewFeatureGate creates a new FeatureGate for the given known features.
+func NewFeatureGate(known map[Feature]FeatureSpec) *FeatureGate {
+	return &FeatureGate{
+		known:   known,
+		enabled: make(map[Feature]bool),
+	}
+}
+
+// Set enables or disables the features in a comma separated list of
+// feature=bool pairs. Unknown features, and attempts to disable GA
+// features, are rejected.
+func (g *FeatureGate) Set(value string) error {
+	enabled := make(map[Feature]bool)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, rawValue, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid feature gate %q (expected feature=bool)", pair)
+		}
+
+		feature := Feature(strings.TrimSpace(name))
+		spec, ok := g.known[feature]
+		if !ok {
+			return fmt.Errorf("unknown feature gate %q", feature)
+		}
+
+		on, err := strconv.ParseBool(strings.TrimSpace(rawValue))
+		if err != nil {
+			return fmt.Errorf("invalid value for feature gate %q: %w", feature, err)
+		}
+
+		if spec.Stage == GA && !on {
+			return fmt.Errorf("feature gate %q is GA and cannot be disabled", feature)
+		}
+
+		enabled[feature] = on
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for feature, on := range enabled {
+		g.enabled[feature] = on
+	}
+
+	return nil
+}
+
+// String returns the explicitly set features as a comma separated list.
+func (g *FeatureGate) String() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var pairs []string
+	for feature, on := range g.enabled {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", feature, on))
+	}
+	slices.Sort(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// Enabled returns true if the feature is enabled.
+func (g *FeatureGate) Enabled(feature Feature) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if on, ok := g.enabled[feature]; ok {
+		return on
+	}
+
+	return g.known[feature].Default
+}
+
+// KnownFeatures returns a description of every known feature (suitable for
+// inclusion in usage text).
+func (g *FeatureGate) KnownFeatures() []string {
+	var descriptions []string
+	for feature, spec := range g.known {
+		descriptions = append(descriptions, fmt.Sprintf("%s=true|false (%s - default=%t)", feature, spec.Stage, spec.Default))
+	}
+	slices.Sort(descriptions)
+
+	return descriptions
+}
@@ -0,0 +1,80 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package features_test
+
+import (
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/features"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureGate(t *testing.T) {
+	known := map[features.Feature]features.FeatureSpec{
+		"AlphaFeature": {Default: false, Stage: features.Alpha},
+		"BetaFeature":  {Default: true, Stage: features.Beta},
+		"GAFeature":    {Default: true, Stage: features.GA},
+	}
+
+	t.Run("Should Use Defaults", func(t *testing.T) {
+		gate := features.NewFeatureGate(known)
+
+		assert.False(t, gate.Enabled("AlphaFeature"))
+		assert.True(t, gate.Enabled("BetaFeature"))
+		assert.False(t, gate.Enabled("UnknownFeature"))
+	})
+
+	t.Run("Should Override Defaults", func(t *testing.T) {
+		gate := features.NewFeatureGate(known)
+
+		require.NoError(t, gate.Set("AlphaFeature=true, BetaFeature=false"))
+
+		assert.True(t, gate.Enabled("AlphaFeature"))
+		assert.False(t, gate.Enabled("BetaFeature"))
+		assert.Equal(t, "AlphaFeature=true,BetaFeature=false", gate.String())
+	})
+
+	t.Run("Should Reject Invalid Gates", func(t *testing.T) {
+		gate := features.NewFeatureGate(known)
+
+		assert.Error(t, gate.Set("UnknownFeature=true"))
+		assert.Error(t, gate.Set("AlphaFeature"))
+		assert.Error(t, gate.Set("AlphaFeature=maybe"))
+		assert.Error(t, gate.Set("GAFeature=false"))
+
+		// Rejected gates shouldn't be partially applied.
+		assert.Error(t, gate.Set("AlphaFeature=true,UnknownFeature=true"))
+		assert.False(t, gate.Enabled("AlphaFeature"))
+	})
+}
+
+func TestDefaultFeatureGate(t *testing.T) {
+	t.Run("Should Enable The Pull Model", func(t *testing.T) {
+		t.Cleanup(func() {
+			require.NoError(t, features.DefaultFeatureGate.Set("PullModel=false"))
+		})
+
+		assert.False(t, features.Enabled(features.PullModel))
+
+		require.NoError(t, features.DefaultFeatureGate.Set("PullModel=true"))
+
+		assert.True(t, features.Enabled(features.PullModel))
+		assert.Contains(t, features.DefaultFeatureGate.KnownFeatures(), "PullModel=true|false (ALPHA - default=false)")
+	})
+}
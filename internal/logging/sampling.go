@@ -0,0 +1,111 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package logging provides slog handlers used by replikator.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SamplingHandler is a slog.Handler that samples repetitive log messages, so
+// that messages logged for every reconcile don't dominate the log volume at
+// scale. Within each interval the first Initial records with a given level and
+// message are logged, and then only every Thereafter'th record. Warnings and
+// errors are never sampled.
+type SamplingHandler struct {
+	slog.Handler
+	sampler *sampler
+}
+
+// NewSamplingHandler wraps the handler with sampling. Sampling is disabled if
+// initial is zero.
+func NewSamplingHandler(handler slog.Handler, initial, thereafter int, interval time.Duration) slog.Handler {
+	if initial <= 0 {
+		return handler
+	}
+
+	return &SamplingHandler{
+		Handler: handler,
+		sampler: &sampler{
+			initial:    initial,
+			thereafter: thereafter,
+			interval:   interval,
+			counts:     make(map[sampleKey]*sampleCount),
+		},
+	}
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn && !h.sampler.allow(r.Level, r.Message, r.Time) {
+		return nil
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{Handler: h.Handler.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{Handler: h.Handler.WithGroup(name), sampler: h.sampler}
+}
+
+type sampleKey struct {
+	level   slog.Level
+	message string
+}
+
+type sampleCount struct {
+	resetAt time.Time
+	n       int
+}
+
+type sampler struct {
+	initial    int
+	thereafter int
+	interval   time.Duration
+	mu         sync.Mutex
+	counts     map[sampleKey]*sampleCount
+}
+
+func (s *sampler) allow(level slog.Level, message string, now time.Time) bool {
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := sampleKey{level: level, message: message}
+	count, ok := s.counts[key]
+	if !ok || !now.Before(count.resetAt) {
+		count = &sampleCount{resetAt: now.Add(s.interval)}
+		s.counts[key] = count
+	}
+
+	count.n++
+	if count.n <= s.initial {
+		return true
+	}
+
+	return s.thereafter > 0 && (count.n-s.initial)%s.thereafter == 0
+}
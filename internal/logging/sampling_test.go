@@ -0,0 +1,77 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamplingHandler(t *testing.T) {
+	t.Run("Should Sample Repetitive Messages", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(logging.NewSamplingHandler(slog.NewTextHandler(&buf, nil), 2, 3, time.Hour))
+
+		for i := 0; i < 10; i++ {
+			logger.Info("Reconciling", "name", i)
+		}
+
+		// The first two, and then every third.
+		assert.Equal(t, 4, strings.Count(buf.String(), "Reconciling"))
+	})
+
+	t.Run("Should Sample Messages Independently", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(logging.NewSamplingHandler(slog.NewTextHandler(&buf, nil), 1, 0, time.Hour))
+
+		logger.Info("Reconciling")
+		logger.With("namespace", "test").Info("Reconciling")
+		logger.Info("Replication not enabled")
+
+		assert.Equal(t, 1, strings.Count(buf.String(), "Reconciling"))
+		assert.Equal(t, 1, strings.Count(buf.String(), "Replication not enabled"))
+	})
+
+	t.Run("Should Not Sample Warnings And Errors", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(logging.NewSamplingHandler(slog.NewTextHandler(&buf, nil), 1, 0, time.Hour))
+
+		for i := 0; i < 5; i++ {
+			logger.Error("Failed to reconcile")
+		}
+
+		assert.Equal(t, 5, strings.Count(buf.String(), "Failed to reconcile"))
+	})
+
+	t.Run("Should Reset Each Interval", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(logging.NewSamplingHandler(slog.NewTextHandler(&buf, nil), 1, 0, time.Millisecond))
+
+		logger.Info("Reconciling")
+		time.Sleep(2 * time.Millisecond)
+		logger.Info("Reconciling")
+
+		assert.Equal(t, 2, strings.Count(buf.String(), "Reconciling"))
+	})
+}
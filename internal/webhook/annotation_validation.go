@@ -0,0 +1,69 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// AnnotationValidationPath is the path the annotation validation webhook is served on.
+	AnnotationValidationPath = "/validate-annotations"
+)
+
+// AnnotationValidationHandler is a validating admission webhook that checks
+// the replikator annotations on secrets and configmaps at apply time.
+type AnnotationValidationHandler struct {
+	// WarnOnly allows objects with invalid annotations but returns a warning to the user.
+	WarnOnly bool
+}
+
+func (h *AnnotationValidationHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	var obj metav1.PartialObjectMetadata
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("failed to decode object: %w", err))
+	}
+
+	errs, warnings := controller.ValidateAnnotations(&obj)
+	if len(errs) > 0 && !h.WarnOnly {
+		return admission.Denied("invalid replikator annotations: " + strings.Join(errs, ", ")).WithWarnings(warnings...)
+	}
+
+	return admission.Allowed("").WithWarnings(append(errs, warnings...)...)
+}
+
+func (h *AnnotationValidationHandler) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(AnnotationValidationPath, &webhook.Admission{Handler: h})
+
+	return nil
+}
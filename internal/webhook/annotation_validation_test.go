@@ -0,0 +1,92 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/webhook"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestAnnotationValidationHandler(t *testing.T) {
+	ctx := context.Background()
+
+	newRequest := func(t *testing.T, annotations map[string]string) admission.Request {
+		raw, err := json.Marshal(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-configmap",
+				Namespace:   "test-namespace",
+				Annotations: annotations,
+			},
+		})
+		require.NoError(t, err)
+
+		return admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		}
+	}
+
+	h := &webhook.AnnotationValidationHandler{}
+
+	t.Run("Should Allow Valid Annotations", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, map[string]string{
+			api.Key(api.AnnotationEnabled):     "true",
+			api.Key(api.AnnotationReplicateTo): "team-*",
+		}))
+		assert.True(t, resp.Allowed)
+	})
+
+	t.Run("Should Deny Malformed Patterns", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, map[string]string{
+			api.Key(api.AnnotationEnabled):     "true",
+			api.Key(api.AnnotationReplicateTo): "team-[",
+		}))
+		assert.False(t, resp.Allowed)
+	})
+
+	t.Run("Should Deny Unknown Annotations", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, map[string]string{
+			api.KeyPrefix() + "enable": "true",
+		}))
+		assert.False(t, resp.Allowed)
+	})
+
+	t.Run("Should Warn When In Warn Only Mode", func(t *testing.T) {
+		h := &webhook.AnnotationValidationHandler{WarnOnly: true}
+
+		resp := h.Handle(ctx, newRequest(t, map[string]string{
+			api.Key(api.AnnotationEnabled):     "true",
+			api.Key(api.AnnotationReplicateTo): "team-[",
+		}))
+		assert.True(t, resp.Allowed)
+		assert.NotEmpty(t, resp.Warnings)
+	})
+}
@@ -0,0 +1,192 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// AutoAnnotationPath is the path the auto annotation webhook is served on.
+	AutoAnnotationPath = "/mutate-auto-annotate"
+)
+
+// AutoAnnotationRule describes a set of annotations that should be added to
+// matching secrets/configmaps when they are created or updated.
+type AutoAnnotationRule struct {
+	// Kinds are the kinds of object the rule applies to (eg. Secret, ConfigMap).
+	// If empty the rule applies to all kinds.
+	Kinds []string `json:"kinds,omitempty"`
+	// Namespaces is a list of namespace glob patterns the rule applies to.
+	// If empty the rule applies to all namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selector is a label selector the object must match.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// Annotations are the annotations to add to matching objects. Annotations
+	// already present on the object are never overwritten.
+	Annotations map[string]string `json:"annotations"`
+}
+
+// AutoAnnotationConfig is the on-disk format of the auto annotation rules.
+type AutoAnnotationConfig struct {
+	Rules []AutoAnnotationRule `json:"rules"`
+}
+
+// LoadAutoAnnotationRules reads the auto annotation rules from a YAML file.
+func LoadAutoAnnotationRules(path string) ([]AutoAnnotationRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auto annotation rules: %w", err)
+	}
+
+	var config AutoAnnotationConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse auto annotation rules: %w", err)
+	}
+
+	for i, rule := range config.Rules {
+		for _, pattern := range rule.Namespaces {
+			if err := api.ValidatePattern(pattern); err != nil {
+				return nil, fmt.Errorf("rule %d has invalid namespace pattern %q: %w", i, pattern, err)
+			}
+		}
+
+		if _, err := metav1.LabelSelectorAsSelector(rule.Selector); err != nil {
+			return nil, fmt.Errorf("rule %d has invalid selector: %w", i, err)
+		}
+	}
+
+	return config.Rules, nil
+}
+
+// AutoAnnotationHandler is a mutating admission webhook that adds replikator
+// annotations to secrets/configmaps matching a set of rules. This allows
+// replication of objects created by other operators that can't be annotated
+// at creation time.
+type AutoAnnotationHandler struct {
+	Rules []AutoAnnotationRule
+}
+
+func (h *AutoAnnotationHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	var obj metav1.PartialObjectMetadata
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("failed to decode object: %w", err))
+	}
+
+	namespace := obj.Namespace
+	if namespace == "" {
+		namespace = req.Namespace
+	}
+
+	annotations := make(map[string]string)
+	for _, rule := range h.Rules {
+		if !ruleMatches(rule, req.Kind.Kind, namespace, obj.Labels) {
+			continue
+		}
+
+		for key, value := range rule.Annotations {
+			if _, ok := obj.Annotations[key]; ok {
+				continue
+			}
+
+			// Earlier rules take precedence.
+			if _, ok := annotations[key]; !ok {
+				annotations[key] = value
+			}
+		}
+	}
+
+	if len(annotations) == 0 {
+		return admission.Allowed("")
+	}
+
+	mutated, err := patchAnnotations(req.Object.Raw, func(existing map[string]any) {
+		for key, value := range annotations {
+			existing[key] = value
+		}
+	})
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, mutated)
+}
+
+func (h *AutoAnnotationHandler) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(AutoAnnotationPath, &webhook.Admission{Handler: h})
+
+	return nil
+}
+
+func ruleMatches(rule AutoAnnotationRule, kind, namespace string, objLabels map[string]string) bool {
+	if len(rule.Kinds) > 0 {
+		var found bool
+		for _, k := range rule.Kinds {
+			if strings.EqualFold(k, kind) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(rule.Namespaces) > 0 {
+		var found bool
+		for _, pattern := range rule.Namespaces {
+			if ok, err := filepath.Match(pattern, namespace); err == nil && ok {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if rule.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(rule.Selector)
+		if err != nil || !selector.Matches(labels.Set(objLabels)) {
+			return false
+		}
+	}
+
+	return true
+}
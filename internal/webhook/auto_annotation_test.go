@@ -0,0 +1,108 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/webhook"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestAutoAnnotationHandler(t *testing.T) {
+	ctx := context.Background()
+
+	rulesPath := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(rulesPath, []byte(`rules:
+- kinds: [Secret]
+  namespaces: [cert-manager]
+  selector:
+    matchLabels:
+      replicate: "yes"
+  annotations:
+    v1alpha1.replikator.pecke.tt/enabled: "true"
+    v1alpha1.replikator.pecke.tt/replicate-to: "*"
+`), 0o644))
+
+	rules, err := webhook.LoadAutoAnnotationRules(rulesPath)
+	require.NoError(t, err)
+
+	h := &webhook.AutoAnnotationHandler{Rules: rules}
+
+	newRequest := func(t *testing.T, namespace string, labels, annotations map[string]string) admission.Request {
+		raw, err := json.Marshal(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "root-ca-tls",
+				Namespace:   namespace,
+				Labels:      labels,
+				Annotations: annotations,
+			},
+		})
+		require.NoError(t, err)
+
+		return admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+				Namespace: namespace,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		}
+	}
+
+	t.Run("Should Annotate Matching Objects", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, "cert-manager", map[string]string{"replicate": "yes"}, nil))
+		assert.True(t, resp.Allowed)
+		require.Len(t, resp.Patches, 1)
+		assert.Equal(t, "/metadata/annotations", resp.Patches[0].Path)
+	})
+
+	t.Run("Should Not Overwrite Existing Annotations", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, "cert-manager", map[string]string{"replicate": "yes"}, map[string]string{
+			api.Key(api.AnnotationReplicateTo): "team-*",
+		}))
+		assert.True(t, resp.Allowed)
+
+		for _, patch := range resp.Patches {
+			assert.NotContains(t, patch.Path, "replicate-to")
+		}
+	})
+
+	t.Run("Should Ignore Objects In Other Namespaces", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, "default", map[string]string{"replicate": "yes"}, nil))
+		assert.True(t, resp.Allowed)
+		assert.Empty(t, resp.Patches)
+	})
+
+	t.Run("Should Ignore Objects Not Matching The Selector", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, "cert-manager", nil, nil))
+		assert.True(t, resp.Allowed)
+		assert.Empty(t, resp.Patches)
+	})
+}
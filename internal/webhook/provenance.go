@@ -0,0 +1,95 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// ProvenancePath is the path the provenance webhook is served on.
+	ProvenancePath = "/mutate-provenance"
+)
+
+// ProvenanceHandler is a mutating admission webhook that records the user who
+// enabled replication of a source in its enabled-by annotation. The annotation
+// can't be set (or altered) by users directly.
+type ProvenanceHandler struct{}
+
+func (h *ProvenanceHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	var obj metav1.PartialObjectMetadata
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("failed to decode object: %w", err))
+	}
+
+	// Replicas carry the enabled-by annotation of their source, which is
+	// written (and kept in sync) by replikator itself.
+	if api.IsReplica(&obj) && !api.IsReplicationEnabled(&obj) {
+		return admission.Allowed("")
+	}
+
+	var oldObj metav1.PartialObjectMetadata
+	if req.Operation == admissionv1.Update {
+		if err := json.Unmarshal(req.OldObject.Raw, &oldObj); err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("failed to decode old object: %w", err))
+		}
+	}
+
+	// By default, retain whatever was previously recorded.
+	enabledBy, hasEnabledBy := oldObj.Annotations[api.Key(api.AnnotationEnabledBy)]
+	if api.IsReplicationEnabled(&obj) && !api.IsReplicationEnabled(&oldObj) {
+		enabledBy, hasEnabledBy = req.UserInfo.Username, true
+	}
+
+	if current, ok := obj.Annotations[api.Key(api.AnnotationEnabledBy)]; ok == hasEnabledBy && current == enabledBy {
+		return admission.Allowed("")
+	}
+
+	mutated, err := patchAnnotations(req.Object.Raw, func(annotations map[string]any) {
+		if hasEnabledBy {
+			annotations[api.Key(api.AnnotationEnabledBy)] = enabledBy
+		} else {
+			delete(annotations, api.Key(api.AnnotationEnabledBy))
+		}
+	})
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, mutated)
+}
+
+func (h *ProvenanceHandler) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(ProvenancePath, &webhook.Admission{Handler: h})
+
+	return nil
+}
@@ -0,0 +1,151 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/webhook"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestProvenanceHandler(t *testing.T) {
+	ctx := context.Background()
+
+	h := &webhook.ProvenanceHandler{}
+
+	newRequest := func(t *testing.T, oldAnnotations, annotations map[string]string) admission.Request {
+		newRaw := func(annotations map[string]string) []byte {
+			raw, err := json.Marshal(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "root-ca-tls",
+					Namespace:   "cert-manager",
+					Annotations: annotations,
+				},
+			})
+			require.NoError(t, err)
+
+			return raw
+		}
+
+		req := admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+				Namespace: "cert-manager",
+				UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+				Object:    runtime.RawExtension{Raw: newRaw(annotations)},
+			},
+		}
+
+		if oldAnnotations != nil {
+			req.Operation = admissionv1.Update
+			req.OldObject = runtime.RawExtension{Raw: newRaw(oldAnnotations)}
+		}
+
+		return req
+	}
+
+	t.Run("Should Record Who Enabled Replication", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, nil, map[string]string{
+			api.Key(api.AnnotationEnabled): "true",
+		}))
+		require.True(t, resp.Allowed)
+
+		require.Len(t, resp.Patches, 1)
+		assert.Equal(t, "alice", resp.Patches[0].Value)
+	})
+
+	t.Run("Should Not Allow Provenance To Be Altered", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, map[string]string{
+			api.Key(api.AnnotationEnabled):   "true",
+			api.Key(api.AnnotationEnabledBy): "bob",
+		}, map[string]string{
+			api.Key(api.AnnotationEnabled):   "true",
+			api.Key(api.AnnotationEnabledBy): "alice",
+		}))
+		require.True(t, resp.Allowed)
+
+		require.Len(t, resp.Patches, 1)
+		assert.Equal(t, "bob", resp.Patches[0].Value)
+	})
+
+	t.Run("Should Not Allow Provenance To Be Forged", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, nil, map[string]string{
+			api.Key(api.AnnotationEnabledBy): "bob",
+		}))
+		require.True(t, resp.Allowed)
+
+		require.Len(t, resp.Patches, 1)
+		assert.Equal(t, "remove", resp.Patches[0].Operation)
+	})
+
+	t.Run("Should Leave Provenance Of Replicas Alone", func(t *testing.T) {
+		raw, err := json.Marshal(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "root-ca-tls",
+				Namespace: "team-a",
+				Labels: map[string]string{
+					api.LabelManagedByKey: api.LabelManagedByValue,
+				},
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabledBy): "bob",
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		for _, operation := range []admissionv1.Operation{admissionv1.Create, admissionv1.Update} {
+			req := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: operation,
+					Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+					Namespace: "team-a",
+					UserInfo:  authenticationv1.UserInfo{Username: "system:serviceaccount:replikator:replikator"},
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			}
+
+			if operation == admissionv1.Update {
+				req.OldObject = runtime.RawExtension{Raw: raw}
+			}
+
+			resp := h.Handle(ctx, req)
+			require.True(t, resp.Allowed)
+
+			assert.Empty(t, resp.Patches)
+		}
+	})
+
+	t.Run("Should Ignore Unrelated Changes", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest(t, nil, nil))
+		require.True(t, resp.Allowed)
+
+		assert.Empty(t, resp.Patches)
+	})
+}
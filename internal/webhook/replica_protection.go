@@ -0,0 +1,123 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// ReplicaProtectionPath is the path the replica protection webhook is served on.
+	ReplicaProtectionPath = "/validate-replica"
+)
+
+// systemServiceAccountPrefix is the username prefix of the built-in Kubernetes
+// controllers (eg. the namespace and garbage collection controllers), these
+// must always be allowed to delete replicas.
+const systemServiceAccountPrefix = "system:serviceaccount:kube-system:"
+
+// kubeControllerManagerUsername is the username of the built-in Kubernetes
+// controllers when kube-controller-manager runs without per-controller
+// service account credentials.
+const kubeControllerManagerUsername = "system:kube-controller-manager"
+
+// ReplicaProtectionHandler is a validating admission webhook that rejects
+// (or warns about) direct modifications and deletions of replicas by anyone
+// other than replikator itself.
+type ReplicaProtectionHandler struct {
+	// AllowedUsernames are the users permitted to modify replicas (eg. the
+	// replikator service account).
+	AllowedUsernames []string
+	// AllowedServiceAccountNames are the names of the service accounts permitted
+	// to modify replicas in their own namespace (eg. the service account
+	// impersonated by replikator to write replicas).
+	AllowedServiceAccountNames []string
+	// WarnOnly allows modifications but returns a warning to the user.
+	WarnOnly bool
+}
+
+func (h *ReplicaProtectionHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Update && req.Operation != admissionv1.Delete {
+		return admission.Allowed("")
+	}
+
+	if h.isAllowedUser(req.Namespace, req.UserInfo.Username) {
+		return admission.Allowed("")
+	}
+
+	var obj metav1.PartialObjectMetadata
+	if err := json.Unmarshal(req.OldObject.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("failed to decode object: %w", err))
+	}
+
+	if !api.IsReplica(&obj) {
+		return admission.Allowed("")
+	}
+
+	kind := strings.ToLower(req.Kind.Kind)
+
+	msg := fmt.Sprintf("%s %s/%s is a replica managed by replikator, changes should be made to the source %s named %q instead",
+		kind, req.Namespace, req.Name, kind, req.Name)
+	if source, _, ok := api.GetSourceReference(&obj); ok {
+		msg = fmt.Sprintf("%s %s/%s is a replica managed by replikator, changes should be made to the source %s %s instead",
+			kind, req.Namespace, req.Name, kind, source)
+	}
+
+	if h.WarnOnly {
+		return admission.Allowed("").WithWarnings(msg)
+	}
+
+	return admission.Denied(msg)
+}
+
+func (h *ReplicaProtectionHandler) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(ReplicaProtectionPath, &webhook.Admission{Handler: h})
+
+	return nil
+}
+
+func (h *ReplicaProtectionHandler) isAllowedUser(namespace, username string) bool {
+	if strings.HasPrefix(username, systemServiceAccountPrefix) || username == kubeControllerManagerUsername {
+		return true
+	}
+
+	for _, name := range h.AllowedServiceAccountNames {
+		if username == fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name) {
+			return true
+		}
+	}
+
+	for _, allowed := range h.AllowedUsernames {
+		if username == allowed {
+			return true
+		}
+	}
+
+	return false
+}
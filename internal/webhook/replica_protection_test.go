@@ -0,0 +1,120 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/internal/webhook"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestReplicaProtectionHandler(t *testing.T) {
+	ctx := context.Background()
+
+	replica := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "another-namespace",
+			Labels: map[string]string{
+				api.LabelManagedByKey: api.LabelManagedByValue,
+			},
+			Annotations: map[string]string{
+				api.Key(api.AnnotationSourceNamespace): "test-namespace",
+				api.Key(api.AnnotationSourceName):      "test-secret",
+			},
+		},
+	}
+
+	raw, err := json.Marshal(replica)
+	require.NoError(t, err)
+
+	newRequest := func(username string) admission.Request {
+		return admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Delete,
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+				Namespace: replica.Namespace,
+				Name:      replica.Name,
+				UserInfo:  authenticationv1.UserInfo{Username: username},
+				OldObject: runtime.RawExtension{Raw: raw},
+			},
+		}
+	}
+
+	h := &webhook.ReplicaProtectionHandler{
+		AllowedUsernames: []string{"system:serviceaccount:replikator:controller-manager"},
+	}
+
+	t.Run("Should Deny Modifications By Users", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest("alice"))
+		assert.False(t, resp.Allowed)
+		assert.Contains(t, resp.Result.Message, "source secret test-namespace/test-secret")
+	})
+
+	t.Run("Should Allow Modifications By Replikator", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest("system:serviceaccount:replikator:controller-manager"))
+		assert.True(t, resp.Allowed)
+	})
+
+	t.Run("Should Allow Modifications By System Controllers", func(t *testing.T) {
+		resp := h.Handle(ctx, newRequest("system:serviceaccount:kube-system:namespace-controller"))
+		assert.True(t, resp.Allowed)
+
+		// Without --use-service-account-credentials, the built-in controllers
+		// share the kube-controller-manager identity.
+		resp = h.Handle(ctx, newRequest("system:kube-controller-manager"))
+		assert.True(t, resp.Allowed)
+	})
+
+	t.Run("Should Allow Modifications By Impersonated Service Accounts", func(t *testing.T) {
+		h := &webhook.ReplicaProtectionHandler{
+			AllowedServiceAccountNames: []string{"replikator-writer"},
+		}
+
+		impersonate := controller.ImpersonationConfigFor(replica.Namespace, "replikator-writer")
+
+		resp := h.Handle(ctx, newRequest(impersonate.UserName))
+		assert.True(t, resp.Allowed)
+
+		// But only in their own namespace.
+		impersonate = controller.ImpersonationConfigFor("other-namespace", "replikator-writer")
+
+		resp = h.Handle(ctx, newRequest(impersonate.UserName))
+		assert.False(t, resp.Allowed)
+	})
+
+	t.Run("Should Warn When In Warn Only Mode", func(t *testing.T) {
+		h := &webhook.ReplicaProtectionHandler{WarnOnly: true}
+
+		resp := h.Handle(ctx, newRequest("alice"))
+		assert.True(t, resp.Allowed)
+		assert.NotEmpty(t, resp.Warnings)
+	})
+}
@@ -0,0 +1,54 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import "slices"
+
+const (
+	// ArgoCDCompareOptionsAnnotation is the annotation Argo CD reads comparison options from.
+	ArgoCDCompareOptionsAnnotation = "argocd.argoproj.io/compare-options"
+	// ArgoCDSyncOptionsAnnotation is the annotation Argo CD reads sync options from.
+	ArgoCDSyncOptionsAnnotation = "argocd.argoproj.io/sync-options"
+	// ArgoCDTrackingIDAnnotation is the annotation Argo CD uses to track the resources of an application.
+	ArgoCDTrackingIDAnnotation = "argocd.argoproj.io/tracking-id"
+	// ArgoCDInstanceLabel is the label Argo CD can be configured to track the resources of an application with.
+	ArgoCDInstanceLabel = "argocd.argoproj.io/instance"
+	// ArgoCDDefaultInstanceLabel is the label Argo CD tracks the resources of an application with by default.
+	ArgoCDDefaultInstanceLabel = "app.kubernetes.io/instance"
+)
+
+// WithArgoCDCompatibility returns a copy of the metadata filter for clusters
+// managed by Argo CD. Replicas don't inherit the Argo CD tracking metadata of
+// their source (so they aren't considered part of the source's application,
+// unless it is explicitly kept), and are annotated so that applications owning
+// the target namespace neither report them as out of sync nor prune them.
+func (f MetadataFilter) WithArgoCDCompatibility() MetadataFilter {
+	f.StripLabels = append(slices.Clone(f.StripLabels), ArgoCDInstanceLabel, ArgoCDDefaultInstanceLabel)
+	f.StripAnnotations = append(slices.Clone(f.StripAnnotations), ArgoCDTrackingIDAnnotation)
+
+	annotations := make(map[string]string, len(f.SetAnnotations)+2)
+	for key, value := range f.SetAnnotations {
+		annotations[key] = value
+	}
+
+	annotations[ArgoCDCompareOptionsAnnotation] = "IgnoreExtraneous"
+	annotations[ArgoCDSyncOptionsAnnotation] = "Prune=false"
+	f.SetAnnotations = annotations
+
+	return f
+}
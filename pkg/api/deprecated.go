@@ -0,0 +1,178 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+// The keys of replikator annotations and labels in the default domain, as
+// published before the domain became configurable. They don't follow
+// SetDomain, use Key (and the other accessors) instead.
+const (
+	// AnnotationPrefix is the common prefix of all replikator annotations in the default domain.
+	//
+	// Deprecated: use KeyPrefix, which honors SetDomain.
+	AnnotationPrefix = "v1alpha1.replikator.pecke.tt/"
+	// AnnotationEnabledKey is the key of AnnotationEnabled in the default domain.
+	//
+	// Deprecated: use Key(AnnotationEnabled), which honors SetDomain.
+	AnnotationEnabledKey = "v1alpha1.replikator.pecke.tt/enabled"
+	// AnnotationReplicateToKey is the key of AnnotationReplicateTo in the default domain.
+	//
+	// Deprecated: use Key(AnnotationReplicateTo), which honors SetDomain.
+	AnnotationReplicateToKey = "v1alpha1.replikator.pecke.tt/replicate-to"
+	// AnnotationReplicateExceptKey is the key of AnnotationReplicateExcept in the default domain.
+	//
+	// Deprecated: use Key(AnnotationReplicateExcept), which honors SetDomain.
+	AnnotationReplicateExceptKey = "v1alpha1.replikator.pecke.tt/replicate-except"
+	// AnnotationReplicateToTenantKey is the key of AnnotationReplicateToTenant in the default domain.
+	//
+	// Deprecated: use Key(AnnotationReplicateToTenant), which honors SetDomain.
+	AnnotationReplicateToTenantKey = "v1alpha1.replikator.pecke.tt/replicate-to-tenant"
+	// AnnotationAcceptFromTenantsKey is the key of AnnotationAcceptFromTenants in the default domain.
+	//
+	// Deprecated: use Key(AnnotationAcceptFromTenants), which honors SetDomain.
+	AnnotationAcceptFromTenantsKey = "v1alpha1.replikator.pecke.tt/accept-from-tenants"
+	// AnnotationReplicateKeysKey is the key of AnnotationReplicateKeys in the default domain.
+	//
+	// Deprecated: use Key(AnnotationReplicateKeys), which honors SetDomain.
+	AnnotationReplicateKeysKey = "v1alpha1.replikator.pecke.tt/replicate-keys"
+	// AnnotationAllowPrivateKeyKey is the key of AnnotationAllowPrivateKey in the default domain.
+	//
+	// Deprecated: use Key(AnnotationAllowPrivateKey), which honors SetDomain.
+	AnnotationAllowPrivateKeyKey = "v1alpha1.replikator.pecke.tt/allow-private-key"
+	// AnnotationEnabledByKey is the key of AnnotationEnabledBy in the default domain.
+	//
+	// Deprecated: use Key(AnnotationEnabledBy), which honors SetDomain.
+	AnnotationEnabledByKey = "v1alpha1.replikator.pecke.tt/enabled-by"
+	// AnnotationConflictPolicyKey is the key of AnnotationConflictPolicy in the default domain.
+	//
+	// Deprecated: use Key(AnnotationConflictPolicy), which honors SetDomain.
+	AnnotationConflictPolicyKey = "v1alpha1.replikator.pecke.tt/conflict-policy"
+	// AnnotationAdoptExistingKey is the key of AnnotationAdoptExisting in the default domain.
+	//
+	// Deprecated: use Key(AnnotationAdoptExisting), which honors SetDomain.
+	AnnotationAdoptExistingKey = "v1alpha1.replikator.pecke.tt/adopt-existing"
+	// AnnotationForceDeleteKey is the key of AnnotationForceDelete in the default domain.
+	//
+	// Deprecated: use Key(AnnotationForceDelete), which honors SetDomain.
+	AnnotationForceDeleteKey = "v1alpha1.replikator.pecke.tt/force-delete"
+	// AnnotationTrustBundleKey is the key of AnnotationTrustBundle in the default domain.
+	//
+	// Deprecated: use Key(AnnotationTrustBundle), which honors SetDomain.
+	AnnotationTrustBundleKey = "v1alpha1.replikator.pecke.tt/trust-bundle"
+	// AnnotationCertificateKey is the key of AnnotationCertificate in the default domain.
+	//
+	// Deprecated: use Key(AnnotationCertificate), which honors SetDomain.
+	AnnotationCertificateKey = "v1alpha1.replikator.pecke.tt/certificate"
+	// AnnotationPresetKey is the key of AnnotationPreset in the default domain.
+	//
+	// Deprecated: use Key(AnnotationPreset), which honors SetDomain.
+	AnnotationPresetKey = "v1alpha1.replikator.pecke.tt/preset"
+	// AnnotationReplicateToClustersKey is the key of AnnotationReplicateToClusters in the default domain.
+	//
+	// Deprecated: use Key(AnnotationReplicateToClusters), which honors SetDomain.
+	AnnotationReplicateToClustersKey = "v1alpha1.replikator.pecke.tt/replicate-to-clusters"
+	// AnnotationHelmReleaseKey is the key of AnnotationHelmRelease in the default domain.
+	//
+	// Deprecated: use Key(AnnotationHelmRelease), which honors SetDomain.
+	AnnotationHelmReleaseKey = "v1alpha1.replikator.pecke.tt/helm-release"
+	// AnnotationCAOverlapKey is the key of AnnotationCAOverlap in the default domain.
+	//
+	// Deprecated: use Key(AnnotationCAOverlap), which honors SetDomain.
+	AnnotationCAOverlapKey = "v1alpha1.replikator.pecke.tt/ca-overlap"
+	// AnnotationCAOverlapUntilKey is the key of AnnotationCAOverlapUntil in the default domain.
+	//
+	// Deprecated: use Key(AnnotationCAOverlapUntil), which honors SetDomain.
+	AnnotationCAOverlapUntilKey = "v1alpha1.replikator.pecke.tt/ca-overlap-until"
+	// AnnotationCAChecksumKey is the key of AnnotationCAChecksum in the default domain.
+	//
+	// Deprecated: use Key(AnnotationCAChecksum), which honors SetDomain.
+	AnnotationCAChecksumKey = "v1alpha1.replikator.pecke.tt/ca-checksum"
+	// AnnotationWithdrawExpiredKey is the key of AnnotationWithdrawExpired in the default domain.
+	//
+	// Deprecated: use Key(AnnotationWithdrawExpired), which honors SetDomain.
+	AnnotationWithdrawExpiredKey = "v1alpha1.replikator.pecke.tt/withdraw-expired"
+	// AnnotationSPIFFEBundleKey is the key of AnnotationSPIFFEBundle in the default domain.
+	//
+	// Deprecated: use Key(AnnotationSPIFFEBundle), which honors SetDomain.
+	AnnotationSPIFFEBundleKey = "v1alpha1.replikator.pecke.tt/spiffe-bundle"
+	// AnnotationReplicateFromKey is the key of AnnotationReplicateFrom in the default domain.
+	//
+	// Deprecated: use Key(AnnotationReplicateFrom), which honors SetDomain.
+	AnnotationReplicateFromKey = "v1alpha1.replikator.pecke.tt/replicate-from"
+	// AnnotationReplicationAllowedKey is the key of AnnotationReplicationAllowed in the default domain.
+	//
+	// Deprecated: use Key(AnnotationReplicationAllowed), which honors SetDomain.
+	AnnotationReplicationAllowedKey = "v1alpha1.replikator.pecke.tt/replication-allowed"
+	// AnnotationReplicationAllowedNamespacesKey is the key of AnnotationReplicationAllowedNamespaces in the default domain.
+	//
+	// Deprecated: use Key(AnnotationReplicationAllowedNamespaces), which honors SetDomain.
+	AnnotationReplicationAllowedNamespacesKey = "v1alpha1.replikator.pecke.tt/replication-allowed-namespaces"
+	// AnnotationVClusterNamespacesKey is the key of AnnotationVClusterNamespaces in the default domain.
+	//
+	// Deprecated: use Key(AnnotationVClusterNamespaces), which honors SetDomain.
+	AnnotationVClusterNamespacesKey = "v1alpha1.replikator.pecke.tt/vcluster-namespaces"
+	// LabelVClusterReplicaKey is the key of LabelVClusterReplica in the default domain.
+	//
+	// Deprecated: use Key(LabelVClusterReplica), which honors SetDomain.
+	LabelVClusterReplicaKey = "v1alpha1.replikator.pecke.tt/vcluster-replica"
+	// LabelSourceUIDKey is the key of LabelSourceUID in the default domain.
+	//
+	// Deprecated: use Key(LabelSourceUID), which honors SetDomain.
+	LabelSourceUIDKey = "v1alpha1.replikator.pecke.tt/source-uid"
+	// AnnotationSourceNamespaceKey is the key of AnnotationSourceNamespace in the default domain.
+	//
+	// Deprecated: use Key(AnnotationSourceNamespace), which honors SetDomain.
+	AnnotationSourceNamespaceKey = "v1alpha1.replikator.pecke.tt/source-namespace"
+	// AnnotationSourceNameKey is the key of AnnotationSourceName in the default domain.
+	//
+	// Deprecated: use Key(AnnotationSourceName), which honors SetDomain.
+	AnnotationSourceNameKey = "v1alpha1.replikator.pecke.tt/source-name"
+	// AnnotationSyncedAtKey is the key of AnnotationSyncedAt in the default domain.
+	//
+	// Deprecated: use Key(AnnotationSyncedAt), which honors SetDomain.
+	AnnotationSyncedAtKey = "v1alpha1.replikator.pecke.tt/synced-at"
+	// AnnotationSourceHashKey is the key of AnnotationSourceHash in the default domain.
+	//
+	// Deprecated: use Key(AnnotationSourceHash), which honors SetDomain.
+	AnnotationSourceHashKey = "v1alpha1.replikator.pecke.tt/source-hash"
+	// AnnotationRolloutOnChangeKey is the key of AnnotationRolloutOnChange in the default domain.
+	//
+	// Deprecated: use Key(AnnotationRolloutOnChange), which honors SetDomain.
+	AnnotationRolloutOnChangeKey = "v1alpha1.replikator.pecke.tt/rollout-on-change"
+	// AnnotationRolloutChecksumKey is the key of AnnotationRolloutChecksum in the default domain.
+	//
+	// Deprecated: use Key(AnnotationRolloutChecksum), which honors SetDomain.
+	AnnotationRolloutChecksumKey = "v1alpha1.replikator.pecke.tt/rollout-checksum"
+	// FinalizerName is the name of the finalizer that is added to sources in the default domain.
+	//
+	// Deprecated: use Finalizer, which honors SetDomain.
+	FinalizerName = "replikator.pecke.tt/finalizer"
+)
+
+var (
+	// LegacyAnnotationPrefixes are the annotation prefixes used by earlier releases
+	// of replikator (and its predecessor tls-replicator).
+	//
+	// Deprecated: use LegacyKeyPrefixes, which honors SetDomain and
+	// DisableLegacyAnnotations. Changing this variable has no effect.
+	LegacyAnnotationPrefixes = LegacyKeyPrefixes()
+	// LegacyFinalizerNames are the finalizers added by earlier releases of replikator.
+	//
+	// Deprecated: use LegacyFinalizers, which honors SetDomain. Changing this
+	// variable has no effect.
+	LegacyFinalizerNames = LegacyFinalizers()
+)
@@ -0,0 +1,171 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// IsReplicationEnabled returns true if the object has been annotated for replication.
+func IsReplicationEnabled(obj metav1.Object) bool {
+	enabledStr, ok := GetAnnotation(obj, Key(AnnotationEnabled))
+	return ok && strings.ToLower(enabledStr) == "true"
+}
+
+// IsTrustBundleSource returns true if the object has been annotated to be
+// replicated as a trust-manager Bundle.
+func IsTrustBundleSource(obj metav1.Object) bool {
+	value, ok := GetAnnotation(obj, Key(AnnotationTrustBundle))
+	return ok && strings.ToLower(value) == "true"
+}
+
+// IsReplica returns true if the object is a replica managed by replikator.
+func IsReplica(obj metav1.Object) bool {
+	if obj.GetLabels()[LabelManagedByKey] == LabelManagedByValue {
+		return true
+	}
+
+	_, ok := obj.GetLabels()[Key(LabelSourceUID)]
+	return ok
+}
+
+// GetSourceReference returns the namespace, name, and UID of the source of a
+// replica (if recorded).
+func GetSourceReference(replica metav1.Object) (types.NamespacedName, types.UID, bool) {
+	namespace, hasNamespace := replica.GetAnnotations()[Key(AnnotationSourceNamespace)]
+	name, hasName := replica.GetAnnotations()[Key(AnnotationSourceName)]
+	if !hasNamespace || !hasName {
+		return types.NamespacedName{}, "", false
+	}
+
+	return types.NamespacedName{Namespace: namespace, Name: name}, types.UID(replica.GetLabels()[Key(LabelSourceUID)]), true
+}
+
+// ParseFilters splits a comma-separated list of glob patterns (as used by the
+// replicate-to, replicate-except and replicate-keys annotations). Whitespace
+// around each pattern is ignored, so "a, b" is the same as "a,b".
+func ParseFilters(value string) []string {
+	filters := strings.Split(value, ",")
+	for i, filter := range filters {
+		filters[i] = strings.TrimSpace(filter)
+	}
+
+	return filters
+}
+
+// ValidateFilters checks that a comma-separated list of glob patterns is well formed.
+func ValidateFilters(value string) error {
+	for _, filter := range ParseFilters(value) {
+		if filter == "" {
+			return fmt.Errorf("empty pattern in %q", value)
+		}
+
+		if err := ValidatePattern(filter); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", filter, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidatePattern checks that a glob pattern is well formed, so that matching
+// it against any value will not fail.
+func ValidatePattern(pattern string) error {
+	// filepath.Match stops checking the syntax of a pattern once a star fails
+	// to match, so stars are replaced with (syntactically equivalent) question
+	// marks to check the whole of the pattern.
+	_, err := filepath.Match(strings.ReplaceAll(pattern, "*", "?"), "")
+	return err
+}
+
+// ShouldReplicateTo returns true if the source object should be replicated
+// to the given namespace (according to its replicate-to and replicate-except
+// annotations).
+func ShouldReplicateTo(obj metav1.Object, namespace string) (bool, error) {
+	if namespace == obj.GetNamespace() {
+		return false, nil
+	}
+
+	if excluded, err := IsExcludedNamespace(obj, namespace); err != nil || excluded {
+		return false, err
+	}
+
+	replicateTo, ok := GetAnnotation(obj, Key(AnnotationReplicateTo))
+	if !ok {
+		return true, nil
+	}
+
+	for _, filter := range ParseFilters(replicateTo) {
+		if ok, err := filepath.Match(filter, namespace); err != nil {
+			return false, fmt.Errorf("failed to evaluate namespace filter: %w", err)
+		} else if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// IsExcludedNamespace returns true if the given namespace matches the
+// replicate-except annotation of the source object. As ignoring a malformed
+// pattern would replicate to namespaces that were meant to be excluded, the
+// annotation is rejected entirely if any of its patterns are malformed.
+func IsExcludedNamespace(obj metav1.Object, namespace string) (bool, error) {
+	replicateExcept, ok := GetAnnotation(obj, Key(AnnotationReplicateExcept))
+	if !ok {
+		return false, nil
+	}
+
+	if err := ValidateFilters(replicateExcept); err != nil {
+		return false, fmt.Errorf("failed to evaluate namespace exclusions: %w", err)
+	}
+
+	for _, filter := range ParseFilters(replicateExcept) {
+		if ok, err := filepath.Match(filter, namespace); err != nil {
+			return false, fmt.Errorf("failed to evaluate namespace exclusions: %w", err)
+		} else if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ShouldReplicateKey returns true if the given data key of the source object
+// should be replicated (according to its replicate-keys annotation).
+func ShouldReplicateKey(obj metav1.Object, key string) (bool, error) {
+	replicateKeys, ok := GetAnnotation(obj, Key(AnnotationReplicateKeys))
+	if !ok {
+		return true, nil
+	}
+
+	for _, filter := range ParseFilters(replicateKeys) {
+		if ok, err := filepath.Match(filter, key); err != nil {
+			return false, fmt.Errorf("failed to evaluate key filter: %w", err)
+		} else if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,140 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseFilters(t *testing.T) {
+	t.Run("Should Ignore Whitespace Around Patterns", func(t *testing.T) {
+		assert.Equal(t, []string{"team-a", "team-b"}, api.ParseFilters("team-a, team-b"))
+		assert.Equal(t, []string{"team-a", "team-b"}, api.ParseFilters(" team-a ,\tteam-b "))
+	})
+
+	t.Run("Should Reject Blank Patterns", func(t *testing.T) {
+		assert.Error(t, api.ValidateFilters("team-a, ,team-b"))
+	})
+
+	t.Run("Should Match Spaced Patterns", func(t *testing.T) {
+		source := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-secret",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled):     "true",
+					api.Key(api.AnnotationReplicateTo): "team-a, team-b",
+				},
+			},
+		}
+
+		require.NoError(t, api.ValidateFilters("team-a, team-b"))
+
+		ok, err := api.ShouldReplicateTo(source, "team-b")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func FuzzValidateFilters(f *testing.F) {
+	f.Add("team-*")
+	f.Add("team-a,team-b")
+	f.Add("team-a, team-b")
+	f.Add("")
+	f.Add(",,,")
+	f.Add("[")
+	f.Add("[a-")
+	f.Add("0*[")
+	f.Add("\\")
+	f.Add("ünïcödé-*,日本語")
+	f.Add(strings.Repeat("*,", 10000))
+
+	f.Fuzz(func(t *testing.T, value string) {
+		err := api.ValidateFilters(value)
+
+		if err == nil {
+			assert.NotEmpty(t, api.ParseFilters(value))
+		}
+	})
+}
+
+func FuzzShouldReplicateTo(f *testing.F) {
+	f.Add("team-*", "team-a")
+	f.Add("team-a,team-b", "team-b")
+	f.Add("[", "team-a")
+	f.Add("team-*[", "team-a")
+	f.Add("*", "")
+	f.Add("ünïcödé-*", "ünïcödé-namespace")
+	f.Add(strings.Repeat("*a", 1000), strings.Repeat("a", 1000))
+
+	f.Fuzz(func(t *testing.T, replicateTo, namespace string) {
+		source := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-secret",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled):     "true",
+					api.Key(api.AnnotationReplicateTo): replicateTo,
+				},
+			},
+		}
+
+		_, err := api.ShouldReplicateTo(source, namespace)
+
+		// Sources with valid filters must always be evaluated successfully.
+		if api.ValidateFilters(replicateTo) == nil {
+			assert.NoError(t, err)
+		}
+	})
+}
+
+func FuzzShouldReplicateKey(f *testing.F) {
+	f.Add("ca.crt", "ca.crt")
+	f.Add("tls.*,ca.crt", "tls.key")
+	f.Add("[]", "key")
+	f.Add("*", "")
+	f.Add("ünïcödé-*", "ünïcödé-key")
+	f.Add(strings.Repeat("*a", 1000), strings.Repeat("a", 1000))
+
+	f.Fuzz(func(t *testing.T, replicateKeys, key string) {
+		source := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-secret",
+				Namespace: "test-namespace",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled):       "true",
+					api.Key(api.AnnotationReplicateKeys): replicateKeys,
+				},
+			},
+		}
+
+		_, err := api.ShouldReplicateKey(source, key)
+
+		// Sources with valid filters must always be evaluated successfully.
+		if api.ValidateFilters(replicateKeys) == nil {
+			assert.NoError(t, err)
+		}
+	})
+}
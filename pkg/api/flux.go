@@ -0,0 +1,54 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import "slices"
+
+const (
+	// FluxPruneAnnotation is the annotation Flux reads garbage collection options from.
+	FluxPruneAnnotation = "kustomize.toolkit.fluxcd.io/prune"
+	// FluxSSAAnnotation is the annotation Flux reads server-side apply options from.
+	FluxSSAAnnotation = "kustomize.toolkit.fluxcd.io/ssa"
+	// FluxKustomizeMetadata matches the labels and annotations Flux uses to
+	// track the resources of a Kustomization.
+	FluxKustomizeMetadata = "kustomize.toolkit.fluxcd.io/*"
+	// FluxHelmMetadata matches the labels and annotations Flux uses to track
+	// the resources of a HelmRelease.
+	FluxHelmMetadata = "helm.toolkit.fluxcd.io/*"
+)
+
+// WithFluxCompatibility returns a copy of the metadata filter for clusters
+// managed by Flux. Replicas don't inherit the Flux ownership metadata of their
+// source (so they are never included in the inventory of the source's
+// Kustomization or HelmRelease), and are annotated so that Flux neither prunes
+// them nor overwrites them should a Kustomization also declare them.
+func (f MetadataFilter) WithFluxCompatibility() MetadataFilter {
+	f.StripLabels = append(slices.Clone(f.StripLabels), FluxKustomizeMetadata, FluxHelmMetadata)
+	f.StripAnnotations = append(slices.Clone(f.StripAnnotations), FluxKustomizeMetadata, FluxHelmMetadata)
+
+	annotations := make(map[string]string, len(f.SetAnnotations)+2)
+	for key, value := range f.SetAnnotations {
+		annotations[key] = value
+	}
+
+	annotations[FluxPruneAnnotation] = "disabled"
+	annotations[FluxSSAAnnotation] = "Ignore"
+	f.SetAnnotations = annotations
+
+	return f
+}
@@ -0,0 +1,59 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+const (
+	// HelmManagedByValue is the managed-by label value Helm requires of the
+	// objects it adopts.
+	HelmManagedByValue = "Helm"
+	// HelmReleaseNameAnnotation is the annotation recording the name of the
+	// Helm release an object belongs to.
+	HelmReleaseNameAnnotation = "meta.helm.sh/release-name"
+	// HelmReleaseNamespaceAnnotation is the annotation recording the namespace
+	// of the Helm release an object belongs to.
+	HelmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+)
+
+// SetHelmOwnership stamps the replica (which must already be in its target
+// namespace) with the Helm ownership metadata of the release named by the
+// helm-release annotation of its source, if any, so that a chart installed as
+// that release in the target namespace can adopt it. Replicas remain
+// identifiable by their source-uid label.
+func SetHelmOwnership(source, replica metav1.Object) {
+	release, ok := GetAnnotation(source, Key(AnnotationHelmRelease))
+	if !ok || release == "" {
+		return
+	}
+
+	labels := replica.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[LabelManagedByKey] = HelmManagedByValue
+	replica.SetLabels(labels)
+
+	annotations := replica.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[HelmReleaseNameAnnotation] = release
+	annotations[HelmReleaseNamespaceAnnotation] = replica.GetNamespace()
+	replica.SetAnnotations(annotations)
+}
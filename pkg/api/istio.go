@@ -0,0 +1,88 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PresetIstioCA replicates the root certificate of a mesh CA secret into
+	// every namespace enrolled in an Istio (sidecar or ambient) mesh.
+	PresetIstioCA = "istio-ca"
+	// IstioRootCertKey is the key Istio reads the mesh root certificate from.
+	IstioRootCertKey = "root-cert.pem"
+	// IstioInjectionLabel is the namespace label that enables sidecar injection.
+	IstioInjectionLabel = "istio-injection"
+	// IstioRevisionLabel is the namespace label that enables sidecar injection
+	// for a specific control plane revision.
+	IstioRevisionLabel = "istio.io/rev"
+	// IstioDataplaneModeLabel is the namespace label that enrolls a namespace
+	// in an ambient mesh.
+	IstioDataplaneModeLabel = "istio.io/dataplane-mode"
+)
+
+// IsKnownPreset returns true if the value of a preset annotation is supported.
+func IsKnownPreset(preset string) bool {
+	return preset == PresetIstioCA
+}
+
+// IsIstioCAPreset returns true if the object is replicated with the istio-ca preset.
+func IsIstioCAPreset(obj metav1.Object) bool {
+	preset, ok := GetAnnotation(obj, Key(AnnotationPreset))
+	return ok && preset == PresetIstioCA
+}
+
+// IsMeshEnabled returns true if the namespace is enrolled in an Istio mesh,
+// either for sidecar injection or in ambient mode.
+func IsMeshEnabled(namespace *corev1.Namespace) bool {
+	if namespace == nil {
+		return false
+	}
+
+	labels := namespace.Labels
+	if labels[IstioInjectionLabel] == "disabled" || labels[IstioDataplaneModeLabel] == "none" {
+		return false
+	}
+
+	return labels[IstioInjectionLabel] == "enabled" ||
+		labels[IstioRevisionLabel] != "" ||
+		labels[IstioDataplaneModeLabel] == "ambient"
+}
+
+// istioCATemplate returns the replica template of a mesh CA secret, which
+// contains only its root certificate (under the key Istio expects). The root
+// certificate is taken from ca.crt, falling back to tls.crt for self-signed
+// CAs. Private keys are never replicated.
+func istioCATemplate(secret *corev1.Secret, metadata MetadataFilter) *corev1.Secret {
+	template := corev1.Secret{
+		ObjectMeta: ReplicaObjectMeta(secret, metadata),
+		Type:       corev1.SecretTypeOpaque,
+		Data:       make(map[string][]byte),
+	}
+
+	for _, key := range []string{IstioRootCertKey, "ca.crt", corev1.TLSCertKey} {
+		if value := secret.Data[key]; len(value) > 0 {
+			template.Data[IstioRootCertKey] = value
+			break
+		}
+	}
+
+	return &template
+}
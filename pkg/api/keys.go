@@ -0,0 +1,314 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package api defines the annotations, labels and finalizers used by replikator,
+// along with helpers for constructing and inspecting replication sources and
+// replicas, so that tooling can work with them without duplicating strings.
+package api
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultDomain is the domain used for replikator annotations, labels and finalizers.
+const DefaultDomain = "replikator.pecke.tt"
+
+// Name is the name of a replikator annotation or label. The key it is set
+// under depends on the domain in use (see Key).
+type Name string
+
+const (
+	// AnnotationEnabled is the annotation that enables replication.
+	AnnotationEnabled Name = "enabled"
+	// AnnotationReplicateTo is the annotation that specifies the target namespace/s to replicate to.
+	// The value of this annotation should be a comma-separated list of values / glob patterns.
+	// If this annotation is not present, the source will be replicated to all namespaces.
+	AnnotationReplicateTo Name = "replicate-to"
+	// AnnotationReplicateExcept is the annotation that specifies namespace/s never to
+	// replicate to, even if they match replicate-to. The value of this annotation should be
+	// a comma-separated list of values / glob patterns (eg. "kube-*,cattle-*").
+	AnnotationReplicateExcept Name = "replicate-except"
+	// AnnotationReplicateToTenant is the annotation that specifies the tenant/s whose
+	// namespaces to replicate to (eg. Capsule tenants). The value of this annotation should
+	// be a comma-separated list of values / glob patterns. It further restricts replicate-to.
+	AnnotationReplicateToTenant Name = "replicate-to-tenant"
+	// AnnotationAcceptFromTenants is the namespace annotation that consents to replicas
+	// of sources belonging to other tenants being written to the namespace. The value of
+	// this annotation should be a comma-separated list of values / glob patterns.
+	AnnotationAcceptFromTenants Name = "accept-from-tenants"
+	// AnnotationReplicateKeys is the annotation that specifies the keys to replicate.
+	// The value of this annotation should be a comma-separated list of values / glob patterns.
+	// If this annotation is not present, all keys will be replicated.
+	AnnotationReplicateKeys Name = "replicate-keys"
+	// AnnotationAllowPrivateKey is the annotation that permits a secret containing a TLS
+	// private key to be replicated without a replicate-keys filter (when this is required by policy).
+	AnnotationAllowPrivateKey Name = "allow-private-key"
+	// AnnotationEnabledBy is the annotation that records the user who enabled replication
+	// of a source (as captured by the provenance webhook). It is carried on replicas.
+	AnnotationEnabledBy Name = "enabled-by"
+	// AnnotationConflictPolicy is the annotation that specifies what to do when a target
+	// namespace already contains an object with the same name that is not managed by replikator.
+	// The value of this annotation should be one of fail, skip, or overwrite (defaults to skip).
+	AnnotationConflictPolicy Name = "conflict-policy"
+	// AnnotationAdoptExisting is the annotation that allows pre-existing objects with the same
+	// name in target namespaces (that aren't managed by replikator) to be taken over as replicas.
+	AnnotationAdoptExisting Name = "adopt-existing"
+	// AnnotationForceDelete is the annotation that allows a source to be deleted (or have
+	// replication disabled) even if some of its replicas could not be deleted, orphaning them.
+	AnnotationForceDelete Name = "force-delete"
+	// AnnotationTrustBundle is the annotation that replicates a CA secret as a trust-manager
+	// Bundle (when enabled on the operator), rather than by copying it to each namespace.
+	AnnotationTrustBundle Name = "trust-bundle"
+	// AnnotationCertificate is the annotation recording the name of the cert-manager
+	// Certificate whose replikator annotations have been copied to the secret it issued.
+	AnnotationCertificate Name = "certificate"
+	// AnnotationPreset is the annotation that replicates a source according to a
+	// built-in preset (eg. "istio-ca"), which chooses its target namespaces and
+	// the keys of its replicas.
+	AnnotationPreset Name = "preset"
+	// AnnotationReplicateToClusters is the annotation that distributes a source to the
+	// Open Cluster Management managed clusters with matching names, through ManifestWorks
+	// (when enabled on the operator). The value of this annotation should be a
+	// comma-separated list of values / glob patterns.
+	AnnotationReplicateToClusters Name = "replicate-to-clusters"
+	// AnnotationHelmRelease is the annotation that stamps replicas with the Helm ownership
+	// metadata of the named release (in each target namespace), so that a chart installed as
+	// that release can adopt them.
+	AnnotationHelmRelease Name = "helm-release"
+	// AnnotationCAOverlap is the annotation that retains the previous certificates of the
+	// ca.crt key in replicas after the source is rotated, for the given duration (eg. "168h").
+	AnnotationCAOverlap Name = "ca-overlap"
+	// AnnotationCAOverlapUntil is the annotation recording the time until which a replica
+	// retains the previous certificates of its source.
+	AnnotationCAOverlapUntil Name = "ca-overlap-until"
+	// AnnotationCAChecksum is the annotation recording the checksum of the ca.crt key
+	// last written to a replica.
+	AnnotationCAChecksum Name = "ca-checksum"
+	// AnnotationWithdrawExpired is the annotation that removes the replicas of a source
+	// once the certificate in its tls.crt key has expired (until it is renewed).
+	AnnotationWithdrawExpired Name = "withdraw-expired"
+	// AnnotationSPIFFEBundle is the annotation that adds a SPIFFE trust bundle, rendered
+	// from the CA certificates in the ca.crt key, to the replicas of a source.
+	AnnotationSPIFFEBundle Name = "spiffe-bundle"
+	// AnnotationReplicateFrom is the annotation on an (empty) object that requests it be
+	// filled with the data of the source of the same kind named by the annotation, in the
+	// form "<namespace>/<name>". The source must permit this with replication-allowed.
+	AnnotationReplicateFrom Name = "replicate-from"
+	// AnnotationReplicationAllowed is the annotation that permits objects in other
+	// namespaces to pull the data of a source with replicate-from.
+	AnnotationReplicationAllowed Name = "replication-allowed"
+	// AnnotationReplicationAllowedNamespaces is the annotation that restricts which
+	// namespaces may pull the data of a source with replicate-from. The value of this
+	// annotation should be a comma-separated list of values / glob patterns.
+	AnnotationReplicationAllowedNamespaces Name = "replication-allowed-namespaces"
+	// AnnotationVClusterNamespaces is the annotation on the host namespace of a virtual
+	// cluster that specifies the namespace/s within the virtual cluster that the replicas in
+	// the host namespace are copied to. The value of this annotation should be a
+	// comma-separated list of values / glob patterns (defaults to "default").
+	AnnotationVClusterNamespaces Name = "vcluster-namespaces"
+	// LabelVClusterReplica is the label used to mark copies of replicas within virtual clusters.
+	LabelVClusterReplica Name = "vcluster-replica"
+	// LabelSourceUID is the label recording the UID of the source of a replica.
+	LabelSourceUID Name = "source-uid"
+	// AnnotationSourceNamespace is the annotation recording the namespace of the source of a replica.
+	AnnotationSourceNamespace Name = "source-namespace"
+	// AnnotationSourceName is the annotation recording the name of the source of a replica.
+	AnnotationSourceName Name = "source-name"
+	// AnnotationSyncedAt is the annotation recording when a replica was last written.
+	AnnotationSyncedAt Name = "synced-at"
+	// AnnotationSourceHash is the annotation recording a hash of the content (type, data,
+	// labels and annotations) last written to a replica, replicas with a matching hash aren't rewritten.
+	AnnotationSourceHash Name = "source-hash"
+	// AnnotationRolloutOnChange is the annotation that opts a Deployment or StatefulSet
+	// into being restarted when a replica it consumes (via a volume, env or envFrom) changes.
+	AnnotationRolloutOnChange Name = "rollout-on-change"
+	// AnnotationRolloutChecksum is the pod template annotation recording the checksum of
+	// the replicas consumed by a workload, changing it triggers a rollout.
+	AnnotationRolloutChecksum Name = "rollout-checksum"
+)
+
+const (
+	// LabelManagedByKey is the label used to mark replicas as managed by replikator.
+	LabelManagedByKey = "app.kubernetes.io/managed-by"
+	// LabelManagedByValue is the value of the managed-by label on replicas.
+	LabelManagedByValue = "replikator"
+)
+
+const (
+	// ConflictPolicyFail fails the reconcile when a conflicting object exists.
+	ConflictPolicyFail = "fail"
+	// ConflictPolicySkip skips namespaces containing a conflicting object.
+	ConflictPolicySkip = "skip"
+	// ConflictPolicyOverwrite overwrites conflicting objects with replicas.
+	ConflictPolicyOverwrite = "overwrite"
+)
+
+// keys holds the domain dependent state, it is only changed (by SetDomain and
+// DisableLegacyAnnotations) before any controllers are started.
+var keys = struct {
+	mu sync.RWMutex
+	// annotationPrefix is the common prefix of all replikator annotations.
+	annotationPrefix string
+	// finalizerName is the name of the finalizer that is added to sources.
+	finalizerName string
+	// legacyAnnotationPrefixes are the annotation prefixes used by earlier
+	// releases of replikator (and its predecessor tls-replicator).
+	legacyAnnotationPrefixes []string
+	// legacyFinalizerNames are the finalizers added by earlier releases of replikator.
+	legacyFinalizerNames []string
+	// legacyDisabled is true once legacy annotations are no longer honored.
+	legacyDisabled bool
+}{
+	annotationPrefix: annotationPrefixFor(DefaultDomain),
+	finalizerName:    DefaultDomain + "/finalizer",
+	legacyAnnotationPrefixes: []string{
+		"v1alpha1.replikator.gpuninja.com/",
+		"v1alpha1.tls-replicator.gpuninja.com/",
+	},
+	legacyFinalizerNames: []string{
+		"replikator.gpu-ninja.com/finalizer",
+	},
+}
+
+// Key returns the key of a replikator annotation or label (eg.
+// "v1alpha1.replikator.pecke.tt/enabled" for AnnotationEnabled).
+func Key(name Name) string {
+	return KeyPrefix() + string(name)
+}
+
+// KeyPrefix returns the common prefix of all replikator annotations.
+func KeyPrefix() string {
+	keys.mu.RLock()
+	defer keys.mu.RUnlock()
+
+	return keys.annotationPrefix
+}
+
+// Finalizer returns the name of the finalizer that is added to sources.
+func Finalizer() string {
+	keys.mu.RLock()
+	defer keys.mu.RUnlock()
+
+	return keys.finalizerName
+}
+
+// LegacyKeyPrefixes returns the annotation prefixes used by earlier
+// releases of replikator (and its predecessor tls-replicator). They are still
+// honored on sources, but the current annotations take precedence.
+func LegacyKeyPrefixes() []string {
+	keys.mu.RLock()
+	defer keys.mu.RUnlock()
+
+	return slices.Clone(keys.legacyAnnotationPrefixes)
+}
+
+// LegacyFinalizers returns the finalizers added by earlier releases of replikator.
+func LegacyFinalizers() []string {
+	keys.mu.RLock()
+	defer keys.mu.RUnlock()
+
+	return slices.Clone(keys.legacyFinalizerNames)
+}
+
+// setDomainOnce guards against the domain changing once it is in use.
+var setDomainOnce sync.Once
+
+// SetDomain changes the domain used for replikator annotations, labels and finalizers
+// (eg. for white-label deployments). Annotations and finalizers using the previous
+// domain are still honored (as legacy annotations and finalizers), so existing
+// objects continue to work.
+//
+// It must be called (once) before any controllers or webhooks are started. Only
+// the first call has any effect.
+func SetDomain(domain string) {
+	setDomainOnce.Do(func() {
+		setDomain(domain)
+	})
+}
+
+func setDomain(domain string) {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+
+	if domain == "" || keys.annotationPrefix == annotationPrefixFor(domain) {
+		return
+	}
+
+	// The annotations of the previous domain are only honored if legacy
+	// annotations are, whichever order the two are configured in.
+	if !keys.legacyDisabled {
+		keys.legacyAnnotationPrefixes = append(keys.legacyAnnotationPrefixes, keys.annotationPrefix)
+	}
+
+	keys.legacyFinalizerNames = append(keys.legacyFinalizerNames, keys.finalizerName)
+
+	keys.annotationPrefix = annotationPrefixFor(domain)
+	keys.finalizerName = domain + "/finalizer"
+}
+
+func annotationPrefixFor(domain string) string {
+	return "v1alpha1." + domain + "/"
+}
+
+// DisableLegacyAnnotations stops legacy annotations from being honored on sources
+// (eg. once every source has been migrated). Legacy finalizers are still removed
+// so that sources are not left stuck deleting. It must be called before any
+// controllers are started.
+func DisableLegacyAnnotations() {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+
+	keys.legacyAnnotationPrefixes = nil
+	keys.legacyDisabled = true
+}
+
+// IsLegacyAnnotation returns true if the annotation key belongs to a legacy annotation family.
+func IsLegacyAnnotation(key string) bool {
+	for _, prefix := range LegacyKeyPrefixes() {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetAnnotation returns the value of a replikator annotation, falling back
+// to any legacy equivalent if the current annotation is not present.
+func GetAnnotation(obj metav1.Object, key string) (string, bool) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		return "", false
+	}
+
+	if value, ok := annotations[key]; ok {
+		return value, true
+	}
+
+	suffix := strings.TrimPrefix(key, KeyPrefix())
+	for _, prefix := range LegacyKeyPrefixes() {
+		if value, ok := annotations[prefix+suffix]; ok {
+			return value, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,167 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api_test
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// inSubprocess reruns the test in a separate process, for tests that change
+// package level state. It returns true if the caller is that process.
+func inSubprocess(t *testing.T) bool {
+	if os.Getenv("TEST_SUBPROCESS") == t.Name() {
+		return true
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$", "-test.v")
+	cmd.Env = append(os.Environ(), "TEST_SUBPROCESS="+t.Name())
+
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	return false
+}
+
+func TestSetDomain(t *testing.T) {
+	if !inSubprocess(t) {
+		return
+	}
+
+	require.Equal(t, "v1alpha1.replikator.pecke.tt/enabled", api.Key(api.AnnotationEnabled))
+
+	api.SetDomain("example.com")
+
+	t.Run("Should Rewrite Keys", func(t *testing.T) {
+		assert.Equal(t, "v1alpha1.example.com/", api.KeyPrefix())
+		assert.Equal(t, "v1alpha1.example.com/enabled", api.Key(api.AnnotationEnabled))
+		assert.Equal(t, "v1alpha1.example.com/replicate-to", api.Key(api.AnnotationReplicateTo))
+		assert.Equal(t, "v1alpha1.example.com/source-uid", api.Key(api.LabelSourceUID))
+		assert.Equal(t, "v1alpha1.example.com/synced-at", api.Key(api.AnnotationSyncedAt))
+		assert.Equal(t, "example.com/finalizer", api.Finalizer())
+
+		// Replicas are still identified by the standard managed-by label.
+		assert.Equal(t, "app.kubernetes.io/managed-by", api.LabelManagedByKey)
+	})
+
+	t.Run("Should Fall Back To Default Domain", func(t *testing.T) {
+		assert.True(t, api.IsLegacyAnnotation("v1alpha1.replikator.pecke.tt/enabled"))
+		assert.Contains(t, api.LegacyFinalizers(), "replikator.pecke.tt/finalizer")
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					"v1alpha1.replikator.pecke.tt/replicate-to": "team-*",
+				},
+			},
+		}
+
+		value, ok := api.GetAnnotation(secret, api.Key(api.AnnotationReplicateTo))
+		assert.True(t, ok)
+		assert.Equal(t, "team-*", value)
+
+		// The current annotations take precedence.
+		secret.Annotations[api.Key(api.AnnotationReplicateTo)] = "team-a"
+
+		value, ok = api.GetAnnotation(secret, api.Key(api.AnnotationReplicateTo))
+		assert.True(t, ok)
+		assert.Equal(t, "team-a", value)
+	})
+
+	t.Run("Should Only Set Domain Once", func(t *testing.T) {
+		api.SetDomain("example.org")
+
+		assert.Equal(t, "v1alpha1.example.com/enabled", api.Key(api.AnnotationEnabled))
+		assert.NotContains(t, api.LegacyKeyPrefixes(), "v1alpha1.example.com/")
+	})
+}
+
+func TestDisableLegacyAnnotations(t *testing.T) {
+	if !inSubprocess(t) {
+		return
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"v1alpha1.replikator.gpuninja.com/enabled": "true",
+			},
+		},
+	}
+
+	_, ok := api.GetAnnotation(secret, api.Key(api.AnnotationEnabled))
+	require.True(t, ok)
+
+	api.DisableLegacyAnnotations()
+
+	t.Run("Should Ignore Legacy Annotations", func(t *testing.T) {
+		_, ok := api.GetAnnotation(secret, api.Key(api.AnnotationEnabled))
+		assert.False(t, ok)
+		assert.False(t, api.IsLegacyAnnotation("v1alpha1.replikator.gpuninja.com/enabled"))
+	})
+
+	t.Run("Should Still Remove Legacy Finalizers", func(t *testing.T) {
+		assert.Contains(t, api.LegacyFinalizers(), "replikator.gpu-ninja.com/finalizer")
+	})
+}
+
+func TestDisableLegacyAnnotationsWithDomain(t *testing.T) {
+	if !inSubprocess(t) {
+		return
+	}
+
+	// As with --legacy-annotations=false --domain-prefix=example.com.
+	api.DisableLegacyAnnotations()
+	api.SetDomain("example.com")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"v1alpha1.replikator.pecke.tt/enabled": "true",
+			},
+		},
+	}
+
+	t.Run("Should Ignore Annotations Of The Previous Domain", func(t *testing.T) {
+		_, ok := api.GetAnnotation(secret, api.Key(api.AnnotationEnabled))
+		assert.False(t, ok)
+		assert.False(t, api.IsLegacyAnnotation("v1alpha1.replikator.pecke.tt/enabled"))
+	})
+
+	t.Run("Should Still Remove Finalizers Of The Previous Domain", func(t *testing.T) {
+		assert.Contains(t, api.LegacyFinalizers(), "replikator.pecke.tt/finalizer")
+	})
+}
+
+func TestDeprecatedKeys(t *testing.T) {
+	t.Run("Should Match The Default Domain", func(t *testing.T) {
+		assert.Equal(t, api.KeyPrefix(), api.AnnotationPrefix)
+		assert.Equal(t, api.Key(api.AnnotationEnabled), api.AnnotationEnabledKey)
+		assert.Equal(t, api.Key(api.AnnotationAllowPrivateKey), api.AnnotationAllowPrivateKeyKey)
+		assert.Equal(t, api.Key(api.LabelSourceUID), api.LabelSourceUIDKey)
+		assert.Equal(t, api.Finalizer(), api.FinalizerName)
+		assert.Equal(t, api.LegacyKeyPrefixes(), api.LegacyAnnotationPrefixes)
+	})
+}
@@ -0,0 +1,51 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"path/filepath"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IsManifestWorkSource returns true if the object has been annotated to be
+// distributed to Open Cluster Management managed clusters.
+func IsManifestWorkSource(obj metav1.Object) bool {
+	_, ok := GetAnnotation(obj, Key(AnnotationReplicateToClusters))
+	return ok
+}
+
+// ShouldReplicateToCluster returns true if the object should be distributed
+// to the managed cluster (according to its replicate-to-clusters annotation).
+func ShouldReplicateToCluster(obj metav1.Object, cluster string) (bool, error) {
+	replicateToClusters, ok := GetAnnotation(obj, Key(AnnotationReplicateToClusters))
+	if !ok {
+		return false, nil
+	}
+
+	for _, filter := range ParseFilters(replicateToClusters) {
+		if ok, err := filepath.Match(filter, cluster); err != nil {
+			return false, fmt.Errorf("failed to evaluate cluster filter: %w", err)
+		} else if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
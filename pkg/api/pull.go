@@ -0,0 +1,68 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// GetReplicateFrom returns the source an object has requested to be filled
+// from (according to its replicate-from annotation).
+func GetReplicateFrom(obj metav1.Object) (types.NamespacedName, bool, error) {
+	value, ok := GetAnnotation(obj, Key(AnnotationReplicateFrom))
+	if !ok {
+		return types.NamespacedName{}, false, nil
+	}
+
+	namespace, name, found := strings.Cut(value, "/")
+	if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return types.NamespacedName{}, true, fmt.Errorf("invalid source %q (expected <namespace>/<name>)", value)
+	}
+
+	return types.NamespacedName{Namespace: namespace, Name: name}, true, nil
+}
+
+// AllowsReplicationTo returns true if the source object permits objects in the
+// given namespace to pull its data (according to its replication-allowed and
+// replication-allowed-namespaces annotations).
+func AllowsReplicationTo(obj metav1.Object, namespace string) (bool, error) {
+	allowedStr, ok := GetAnnotation(obj, Key(AnnotationReplicationAllowed))
+	if !ok || strings.ToLower(allowedStr) != "true" {
+		return false, nil
+	}
+
+	allowedNamespaces, ok := GetAnnotation(obj, Key(AnnotationReplicationAllowedNamespaces))
+	if !ok {
+		return true, nil
+	}
+
+	for _, filter := range ParseFilters(allowedNamespaces) {
+		if ok, err := filepath.Match(filter, namespace); err != nil {
+			return false, fmt.Errorf("failed to evaluate namespace filter: %w", err)
+		} else if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,239 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultStrippedAnnotations are the well-known system and tooling annotations
+// that are not copied from sources to replicas by default, as they would
+// otherwise confuse other controllers in the target namespaces.
+var DefaultStrippedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"meta.helm.sh/*",
+	"argocd.argoproj.io/*",
+	"cert-manager.io/*",
+	"controller.cert-manager.io/*",
+	"kapp.k14s.io/*",
+}
+
+// DefaultStrippedLabels are the ownership labels of well-known GitOps and package
+// management tools that are not copied from sources to replicas by default, as
+// they would otherwise cause those tools to claim (or prune) replicas.
+var DefaultStrippedLabels = []string{
+	"helm.sh/chart",
+	"app.kubernetes.io/instance",
+	"argocd.argoproj.io/instance",
+	"kustomize.toolkit.fluxcd.io/*",
+	"helm.toolkit.fluxcd.io/*",
+	"kapp.k14s.io/*",
+}
+
+// MetadataFilter decides which labels and annotations are copied from sources to replicas.
+type MetadataFilter struct {
+	// StripLabels is a list of label key glob patterns that are not copied.
+	StripLabels []string
+	// StripAnnotations is a list of annotation key glob patterns that are not copied.
+	StripAnnotations []string
+	// KeepAnnotations is a list of annotation key glob patterns that are always
+	// copied (taking precedence over StripAnnotations).
+	KeepAnnotations []string
+	// SetAnnotations are annotations set on every replica (taking precedence
+	// over those copied from the source).
+	SetAnnotations map[string]string
+	// SetLabels are labels set on every replica (taking precedence over those
+	// copied from the source).
+	SetLabels map[string]string
+}
+
+// ShouldCopyAnnotation returns true if the annotation should be copied to replicas.
+func (f *MetadataFilter) ShouldCopyAnnotation(key string) bool {
+	// Provenance is always carried on replicas.
+	if key == Key(AnnotationEnabledBy) {
+		return true
+	}
+
+	// Otherwise replikator's own annotations are never copied, replicas must
+	// not themselves be replicated.
+	if strings.HasPrefix(key, KeyPrefix()) || IsLegacyAnnotation(key) {
+		return false
+	}
+
+	if matchesAny(f.KeepAnnotations, key) {
+		return true
+	}
+
+	return !matchesAny(f.StripAnnotations, key)
+}
+
+// ShouldCopyLabel returns true if the label should be copied to replicas.
+func (f *MetadataFilter) ShouldCopyLabel(key string) bool {
+	return !matchesAny(f.StripLabels, key)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, err := filepath.Match(pattern, value); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BuildReplicaTemplate returns the replica template (sans namespace) for the
+// given source secret or configmap.
+func BuildReplicaTemplate(source client.Object, metadata MetadataFilter) (client.Object, error) {
+	switch source := source.(type) {
+	case *corev1.Secret:
+		template, err := SecretTemplate(source, metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		return template, nil
+	case *corev1.ConfigMap:
+		template, err := ConfigMapTemplate(source, metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		return template, nil
+	default:
+		return nil, fmt.Errorf("unsupported kind %T", source)
+	}
+}
+
+// SecretTemplate returns the replica template (sans namespace) for the given source secret.
+func SecretTemplate(secret *corev1.Secret, metadata MetadataFilter) (*corev1.Secret, error) {
+	if IsIstioCAPreset(secret) {
+		return istioCATemplate(secret, metadata), nil
+	}
+
+	template := corev1.Secret{
+		ObjectMeta: ReplicaObjectMeta(secret, metadata),
+		Type:       secret.Type,
+		Data:       make(map[string][]byte),
+	}
+
+	// For tls secrets, we need to ensure that the cert and private key are present.
+	if secret.Type == corev1.SecretTypeTLS {
+		template.Data[corev1.TLSCertKey] = []byte("")
+		template.Data[corev1.TLSPrivateKeyKey] = []byte("")
+	}
+
+	for key, value := range secret.Data {
+		replicate, err := ShouldReplicateKey(secret, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if replicate {
+			template.Data[key] = value
+		}
+	}
+
+	return &template, nil
+}
+
+// ConfigMapTemplate returns the replica template (sans namespace) for the given source configmap.
+func ConfigMapTemplate(cm *corev1.ConfigMap, metadata MetadataFilter) (*corev1.ConfigMap, error) {
+	template := corev1.ConfigMap{
+		ObjectMeta: ReplicaObjectMeta(cm, metadata),
+		Data:       make(map[string]string),
+	}
+
+	for key, value := range cm.Data {
+		replicate, err := ShouldReplicateKey(cm, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if replicate {
+			template.Data[key] = value
+		}
+	}
+
+	for key, value := range cm.BinaryData {
+		replicate, err := ShouldReplicateKey(cm, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if !replicate {
+			continue
+		}
+
+		if template.BinaryData == nil {
+			template.BinaryData = make(map[string][]byte)
+		}
+
+		template.BinaryData[key] = value
+	}
+
+	return &template, nil
+}
+
+// ReplicaObjectMeta returns the object metadata (sans namespace) of a replica
+// of the given source. The replica is labeled as managed by replikator and
+// records a reference to its source.
+func ReplicaObjectMeta(source metav1.Object, metadata MetadataFilter) metav1.ObjectMeta {
+	objectMeta := metav1.ObjectMeta{
+		Name:        source.GetName(),
+		Labels:      make(map[string]string),
+		Annotations: make(map[string]string),
+	}
+
+	for key, value := range source.GetLabels() {
+		if metadata.ShouldCopyLabel(key) {
+			objectMeta.Labels[key] = value
+		}
+	}
+
+	for key, value := range metadata.SetLabels {
+		objectMeta.Labels[key] = value
+	}
+
+	objectMeta.Labels[LabelManagedByKey] = LabelManagedByValue
+
+	if source.GetUID() != "" {
+		objectMeta.Labels[Key(LabelSourceUID)] = string(source.GetUID())
+	}
+
+	for key, value := range source.GetAnnotations() {
+		if metadata.ShouldCopyAnnotation(key) {
+			objectMeta.Annotations[key] = value
+		}
+	}
+
+	for key, value := range metadata.SetAnnotations {
+		objectMeta.Annotations[key] = value
+	}
+
+	objectMeta.Annotations[Key(AnnotationSourceNamespace)] = source.GetNamespace()
+	objectMeta.Annotations[Key(AnnotationSourceName)] = source.GetName()
+
+	return objectMeta
+}
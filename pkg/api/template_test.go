@@ -0,0 +1,199 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api_test
+
+import (
+	"testing"
+
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMetadataFilter(t *testing.T) {
+	filter := api.MetadataFilter{
+		StripLabels:      api.DefaultStrippedLabels,
+		StripAnnotations: api.DefaultStrippedAnnotations,
+		KeepAnnotations:  []string{"cert-manager.io/issuer-name"},
+	}
+
+	t.Run("Should Copy Regular Annotations", func(t *testing.T) {
+		assert.True(t, filter.ShouldCopyAnnotation("example.com/owner"))
+	})
+
+	t.Run("Should Strip Default Annotations", func(t *testing.T) {
+		assert.False(t, filter.ShouldCopyAnnotation("kubectl.kubernetes.io/last-applied-configuration"))
+		assert.False(t, filter.ShouldCopyAnnotation("meta.helm.sh/release-name"))
+		assert.False(t, filter.ShouldCopyAnnotation("argocd.argoproj.io/tracking-id"))
+		assert.False(t, filter.ShouldCopyAnnotation("cert-manager.io/certificate-name"))
+	})
+
+	t.Run("Should Keep Explicitly Allowed Annotations", func(t *testing.T) {
+		assert.True(t, filter.ShouldCopyAnnotation("cert-manager.io/issuer-name"))
+	})
+
+	t.Run("Should Never Copy Replikator Annotations", func(t *testing.T) {
+		keepAll := api.MetadataFilter{KeepAnnotations: []string{"*"}}
+
+		assert.False(t, keepAll.ShouldCopyAnnotation(api.Key(api.AnnotationEnabled)))
+		assert.False(t, keepAll.ShouldCopyAnnotation("v1alpha1.replikator.gpuninja.com/enabled"))
+	})
+
+	t.Run("Should Strip Default Labels", func(t *testing.T) {
+		assert.True(t, filter.ShouldCopyLabel("app.kubernetes.io/name"))
+		assert.False(t, filter.ShouldCopyLabel("helm.sh/chart"))
+		assert.False(t, filter.ShouldCopyLabel("app.kubernetes.io/instance"))
+		assert.False(t, filter.ShouldCopyLabel("kustomize.toolkit.fluxcd.io/name"))
+	})
+
+	t.Run("Should Sanitize Replica Templates", func(t *testing.T) {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-configmap",
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					"helm.sh/chart":          "test-1.0.0",
+					"app.kubernetes.io/name": "test",
+				},
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+					"meta.helm.sh/release-name":    "test",
+					"example.com/owner":            "team-a",
+				},
+			},
+		}
+
+		template, err := api.ConfigMapTemplate(cm, filter)
+		require.NoError(t, err)
+
+		assert.Equal(t, "team-a", template.Annotations["example.com/owner"])
+		assert.NotContains(t, template.Annotations, "meta.helm.sh/release-name")
+		assert.NotContains(t, template.Annotations, api.Key(api.AnnotationEnabled))
+		assert.Equal(t, "test", template.Labels["app.kubernetes.io/name"])
+		assert.NotContains(t, template.Labels, "helm.sh/chart")
+	})
+	t.Run("Should Apply Argo CD Compatibility", func(t *testing.T) {
+		argoFilter := api.MetadataFilter{}.WithArgoCDCompatibility()
+
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-configmap",
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					api.ArgoCDDefaultInstanceLabel: "source-app",
+					"app.kubernetes.io/name":       "test",
+				},
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+					api.ArgoCDTrackingIDAnnotation: "source-app:/ConfigMap:test-namespace/test-configmap",
+				},
+			},
+		}
+
+		template, err := api.ConfigMapTemplate(cm, argoFilter)
+		require.NoError(t, err)
+
+		assert.NotContains(t, template.Labels, api.ArgoCDDefaultInstanceLabel)
+		assert.NotContains(t, template.Annotations, api.ArgoCDTrackingIDAnnotation)
+		assert.Equal(t, "IgnoreExtraneous", template.Annotations[api.ArgoCDCompareOptionsAnnotation])
+		assert.Equal(t, "Prune=false", template.Annotations[api.ArgoCDSyncOptionsAnnotation])
+
+		// The original filter is left untouched.
+		assert.Empty(t, filter.SetAnnotations)
+	})
+	t.Run("Should Apply Flux Compatibility", func(t *testing.T) {
+		fluxFilter := api.MetadataFilter{
+			KeepAnnotations: []string{"kustomize.toolkit.fluxcd.io/*"},
+		}.WithFluxCompatibility()
+
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-configmap",
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					"kustomize.toolkit.fluxcd.io/name":      "source-app",
+					"kustomize.toolkit.fluxcd.io/namespace": "flux-system",
+					"app.kubernetes.io/name":                "test",
+				},
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+					api.FluxPruneAnnotation:        "enabled",
+				},
+			},
+		}
+
+		template, err := api.ConfigMapTemplate(cm, fluxFilter)
+		require.NoError(t, err)
+
+		assert.NotContains(t, template.Labels, "kustomize.toolkit.fluxcd.io/name")
+		assert.NotContains(t, template.Labels, "kustomize.toolkit.fluxcd.io/namespace")
+		assert.Equal(t, "test", template.Labels["app.kubernetes.io/name"])
+		assert.Equal(t, "disabled", template.Annotations[api.FluxPruneAnnotation])
+		assert.Equal(t, "Ignore", template.Annotations[api.FluxSSAAnnotation])
+	})
+	t.Run("Should Exclude Replicas From Velero Backups", func(t *testing.T) {
+		veleroFilter := api.MetadataFilter{}.WithVeleroExclusion()
+
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-configmap",
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					api.VeleroRestoreNameLabel: "nightly-20240101",
+					"velero.io/backup-name":    "nightly",
+					"app.kubernetes.io/name":   "test",
+				},
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+				},
+			},
+		}
+
+		template, err := api.ConfigMapTemplate(cm, veleroFilter)
+		require.NoError(t, err)
+
+		assert.Equal(t, "true", template.Labels[api.VeleroExcludeFromBackupLabel])
+		assert.NotContains(t, template.Labels, api.VeleroRestoreNameLabel)
+		assert.NotContains(t, template.Labels, "velero.io/backup-name")
+		assert.Equal(t, "test", template.Labels["app.kubernetes.io/name"])
+	})
+	t.Run("Should Record Source Reference", func(t *testing.T) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "root-ca",
+				Namespace: "cert-manager",
+				UID:       "test-uid",
+				Annotations: map[string]string{
+					api.Key(api.AnnotationEnabled): "true",
+				},
+			},
+		}
+
+		template, err := api.SecretTemplate(secret, filter)
+		require.NoError(t, err)
+
+		assert.True(t, api.IsReplica(template))
+
+		ref, uid, ok := api.GetSourceReference(template)
+		require.True(t, ok)
+		assert.Equal(t, "cert-manager/root-ca", ref.String())
+		assert.Equal(t, secret.UID, uid)
+	})
+}
@@ -0,0 +1,69 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"path/filepath"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CapsuleTenantLabel is the label Capsule records the tenant of a namespace in.
+const CapsuleTenantLabel = "capsule.clastix.io/tenant"
+
+// ShouldReplicateToTenant returns true if the source object should be
+// replicated to a namespace belonging to the given tenant (according to its
+// replicate-to-tenant annotation). Namespaces without a tenant are never
+// matched by the annotation.
+func ShouldReplicateToTenant(obj metav1.Object, tenant string) (bool, error) {
+	replicateToTenant, ok := GetAnnotation(obj, Key(AnnotationReplicateToTenant))
+	if !ok {
+		return true, nil
+	}
+
+	if tenant == "" {
+		return false, nil
+	}
+
+	return matchesFilters(replicateToTenant, tenant, "tenant")
+}
+
+// AcceptsFromTenant returns true if the namespace has consented (with its
+// accept-from-tenants annotation) to replicas of sources belonging to the
+// given tenant.
+func AcceptsFromTenant(namespace metav1.Object, tenant string) (bool, error) {
+	acceptFromTenants, ok := GetAnnotation(namespace, Key(AnnotationAcceptFromTenants))
+	if !ok || tenant == "" {
+		return false, nil
+	}
+
+	return matchesFilters(acceptFromTenants, tenant, "tenant")
+}
+
+func matchesFilters(filters, value, kind string) (bool, error) {
+	for _, filter := range ParseFilters(filters) {
+		if ok, err := filepath.Match(filter, value); err != nil {
+			return false, fmt.Errorf("failed to evaluate %s filter: %w", kind, err)
+		} else if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
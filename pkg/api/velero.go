@@ -0,0 +1,67 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+)
+
+const (
+	// VeleroExcludeFromBackupLabel is the label that excludes an object from Velero backups.
+	VeleroExcludeFromBackupLabel = "velero.io/exclude-from-backup"
+	// VeleroRestoreNameLabel is the label Velero records the name of the
+	// restore that created an object in.
+	VeleroRestoreNameLabel = "velero.io/restore-name"
+	// VeleroMetadata matches the labels Velero adds to restored objects.
+	VeleroMetadata = "velero.io/*"
+)
+
+// WithVeleroExclusion returns a copy of the metadata filter that labels
+// replicas so they are excluded from Velero backups. Replicas are rebuilt from
+// their (backed up) sources after a restore, so backing them up would only
+// store the same data many times over. The labels Velero adds to restored
+// sources are not inherited by replicas.
+func (f MetadataFilter) WithVeleroExclusion() MetadataFilter {
+	f.StripLabels = append(slices.Clone(f.StripLabels), VeleroMetadata)
+
+	labels := make(map[string]string, len(f.SetLabels)+1)
+	for key, value := range f.SetLabels {
+		labels[key] = value
+	}
+
+	labels[VeleroExcludeFromBackupLabel] = "true"
+	f.SetLabels = labels
+
+	return f
+}
+
+// VeleroLabelValue returns the value Velero uses when recording the given name
+// in a label (names longer than a label value allows are truncated and
+// suffixed with a hash).
+func VeleroLabelValue(name string) string {
+	const maxLength = 63
+	if len(name) <= maxLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+
+	return name[:maxLength-6] + hex.EncodeToString(sum[:])[:6]
+}
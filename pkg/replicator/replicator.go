@@ -0,0 +1,300 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package replicator provides replikator's replication engine for embedding
+// in other operators, eg. to replicate an object an operator has just created
+// to a set of namespaces without depending on a deployed replikator.
+//
+// Replicas are labeled and annotated exactly as they would be by replikator.
+// If replikator is also running in the cluster, sources should be annotated
+// for replication, otherwise their replicas will be garbage collected as
+// orphans.
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/dpeckett/replikator/internal/controller"
+	"github.com/dpeckett/replikator/pkg/api"
+	"github.com/gpu-ninja/operator-utils/updater"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrUnsupportedKind is returned for sources that are neither secrets nor configmaps.
+var ErrUnsupportedKind = errors.New("unsupported kind")
+
+// Options configures a Replicator.
+type Options struct {
+	// StripLabels is a list of label key glob patterns that are not copied to
+	// replicas. If nil, the labels of well-known GitOps tools are stripped.
+	StripLabels []string
+	// StripAnnotations is a list of annotation key glob patterns that are not
+	// copied to replicas. If nil, well-known system and tooling annotations
+	// are stripped.
+	StripAnnotations []string
+	// KeepAnnotations is a list of annotation key glob patterns that are always
+	// copied (taking precedence over StripAnnotations).
+	KeepAnnotations []string
+	// AdoptExisting takes over pre-existing objects with the same name in
+	// target namespaces (that aren't managed by replikator) as replicas.
+	AdoptExisting bool
+	// ArgoCDCompatibility annotates replicas so that Argo CD applications
+	// owning their namespace neither report them as out of sync nor prune them.
+	ArgoCDCompatibility bool
+	// FluxCompatibility annotates replicas so that Flux neither prunes them
+	// nor takes ownership of them.
+	FluxCompatibility bool
+	// VeleroExclusion labels replicas so they are excluded from Velero backups.
+	VeleroExclusion bool
+}
+
+// Plan is the set of changes required for the replicas of a source to match
+// the desired target namespaces.
+type Plan struct {
+	// Create are the replicas to be created.
+	Create []client.Object
+	// Update are the existing replicas that differ from the source.
+	Update []client.Object
+	// Delete are the existing replicas in namespaces that are no longer targeted.
+	Delete []client.Object
+	// Conflicts are the target namespaces that already contain an object with
+	// the same name that isn't managed by replikator (these are left untouched).
+	Conflicts []string
+}
+
+// Empty returns true if the plan contains no changes.
+func (p *Plan) Empty() bool {
+	return len(p.Create) == 0 && len(p.Update) == 0 && len(p.Delete) == 0
+}
+
+// Replicator replicates secrets and configmaps to other namespaces.
+type Replicator struct {
+	client   client.Client
+	metadata api.MetadataFilter
+	adopt    bool
+}
+
+// New creates a new Replicator using the given client to read and write replicas.
+func New(c client.Client, opts Options) *Replicator {
+	metadata := api.MetadataFilter{
+		StripLabels:      opts.StripLabels,
+		StripAnnotations: opts.StripAnnotations,
+		KeepAnnotations:  opts.KeepAnnotations,
+	}
+
+	if metadata.StripLabels == nil {
+		metadata.StripLabels = api.DefaultStrippedLabels
+	}
+
+	if metadata.StripAnnotations == nil {
+		metadata.StripAnnotations = api.DefaultStrippedAnnotations
+	}
+
+	if opts.ArgoCDCompatibility {
+		metadata = metadata.WithArgoCDCompatibility()
+	}
+
+	if opts.FluxCompatibility {
+		metadata = metadata.WithFluxCompatibility()
+	}
+
+	if opts.VeleroExclusion {
+		metadata = metadata.WithVeleroExclusion()
+	}
+
+	return &Replicator{
+		client:   c,
+		metadata: metadata,
+		adopt:    opts.AdoptExisting,
+	}
+}
+
+// Template returns the replica template (sans namespace) for the source. The
+// source's replicate-keys annotation (if any) is honored.
+func (r *Replicator) Template(source client.Object) (client.Object, error) {
+	switch source := source.(type) {
+	case *corev1.Secret:
+		return api.SecretTemplate(source, r.metadata)
+	case *corev1.ConfigMap:
+		return api.ConfigMapTemplate(source, r.metadata)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKind, source)
+	}
+}
+
+// Plan computes the changes required for the source to be replicated to
+// exactly the given namespaces (replicas in any other namespace are deleted).
+func (r *Replicator) Plan(ctx context.Context, source client.Object, namespaces []string) (*Plan, error) {
+	template, err := r.Template(source)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := r.replicas(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+	for _, replica := range existing {
+		if !slices.Contains(namespaces, replica.GetNamespace()) {
+			plan.Delete = append(plan.Delete, replica)
+		}
+	}
+
+	for _, namespace := range namespaces {
+		if namespace == source.GetNamespace() {
+			continue
+		}
+
+		desired := template.DeepCopyObject().(client.Object)
+		desired.SetNamespace(namespace)
+		api.SetHelmOwnership(source, desired)
+		controller.StampSourceHash(desired)
+
+		current := desired.DeepCopyObject().(client.Object)
+		if err := r.client.Get(ctx, client.ObjectKeyFromObject(desired), current); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get replica in namespace %s: %w", namespace, err)
+			}
+
+			plan.Create = append(plan.Create, desired)
+			continue
+		}
+
+		if !api.IsReplica(current) && !r.adopt {
+			plan.Conflicts = append(plan.Conflicts, namespace)
+			continue
+		}
+
+		// The type of a secret is immutable, so the replica must be recreated.
+		if desiredSecret, ok := desired.(*corev1.Secret); ok && current.(*corev1.Secret).Type != desiredSecret.Type {
+			plan.Delete = append(plan.Delete, current)
+			plan.Create = append(plan.Create, desired)
+			continue
+		}
+
+		if len(controller.CompareReplica(desired, current)) > 0 {
+			desired.SetResourceVersion(current.GetResourceVersion())
+			plan.Update = append(plan.Update, desired)
+		}
+	}
+
+	return &plan, nil
+}
+
+// Apply performs the changes in the plan.
+func (r *Replicator) Apply(ctx context.Context, plan *Plan) error {
+	for _, replica := range plan.Delete {
+		if err := r.client.Delete(ctx, replica); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete replica in namespace %s: %w", replica.GetNamespace(), err)
+		}
+	}
+
+	for _, replica := range plan.Create {
+		stampSyncedAt(replica)
+
+		if _, err := updater.CreateOrUpdateFromTemplate(ctx, r.client, replica); err != nil {
+			return fmt.Errorf("failed to create replica in namespace %s: %w", replica.GetNamespace(), err)
+		}
+	}
+
+	for _, replica := range plan.Update {
+		stampSyncedAt(replica)
+
+		if err := r.client.Update(ctx, replica); err != nil {
+			return fmt.Errorf("failed to update replica in namespace %s: %w", replica.GetNamespace(), err)
+		}
+	}
+
+	return nil
+}
+
+// Replicate replicates the source to exactly the given namespaces (deleting
+// replicas from any other namespace), and returns the changes made.
+func (r *Replicator) Replicate(ctx context.Context, source client.Object, namespaces ...string) (*Plan, error) {
+	plan, err := r.Plan(ctx, source, namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, r.Apply(ctx, plan)
+}
+
+// Cleanup deletes every replica of the source.
+func (r *Replicator) Cleanup(ctx context.Context, source client.Object) error {
+	_, err := r.Replicate(ctx, source)
+	return err
+}
+
+// replicas returns the existing replicas of the source (in every namespace).
+func (r *Replicator) replicas(ctx context.Context, source client.Object) ([]client.Object, error) {
+	var objects []client.Object
+	switch source.(type) {
+	case *corev1.Secret:
+		var secrets corev1.SecretList
+		if err := r.client.List(ctx, &secrets); err != nil {
+			return nil, fmt.Errorf("failed to list secrets: %w", err)
+		}
+
+		for i := range secrets.Items {
+			objects = append(objects, &secrets.Items[i])
+		}
+	case *corev1.ConfigMap:
+		var configMaps corev1.ConfigMapList
+		if err := r.client.List(ctx, &configMaps); err != nil {
+			return nil, fmt.Errorf("failed to list configmaps: %w", err)
+		}
+
+		for i := range configMaps.Items {
+			objects = append(objects, &configMaps.Items[i])
+		}
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKind, source)
+	}
+
+	var replicas []client.Object
+	for _, obj := range objects {
+		if !api.IsReplica(obj) {
+			continue
+		}
+
+		ref, _, ok := api.GetSourceReference(obj)
+		if ok && ref == client.ObjectKeyFromObject(source) {
+			replicas = append(replicas, obj)
+		}
+	}
+
+	return replicas, nil
+}
+
+func stampSyncedAt(replica client.Object) {
+	annotations := replica.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[api.Key(api.AnnotationSyncedAt)] = time.Now().UTC().Format(time.RFC3339)
+
+	replica.SetAnnotations(annotations)
+}
@@ -0,0 +1,125 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package replicator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dpeckett/replikator/pkg/replicator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReplicator(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-secret",
+			Namespace: "test-namespace",
+			UID:       "1234",
+		},
+		Data: map[string][]byte{
+			"key": []byte("test-value"),
+		},
+	}
+
+	ctx := context.Background()
+
+	t.Run("Should Replicate To Namespaces", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret).
+			Build()
+
+		r := replicator.New(client, replicator.Options{})
+
+		plan, err := r.Replicate(ctx, secret, "tenant-a", "tenant-b")
+		require.NoError(t, err)
+		assert.Len(t, plan.Create, 2)
+
+		var replica corev1.Secret
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: "tenant-a"}, &replica))
+		assert.Equal(t, secret.Data, replica.Data)
+
+		// Replicating again should be a no-op.
+		plan, err = r.Plan(ctx, secret, []string{"tenant-a", "tenant-b"})
+		require.NoError(t, err)
+		assert.True(t, plan.Empty())
+	})
+
+	t.Run("Should Delete Untargeted Replicas", func(t *testing.T) {
+		client := fake.NewClientBuilder().
+			WithObjects(secret).
+			Build()
+
+		r := replicator.New(client, replicator.Options{})
+
+		_, err := r.Replicate(ctx, secret, "tenant-a", "tenant-b")
+		require.NoError(t, err)
+
+		plan, err := r.Replicate(ctx, secret, "tenant-a")
+		require.NoError(t, err)
+		assert.Len(t, plan.Delete, 1)
+
+		var replica corev1.Secret
+		err = client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: "tenant-b"}, &replica)
+		assert.True(t, apierrors.IsNotFound(err))
+
+		require.NoError(t, r.Cleanup(ctx, secret))
+
+		err = client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: "tenant-a"}, &replica)
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Should Not Overwrite Unmanaged Objects", func(t *testing.T) {
+		unmanaged := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secret.Name,
+				Namespace: "tenant-a",
+			},
+			Data: map[string][]byte{
+				"key": []byte("another-value"),
+			},
+		}
+
+		client := fake.NewClientBuilder().
+			WithObjects(secret, unmanaged).
+			Build()
+
+		r := replicator.New(client, replicator.Options{})
+
+		plan, err := r.Replicate(ctx, secret, "tenant-a")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tenant-a"}, plan.Conflicts)
+
+		var existing corev1.Secret
+		require.NoError(t, client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: "tenant-a"}, &existing))
+		assert.Equal(t, unmanaged.Data, existing.Data)
+	})
+
+	t.Run("Should Reject Unsupported Kinds", func(t *testing.T) {
+		r := replicator.New(fake.NewClientBuilder().Build(), replicator.Options{})
+
+		_, err := r.Template(&corev1.Service{})
+		assert.ErrorIs(t, err, replicator.ErrUnsupportedKind)
+	})
+}
@@ -0,0 +1,252 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// fieldManager is the field manager used when applying manifests.
+const fieldManager = "replikator-e2e"
+
+// readManifests reads the objects in the YAML files of a directory, or at a URL.
+func readManifests(ctx context.Context, location string) ([]*unstructured.Unstructured, error) {
+	var sources [][]byte
+
+	if strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", location, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to download %s: %s", location, resp.Status)
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", location, err)
+		}
+
+		sources = append(sources, data)
+	} else {
+		paths, err := filepath.Glob(filepath.Join(location, "*.yaml"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+
+			sources = append(sources, data)
+		}
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, data := range sources {
+		decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+		for {
+			var obj unstructured.Unstructured
+			if err := decoder.Decode(&obj.Object); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+
+				return nil, fmt.Errorf("failed to decode manifests from %s: %w", location, err)
+			}
+
+			// Skip empty documents.
+			if len(obj.Object) == 0 {
+				continue
+			}
+
+			objects = append(objects, &obj)
+		}
+	}
+
+	return objects, nil
+}
+
+// applyManifests server-side applies the objects. Objects are retried until
+// they can all be applied, as they may depend on custom resource definitions
+// or webhooks that are still becoming ready.
+func applyManifests(ctx context.Context, config *rest.Config, objects []*unstructured.Unstructured) error {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+
+	var lastErr error
+	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, 5*time.Minute, true, func(ctx context.Context) (bool, error) {
+		var pending []*unstructured.Unstructured
+		for _, obj := range objects {
+			if lastErr = applyObject(ctx, dynamicClient, mapper, obj); lastErr != nil {
+				if meta.IsNoMatchError(lastErr) {
+					// The custom resource definition was applied after the mapper was populated.
+					mapper.Reset()
+				}
+
+				pending = append(pending, obj)
+			}
+		}
+
+		objects = pending
+
+		return len(objects) == 0, nil
+	})
+	if err != nil && lastErr != nil {
+		return fmt.Errorf("failed to apply manifests: %w", lastErr)
+	}
+
+	return err
+}
+
+func applyObject(ctx context.Context, dynamicClient dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+
+	var resource dynamic.ResourceInterface = dynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+
+		resource = dynamicClient.Resource(mapping.Resource).Namespace(namespace)
+	}
+
+	_, err = resource.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+	if err != nil {
+		return fmt.Errorf("failed to apply %s %s: %w", gvk.Kind, obj.GetName(), err)
+	}
+
+	return nil
+}
+
+// setDeploymentImage sets the image of a container of the named deployment.
+func setDeploymentImage(objects []*unstructured.Unstructured, deploymentName, containerName, image string) error {
+	for _, obj := range objects {
+		if obj.GetKind() != "Deployment" || obj.GetName() != deploymentName {
+			continue
+		}
+
+		containers, _, err := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+		if err != nil {
+			return err
+		}
+
+		for _, container := range containers {
+			if container, ok := container.(map[string]any); ok && container["name"] == containerName {
+				container["image"] = image
+			}
+		}
+
+		return unstructured.SetNestedSlice(obj.Object, containers, "spec", "template", "spec", "containers")
+	}
+
+	return fmt.Errorf("deployment %s not found", deploymentName)
+}
+
+// restartDeployment triggers a rollout of the deployment (eg. to pick up a
+// rebuilt image with the same tag).
+func restartDeployment(ctx context.Context, clientset kubernetes.Interface, namespace, name string) error {
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"replikator.pecke.tt/restartedAt":%q}}}}}`,
+		time.Now().Format(time.RFC3339))
+
+	_, err := clientset.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// waitForDeployments waits for every deployment in the namespace to finish
+// rolling out.
+func waitForDeployments(ctx context.Context, clientset kubernetes.Interface, namespace string) error {
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, 5*time.Minute, true, func(ctx context.Context) (bool, error) {
+		deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		for _, deployment := range deployments.Items {
+			if !deploymentRolledOut(&deployment) {
+				return false, nil
+			}
+		}
+
+		return len(deployments.Items) > 0, nil
+	})
+}
+
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.AvailableReplicas == replicas &&
+		deployment.Status.Replicas == replicas
+}
@@ -0,0 +1,157 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// operatorNamespace is the namespace the operator is deployed to.
+	operatorNamespace = "replikator"
+	// operatorSelector selects the operator pods.
+	operatorSelector = "app.kubernetes.io/name=replikator"
+)
+
+func TestOperatorRestartDuringFanOut(t *testing.T) {
+	ctx := context.Background()
+	clientset := newClientset(t)
+
+	var targetNamespaces []string
+	for i := 0; i < 50; i++ {
+		targetNamespaces = append(targetNamespaces, fmt.Sprintf("replikator-chaos-%d", i))
+	}
+
+	t.Log("Creating target namespaces")
+
+	for _, name := range targetNamespaces {
+		createNamespace(ctx, t, clientset, name)
+	}
+
+	t.Log("Creating source secret")
+
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "replikator-chaos-test",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"v1alpha1.replikator.pecke.tt/enabled":      "true",
+				"v1alpha1.replikator.pecke.tt/replicate-to": "replikator-chaos-*",
+			},
+		},
+		Data: map[string][]byte{
+			"value": []byte("test"),
+		},
+	}
+
+	_, err := clientset.CoreV1().Secrets(source.Namespace).Create(ctx, source, metav1.CreateOptions{})
+	require.NoError(t, err, "failed to create source secret")
+
+	t.Log("Killing the operator while it is replicating")
+
+	require.NoError(t, killOperator(ctx, clientset))
+
+	t.Log("Waiting for replication to converge after restart")
+
+	require.NoError(t, waitForReplicas(ctx, t, clientset, source.Name, targetNamespaces, []byte("test")))
+
+	t.Log("Checking that there are no duplicate or unexpected replicas")
+
+	replicas, err := listReplicas(ctx, clientset, source)
+	require.NoError(t, err)
+
+	counts := make(map[string]int)
+	for _, replica := range replicas {
+		counts[replica.Namespace]++
+	}
+
+	assert.Len(t, counts, len(targetNamespaces))
+	for namespace, count := range counts {
+		assert.Equal(t, 1, count, "namespace %s contains %d replicas", namespace, count)
+	}
+
+	t.Log("Deleting source secret and killing the operator during cleanup")
+
+	err = clientset.CoreV1().Secrets(source.Namespace).Delete(ctx, source.Name, metav1.DeleteOptions{})
+	require.NoError(t, err, "failed to delete source secret")
+
+	require.NoError(t, killOperator(ctx, clientset))
+
+	t.Log("Checking that no replicas are orphaned")
+
+	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, propagationTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := clientset.CoreV1().Secrets(source.Namespace).Get(ctx, source.Name, metav1.GetOptions{})
+		if err == nil || !apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		replicas, err := listReplicas(ctx, clientset, source)
+		if err != nil {
+			return false, err
+		}
+
+		if len(replicas) > 0 {
+			t.Logf("%d replicas remaining", len(replicas))
+
+			return false, nil
+		}
+
+		return true, nil
+	})
+	require.NoError(t, err, "replicas were orphaned after the operator restarted")
+}
+
+// killOperator forcefully deletes the operator pods (simulating a crash).
+func killOperator(ctx context.Context, clientset kubernetes.Interface) error {
+	gracePeriod := int64(0)
+
+	return clientset.CoreV1().Pods(operatorNamespace).DeleteCollection(ctx,
+		metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod},
+		metav1.ListOptions{LabelSelector: operatorSelector})
+}
+
+// listReplicas returns the replicas of the source in every namespace.
+func listReplicas(ctx context.Context, clientset kubernetes.Interface, source *corev1.Secret) ([]corev1.Secret, error) {
+	secrets, err := clientset.CoreV1().Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/managed-by=replikator",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var replicas []corev1.Secret
+	for _, secret := range secrets.Items {
+		if secret.Annotations["v1alpha1.replikator.pecke.tt/source-namespace"] == source.Namespace &&
+			secret.Annotations["v1alpha1.replikator.pecke.tt/source-name"] == source.Name {
+			replicas = append(replicas, secret)
+		}
+	}
+
+	return replicas, nil
+}
@@ -0,0 +1,516 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/kind/pkg/cluster"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+	"sigs.k8s.io/kind/pkg/cmd"
+)
+
+// k3sDefaultVersion is the version of k3s used when no Kubernetes version is
+// requested (the default of k3d v5.6.0).
+const k3sDefaultVersion = "v1.27.4-k3s1"
+
+// clusterProvider provisions the cluster the tests are run against.
+type clusterProvider interface {
+	// Exists returns true if the cluster already exists.
+	Exists() (bool, error)
+	// Create creates the cluster.
+	Create(ctx context.Context) error
+	// Kubeconfig returns the path of a kubeconfig file for the cluster, or an
+	// empty string to use the default kubeconfig.
+	Kubeconfig() (string, error)
+	// LoadImage makes an image from the local container runtime available to
+	// the cluster.
+	LoadImage(ctx context.Context, image string) error
+	// Delete deletes the cluster.
+	Delete() error
+}
+
+func newClusterProvider(provider, clusterName, k8sVersion string) (clusterProvider, error) {
+	switch provider {
+	case "k3d":
+		docker, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create docker client: %w", err)
+		}
+
+		return &k3dProvider{
+			name:       clusterName,
+			k8sVersion: k8sVersion,
+			docker:     docker,
+		}, nil
+	case "kind":
+		return &kindProvider{
+			name:       clusterName,
+			k8sVersion: k8sVersion,
+			provider:   cluster.NewProvider(cluster.ProviderWithLogger(cmd.NewLogger())),
+		}, nil
+	case "existing":
+		return existingProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported cluster provider %q (expected k3d, kind or existing)", provider)
+	}
+}
+
+// k3dProvider provisions single node k3s clusters, running k3s in a container
+// the same way k3d does (using the Docker API, so that only a container runtime
+// is required).
+type k3dProvider struct {
+	name       string
+	k8sVersion string
+	docker     *client.Client
+	kubeconfig string
+}
+
+func (p *k3dProvider) Exists() (bool, error) {
+	_, err := p.docker.ContainerInspect(context.Background(), p.containerName())
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to inspect k3s container: %w", err)
+	}
+
+	return true, nil
+}
+
+func (p *k3dProvider) Create(ctx context.Context) error {
+	image := "rancher/k3s:" + k3sDefaultVersion
+	if p.k8sVersion != "" {
+		image = "rancher/k3s:" + p.k8sVersion + "-k3s1"
+	}
+
+	progress, err := p.docker.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", image, err)
+	}
+
+	_, err = io.Copy(io.Discard, progress)
+	_ = progress.Close()
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", image, err)
+	}
+
+	apiServerPort := nat.Port("6443/tcp")
+
+	resp, err := p.docker.ContainerCreate(ctx, &container.Config{
+		Image: image,
+		// Traefik isn't needed by the tests, and its installation job would
+		// otherwise have to be waited for.
+		Cmd:          []string{"server", "--disable=traefik", "--tls-san=127.0.0.1"},
+		ExposedPorts: nat.PortSet{apiServerPort: struct{}{}},
+	}, &container.HostConfig{
+		Privileged: true,
+		Tmpfs: map[string]string{
+			"/run":     "",
+			"/var/run": "",
+		},
+		PortBindings: nat.PortMap{
+			apiServerPort: []nat.PortBinding{{HostIP: "127.0.0.1"}},
+		},
+	}, nil, nil, p.containerName())
+	if err != nil {
+		return fmt.Errorf("failed to create k3s container: %w", err)
+	}
+
+	if err := p.docker.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("failed to start k3s container: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	// k3s writes its kubeconfig once the API server is up.
+	err = wait.PollUntilContextCancel(ctx, time.Second, true, func(ctx context.Context) (bool, error) {
+		if _, err := p.writeKubeconfig(ctx); err != nil {
+			return false, nil
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to wait for k3s kubeconfig: %w", err)
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", p.kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	err = wait.PollUntilContextCancel(ctx, time.Second, true, func(ctx context.Context) (bool, error) {
+		nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil || len(nodes.Items) == 0 {
+			return false, nil
+		}
+
+		for _, node := range nodes.Items {
+			for _, condition := range node.Status.Conditions {
+				if condition.Type == corev1.NodeReady && condition.Status != corev1.ConditionTrue {
+					return false, nil
+				}
+			}
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to wait for k3s node to be ready: %w", err)
+	}
+
+	return nil
+}
+
+func (p *k3dProvider) Kubeconfig() (string, error) {
+	if p.kubeconfig != "" {
+		return p.kubeconfig, nil
+	}
+
+	return p.writeKubeconfig(context.Background())
+}
+
+// writeKubeconfig copies the kubeconfig written by k3s out of the container,
+// pointing it at the port the API server is published on.
+func (p *k3dProvider) writeKubeconfig(ctx context.Context) (string, error) {
+	info, err := p.docker.ContainerInspect(ctx, p.containerName())
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect k3s container: %w", err)
+	}
+
+	bindings := info.NetworkSettings.Ports[nat.Port("6443/tcp")]
+	if len(bindings) == 0 {
+		return "", errors.New("k3s API server port is not published")
+	}
+
+	rc, _, err := p.docker.CopyFromContainer(ctx, info.ID, "/etc/rancher/k3s/k3s.yaml")
+	if err != nil {
+		return "", fmt.Errorf("failed to copy kubeconfig from k3s container: %w", err)
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	if _, err := tr.Next(); err != nil {
+		return "", fmt.Errorf("failed to read kubeconfig from k3s container: %w", err)
+	}
+
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read kubeconfig from k3s container: %w", err)
+	}
+
+	kubeconfig, err := clientcmd.Load(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	for _, cluster := range kubeconfig.Clusters {
+		cluster.Server = "https://127.0.0.1:" + bindings[0].HostPort
+	}
+
+	path := p.kubeconfig
+	if path == "" {
+		if path, err = newKubeconfigFile(); err != nil {
+			return "", err
+		}
+	}
+
+	if err := clientcmd.WriteToFile(*kubeconfig, path); err != nil {
+		return "", fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+
+	p.kubeconfig = path
+
+	return p.kubeconfig, nil
+}
+
+func (p *k3dProvider) LoadImage(ctx context.Context, image string) error {
+	archive, err := exportImage(image)
+	if err != nil {
+		return err
+	}
+
+	// Copy the image archive into the container, then import it into the
+	// containerd instance embedded in k3s.
+	const archivePath = "/tmp/replikator-e2e-image.tar"
+
+	var content bytes.Buffer
+	tw := tar.NewWriter(&content)
+	if err := tw.WriteHeader(&tar.Header{
+		Name: strings.TrimPrefix(archivePath, "/tmp/"),
+		Mode: 0o644,
+		Size: int64(len(archive)),
+	}); err != nil {
+		return err
+	}
+
+	if _, err := tw.Write(archive); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if err := p.docker.CopyToContainer(ctx, p.containerName(), "/tmp", &content, types.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to copy image %s into k3s container: %w", image, err)
+	}
+
+	if err := p.exec(ctx, "ctr", "images", "import", archivePath); err != nil {
+		return fmt.Errorf("failed to import image %s: %w", image, err)
+	}
+
+	return p.exec(ctx, "rm", "-f", archivePath)
+}
+
+// exec runs a command in the k3s container.
+func (p *k3dProvider) exec(ctx context.Context, cmd ...string) error {
+	created, err := p.docker.ContainerExecCreate(ctx, p.containerName(), types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.docker.ContainerExecAttach(ctx, created.ID, types.ExecStartCheck{})
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, resp.Reader); err != nil {
+		return err
+	}
+
+	info, err := p.docker.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return err
+	}
+
+	if info.ExitCode != 0 {
+		return fmt.Errorf("%s exited with code %d: %s", cmd[0], info.ExitCode, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
+
+func (p *k3dProvider) Delete() error {
+	err := p.docker.ContainerRemove(context.Background(), p.containerName(), types.ContainerRemoveOptions{
+		RemoveVolumes: true,
+		Force:         true,
+	})
+	if err != nil && !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to delete k3s container: %w", err)
+	}
+
+	if p.kubeconfig != "" {
+		_ = os.Remove(p.kubeconfig)
+	}
+
+	return nil
+}
+
+func (p *k3dProvider) containerName() string {
+	return p.name + "-server"
+}
+
+// kindProvider provisions kind clusters (using kind as a library, so that
+// only a container runtime is required).
+type kindProvider struct {
+	name       string
+	k8sVersion string
+	provider   *cluster.Provider
+	kubeconfig string
+}
+
+func (p *kindProvider) Exists() (bool, error) {
+	clusters, err := p.provider.List()
+	if err != nil {
+		return false, fmt.Errorf("failed to list kind clusters: %w", err)
+	}
+
+	return slices.Contains(clusters, p.name), nil
+}
+
+func (p *kindProvider) Create(_ context.Context) error {
+	// Don't modify the default kubeconfig, the tests use their own.
+	kubeconfig, err := newKubeconfigFile()
+	if err != nil {
+		return err
+	}
+
+	p.kubeconfig = kubeconfig
+
+	opts := []cluster.CreateOption{
+		cluster.CreateWithWaitForReady(5 * time.Minute),
+		cluster.CreateWithKubeconfigPath(p.kubeconfig),
+	}
+
+	if p.k8sVersion != "" {
+		opts = append(opts, cluster.CreateWithNodeImage("kindest/node:"+p.k8sVersion))
+	}
+
+	if err := p.provider.Create(p.name, opts...); err != nil {
+		return fmt.Errorf("failed to create kind cluster: %w", err)
+	}
+
+	return nil
+}
+
+func (p *kindProvider) Kubeconfig() (string, error) {
+	if p.kubeconfig != "" {
+		return p.kubeconfig, nil
+	}
+
+	kubeconfig, err := p.provider.KubeConfig(p.name, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to get kubeconfig of kind cluster: %w", err)
+	}
+
+	path, err := newKubeconfigFile()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+
+	p.kubeconfig = path
+
+	return p.kubeconfig, nil
+}
+
+func (p *kindProvider) LoadImage(_ context.Context, image string) error {
+	archive, err := exportImage(image)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := p.provider.ListInternalNodes(p.name)
+	if err != nil {
+		return fmt.Errorf("failed to list kind nodes: %w", err)
+	}
+
+	for _, node := range nodes {
+		if err := nodeutils.LoadImageArchive(node, bytes.NewReader(archive)); err != nil {
+			return fmt.Errorf("failed to load image into node %s: %w", node.String(), err)
+		}
+	}
+
+	return nil
+}
+
+func (p *kindProvider) Delete() error {
+	if err := p.provider.Delete(p.name, p.kubeconfig); err != nil {
+		return fmt.Errorf("failed to delete kind cluster: %w", err)
+	}
+
+	if p.kubeconfig != "" {
+		_ = os.Remove(p.kubeconfig)
+	}
+
+	return nil
+}
+
+// exportImage returns a tarball of an image from the local container runtime.
+func exportImage(image string) ([]byte, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+
+	img, err := daemon.Image(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image %s from container runtime: %w", image, err)
+	}
+
+	var archive bytes.Buffer
+	if err := tarball.Write(ref, img, &archive); err != nil {
+		return nil, fmt.Errorf("failed to export image %s: %w", image, err)
+	}
+
+	return archive.Bytes(), nil
+}
+
+// newKubeconfigFile returns the path of a new, empty kubeconfig file.
+func newKubeconfigFile() (string, error) {
+	f, err := os.CreateTemp("", "replikator-e2e-kubeconfig-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create kubeconfig: %w", err)
+	}
+
+	return f.Name(), f.Close()
+}
+
+// existingProvider uses the cluster of the current kubeconfig context.
+type existingProvider struct{}
+
+func (existingProvider) Exists() (bool, error) {
+	return true, nil
+}
+
+func (existingProvider) Create(_ context.Context) error {
+	return nil
+}
+
+func (existingProvider) Kubeconfig() (string, error) {
+	return "", nil
+}
+
+func (existingProvider) LoadImage(_ context.Context, _ string) error {
+	// The image must already be available to the cluster (eg. from a registry).
+	return nil
+}
+
+func (existingProvider) Delete() error {
+	return nil
+}
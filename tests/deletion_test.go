@@ -0,0 +1,147 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// sourceFinalizer is the finalizer replikator adds to sources.
+	sourceFinalizer = "replikator.pecke.tt/finalizer"
+	// holdFinalizer keeps a namespace terminating until it is removed.
+	holdFinalizer = "replikator.pecke.tt/e2e-hold"
+)
+
+func TestSourceDeletion(t *testing.T) {
+	ctx := context.Background()
+	clientset := newClientset(t)
+
+	targetNamespaces := []string{"replikator-delete-a", "replikator-delete-b"}
+	terminatingNamespace := "replikator-delete-terminating"
+
+	t.Log("Creating target namespaces")
+
+	for _, name := range append(targetNamespaces, terminatingNamespace) {
+		createNamespace(ctx, t, clientset, name)
+	}
+
+	t.Log("Creating source secret")
+
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "replikator-delete-test",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"v1alpha1.replikator.pecke.tt/enabled":      "true",
+				"v1alpha1.replikator.pecke.tt/replicate-to": "replikator-delete-*",
+			},
+		},
+		Data: map[string][]byte{
+			"value": []byte("test"),
+		},
+	}
+
+	_, err := clientset.CoreV1().Secrets(source.Namespace).Create(ctx, source, metav1.CreateOptions{})
+	require.NoError(t, err, "failed to create source secret")
+
+	t.Log("Waiting for source secret to be replicated")
+
+	require.NoError(t, waitForReplicas(ctx, t, clientset, source.Name, append(targetNamespaces, terminatingNamespace), []byte("test")))
+
+	source, err = clientset.CoreV1().Secrets(source.Namespace).Get(ctx, source.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Contains(t, source.Finalizers, sourceFinalizer, "source secret does not have the replikator finalizer")
+
+	t.Log("Holding a target namespace in the terminating state")
+
+	hold := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "replikator-hold",
+			Namespace:  terminatingNamespace,
+			Finalizers: []string{holdFinalizer},
+		},
+	}
+
+	_, err = clientset.CoreV1().ConfigMaps(terminatingNamespace).Create(ctx, hold, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		err = nil
+	}
+	require.NoError(t, err, "failed to create hold configmap")
+
+	t.Cleanup(func() {
+		// Release the namespace so that it can finish terminating.
+		patch := []byte(`{"metadata":{"finalizers":null}}`)
+		_, _ = clientset.CoreV1().ConfigMaps(terminatingNamespace).Patch(context.Background(), hold.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	})
+
+	err = clientset.CoreV1().Namespaces().Delete(ctx, terminatingNamespace, metav1.DeleteOptions{})
+	require.NoError(t, err, "failed to delete %s namespace", terminatingNamespace)
+
+	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, time.Minute, true, func(ctx context.Context) (bool, error) {
+		namespace, err := clientset.CoreV1().Namespaces().Get(ctx, terminatingNamespace, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		return namespace.Status.Phase == corev1.NamespaceTerminating, nil
+	})
+	require.NoError(t, err, "%s namespace did not start terminating", terminatingNamespace)
+
+	t.Log("Deleting source secret")
+
+	err = clientset.CoreV1().Secrets(source.Namespace).Delete(ctx, source.Name, metav1.DeleteOptions{})
+	require.NoError(t, err, "failed to delete source secret")
+
+	t.Log("Waiting for the finalizer to be released")
+
+	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, propagationTimeout, true, func(ctx context.Context) (bool, error) {
+		secret, err := clientset.CoreV1().Secrets(source.Namespace).Get(ctx, source.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+
+			return false, err
+		}
+
+		if slices.Contains(secret.Finalizers, sourceFinalizer) {
+			t.Log("Finalizer not yet released")
+		}
+
+		return false, nil
+	})
+	require.NoError(t, err, "source secret was not deleted within %s", propagationTimeout)
+
+	t.Log("Checking that every replica was deleted")
+
+	for _, namespace := range append(targetNamespaces, terminatingNamespace) {
+		_, err := clientset.CoreV1().Secrets(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+		require.True(t, apierrors.IsNotFound(err), "replica in namespace %s was not deleted", namespace)
+	}
+}
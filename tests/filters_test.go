@@ -0,0 +1,104 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFilters(t *testing.T) {
+	ctx := context.Background()
+	clientset := newClientset(t)
+
+	includedNamespaces := []string{"replikator-filter-team-a", "replikator-filter-team-b"}
+	excludedNamespaces := []string{"replikator-filter-other", "replikator-filter-teamless"}
+
+	t.Log("Creating target namespaces")
+
+	for _, name := range append(includedNamespaces, excludedNamespaces...) {
+		createNamespace(ctx, t, clientset, name)
+	}
+
+	t.Log("Creating source secret")
+
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "replikator-filter-test",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"v1alpha1.replikator.pecke.tt/enabled":        "true",
+				"v1alpha1.replikator.pecke.tt/replicate-to":   "replikator-filter-team-*",
+				"v1alpha1.replikator.pecke.tt/replicate-keys": "value,public-*",
+			},
+		},
+		Data: map[string][]byte{
+			"value":        []byte("test"),
+			"public-key":   []byte("public"),
+			"private-key":  []byte("private"),
+			"unrelated.db": []byte("unrelated"),
+		},
+	}
+
+	_, err := clientset.CoreV1().Secrets(source.Namespace).Create(ctx, source, metav1.CreateOptions{})
+	require.NoError(t, err, "failed to create source secret")
+
+	t.Cleanup(func() {
+		_ = clientset.CoreV1().Secrets(source.Namespace).Delete(context.Background(), source.Name, metav1.DeleteOptions{})
+	})
+
+	t.Log("Waiting for source secret to be replicated to included namespaces")
+
+	require.NoError(t, waitForReplicas(ctx, t, clientset, source.Name, includedNamespaces, []byte("test")))
+
+	t.Log("Checking that only the filtered keys were replicated")
+
+	for _, namespace := range includedNamespaces {
+		replica, err := clientset.CoreV1().Secrets(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string][]byte{
+			"value":      []byte("test"),
+			"public-key": []byte("public"),
+		}, replica.Data, "unexpected keys replicated to namespace %s", namespace)
+	}
+
+	t.Log("Checking that excluded namespaces don't have replicas")
+
+	// Every target namespace is written in the same reconcile, so replicas
+	// to excluded namespaces would appear along with the included ones.
+	assert.Never(t, func() bool {
+		for _, namespace := range excludedNamespaces {
+			_, err := clientset.CoreV1().Secrets(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+			if !apierrors.IsNotFound(err) {
+				t.Logf("Source secret was replicated to excluded namespace %s (or the lookup failed: %v)", namespace, err)
+
+				return true
+			}
+		}
+
+		return false
+	}, 5*time.Second, 500*time.Millisecond, "source secret was replicated to an excluded namespace")
+}
@@ -17,49 +17,15 @@ find_root_dir() {
 
 export ROOT_DIR="$(find_root_dir)"
 
-PROMETHEUS_VERSION="v0.68.0"
-CERT_MANAGER_VERSION="v1.12.0"
-
-CLUSTER_NAME="replikator-$(date +%s | rhash --simple - | cut -f 1 -d ' ')"
-
-clean_up() {
-  echo 'Deleting k3d cluster'
-
-  k3d cluster delete "${CLUSTER_NAME}" || true
-}
-
-trap clean_up EXIT
-
 if [ -z "${SKIP_BUILD:-}" ]; then
   echo 'Building operator image'
 
   (cd "${ROOT_DIR}" && earthly +docker --VERSION=latest-dev)
 fi
 
-echo 'Creating k3d cluster'
-
-k3d cluster create "${CLUSTER_NAME}" --wait
-
-echo 'Waiting for k3s setup to complete'
-
-kubectl wait -n kube-system job/helm-install-traefik-crd --for=condition=complete --timeout=300s
-
-echo 'Installing Prometheus CRDs'
-
-kapp deploy -y -a prometheus-crds -f "https://github.com/prometheus-operator/prometheus-operator/releases/download/${PROMETHEUS_VERSION}/stripped-down-crds.yaml"
-
-echo 'Installing Cert Manager'
-
-kapp deploy -y -a cert-manager -f "https://github.com/cert-manager/cert-manager/releases/download/${CERT_MANAGER_VERSION}/cert-manager.yaml"
-
-echo 'Loading operator image into cluster'
-
-k3d image import -c "${CLUSTER_NAME}" ghcr.io/dpeckett/replikator:latest-dev
-
-echo 'Installing operator'
-
-ytt --data-value version=latest-dev -f "${ROOT_DIR}/hack/set-version.yaml" -f "${ROOT_DIR}/config" | kapp deploy -y -a replikator -f -
-
 echo 'Running tests'
 
-(cd "${ROOT_DIR}/tests" && go test -v ./...)
\ No newline at end of file
+# The test suite provisions the cluster itself; arguments (eg. --provider kind,
+# --k8s-version v1.28.0, --use-existing-cluster or --keep-cluster) are passed
+# through to it.
+(cd "${ROOT_DIR}/tests" && go test -count=1 -timeout 60m -v ./... -args "$@")
@@ -19,10 +19,12 @@ package main_test
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,25 +32,188 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-func TestOperator(t *testing.T) {
-	t.Log("Creating example resources")
+const (
+	prometheusVersion  = "v0.68.0"
+	certManagerVersion = "v1.12.0"
+)
 
-	rootDir := os.Getenv("ROOT_DIR")
+var (
+	providerFlag           = flag.String("provider", envOrDefault("CLUSTER_PROVIDER", "k3d"), "Cluster provider (k3d, kind or existing, which uses the current kubeconfig context)")
+	clusterNameFlag        = flag.String("cluster-name", os.Getenv("CLUSTER_NAME"), "Name of the cluster (default: a unique name, or replikator-dev when reusing a cluster)")
+	k8sVersionFlag         = flag.String("k8s-version", os.Getenv("K8S_VERSION"), "Kubernetes version of the cluster (eg. v1.28.0)")
+	useExistingClusterFlag = flag.Bool("use-existing-cluster", os.Getenv("USE_EXISTING_CLUSTER") != "", "Reuse the named cluster if it already exists (implies --keep-cluster)")
+	keepClusterFlag        = flag.Bool("keep-cluster", os.Getenv("KEEP_CLUSTER") != "", "Keep the cluster after the tests")
+	imageFlag              = flag.String("image", "ghcr.io/dpeckett/replikator:latest-dev", "Operator image to test (loaded from the local container runtime)")
+)
 
-	require.NoError(t, createExampleResources(filepath.Join(rootDir, "examples")))
+func TestMain(m *testing.M) {
+	flag.Parse()
 
-	kubeconfig := filepath.Join(clientcmd.RecommendedConfigDir, "config")
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	require.NoError(t, err)
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	provider, err := setUp(context.Background())
+	if provider != nil && !*keepClusterFlag && !*useExistingClusterFlag {
+		defer func() {
+			log.Printf("Deleting %s cluster", *providerFlag)
+
+			if err := provider.Delete(); err != nil {
+				log.Printf("Failed to delete cluster: %v", err)
+			}
+		}()
+	}
+	if err != nil {
+		log.Printf("Failed to set up cluster: %v", err)
+		return 1
+	}
+
+	return m.Run()
+}
+
+// setUp provisions the cluster and installs the operator (along with its
+// dependencies) into it.
+func setUp(ctx context.Context) (clusterProvider, error) {
+	clusterName := *clusterNameFlag
+	if clusterName == "" {
+		clusterName = fmt.Sprintf("replikator-%d", time.Now().Unix())
+		if *useExistingClusterFlag {
+			clusterName = "replikator-dev"
+		}
+	}
+
+	provider, err := newClusterProvider(*providerFlag, clusterName, *k8sVersionFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	reused := false
+	if *useExistingClusterFlag {
+		if reused, err = provider.Exists(); err != nil {
+			return nil, err
+		}
+	}
+
+	if reused {
+		log.Printf("Reusing %s cluster %s", *providerFlag, clusterName)
+	} else {
+		log.Printf("Creating %s cluster %s", *providerFlag, clusterName)
+
+		if err := provider.Create(ctx); err != nil {
+			return provider, err
+		}
+	}
+
+	kubeconfig, err := provider.Kubeconfig()
+	if err != nil {
+		return provider, err
+	}
+
+	if kubeconfig != "" {
+		if err := os.Setenv("KUBECONFIG", kubeconfig); err != nil {
+			return provider, err
+		}
+	}
+
+	config, err := newConfig()
+	if err != nil {
+		return provider, err
+	}
 
 	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return provider, err
+	}
+
+	version, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return provider, fmt.Errorf("failed to get server version: %w", err)
+	}
+
+	log.Printf("Testing against Kubernetes %s", version.GitVersion)
+
+	// Make sure the version matrix actually tests the requested version (eg.
+	// a reused cluster could be running another one). Distributions append
+	// build metadata to the version (eg. v1.28.0+k3s1), which is ignored.
+	if *k8sVersionFlag != "" {
+		if gitVersion, _, _ := strings.Cut(version.GitVersion, "+"); gitVersion != *k8sVersionFlag {
+			return provider, fmt.Errorf("cluster is running Kubernetes %s, expected %s", version.GitVersion, *k8sVersionFlag)
+		}
+	}
+
+	log.Print("Installing Prometheus CRDs")
+
+	if err := installManifests(ctx, config, "https://github.com/prometheus-operator/prometheus-operator/releases/download/"+prometheusVersion+"/stripped-down-crds.yaml"); err != nil {
+		return provider, err
+	}
+
+	log.Print("Installing Cert Manager")
+
+	if err := installManifests(ctx, config, "https://github.com/cert-manager/cert-manager/releases/download/"+certManagerVersion+"/cert-manager.yaml"); err != nil {
+		return provider, err
+	}
+
+	if err := waitForDeployments(ctx, clientset, "cert-manager"); err != nil {
+		return provider, fmt.Errorf("failed to wait for cert-manager: %w", err)
+	}
+
+	log.Print("Loading operator image into cluster")
+
+	if err := provider.LoadImage(ctx, *imageFlag); err != nil {
+		return provider, err
+	}
+
+	log.Print("Installing operator")
+
+	var objects []*unstructured.Unstructured
+	for _, dir := range []string{"config/manager", "config/rbac"} {
+		manifests, err := readManifests(ctx, filepath.Join(rootDir(), dir))
+		if err != nil {
+			return provider, err
+		}
+
+		objects = append(objects, manifests...)
+	}
+
+	if err := setDeploymentImage(objects, "replikator", "manager", *imageFlag); err != nil {
+		return provider, err
+	}
+
+	if err := applyManifests(ctx, config, objects); err != nil {
+		return provider, err
+	}
+
+	if reused {
+		// Pick up the rebuilt image (which has the same tag).
+		if err := restartDeployment(ctx, clientset, operatorNamespace, "replikator"); err != nil {
+			return provider, fmt.Errorf("failed to restart operator: %w", err)
+		}
+	}
+
+	if err := waitForDeployments(ctx, clientset, operatorNamespace); err != nil {
+		return provider, fmt.Errorf("failed to wait for operator: %w", err)
+	}
+
+	return provider, nil
+}
+
+func TestOperator(t *testing.T) {
+	t.Log("Creating example resources")
+
+	config, err := newConfig()
 	require.NoError(t, err)
 
+	require.NoError(t, installManifests(context.Background(), config, filepath.Join(rootDir(), "examples")))
+
+	clientset := newClientset(t)
+
 	t.Log("Waiting for root-ca-tls secret to be replicated")
 
 	ctx := context.Background()
@@ -75,14 +240,7 @@ func TestOperator(t *testing.T) {
 
 	t.Log("Creating additional test namespace")
 
-	namespace := &corev1.Namespace{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "replikator-test",
-		},
-	}
-
-	_, err = clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{})
-	require.NoError(t, err, "failed to create replikator-test namespace")
+	createNamespace(ctx, t, clientset, "replikator-test")
 
 	t.Log("Checking that root-ca-tls secret is replicated to new namespace")
 
@@ -103,10 +261,58 @@ func TestOperator(t *testing.T) {
 	require.NoError(t, err, "failed to wait for root-ca-tls secret to be replicated")
 }
 
-func createExampleResources(examplesDir string) error {
-	cmd := exec.Command("kapp", "deploy", "-y", "-a", "ldap-operator-examples", "-f", examplesDir)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// newConfig returns the client configuration of the current kubeconfig context.
+func newConfig() (*rest.Config, error) {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+}
+
+// newClientset returns a clientset for the cluster of the current kubeconfig context.
+func newClientset(t *testing.T) *kubernetes.Clientset {
+	config, err := newConfig()
+	require.NoError(t, err)
+
+	clientset, err := kubernetes.NewForConfig(config)
+	require.NoError(t, err)
+
+	return clientset
+}
+
+// createNamespace creates a namespace (if it doesn't already exist, eg. from an
+// earlier run against a reused cluster).
+func createNamespace(ctx context.Context, t *testing.T, clientset kubernetes.Interface, name string) {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+
+	_, err := clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		err = nil
+	}
+	require.NoError(t, err, "failed to create %s namespace", name)
+}
+
+// installManifests server-side applies the manifests in a directory, or at a URL.
+func installManifests(ctx context.Context, config *rest.Config, location string) error {
+	objects, err := readManifests(ctx, location)
+	if err != nil {
+		return err
+	}
+
+	return applyManifests(ctx, config, objects)
+}
+
+// rootDir returns the directory containing the operator sources.
+func rootDir() string {
+	return envOrDefault("ROOT_DIR", "..")
+}
+
+func envOrDefault(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
 
-	return cmd.Run()
+	return defaultValue
 }
@@ -0,0 +1,116 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestConcurrentNamespaceCreation models a provisioning system creating
+// namespaces while a large source is being replicated, checking that every
+// namespace receives a replica without the reconciles racing the namespace
+// controller failing.
+func TestConcurrentNamespaceCreation(t *testing.T) {
+	ctx := context.Background()
+	clientset := newClientset(t)
+
+	const reconcileErrors = "controller_runtime_reconcile_errors_total"
+	const secretController = `controller="secret-controller"`
+
+	errorsBefore, err := operatorMetric(ctx, clientset, reconcileErrors, secretController)
+	require.NoError(t, err, "failed to read operator metrics")
+
+	t.Log("Creating large source secret")
+
+	value := bytes.Repeat([]byte("x"), 512*1024)
+
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "replikator-race-test",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"v1alpha1.replikator.pecke.tt/enabled":      "true",
+				"v1alpha1.replikator.pecke.tt/replicate-to": "replikator-race-*",
+			},
+		},
+		Data: map[string][]byte{
+			"value": value,
+		},
+	}
+
+	_, err = clientset.CoreV1().Secrets(source.Namespace).Create(ctx, source, metav1.CreateOptions{})
+	require.NoError(t, err, "failed to create source secret")
+
+	t.Cleanup(func() {
+		_ = clientset.CoreV1().Secrets(source.Namespace).Delete(context.Background(), source.Name, metav1.DeleteOptions{})
+	})
+
+	t.Log("Creating target namespaces concurrently")
+
+	var targetNamespaces []string
+	for i := 0; i < 30; i++ {
+		targetNamespaces = append(targetNamespaces, fmt.Sprintf("replikator-race-%d", i))
+	}
+
+	errs := make([]error, len(targetNamespaces))
+
+	var wg sync.WaitGroup
+	for i, name := range targetNamespaces {
+		wg.Add(1)
+
+		go func(i int, name string) {
+			defer wg.Done()
+
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+
+			_, err := clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{})
+			if !apierrors.IsAlreadyExists(err) {
+				errs[i] = err
+			}
+		}(i, name)
+	}
+	wg.Wait()
+
+	require.NoError(t, errors.Join(errs...), "failed to create target namespaces")
+
+	t.Cleanup(func() {
+		for _, name := range targetNamespaces {
+			_ = clientset.CoreV1().Namespaces().Delete(context.Background(), name, metav1.DeleteOptions{})
+		}
+	})
+
+	t.Log("Waiting for every namespace to receive a replica")
+
+	require.NoError(t, waitForReplicas(ctx, t, clientset, source.Name, targetNamespaces, value))
+
+	errorsAfter, err := operatorMetric(ctx, clientset, reconcileErrors, secretController)
+	require.NoError(t, err, "failed to read operator metrics")
+
+	assert.Equal(t, errorsBefore, errorsAfter, "reconciles failed while namespaces were being created")
+}
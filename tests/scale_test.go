@@ -0,0 +1,190 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ScaleReport is the result of the scale test.
+type ScaleReport struct {
+	// Namespaces is the number of target namespaces.
+	Namespaces int `json:"namespaces"`
+	// TimeToFullReplication is how long it took for every namespace to
+	// contain a replica after the source was created.
+	TimeToFullReplication time.Duration `json:"timeToFullReplication"`
+	// APIRequests is the number of Kubernetes API requests the operator made
+	// while replicating.
+	APIRequests int `json:"apiRequests"`
+}
+
+// TestScale replicates a source to hundreds of namespaces and reports how long
+// it took, and how many API requests the operator made. It is opt-in, set
+// SCALE_NAMESPACES to the number of namespaces to create (eg. 500), and
+// SCALE_REPORT to a file to write the report to.
+func TestScale(t *testing.T) {
+	if os.Getenv("SCALE_NAMESPACES") == "" {
+		t.Skip("Set SCALE_NAMESPACES to run the scale test")
+	}
+
+	count, err := strconv.Atoi(os.Getenv("SCALE_NAMESPACES"))
+	require.NoError(t, err, "invalid SCALE_NAMESPACES")
+
+	ctx := context.Background()
+	clientset := newClientset(t)
+
+	t.Logf("Creating %d target namespaces", count)
+
+	var targetNamespaces []string
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("replikator-scale-%d", i)
+		targetNamespaces = append(targetNamespaces, name)
+
+		createNamespace(ctx, t, clientset, name)
+	}
+
+	t.Cleanup(func() {
+		for _, name := range targetNamespaces {
+			_ = clientset.CoreV1().Namespaces().Delete(context.Background(), name, metav1.DeleteOptions{})
+		}
+	})
+
+	requestsBefore, err := operatorMetric(ctx, clientset, "rest_client_requests_total", "")
+	require.NoError(t, err, "failed to read operator metrics")
+
+	t.Log("Creating source secret")
+
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "replikator-scale-test",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"v1alpha1.replikator.pecke.tt/enabled":      "true",
+				"v1alpha1.replikator.pecke.tt/replicate-to": "replikator-scale-*",
+			},
+		},
+		Data: map[string][]byte{
+			"value": []byte("test"),
+		},
+	}
+
+	start := time.Now()
+
+	_, err = clientset.CoreV1().Secrets(source.Namespace).Create(ctx, source, metav1.CreateOptions{})
+	require.NoError(t, err, "failed to create source secret")
+
+	t.Cleanup(func() {
+		_ = clientset.CoreV1().Secrets(source.Namespace).Delete(context.Background(), source.Name, metav1.DeleteOptions{})
+	})
+
+	t.Log("Waiting for source secret to be replicated to every namespace")
+
+	err = wait.PollUntilContextTimeout(ctx, time.Second, 15*time.Minute, true, func(ctx context.Context) (bool, error) {
+		replicas, err := listReplicas(ctx, clientset, source)
+		if err != nil {
+			return false, err
+		}
+
+		t.Logf("Replicated to %d/%d namespaces", len(replicas), count)
+
+		return len(replicas) >= count, nil
+	})
+	require.NoError(t, err, "failed to wait for source secret to be replicated")
+
+	report := ScaleReport{
+		Namespaces:            count,
+		TimeToFullReplication: time.Since(start),
+	}
+
+	requestsAfter, err := operatorMetric(ctx, clientset, "rest_client_requests_total", "")
+	require.NoError(t, err, "failed to read operator metrics")
+
+	report.APIRequests = requestsAfter - requestsBefore
+
+	t.Logf("Replicated to %d namespaces in %s using %d API requests",
+		report.Namespaces, report.TimeToFullReplication, report.APIRequests)
+
+	if path := os.Getenv("SCALE_REPORT"); path != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(path, data, 0o644), "failed to write scale report")
+	}
+}
+
+// operatorMetric returns the total of a counter across the operator pods and
+// its series containing the label (eg. controller="secret-controller"), or all
+// of its series if the label is empty. Restarted pods reset their counters, so the
+// operator must not restart while measuring.
+func operatorMetric(ctx context.Context, clientset kubernetes.Interface, name, label string) (int, error) {
+	pods, err := clientset.CoreV1().Pods(operatorNamespace).List(ctx, metav1.ListOptions{LabelSelector: operatorSelector})
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+
+		metrics, err := clientset.CoreV1().Pods(operatorNamespace).ProxyGet("http", pod.Name, "8080", "/metrics", nil).DoRaw(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get metrics of pod %s: %w", pod.Name, err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(metrics))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, name+"{") && !strings.HasPrefix(line, name+" ") {
+				continue
+			}
+
+			if label != "" && !strings.Contains(line, label) {
+				continue
+			}
+
+			value, err := strconv.ParseFloat(line[strings.LastIndex(line, " ")+1:], 64)
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse metric %q: %w", line, err)
+			}
+
+			total += value
+		}
+		if err := scanner.Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return int(total), nil
+}
@@ -0,0 +1,124 @@
+/* SPDX-License-Identifier: Apache-2.0
+ *
+ * Copyright 2024 Damian Peckett <damian@pecke.tt>.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// propagationTimeout bounds how long an update to a source may take to reach
+// every replica.
+const propagationTimeout = 2 * time.Minute
+
+func TestSourceUpdatePropagation(t *testing.T) {
+	ctx := context.Background()
+	clientset := newClientset(t)
+
+	targetNamespaces := []string{"replikator-update-a", "replikator-update-b", "replikator-update-c"}
+
+	t.Log("Creating target namespaces")
+
+	for _, name := range targetNamespaces {
+		createNamespace(ctx, t, clientset, name)
+	}
+
+	t.Log("Creating source secret")
+
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "replikator-update-test",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"v1alpha1.replikator.pecke.tt/enabled":      "true",
+				"v1alpha1.replikator.pecke.tt/replicate-to": "replikator-update-*",
+			},
+		},
+		Data: map[string][]byte{
+			"value": []byte("initial"),
+		},
+	}
+
+	_, err := clientset.CoreV1().Secrets(source.Namespace).Create(ctx, source, metav1.CreateOptions{})
+	require.NoError(t, err, "failed to create source secret")
+
+	t.Cleanup(func() {
+		_ = clientset.CoreV1().Secrets(source.Namespace).Delete(context.Background(), source.Name, metav1.DeleteOptions{})
+	})
+
+	t.Log("Waiting for source secret to be replicated")
+
+	require.NoError(t, waitForReplicas(ctx, t, clientset, source.Name, targetNamespaces, []byte("initial")))
+
+	t.Log("Updating source secret")
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret, err := clientset.CoreV1().Secrets(source.Namespace).Get(ctx, source.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		secret.Data["value"] = []byte("updated")
+
+		_, err = clientset.CoreV1().Secrets(source.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+		return err
+	})
+	require.NoError(t, err, "failed to update source secret")
+
+	t.Log("Waiting for every replica to converge")
+
+	require.NoError(t, waitForReplicas(ctx, t, clientset, source.Name, targetNamespaces, []byte("updated")),
+		"replicas did not converge within %s", propagationTimeout)
+}
+
+// waitForReplicas waits for a replica of the named secret in every namespace
+// to have the expected value.
+func waitForReplicas(ctx context.Context, t *testing.T, clientset kubernetes.Interface, name string, namespaces []string, value []byte) error {
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, propagationTimeout, true, func(ctx context.Context) (bool, error) {
+		for _, namespace := range namespaces {
+			replica, err := clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				if !apierrors.IsNotFound(err) {
+					return false, err
+				}
+
+				t.Logf("Not yet replicated to %s", namespace)
+
+				return false, nil
+			}
+
+			if !bytes.Equal(replica.Data["value"], value) {
+				t.Logf("Replica in %s has not yet converged", namespace)
+
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+}